@@ -0,0 +1,123 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/schema"
+	"github.com/juju/utils/set"
+	"github.com/juju/version"
+)
+
+type bootImages struct {
+	connected bool
+	status    string
+	images    []*bootImage
+}
+
+// Connected implements BootImages.
+func (b *bootImages) Connected() bool {
+	return b.connected
+}
+
+// Status implements BootImages.
+func (b *bootImages) Status() string {
+	return b.status
+}
+
+// Images implements BootImages.
+func (b *bootImages) Images() []BootImage {
+	result := make([]BootImage, len(b.images))
+	for i, v := range b.images {
+		result[i] = v
+	}
+	return result
+}
+
+type bootImage struct {
+	name         string
+	architecture string
+	subArches    []string
+}
+
+// Name implements BootImage.
+func (b *bootImage) Name() string {
+	return b.name
+}
+
+// Architecture implements BootImage.
+func (b *bootImage) Architecture() string {
+	return b.architecture
+}
+
+// SubArchitectures implements BootImage.
+func (b *bootImage) SubArchitectures() set.Strings {
+	return set.NewStrings(b.subArches...)
+}
+
+func readBootImages(controllerVersion version.Number, source interface{}) (*bootImages, error) {
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot images base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range bootImagesDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no boot images read func for version %s", controllerVersion)
+	}
+	readFunc := bootImagesDeserializationFuncs[deserialisationVersion]
+	return readFunc(valid)
+}
+
+type bootImagesDeserializationFunc func(map[string]interface{}) (*bootImages, error)
+
+var bootImagesDeserializationFuncs = map[version.Number]bootImagesDeserializationFunc{
+	twoDotOh: bootImages_2_0,
+}
+
+func bootImages_2_0(source map[string]interface{}) (*bootImages, error) {
+	imageFields := schema.Fields{
+		"name":         schema.String(),
+		"architecture": schema.String(),
+		"subarches":    schema.List(schema.String()),
+	}
+	imageDefaults := schema.Defaults{
+		"subarches": []interface{}{},
+	}
+	fields := schema.Fields{
+		"connected": schema.Bool(),
+		"status":    schema.String(),
+		"images":    schema.List(schema.FieldMap(imageFields, imageDefaults)),
+	}
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot images 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var images []*bootImage
+	for _, value := range valid["images"].([]interface{}) {
+		image := value.(map[string]interface{})
+		images = append(images, &bootImage{
+			name:         image["name"].(string),
+			architecture: image["architecture"].(string),
+			subArches:    convertToStringSlice(image["subarches"]),
+		})
+	}
+	result := &bootImages{
+		connected: valid["connected"].(bool),
+		status:    valid["status"].(string),
+		images:    images,
+	}
+	return result, nil
+}
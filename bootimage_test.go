@@ -0,0 +1,58 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type bootImageSuite struct{}
+
+var _ = gc.Suite(&bootImageSuite{})
+
+func (*bootImageSuite) TestReadBootImagesBadSchema(c *gc.C) {
+	_, err := readBootImages(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `boot images base schema check failed: expected map, got string("wat?")`)
+}
+
+func (*bootImageSuite) TestReadBootImages(c *gc.C) {
+	images, err := readBootImages(twoDotOh, parseJSON(c, bootImagesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(images.Connected(), jc.IsTrue)
+	c.Check(images.Status(), gc.Equals, "synced")
+
+	list := images.Images()
+	c.Assert(list, gc.HasLen, 2)
+	c.Check(list[0].Name(), gc.Equals, "ubuntu/xenial")
+	c.Check(list[0].Architecture(), gc.Equals, "amd64")
+	c.Check(list[0].SubArchitectures().SortedValues(), jc.DeepEquals, []string{"generic", "hwe-16.04"})
+	c.Check(list[1].SubArchitectures().IsEmpty(), jc.IsTrue)
+}
+
+func (*bootImageSuite) TestLowVersion(c *gc.C) {
+	_, err := readBootImages(version.MustParse("1.9.0"), parseJSON(c, bootImagesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no boot images read func for version 1.9.0`)
+}
+
+var bootImagesResponse = `
+{
+    "connected": true,
+    "status": "synced",
+    "images": [
+        {
+            "name": "ubuntu/xenial",
+            "architecture": "amd64",
+            "subarches": ["generic", "hwe-16.04"]
+        },
+        {
+            "name": "ubuntu/trusty",
+            "architecture": "arm64"
+        }
+    ]
+}
+`
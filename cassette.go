@@ -0,0 +1,402 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// redactedHeaders are the request headers that are never recorded in a
+// cassette, as they hold credentials.
+var redactedHeaders = []string{"Authorization", "Cookie"}
+
+// redacted replaces the values of the redacted headers.
+const redacted = "REDACTED"
+
+// Cassette is a recording of the HTTP interactions between a Client and a
+// MAAS server, made by a CassetteRecorder and served back by a
+// CassetteReplayer. The bodies are kept as text, as the MAAS API talks
+// JSON, except for those that are not UTF-8, such as uploaded files, which
+// are kept base64 encoded.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction is a request and its response in a Cassette.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request" yaml:"request"`
+	Response RecordedResponse `json:"response" yaml:"response"`
+}
+
+// RecordedRequest is a request in a Cassette. BodyEncoding is "base64" if
+// the body is base64 encoded, and empty if it is kept as text.
+type RecordedRequest struct {
+	Method       string      `json:"method" yaml:"method"`
+	URL          string      `json:"url" yaml:"url"`
+	Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// RecordedResponse is a response in a Cassette. BodyEncoding is "base64" if
+// the body is base64 encoded, and empty if it is kept as text.
+type RecordedResponse struct {
+	StatusCode   int         `json:"status_code" yaml:"status_code"`
+	Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// base64Encoding is the BodyEncoding of base64 encoded bodies.
+const base64Encoding = "base64"
+
+// encodeBody returns the body as it is kept in a cassette, and its
+// encoding.
+func encodeBody(content []byte) (body, encoding string) {
+	if utf8.Valid(content) {
+		return string(content), ""
+	}
+	return base64.StdEncoding.EncodeToString(content), base64Encoding
+}
+
+// decodeBody returns the content of a body kept in a cassette.
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case base64Encoding:
+		content, err := base64.StdEncoding.DecodeString(body)
+		return content, errors.Trace(err)
+	}
+	return nil, errors.NotValidf("body encoding %q", encoding)
+}
+
+// isYAML reports whether the cassette file is YAML rather than JSON, from
+// its extension.
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// LoadCassette reads a cassette from a file, which is YAML if its
+// extension is ".yaml" or ".yml" and JSON otherwise.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var cassette Cassette
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, &cassette)
+	} else {
+		err = json.Unmarshal(data, &cassette)
+	}
+	if err != nil {
+		return nil, errors.Annotatef(err, "cassette %q", path)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to a file, as YAML if its extension is ".yaml"
+// or ".yml" and as JSON otherwise.
+func (c *Cassette) Save(path string) error {
+	var data []byte
+	var err error
+	if isYAML(path) {
+		data, err = yaml.Marshal(c)
+	} else {
+		data, err = json.MarshalIndent(c, "", "  ")
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(path, data, 0644))
+}
+
+// CassetteRecorder is an http.RoundTripper that records the requests it
+// makes and their responses, for use as the Transport of a Client or of
+// ControllerArgs. The credentials in the request headers are redacted.
+type CassetteRecorder struct {
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewCassetteRecorder returns a recorder that makes the requests with the
+// transport, or with http.DefaultTransport if it is nil.
+func NewCassetteRecorder(transport http.RoundTripper) *CassetteRecorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &CassetteRecorder{transport: transport}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *CassetteRecorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	requestBody, err := readAndClose(request.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// The request must not be changed, so the copy made has the body
+	// that was read.
+	outgoing := request.Clone(request.Context())
+	if request.Body != nil {
+		outgoing.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	}
+	response, err := r.transport.RoundTrip(outgoing)
+	if err != nil {
+		// Failures to connect are not part of the conversation.
+		return nil, err
+	}
+	responseBody, err := readBody(&response.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	header := cloneHeader(request.Header)
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+	recordedRequest := RecordedRequest{
+		Method: request.Method,
+		URL:    request.URL.String(),
+		Header: header,
+	}
+	recordedRequest.Body, recordedRequest.BodyEncoding = encodeBody(requestBody)
+	recordedResponse := RecordedResponse{
+		StatusCode: response.StatusCode,
+		Header:     cloneHeader(response.Header),
+	}
+	recordedResponse.Body, recordedResponse.BodyEncoding = encodeBody(responseBody)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  recordedRequest,
+		Response: recordedResponse,
+	})
+	return response, nil
+}
+
+// Cassette returns a copy of the interactions recorded so far.
+func (r *CassetteRecorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Save writes the interactions recorded so far to a cassette file, see
+// Cassette.Save.
+func (r *CassetteRecorder) Save(path string) error {
+	return errors.Trace(r.Cassette().Save(path))
+}
+
+// CassetteMatch selects the parts of a request that must be the same as
+// those of a recorded request for a CassetteReplayer to serve its response.
+// The host is never compared, so a cassette recorded against one server can
+// be replayed with the URL of any other.
+type CassetteMatch int
+
+const (
+	// MatchMethod compares the HTTP methods.
+	MatchMethod CassetteMatch = 1 << iota
+	// MatchPath compares the URL paths.
+	MatchPath
+	// MatchQuery compares the query parameters, in any order.
+	MatchQuery
+	// MatchForm compares the fields of URL encoded and multipart bodies,
+	// in any order. The contents of multipart files are compared as
+	// fields.
+	MatchForm
+
+	// MatchAll compares all the parts of the requests.
+	MatchAll = MatchMethod | MatchPath | MatchQuery | MatchForm
+)
+
+// CassetteReplayer is an http.RoundTripper that serves the responses of a
+// cassette instead of making requests, for use as the Transport of a Client
+// or of ControllerArgs. Each recorded interaction is used once, and a
+// request gets the response of the first unused interaction that matches
+// it. A request that matches none fails.
+type CassetteReplayer struct {
+	match CassetteMatch
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewCassetteReplayer returns a replayer for the interactions of the
+// cassette, matching the requests as configured.
+func NewCassetteReplayer(cassette *Cassette, match CassetteMatch) *CassetteReplayer {
+	interactions := append([]Interaction(nil), cassette.Interactions...)
+	return &CassetteReplayer{
+		match:        match,
+		interactions: interactions,
+		used:         make([]bool, len(interactions)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *CassetteReplayer) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readAndClose(request.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.used[i] {
+			continue
+		}
+		matches, err := r.matches(interaction.Request, request, body)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if !matches {
+			continue
+		}
+		recorded := interaction.Response
+		content, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+		if err != nil {
+			return nil, errors.Annotate(err, "recorded response")
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        cloneHeader(recorded.Header),
+			Body:          ioutil.NopCloser(bytes.NewReader(content)),
+			ContentLength: int64(len(content)),
+			Request:       request,
+		}, nil
+	}
+	return nil, errors.NotFoundf("recorded interaction for %s %s", request.Method, request.URL)
+}
+
+// Unused returns the interactions that have not been replayed.
+func (r *CassetteReplayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			result = append(result, interaction)
+		}
+	}
+	return result
+}
+
+func (r *CassetteReplayer) matches(recorded RecordedRequest, request *http.Request, body []byte) (bool, error) {
+	if r.match&MatchMethod != 0 && recorded.Method != request.Method {
+		return false, nil
+	}
+	recordedURL, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false, errors.Annotatef(err, "recorded URL %q", recorded.URL)
+	}
+	if r.match&MatchPath != 0 && recordedURL.Path != request.URL.Path {
+		return false, nil
+	}
+	if r.match&MatchQuery != 0 && !valuesEqual(recordedURL.Query(), request.URL.Query()) {
+		return false, nil
+	}
+	if r.match&MatchForm != 0 {
+		recordedBody, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+		if err != nil {
+			return false, errors.Annotate(err, "recorded request")
+		}
+		recordedForm, err := formValues(recorded.Header.Get("Content-Type"), recordedBody)
+		if err != nil {
+			return false, errors.Annotate(err, "recorded request")
+		}
+		form, err := formValues(request.Header.Get("Content-Type"), body)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		if !valuesEqual(recordedForm, form) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// valuesEqual reports whether the values are the same, ignoring their
+// order.
+func valuesEqual(a, b url.Values) bool {
+	return valuesSubset(a, b) && valuesSubset(b, a)
+}
+
+// formValues returns the fields of a URL encoded or multipart body, or nil
+// for any other body.
+func formValues(contentType string, body []byte) (url.Values, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		return values, errors.Trace(err)
+	case "multipart/form-data":
+		values := make(url.Values)
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return values, nil
+			}
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			content, err := ioutil.ReadAll(part)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			values.Add(part.FormName(), string(content))
+		}
+	}
+	return nil, nil
+}
+
+// readBody reads the body and replaces it with a copy, so it can be read
+// again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	content, err := readAndClose(*body)
+	if err != nil {
+		return nil, err
+	}
+	if *body != nil {
+		*body = ioutil.NopCloser(bytes.NewReader(content))
+	}
+	return content, nil
+}
+
+func cloneHeader(header http.Header) http.Header {
+	result := make(http.Header, len(header))
+	for name, values := range header {
+		result[name] = append([]string(nil), values...)
+	}
+	return result
+}
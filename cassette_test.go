@@ -0,0 +1,245 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type cassetteSuite struct{}
+
+var _ = gc.Suite(&cassetteSuite{})
+
+// record allocates a machine of a fake MAAS through a recording controller,
+// returning the recorder and the URL of the closed server.
+func (*cassetteSuite) record(c *gc.C) (*CassetteRecorder, string) {
+	server := NewFakeMAASServer()
+	defer server.Close()
+	server.AddMachine(FakeMachine{Hostname: "small", CPUCount: 1, Memory: 1024})
+	server.AddMachine(FakeMachine{Hostname: "big", CPUCount: 8, Memory: 16384})
+	recorder := NewCassetteRecorder(nil)
+	controller, err := NewController(ControllerArgs{
+		BaseURL:   server.URL,
+		APIKey:    "fake:as:key",
+		Transport: recorder,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machine, _, err := controller.AllocateMachine(AllocateMachineArgs{MinCPUCount: 4})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.Hostname(), gc.Equals, "big")
+	return recorder, server.URL
+}
+
+func (s *cassetteSuite) TestRecordAndReplay(c *gc.C) {
+	recorder, serverURL := s.record(c)
+	for _, name := range []string{"cassette.json", "cassette.yaml"} {
+		path := filepath.Join(c.MkDir(), name)
+		c.Assert(recorder.Save(path), jc.ErrorIsNil)
+		data, err := ioutil.ReadFile(path)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(strings.Contains(string(data), "oauth_signature"), jc.IsFalse)
+		c.Check(strings.Contains(string(data), redacted), jc.IsTrue)
+
+		cassette, err := LoadCassette(path)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(cassette.Interactions, gc.HasLen, len(recorder.Cassette().Interactions))
+		replayer := NewCassetteReplayer(cassette, MatchAll)
+		controller, err := NewController(ControllerArgs{
+			BaseURL:   serverURL,
+			APIKey:    "fake:as:key",
+			Transport: replayer,
+		})
+		c.Assert(err, jc.ErrorIsNil)
+		machine, _, err := controller.AllocateMachine(AllocateMachineArgs{MinCPUCount: 4})
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(machine.Hostname(), gc.Equals, "big")
+		c.Check(replayer.Unused(), gc.HasLen, 0)
+	}
+}
+
+func (s *cassetteSuite) TestReplayMismatch(c *gc.C) {
+	recorder, serverURL := s.record(c)
+	replayer := NewCassetteReplayer(recorder.Cassette(), MatchAll)
+	controller, err := NewController(ControllerArgs{
+		BaseURL:   serverURL,
+		APIKey:    "fake:as:key",
+		Transport: replayer,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, _, err = controller.AllocateMachine(AllocateMachineArgs{MinCPUCount: 2})
+	c.Assert(err, gc.ErrorMatches, ".*recorded interaction for POST .* not found")
+	c.Check(replayer.Unused(), gc.HasLen, 1)
+}
+
+// replay makes the request through a replayer of a single interaction.
+func replay(c *gc.C, recorded RecordedRequest, match CassetteMatch, method, uri, contentType, body string) error {
+	replayer := NewCassetteReplayer(&Cassette{Interactions: []Interaction{{
+		Request:  recorded,
+		Response: RecordedResponse{StatusCode: http.StatusOK, Body: `"ok"`},
+	}}}, match)
+	request, err := http.NewRequest(method, uri, strings.NewReader(body))
+	c.Assert(err, jc.ErrorIsNil)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	response, err := replayer.RoundTrip(request)
+	if err != nil {
+		return err
+	}
+	content, err := readAndClose(response.Body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, `"ok"`)
+	return nil
+}
+
+func (*cassetteSuite) TestMatchRules(c *gc.C) {
+	form := "application/x-www-form-urlencoded"
+	recorded := RecordedRequest{
+		Method: "POST",
+		URL:    "http://lab.example.com/api/2.0/machines/?op=allocate&zone=a",
+		Header: http.Header{"Content-Type": {form}},
+		Body:   url.Values{"tags": {"a", "b"}, "cpu_count": {"2"}}.Encode(),
+	}
+	body := "tags=b&cpu_count=2&tags=a"
+	for i, test := range []struct {
+		match  CassetteMatch
+		method string
+		uri    string
+		body   string
+		err    string
+	}{{
+		match:  MatchAll,
+		method: "POST",
+		uri:    "http://localhost/api/2.0/machines/?zone=a&op=allocate",
+		body:   body,
+	}, {
+		match:  MatchAll,
+		method: "PUT",
+		uri:    "http://localhost/api/2.0/machines/?zone=a&op=allocate",
+		body:   body,
+		err:    ".* not found",
+	}, {
+		match:  MatchAll &^ MatchMethod,
+		method: "PUT",
+		uri:    "http://localhost/api/2.0/machines/?zone=a&op=allocate",
+		body:   body,
+	}, {
+		match:  MatchAll,
+		method: "POST",
+		uri:    "http://localhost/api/2.0/machines/?op=allocate",
+		body:   body,
+		err:    ".* not found",
+	}, {
+		match:  MatchMethod | MatchPath,
+		method: "POST",
+		uri:    "http://localhost/api/2.0/machines/?op=allocate",
+		body:   "cpu_count=4",
+	}, {
+		match:  MatchAll,
+		method: "POST",
+		uri:    "http://localhost/api/2.0/machines/?zone=a&op=allocate",
+		body:   "cpu_count=4",
+		err:    ".* not found",
+	}, {
+		match:  MatchAll,
+		method: "POST",
+		uri:    "http://localhost/api/2.0/nodes/?zone=a&op=allocate",
+		body:   body,
+		err:    ".* not found",
+	}} {
+		c.Logf("test %d", i)
+		err := replay(c, recorded, test.match, test.method, test.uri, form, test.body)
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
+func (*cassetteSuite) TestMultipartForm(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddPostResponse("/api/2.0/files/?op=add", http.StatusOK, `"added"`)
+	server.Start()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	recorder := NewCassetteRecorder(nil)
+	client.Transport = recorder
+	params := url.Values{"filename": {"config"}}
+	files := map[string][]byte{"file": []byte("content")}
+	_, err = client.Post(&url.URL{Path: "files/"}, "add", params, files)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The boundaries of the multipart bodies differ.
+	client.Transport = NewCassetteReplayer(recorder.Cassette(), MatchAll)
+	content, err := client.Post(&url.URL{Path: "files/"}, "add", params, files)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, `"added"`)
+
+	client.Transport = NewCassetteReplayer(recorder.Cassette(), MatchAll)
+	files["file"] = []byte("other")
+	_, err = client.Post(&url.URL{Path: "files/"}, "add", params, files)
+	c.Assert(err, gc.ErrorMatches, ".*recorded interaction for POST .* not found")
+}
+
+func (*cassetteSuite) TestRecorderLeavesRequestAlone(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddPostResponse("/api/2.0/files/?op=add", http.StatusOK, `"added"`)
+	server.Start()
+	defer server.Close()
+	body := ioutil.NopCloser(strings.NewReader("filename=config"))
+	request, err := http.NewRequest("POST", server.URL+"/api/2.0/files/?op=add", body)
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := NewCassetteRecorder(nil).RoundTrip(request)
+	c.Assert(err, jc.ErrorIsNil)
+	defer response.Body.Close()
+
+	c.Check(request.Body, gc.Equals, body)
+	c.Check(response.StatusCode, gc.Equals, http.StatusOK)
+}
+
+func (*cassetteSuite) TestBinaryBodies(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddPostResponse("/api/2.0/files/?op=add", http.StatusOK, "\xff\xfe")
+	server.Start()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	recorder := NewCassetteRecorder(nil)
+	client.Transport = recorder
+	params := url.Values{"filename": {"image"}}
+	files := map[string][]byte{"file": {0x89, 'P', 'N', 'G', 0xff, 0x00}}
+	_, err = client.Post(&url.URL{Path: "files/"}, "add", params, files)
+	c.Assert(err, jc.ErrorIsNil)
+	recorded := recorder.Cassette().Interactions[0]
+	c.Check(recorded.Request.BodyEncoding, gc.Equals, "base64")
+	c.Check(recorded.Response.BodyEncoding, gc.Equals, "base64")
+
+	for _, name := range []string{"cassette.json", "cassette.yaml"} {
+		path := filepath.Join(c.MkDir(), name)
+		c.Assert(recorder.Save(path), jc.ErrorIsNil)
+		cassette, err := LoadCassette(path)
+		c.Assert(err, jc.ErrorIsNil)
+
+		client.Transport = NewCassetteReplayer(cassette, MatchAll)
+		content, err := client.Post(&url.URL{Path: "files/"}, "add", params, files)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(string(content), gc.Equals, "\xff\xfe")
+
+		client.Transport = NewCassetteReplayer(cassette, MatchAll)
+		other := map[string][]byte{"file": {0x89, 'P', 'N', 'G', 0xfe, 0x00}}
+		_, err = client.Post(&url.URL{Path: "files/"}, "add", params, other)
+		c.Check(err, gc.ErrorMatches, ".*recorded interaction for POST .* not found")
+	}
+}
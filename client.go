@@ -32,6 +32,9 @@ const (
 type Client struct {
 	APIURL *url.URL
 	Signer OAuthSigner
+	// Transport, if not nil, makes the HTTP requests instead of
+	// http.DefaultTransport, such as to record or replay them.
+	Transport http.RoundTripper
 }
 
 // ServerError is an http error (or at least, a non-2xx result) received from
@@ -105,7 +108,7 @@ func (client Client) dispatchRequest(request *http.Request) ([]byte, error) {
 
 func (client Client) dispatchSingleRequest(request *http.Request) ([]byte, error) {
 	client.Signer.OAuthSign(request)
-	httpClient := http.Client{}
+	httpClient := http.Client{Transport: client.Transport}
 	// See https://code.google.com/p/go/issues/detail?id=4677
 	// We need to force the connection to close each time so that we don't
 	// hit the above Go bug.
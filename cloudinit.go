@@ -0,0 +1,132 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+	"gopkg.in/yaml.v2"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// CloudInitScript is a script run by cloud-init on the first boot of the
+// machine.
+type CloudInitScript struct {
+	// Name is used as the filename of the script, and must be unique.
+	Name string
+	// Content is the script itself, which must start with a #! line.
+	Content string
+}
+
+// CloudInit builds the user data for Machine.Start from cloud-config and
+// scripts, as a multi-part MIME message.
+type CloudInit struct {
+	// CloudConfig is the YAML cloud-config. The #cloud-config header line is
+	// added if it is missing.
+	CloudConfig string
+	Scripts     []CloudInitScript
+}
+
+// Validate checks that there is something to send, that the cloud-config is
+// a YAML map and that the scripts are named and start with a #! line.
+func (c *CloudInit) Validate() error {
+	if strings.TrimSpace(c.CloudConfig) == "" && len(c.Scripts) == 0 {
+		return errors.NotValidf("missing CloudConfig and Scripts")
+	}
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(c.CloudConfig), &config); err != nil {
+		return errors.NewNotValid(err, "CloudConfig")
+	}
+	names := set.NewStrings()
+	for i, script := range c.Scripts {
+		if script.Name == "" {
+			return errors.NotValidf("script %d missing Name", i)
+		}
+		if names.Contains(script.Name) {
+			return errors.NotValidf("reusing script name %q", script.Name)
+		}
+		names.Add(script.Name)
+		if !strings.HasPrefix(script.Content, "#!") {
+			return errors.NotValidf("script %q without #! line", script.Name)
+		}
+	}
+	return nil
+}
+
+// Render returns the multi-part MIME message understood by cloud-init.
+func (c *CloudInit) Render() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	addPart := func(contentType, filename, content string) error {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", contentType+`; charset="utf-8"`)
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		_, err = part.Write([]byte(wrapBase64(content)))
+		return errors.Trace(err)
+	}
+	if strings.TrimSpace(c.CloudConfig) != "" {
+		config := c.CloudConfig
+		if !strings.HasPrefix(config, cloudConfigHeader) {
+			config = cloudConfigHeader + "\n" + config
+		}
+		if err := addPart("text/cloud-config", "cloud-config", config); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	for _, script := range c.Scripts {
+		if err := addPart("text/x-shellscript", script.Name, script.Content); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%q\r\n", writer.Boundary())
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n\r\n")
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// UserData returns the rendered message base64 encoded, as needed for
+// StartArgs.UserData.
+func (c *CloudInit) UserData() (string, error) {
+	message, err := c.Render()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return base64.StdEncoding.EncodeToString(message), nil
+}
+
+// wrapBase64 encodes the content, split into lines of the length required
+// by MIME.
+func wrapBase64(content string) string {
+	const lineLength = 76
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	var lines []string
+	for len(encoded) > lineLength {
+		lines = append(lines, encoded[:lineLength])
+		encoded = encoded[lineLength:]
+	}
+	lines = append(lines, encoded)
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
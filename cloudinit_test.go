@@ -0,0 +1,149 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type cloudInitSuite struct{}
+
+var _ = gc.Suite(&cloudInitSuite{})
+
+type cloudInitPart struct {
+	contentType string
+	filename    string
+	content     string
+}
+
+// parseCloudInit decodes the base64 user data, and returns the decoded
+// parts of the MIME message.
+func parseCloudInit(c *gc.C, userData string) []cloudInitPart {
+	raw, err := base64.StdEncoding.DecodeString(userData)
+	c.Assert(err, jc.ErrorIsNil)
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(message.Header.Get("MIME-Version"), gc.Equals, "1.0")
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(mediaType, gc.Equals, "multipart/mixed")
+
+	var result []cloudInitPart
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(part.Header.Get("Content-Transfer-Encoding"), gc.Equals, "base64")
+		content, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		c.Assert(err, jc.ErrorIsNil)
+		contentType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		c.Assert(err, jc.ErrorIsNil)
+		result = append(result, cloudInitPart{
+			contentType: contentType,
+			filename:    part.FileName(),
+			content:     string(content),
+		})
+	}
+	return result
+}
+
+func (*cloudInitSuite) TestUserData(c *gc.C) {
+	cloudInit := CloudInit{
+		CloudConfig: "packages:\n  - htop\n",
+		Scripts: []CloudInitScript{
+			{Name: "hello.sh", Content: "#!/bin/sh\necho hello\n"},
+			{Name: "world.py", Content: "#!/usr/bin/python3\nprint('world')\n"},
+		},
+	}
+	userData, err := cloudInit.UserData()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(parseCloudInit(c, userData), jc.DeepEquals, []cloudInitPart{{
+		contentType: "text/cloud-config",
+		filename:    "cloud-config",
+		content:     "#cloud-config\npackages:\n  - htop\n",
+	}, {
+		contentType: "text/x-shellscript",
+		filename:    "hello.sh",
+		content:     "#!/bin/sh\necho hello\n",
+	}, {
+		contentType: "text/x-shellscript",
+		filename:    "world.py",
+		content:     "#!/usr/bin/python3\nprint('world')\n",
+	}})
+}
+
+func (*cloudInitSuite) TestUserDataKeepsHeader(c *gc.C) {
+	cloudInit := CloudInit{CloudConfig: "#cloud-config\nruncmd: [ls]\n"}
+	userData, err := cloudInit.UserData()
+	c.Assert(err, jc.ErrorIsNil)
+	parts := parseCloudInit(c, userData)
+	c.Assert(parts, gc.HasLen, 1)
+	c.Assert(parts[0].content, gc.Equals, "#cloud-config\nruncmd: [ls]\n")
+}
+
+func (*cloudInitSuite) TestUserDataScriptsOnly(c *gc.C) {
+	cloudInit := CloudInit{Scripts: []CloudInitScript{{Name: "a", Content: "#!/bin/true"}}}
+	userData, err := cloudInit.UserData()
+	c.Assert(err, jc.ErrorIsNil)
+	parts := parseCloudInit(c, userData)
+	c.Assert(parts, gc.HasLen, 1)
+	c.Assert(parts[0].contentType, gc.Equals, "text/x-shellscript")
+}
+
+func (*cloudInitSuite) TestWrapBase64(c *gc.C) {
+	wrapped := wrapBase64(string(make([]byte, 100)))
+	lines := bytes.Split([]byte(wrapped), []byte("\r\n"))
+	c.Assert(lines, gc.HasLen, 3)
+	c.Check(lines[0], gc.HasLen, 76)
+	c.Check(lines[1], gc.HasLen, 60)
+	c.Check(lines[2], gc.HasLen, 0)
+}
+
+func (*cloudInitSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		cloudInit CloudInit
+		err       string
+	}{{
+		err: "missing CloudConfig and Scripts not valid",
+	}, {
+		cloudInit: CloudInit{CloudConfig: "- not\n- a map\n"},
+		err:       "(?s)CloudConfig: yaml: .*",
+	}, {
+		cloudInit: CloudInit{Scripts: []CloudInitScript{{Content: "#!/bin/sh"}}},
+		err:       "script 0 missing Name not valid",
+	}, {
+		cloudInit: CloudInit{Scripts: []CloudInitScript{
+			{Name: "a", Content: "#!/bin/sh"},
+			{Name: "a", Content: "#!/bin/sh"},
+		}},
+		err: `reusing script name "a" not valid`,
+	}, {
+		cloudInit: CloudInit{Scripts: []CloudInitScript{{Name: "a", Content: "echo"}}},
+		err:       `script "a" without #! line not valid`,
+	}, {
+		cloudInit: CloudInit{CloudConfig: "runcmd: [ls]"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.cloudInit.Validate()
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/juju/gomaasapi"
+)
+
+// fixture holds the state of both APIs, as read from a fixture file and as
+// written to the state file. The 1.0 API is under "v1".
+type fixture struct {
+	gomaasapi.FakeMAASState
+	V1 *gomaasapi.TestServerSnapshot `json:"v1,omitempty"`
+}
+
+// loadFixture reads a fixture file. YAML fixtures are converted to JSON
+// first, so that their keys match the field names in any case as JSON ones
+// do.
+func loadFixture(path string) (*fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		var value interface{}
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, errors.Annotatef(err, "fixture %q", path)
+		}
+		if data, err = json.Marshal(jsonValue(value)); err != nil {
+			return nil, errors.Annotatef(err, "fixture %q", path)
+		}
+	}
+	var result fixture
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, errors.Annotatef(err, "fixture %q", path)
+	}
+	return &result, nil
+}
+
+// jsonValue replaces the maps with interface{} keys that YAML decodes to
+// with maps that can be marshalled as JSON.
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{})
+		for key, item := range value {
+			result[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = jsonValue(item)
+		}
+		return result
+	}
+	return value
+}
+
+// seed adds the contents of the fixture to the servers. The servers panic
+// on inconsistent fixtures, such as a machine on a missing subnet, which is
+// reported as an error.
+func seed(v2 *gomaasapi.FakeMAASServer, v1 *gomaasapi.TestServer, fixture *fixture) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("invalid fixture: %v", r)
+		}
+	}()
+	for _, zone := range fixture.Zones {
+		v2.AddZone(zone)
+	}
+	for _, fabric := range fixture.Fabrics {
+		v2.AddFabric(fabric)
+	}
+	for _, vlan := range fixture.VLANs {
+		v2.AddVLAN(vlan)
+	}
+	for _, space := range fixture.Spaces {
+		v2.AddSpace(space)
+	}
+	for _, subnet := range fixture.Subnets {
+		v2.AddSubnet(subnet)
+	}
+	for _, machine := range fixture.Machines {
+		v2.AddMachine(machine)
+	}
+	for _, device := range fixture.Devices {
+		v2.AddDevice(device)
+	}
+	for filename, content := range fixture.Files {
+		v2.AddFile(filename, content)
+	}
+	if fixture.V1 != nil {
+		// The nodes are added with NewNode, which fills in their resource
+		// URIs and statuses, rather than restored as they are.
+		snapshot := *fixture.V1
+		snapshot.Nodes = nil
+		v1.Restore(snapshot)
+		for _, node := range fixture.V1.Nodes {
+			v1.NewNode(string(node))
+		}
+	}
+	return nil
+}
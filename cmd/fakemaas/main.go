@@ -0,0 +1,119 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+/*
+The fakemaas command serves the fakes of MAAS that the gomaasapi tests use,
+so that clients written in other languages can be tested against them.
+
+	fakemaas [-addr :5240] [-fixture fixture.yaml] [-state state.json]
+
+The 2.0 API is served by a FakeMAASServer and the 1.0 API by a TestServer,
+both under /api/ and /MAAS/api/, so either http://localhost:5240/ or
+http://localhost:5240/MAAS/ can be used as the URL of the MAAS. Any API key
+is accepted.
+
+The fixture is a YAML file if its extension is ".yaml" or ".yml", and a
+JSON file otherwise. It lists the zones, fabrics, VLANs, spaces, subnets,
+machines and devices to add to the 2.0 API, with the fields of the
+gomaasapi Fake types as keys, in any case. The IDs the subnets and
+machines refer to must be given explicitly:
+
+	zones:
+	- name: east
+	spaces:
+	- id: 1
+	  name: db
+	subnets:
+	- id: 2
+	  cidr: 10.20.0.0/24
+	  space: 1
+	  gatewayip: 10.20.0.1
+	machines:
+	- hostname: node-1
+	  cpucount: 4
+	  memory: 8192
+	  zone: east
+	  interfaces:
+	  - macaddress: "52:54:00:00:00:01"
+	    links:
+	    - mode: static
+	      subnet: 2
+	      ipaddress: 10.20.0.5
+
+The 1.0 API is seeded from the optional v1 key of the fixture, which has
+the fields of gomaasapi.TestServerSnapshot. The nodes are given as they
+are to TestServer.NewNode, keyed by their system IDs:
+
+	v1:
+	  nodes:
+	    node-a:
+	      system_id: node-a
+	      hostname: node-a.maas
+	      status: "4"
+
+With -state, the state of both APIs is saved to the file as JSON, in the
+format of the fixture, after every request that changes it, and is
+restored from the file on the next run instead of being seeded from the
+fixture.
+
+The admin endpoints are:
+
+	GET    /admin/state   returns the state of both APIs as JSON.
+	POST   /admin/reset   goes back to the state seeded from the fixture,
+	                      and clears the faults.
+	POST   /admin/faults  adds a fault rule, given as JSON.
+	DELETE /admin/faults  removes the fault rules.
+
+A fault rule has the fields of gomaasapi.FaultRule in snake case, with the
+latencies as durations such as "250ms", and an "api" field of "1.0" or
+"2.0" to limit it to one of the APIs:
+
+	{"api": "2.0", "path": "/machines/$", "status": 503, "times": 2}
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var (
+	addr        = flag.String("addr", ":5240", "the address to serve the fake MAAS on")
+	fixturePath = flag.String("fixture", "", "a YAML or JSON file to seed the fake MAAS from")
+	statePath   = flag.String("state", "", "a JSON file to save the state of the fake MAAS to, and restore it from")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "fakemaas: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	fake, err := newFakeMAAS(*fixturePath, *statePath)
+	if err != nil {
+		return err
+	}
+	defer fake.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- http.ListenAndServe(*addr, fake)
+	}()
+	fmt.Printf("fakemaas: serving on %s\n", *addr)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		return err
+	case <-signals:
+		return nil
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/gomaasapi"
+)
+
+// fakeMAAS serves the 2.0 and 1.0 APIs of the fakes and the admin
+// endpoints.
+type fakeMAAS struct {
+	v2        *gomaasapi.FakeMAASServer
+	v1        *gomaasapi.TestServer
+	mux       *http.ServeMux
+	initial   fixture
+	statePath string
+
+	// mu is held for writing while the admin endpoints change the fakes,
+	// and for reading while the APIs are served.
+	mu sync.RWMutex
+	// v1Mu serialises the use of the TestServer, which is not safe for
+	// concurrent use.
+	v1Mu sync.Mutex
+	// saveMu serialises the saving of the state.
+	saveMu sync.Mutex
+}
+
+// newFakeMAAS returns a fakeMAAS seeded from the fixture, if any, or
+// restored from the state file if it exists.
+func newFakeMAAS(fixturePath, statePath string) (_ *fakeMAAS, err error) {
+	fake := &fakeMAAS{
+		v2:        gomaasapi.NewFakeMAASServer(),
+		v1:        gomaasapi.NewTestServer("1.0"),
+		statePath: statePath,
+	}
+	defer func() {
+		if err != nil {
+			fake.Close()
+		}
+	}()
+	if fixturePath != "" {
+		fixture, err := loadFixture(fixturePath)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := seed(fake.v2, fake.v1, fixture); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	fake.initial = fake.state()
+	if statePath != "" {
+		data, err := ioutil.ReadFile(statePath)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, errors.Trace(err)
+		default:
+			var state fixture
+			if err := json.Unmarshal(data, &state); err != nil {
+				return nil, errors.Annotatef(err, "state %q", statePath)
+			}
+			fake.restore(state)
+		}
+	}
+
+	// The handlers of the fakes are used directly, rather than through
+	// the servers they start.
+	api := http.NewServeMux()
+	api.Handle("/api/2.0/", fake.serveAPI(fake.v2.Config.Handler))
+	api.Handle("/api/1.0/", fake.serveAPI(fake.lockV1(fake.v1.Config.Handler)))
+	fake.mux = http.NewServeMux()
+	fake.mux.Handle("/api/", api)
+	fake.mux.Handle("/MAAS/api/", http.StripPrefix("/MAAS", api))
+	fake.mux.HandleFunc("/admin/state", fake.handleState)
+	fake.mux.HandleFunc("/admin/reset", fake.handleReset)
+	fake.mux.HandleFunc("/admin/faults", fake.handleFaults)
+	return fake, nil
+}
+
+// Close stops the fakes.
+func (fake *fakeMAAS) Close() {
+	fake.v2.Close()
+	fake.v1.Close()
+}
+
+// ServeHTTP implements http.Handler.
+func (fake *fakeMAAS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mux.ServeHTTP(w, r)
+}
+
+// serveAPI returns a handler that serves an API with the handler, saving
+// the state after the requests that may have changed it.
+func (fake *fakeMAAS) serveAPI(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.RLock()
+		defer fake.mu.RUnlock()
+		handler.ServeHTTP(w, r)
+		if r.Method != "GET" {
+			if err := fake.save(); err != nil {
+				fmt.Fprintf(os.Stderr, "fakemaas: cannot save state: %v\n", err)
+			}
+		}
+	})
+}
+
+// lockV1 returns a handler that serves the 1.0 API with the handler while
+// holding v1Mu.
+func (fake *fakeMAAS) lockV1(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.v1Mu.Lock()
+		defer fake.v1Mu.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// state returns the state of both APIs.
+func (fake *fakeMAAS) state() fixture {
+	fake.v1Mu.Lock()
+	defer fake.v1Mu.Unlock()
+	v1 := fake.v1.Snapshot()
+	return fixture{FakeMAASState: fake.v2.State(), V1: &v1}
+}
+
+// restore replaces the state of both APIs. The 1.0 API is cleared if the
+// state has none, as in the state files of older versions.
+func (fake *fakeMAAS) restore(state fixture) {
+	fake.v2.Restore(state.FakeMAASState)
+	fake.v1Mu.Lock()
+	defer fake.v1Mu.Unlock()
+	if state.V1 != nil {
+		fake.v1.Restore(*state.V1)
+	} else {
+		fake.v1.Clear()
+	}
+}
+
+// save writes the state of both APIs to the state file, if there is one.
+func (fake *fakeMAAS) save() error {
+	if fake.statePath == "" {
+		return nil
+	}
+	fake.saveMu.Lock()
+	defer fake.saveMu.Unlock()
+	data, err := json.MarshalIndent(fake.state(), "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// Write the state atomically, so that a crash leaves the old state.
+	tempPath := fake.statePath + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tempPath, fake.statePath))
+}
+
+func (fake *fakeMAAS) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, fake.state())
+}
+
+func (fake *fakeMAAS) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.restore(fake.initial)
+	fake.v2.ClearFaults()
+	fake.v1.ClearFaults()
+	if err := fake.save(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// faultSpec is a gomaasapi.FaultRule as given to the admin endpoint.
+type faultSpec struct {
+	API         string  `json:"api"`
+	Method      string  `json:"method"`
+	Path        string  `json:"path"`
+	Every       int     `json:"every"`
+	Probability float64 `json:"probability"`
+	Times       int     `json:"times"`
+	Latency     string  `json:"latency"`
+	MaxLatency  string  `json:"max_latency"`
+	Status      int     `json:"status"`
+	RetryAfter  int     `json:"retry_after"`
+	Reset       bool    `json:"reset"`
+	Truncate    bool    `json:"truncate"`
+}
+
+// rule returns the fault rule of the spec.
+func (spec faultSpec) rule() (gomaasapi.FaultRule, error) {
+	if _, err := regexp.Compile(spec.Path); err != nil {
+		return gomaasapi.FaultRule{}, errors.NotValidf("path %q", spec.Path)
+	}
+	rule := gomaasapi.FaultRule{
+		Method:      strings.ToUpper(spec.Method),
+		Path:        spec.Path,
+		Every:       spec.Every,
+		Probability: spec.Probability,
+		Times:       spec.Times,
+		Status:      spec.Status,
+		RetryAfter:  spec.RetryAfter,
+		Reset:       spec.Reset,
+		Truncate:    spec.Truncate,
+	}
+	var err error
+	if spec.Latency != "" {
+		if rule.Latency, err = time.ParseDuration(spec.Latency); err != nil {
+			return gomaasapi.FaultRule{}, errors.NotValidf("latency %q", spec.Latency)
+		}
+	}
+	if spec.MaxLatency != "" {
+		if rule.MaxLatency, err = time.ParseDuration(spec.MaxLatency); err != nil {
+			return gomaasapi.FaultRule{}, errors.NotValidf("max_latency %q", spec.MaxLatency)
+		}
+	}
+	return rule, nil
+}
+
+func (fake *fakeMAAS) handleFaults(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	switch r.Method {
+	case "POST":
+		var spec faultSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule, err := spec.rule()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch spec.API {
+		case "":
+			fake.v2.AddFault(rule)
+			fake.v1.AddFault(rule)
+		case "2.0":
+			fake.v2.AddFault(rule)
+		case "1.0":
+			fake.v1.AddFault(rule)
+		default:
+			http.Error(w, fmt.Sprintf("api %q not valid", spec.API), http.StatusBadRequest)
+			return
+		}
+	case "DELETE":
+		fake.v2.ClearFaults()
+		fake.v1.ClearFaults()
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,203 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	stdtesting "testing"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/gomaasapi"
+)
+
+func Test(t *stdtesting.T) {
+	gc.TestingT(t)
+}
+
+const fixtureYAML = `
+zones:
+- name: east
+spaces:
+- id: 1
+  name: db
+subnets:
+- id: 2
+  cidr: 10.20.0.0/24
+  space: 1
+machines:
+- hostname: node-1
+  cpucount: 4
+  memory: 8192
+  zone: east
+  interfaces:
+  - macaddress: "52:54:00:00:00:01"
+    links:
+    - mode: static
+      subnet: 2
+      ipaddress: 10.20.0.5
+- hostname: node-2
+v1:
+  nodes:
+    node-a:
+      system_id: node-a
+      hostname: node-a.maas
+      status: "4"
+`
+
+type serverSuite struct {
+	dir       string
+	statePath string
+}
+
+var _ = gc.Suite(&serverSuite{})
+
+func (s *serverSuite) SetUpTest(c *gc.C) {
+	s.dir = c.MkDir()
+	s.statePath = filepath.Join(s.dir, "state.json")
+	err := ioutil.WriteFile(filepath.Join(s.dir, "fixture.yaml"), []byte(fixtureYAML), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+// start serves a fakeMAAS, returning its URL and a function to stop it.
+func (s *serverSuite) start(c *gc.C) (*httptest.Server, func()) {
+	fake, err := newFakeMAAS(filepath.Join(s.dir, "fixture.yaml"), s.statePath)
+	c.Assert(err, jc.ErrorIsNil)
+	server := httptest.NewServer(fake)
+	return server, func() {
+		server.Close()
+		fake.Close()
+	}
+}
+
+func (s *serverSuite) controller(c *gc.C, serverURL string) gomaasapi.Controller {
+	controller, err := gomaasapi.NewController(gomaasapi.ControllerArgs{
+		BaseURL: serverURL + "/MAAS/",
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return controller
+}
+
+func (s *serverSuite) admin(c *gc.C, method, url, body string) int {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	c.Assert(err, jc.ErrorIsNil)
+	response, err := http.DefaultClient.Do(request)
+	c.Assert(err, jc.ErrorIsNil)
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func (s *serverSuite) TestFixture(c *gc.C) {
+	server, stop := s.start(c)
+	defer stop()
+	machines, err := s.controller(c, server.URL).Machines(gomaasapi.MachinesArgs{Hostnames: []string{"node-1"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].CPUCount(), gc.Equals, 4)
+	c.Check(machines[0].Zone().Name(), gc.Equals, "east")
+	c.Check(machines[0].IPAddresses(), jc.DeepEquals, []string{"10.20.0.5"})
+}
+
+// v1Nodes returns the system IDs of the nodes the 1.0 API lists.
+func (s *serverSuite) v1Nodes(c *gc.C, serverURL string) []string {
+	client, err := gomaasapi.NewAnonymousClient(serverURL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	content, err := client.Get(&url.URL{Path: "nodes/"}, "list", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	var nodes []struct {
+		SystemID string `json:"system_id"`
+	}
+	c.Assert(json.Unmarshal(content, &nodes), jc.ErrorIsNil)
+	var systemIDs []string
+	for _, node := range nodes {
+		systemIDs = append(systemIDs, node.SystemID)
+	}
+	return systemIDs
+}
+
+func (s *serverSuite) TestV1Fixture(c *gc.C) {
+	server, stop := s.start(c)
+	defer stop()
+	c.Check(s.v1Nodes(c, server.URL), jc.DeepEquals, []string{"node-a"})
+}
+
+func (s *serverSuite) TestV1PersistAndReset(c *gc.C) {
+	server, stop := s.start(c)
+	client, err := gomaasapi.NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	err = client.Delete(&url.URL{Path: "nodes/node-a/"})
+	c.Assert(err, jc.ErrorIsNil)
+	stop()
+
+	// The deletion survives a restart.
+	server, stop = s.start(c)
+	defer stop()
+	c.Check(s.v1Nodes(c, server.URL), gc.HasLen, 0)
+
+	c.Check(s.admin(c, "POST", server.URL+"/admin/reset", ""), gc.Equals, http.StatusNoContent)
+	c.Check(s.v1Nodes(c, server.URL), jc.DeepEquals, []string{"node-a"})
+}
+
+func (s *serverSuite) TestInvalidFixture(c *gc.C) {
+	path := filepath.Join(s.dir, "bad.json")
+	err := ioutil.WriteFile(path, []byte(`{"Machines": [{"Interfaces": [{"Links": [{"Subnet": 42}]}]}]}`), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = newFakeMAAS(path, "")
+	c.Assert(err, gc.ErrorMatches, "invalid fixture: no subnet 42")
+}
+
+func (s *serverSuite) TestPersistAndReset(c *gc.C) {
+	server, stop := s.start(c)
+	machine, _, err := s.controller(c, server.URL).AllocateMachine(gomaasapi.AllocateMachineArgs{Hostname: "node-1"})
+	c.Assert(err, jc.ErrorIsNil)
+	stop()
+
+	// The allocation survives a restart.
+	server, stop = s.start(c)
+	defer stop()
+	controller := s.controller(c, server.URL)
+	machines, err := controller.Machines(gomaasapi.MachinesArgs{SystemIDs: []string{machine.SystemID()}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].StatusName(), gc.Equals, "Allocated")
+
+	c.Check(s.admin(c, "POST", server.URL+"/admin/reset", ""), gc.Equals, http.StatusNoContent)
+	machines, err = controller.Machines(gomaasapi.MachinesArgs{SystemIDs: []string{machine.SystemID()}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].StatusName(), gc.Equals, "Ready")
+	data, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(strings.Contains(string(data), "Allocated"), jc.IsFalse)
+}
+
+func (s *serverSuite) TestFaults(c *gc.C) {
+	server, stop := s.start(c)
+	defer stop()
+	controller := s.controller(c, server.URL)
+	status := s.admin(c, "POST", server.URL+"/admin/faults", `{"api": "2.0", "path": "/machines/$", "status": 500}`)
+	c.Assert(status, gc.Equals, http.StatusNoContent)
+	_, err := controller.Machines(gomaasapi.MachinesArgs{})
+	c.Assert(err, gc.ErrorMatches, "(?s).*500 Internal Server Error.*")
+
+	// The 1.0 API is not affected.
+	client, err := gomaasapi.NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(&url.URL{Path: "nodes/"}, "list", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(s.admin(c, "DELETE", server.URL+"/admin/faults", ""), gc.Equals, http.StatusNoContent)
+	_, err = controller.Machines(gomaasapi.MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+
+	status = s.admin(c, "POST", server.URL+"/admin/faults", `{"latency": "soon"}`)
+	c.Check(status, gc.Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// The values of CommissioningResult.ResultType, which say which stage of
+// the life of the machine the script ran in.
+const (
+	ResultTypeCommissioning = 0
+	ResultTypeInstallation  = 1
+	// ResultTypeTesting is only used by MAAS 2.2 and later.
+	ResultTypeTesting = 2
+)
+
+type commissioningResult struct {
+	resourceURI string
+
+	systemID     string
+	name         string
+	scriptResult int
+	resultType   int
+	updated      string
+	data         []byte
+}
+
+// SystemID implements CommissioningResult.
+func (r *commissioningResult) SystemID() string {
+	return r.systemID
+}
+
+// Name implements CommissioningResult.
+func (r *commissioningResult) Name() string {
+	return r.name
+}
+
+// ScriptResult implements CommissioningResult.
+func (r *commissioningResult) ScriptResult() int {
+	return r.scriptResult
+}
+
+// ResultType implements CommissioningResult.
+func (r *commissioningResult) ResultType() int {
+	return r.resultType
+}
+
+// Updated implements CommissioningResult.
+func (r *commissioningResult) Updated() string {
+	return r.updated
+}
+
+// Data implements CommissioningResult.
+func (r *commissioningResult) Data() []byte {
+	return r.data
+}
+
+func readCommissioningResults(controllerVersion version.Number, source interface{}) ([]*commissioningResult, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "commissioning result base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range commissioningResultDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no commissioning result read func for version %s", controllerVersion)
+	}
+	readFunc := commissioningResultDeserializationFuncs[deserialisationVersion]
+	return readCommissioningResultList(valid, readFunc)
+}
+
+// readCommissioningResultList expects the values of the sourceList to be string maps.
+func readCommissioningResultList(sourceList []interface{}, readFunc commissioningResultDeserializationFunc) ([]*commissioningResult, error) {
+	result := make([]*commissioningResult, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for commissioning result %d, %T", i, value)
+		}
+		commissioningResult, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "commissioning result %d", i)
+		}
+		result = append(result, commissioningResult)
+	}
+	return result, nil
+}
+
+type commissioningResultDeserializationFunc func(map[string]interface{}) (*commissioningResult, error)
+
+var commissioningResultDeserializationFuncs = map[version.Number]commissioningResultDeserializationFunc{
+	twoDotOh: commissioningResult_2_0,
+}
+
+func commissioningResult_2_0(source map[string]interface{}) (*commissioningResult, error) {
+	nodeFields := schema.Fields{
+		"system_id": schema.String(),
+	}
+	fields := schema.Fields{
+		"resource_uri":  schema.String(),
+		"node":          schema.FieldMap(nodeFields, nil),
+		"name":          schema.String(),
+		"script_result": schema.ForceInt(),
+		"result_type":   schema.ForceInt(),
+		"updated":       schema.OneOf(schema.Nil(""), schema.String()),
+		"data":          schema.OneOf(schema.Nil(""), schema.String()),
+	}
+	defaults := schema.Defaults{
+		"resource_uri": "",
+		"updated":      "",
+		"data":         "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "commissioning result 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	// The output of the commissioning script is base64 encoded.
+	encoded, _ := valid["data"].(string)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "commissioning result data")
+	}
+	node := valid["node"].(map[string]interface{})
+	updated, _ := valid["updated"].(string)
+
+	result := &commissioningResult{
+		resourceURI:  valid["resource_uri"].(string),
+		systemID:     node["system_id"].(string),
+		name:         valid["name"].(string),
+		scriptResult: valid["script_result"].(int),
+		resultType:   valid["result_type"].(int),
+		updated:      updated,
+		data:         data,
+	}
+	return result, nil
+}
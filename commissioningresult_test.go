@@ -0,0 +1,80 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type commissioningResultSuite struct{}
+
+var _ = gc.Suite(&commissioningResultSuite{})
+
+func (*commissioningResultSuite) TestReadCommissioningResultsBadSchema(c *gc.C) {
+	_, err := readCommissioningResults(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `commissioning result base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*commissioningResultSuite) TestReadCommissioningResultsBadData(c *gc.C) {
+	json := parseJSON(c, commissioningResultsResponse)
+	json.([]interface{})[0].(map[string]interface{})["data"] = "!!not base64!!"
+	_, err := readCommissioningResults(twoDotOh, json)
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (*commissioningResultSuite) TestReadCommissioningResults(c *gc.C) {
+	results, err := readCommissioningResults(twoDotOh, parseJSON(c, commissioningResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 2)
+
+	result := results[0]
+	c.Check(result.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(result.Name(), gc.Equals, "00-maas-01-cpuinfo.out")
+	c.Check(result.ScriptResult(), gc.Equals, 0)
+	c.Check(result.ResultType(), gc.Equals, ResultTypeCommissioning)
+	c.Check(result.Updated(), gc.Equals, "2016-05-12T21:35:31.398")
+	c.Check(string(result.Data()), gc.Equals, "processor: 0\n")
+
+	c.Check(results[1].ScriptResult(), gc.Equals, 1)
+	c.Check(results[1].Data(), gc.HasLen, 0)
+}
+
+func (*commissioningResultSuite) TestLowVersion(c *gc.C) {
+	_, err := readCommissioningResults(version.MustParse("1.9.0"), parseJSON(c, commissioningResultsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*commissioningResultSuite) TestHighVersion(c *gc.C) {
+	results, err := readCommissioningResults(version.MustParse("2.1.9"), parseJSON(c, commissioningResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 2)
+}
+
+const commissioningResultsResponse = `
+[
+    {
+        "name": "00-maas-01-cpuinfo.out",
+        "script_result": 0,
+        "result_type": 0,
+        "updated": "2016-05-12T21:35:31.398",
+        "created": "2016-05-12T21:35:31.398",
+        "node": {"system_id": "4y3ha3"},
+        "data": "cHJvY2Vzc29yOiAwCg==",
+        "resource_uri": "/MAAS/api/2.0/commissioning-results/"
+    },
+    {
+        "name": "99-maas-02-capture-lldp.err",
+        "script_result": 1,
+        "result_type": 0,
+        "updated": null,
+        "created": "2016-05-12T21:35:31.398",
+        "node": {"system_id": "4y3ha3"},
+        "data": "",
+        "resource_uri": "/MAAS/api/2.0/commissioning-results/"
+    }
+]
+`
@@ -0,0 +1,202 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// Config holds the documented global settings of a MAAS server. Each field
+// corresponds to the config key noted beside it.
+type Config struct {
+	MAASName                   string // maas_name
+	DefaultOSystem             string // default_osystem
+	DefaultDistroSeries        string // default_distro_series
+	DefaultMinHWEKernel        string // default_min_hwe_kernel
+	CommissioningDistroSeries  string // commissioning_distro_series
+	KernelOpts                 string // kernel_opts
+	UpstreamDNS                string // upstream_dns
+	DNSSECValidation           string // dnssec_validation
+	NTPServers                 string // ntp_servers
+	NTPExternalOnly            bool   // ntp_external_only
+	HTTPProxy                  string // http_proxy
+	EnableHTTPProxy            bool   // enable_http_proxy
+	EnableDiskErasingOnRelease bool   // enable_disk_erasing_on_release
+	EnableThirdPartyDrivers    bool   // enable_third_party_drivers
+}
+
+// configFields describes the types of the keys read into a Config.
+var configFields = schema.Fields{
+	"maas_name":                      schema.OneOf(schema.Nil(""), schema.String()),
+	"default_osystem":                schema.OneOf(schema.Nil(""), schema.String()),
+	"default_distro_series":          schema.OneOf(schema.Nil(""), schema.String()),
+	"default_min_hwe_kernel":         schema.OneOf(schema.Nil(""), schema.String()),
+	"commissioning_distro_series":    schema.OneOf(schema.Nil(""), schema.String()),
+	"kernel_opts":                    schema.OneOf(schema.Nil(""), schema.String()),
+	"upstream_dns":                   schema.OneOf(schema.Nil(""), schema.String()),
+	"dnssec_validation":              schema.OneOf(schema.Nil(""), schema.String()),
+	"ntp_servers":                    schema.OneOf(schema.Nil(""), schema.String()),
+	"ntp_external_only":              schema.OneOf(schema.Nil(""), schema.Bool()),
+	"http_proxy":                     schema.OneOf(schema.Nil(""), schema.String()),
+	"enable_http_proxy":              schema.OneOf(schema.Nil(""), schema.Bool()),
+	"enable_disk_erasing_on_release": schema.OneOf(schema.Nil(""), schema.Bool()),
+	"enable_third_party_drivers":     schema.OneOf(schema.Nil(""), schema.Bool()),
+}
+
+// values returns the config keyed by the MAAS config names, with the values
+// formatted as they are sent to SetConfig.
+func (c Config) values() map[string]string {
+	return map[string]string{
+		"maas_name":                      c.MAASName,
+		"default_osystem":                c.DefaultOSystem,
+		"default_distro_series":          c.DefaultDistroSeries,
+		"default_min_hwe_kernel":         c.DefaultMinHWEKernel,
+		"commissioning_distro_series":    c.CommissioningDistroSeries,
+		"kernel_opts":                    c.KernelOpts,
+		"upstream_dns":                   c.UpstreamDNS,
+		"dnssec_validation":              c.DNSSECValidation,
+		"ntp_servers":                    c.NTPServers,
+		"ntp_external_only":              fmt.Sprint(c.NTPExternalOnly),
+		"http_proxy":                     c.HTTPProxy,
+		"enable_http_proxy":              fmt.Sprint(c.EnableHTTPProxy),
+		"enable_disk_erasing_on_release": fmt.Sprint(c.EnableDiskErasingOnRelease),
+		"enable_third_party_drivers":     fmt.Sprint(c.EnableThirdPartyDrivers),
+	}
+}
+
+// Diff returns the config keys whose values in c differ from those in
+// current, mapped to the values in c. Only the given keys are compared, or
+// all of them if none are given.
+func (c Config) Diff(current Config, keys ...string) map[string]string {
+	values := c.values()
+	currentValues := current.values()
+	if len(keys) == 0 {
+		for name := range values {
+			keys = append(keys, name)
+		}
+	}
+	result := make(map[string]string)
+	for _, name := range keys {
+		if value := values[name]; currentValues[name] != value {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// ReadConfig gets the given keys from the controller, or all of the keys
+// covered by Config if none are given. Keys the controller rejects with a
+// BadRequestError, as older servers do for the keys they don't know, are
+// skipped and leave their fields empty.
+func ReadConfig(controller Controller, keys ...string) (Config, error) {
+	if err := validateConfigKeys(keys); err != nil {
+		return Config{}, errors.Trace(err)
+	}
+	if len(keys) == 0 {
+		for name := range configFields {
+			keys = append(keys, name)
+		}
+	}
+	source := make(map[string]interface{})
+	for _, name := range keys {
+		value, err := controller.GetConfig(name)
+		if IsBadRequestError(err) {
+			continue
+		}
+		if err != nil {
+			return Config{}, errors.Annotatef(err, "getting %q", name)
+		}
+		source[name] = value
+	}
+	return readConfig(source)
+}
+
+// validateConfigKeys checks that the keys are covered by Config.
+func validateConfigKeys(keys []string) error {
+	for _, name := range keys {
+		if _, ok := configFields[name]; !ok {
+			return errors.NotValidf("config key %q", name)
+		}
+	}
+	return nil
+}
+
+// ApplyConfig sets the given keys to their values in the desired config,
+// where those differ from the config currently on the controller. The other
+// fields of the desired config are ignored, so only the fields of interest
+// need to be filled in, and only the given keys are read from the
+// controller. The keys that were changed are returned, along with an error
+// if setting one of them failed.
+func ApplyConfig(controller Controller, desired Config, keys ...string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return nil, errors.NotValidf("missing config keys")
+	}
+	if err := validateConfigKeys(keys); err != nil {
+		return nil, errors.Trace(err)
+	}
+	current, err := ReadConfig(controller, keys...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	changes := desired.Diff(current, keys...)
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	applied := make(map[string]string)
+	for _, name := range names {
+		if err := controller.SetConfig(name, changes[name]); err != nil {
+			return applied, errors.Annotatef(err, "setting %q", name)
+		}
+		applied[name] = changes[name]
+	}
+	return applied, nil
+}
+
+func readConfig(source map[string]interface{}) (Config, error) {
+	// Keys that were not read are left out.
+	defaults := make(schema.Defaults)
+	for name := range configFields {
+		defaults[name] = schema.Omit
+	}
+	checker := schema.FieldMap(configFields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return Config{}, WrapWithDeserializationError(err, "config schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type. Unset values are nil, so the two
+	// part cast assignment gives us the zero value.
+
+	str := func(name string) string {
+		value, _ := valid[name].(string)
+		return value
+	}
+	boolean := func(name string) bool {
+		value, _ := valid[name].(bool)
+		return value
+	}
+	return Config{
+		MAASName:                   str("maas_name"),
+		DefaultOSystem:             str("default_osystem"),
+		DefaultDistroSeries:        str("default_distro_series"),
+		DefaultMinHWEKernel:        str("default_min_hwe_kernel"),
+		CommissioningDistroSeries:  str("commissioning_distro_series"),
+		KernelOpts:                 str("kernel_opts"),
+		UpstreamDNS:                str("upstream_dns"),
+		DNSSECValidation:           str("dnssec_validation"),
+		NTPServers:                 str("ntp_servers"),
+		NTPExternalOnly:            boolean("ntp_external_only"),
+		HTTPProxy:                  str("http_proxy"),
+		EnableHTTPProxy:            boolean("enable_http_proxy"),
+		EnableDiskErasingOnRelease: boolean("enable_disk_erasing_on_release"),
+		EnableThirdPartyDrivers:    boolean("enable_third_party_drivers"),
+	}, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type configSuite struct{}
+
+var _ = gc.Suite(&configSuite{})
+
+type fakeConfigController struct {
+	Controller
+	values    map[string]interface{}
+	got       []string
+	set       map[string]interface{}
+	err       error
+	setErrors map[string]error
+}
+
+func (f *fakeConfigController) GetConfig(name string) (interface{}, error) {
+	f.got = append(f.got, name)
+	if f.err != nil {
+		return nil, f.err
+	}
+	value, ok := f.values[name]
+	if !ok {
+		return nil, NewBadRequestError("No such configuration setting: " + name)
+	}
+	return value, nil
+}
+
+func (f *fakeConfigController) SetConfig(name string, value interface{}) error {
+	if err := f.setErrors[name]; err != nil {
+		return err
+	}
+	f.set[name] = value
+	return nil
+}
+
+func newFakeConfigController() *fakeConfigController {
+	return &fakeConfigController{
+		values: map[string]interface{}{
+			"maas_name":                      "hoopla",
+			"default_osystem":                "ubuntu",
+			"default_distro_series":          "xenial",
+			"default_min_hwe_kernel":         "",
+			"commissioning_distro_series":    "xenial",
+			"kernel_opts":                    nil,
+			"upstream_dns":                   "8.8.8.8 8.8.4.4",
+			"dnssec_validation":              "auto",
+			"ntp_servers":                    "ntp.ubuntu.com",
+			"ntp_external_only":              false,
+			"http_proxy":                     nil,
+			"enable_http_proxy":              true,
+			"enable_disk_erasing_on_release": false,
+			"enable_third_party_drivers":     true,
+		},
+		set: make(map[string]interface{}),
+	}
+}
+
+func (*configSuite) TestReadConfig(c *gc.C) {
+	config, err := ReadConfig(newFakeConfigController())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config, jc.DeepEquals, Config{
+		MAASName:                  "hoopla",
+		DefaultOSystem:            "ubuntu",
+		DefaultDistroSeries:       "xenial",
+		CommissioningDistroSeries: "xenial",
+		UpstreamDNS:               "8.8.8.8 8.8.4.4",
+		DNSSECValidation:          "auto",
+		NTPServers:                "ntp.ubuntu.com",
+		EnableHTTPProxy:           true,
+		EnableThirdPartyDrivers:   true,
+	})
+}
+
+func (*configSuite) TestReadConfigKeys(c *gc.C) {
+	controller := newFakeConfigController()
+	config, err := ReadConfig(controller, "maas_name", "ntp_servers")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(config, jc.DeepEquals, Config{MAASName: "hoopla", NTPServers: "ntp.ubuntu.com"})
+	c.Check(controller.got, jc.DeepEquals, []string{"maas_name", "ntp_servers"})
+
+	_, err = ReadConfig(controller, "wibble")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `config key "wibble" not valid`)
+}
+
+func (*configSuite) TestReadConfigSkipsUnknownKeys(c *gc.C) {
+	controller := newFakeConfigController()
+	delete(controller.values, "ntp_external_only")
+	delete(controller.values, "dnssec_validation")
+	config, err := ReadConfig(controller)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(config.MAASName, gc.Equals, "hoopla")
+	c.Check(config.DNSSECValidation, gc.Equals, "")
+}
+
+func (*configSuite) TestReadConfigBadValue(c *gc.C) {
+	controller := newFakeConfigController()
+	controller.values["enable_http_proxy"] = "wat"
+	_, err := ReadConfig(controller)
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `config schema check failed: enable_http_proxy: .*`)
+}
+
+func (*configSuite) TestReadConfigError(c *gc.C) {
+	controller := newFakeConfigController()
+	controller.err = errors.New("boom")
+	_, err := ReadConfig(controller)
+	c.Assert(err, gc.ErrorMatches, `getting ".*": boom`)
+}
+
+func (*configSuite) TestDiff(c *gc.C) {
+	current := Config{
+		MAASName:        "hoopla",
+		KernelOpts:      "quiet",
+		EnableHTTPProxy: true,
+	}
+	desired := current
+	c.Assert(desired.Diff(current), gc.HasLen, 0)
+
+	desired.KernelOpts = ""
+	desired.EnableHTTPProxy = false
+	desired.EnableDiskErasingOnRelease = true
+	c.Assert(desired.Diff(current), jc.DeepEquals, map[string]string{
+		"kernel_opts":                    "",
+		"enable_http_proxy":              "false",
+		"enable_disk_erasing_on_release": "true",
+	})
+}
+
+func (*configSuite) TestDiffKeys(c *gc.C) {
+	current := Config{MAASName: "hoopla", KernelOpts: "quiet"}
+	desired := Config{KernelOpts: "debug"}
+	c.Assert(desired.Diff(current, "kernel_opts"), jc.DeepEquals, map[string]string{
+		"kernel_opts": "debug",
+	})
+	c.Assert(desired.Diff(current, "ntp_servers"), gc.HasLen, 0)
+}
+
+func (*configSuite) TestApplyConfig(c *gc.C) {
+	controller := newFakeConfigController()
+	desired, err := ReadConfig(controller)
+	c.Assert(err, jc.ErrorIsNil)
+	desired.DefaultDistroSeries = "trusty"
+	desired.EnableDiskErasingOnRelease = true
+
+	changes, err := ApplyConfig(controller, desired, "default_distro_series", "enable_disk_erasing_on_release", "maas_name")
+	c.Assert(err, jc.ErrorIsNil)
+	expected := map[string]string{
+		"default_distro_series":          "trusty",
+		"enable_disk_erasing_on_release": "true",
+	}
+	c.Assert(changes, jc.DeepEquals, expected)
+	c.Assert(controller.set, jc.DeepEquals, map[string]interface{}{
+		"default_distro_series":          "trusty",
+		"enable_disk_erasing_on_release": "true",
+	})
+}
+
+func (*configSuite) TestApplyConfigOnlyKeys(c *gc.C) {
+	controller := newFakeConfigController()
+	// An older server that doesn't know some of the keys.
+	delete(controller.values, "ntp_external_only")
+	changes, err := ApplyConfig(controller, Config{NTPServers: "ntp.example.com"}, "ntp_servers")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(controller.got, jc.DeepEquals, []string{"ntp_servers"})
+	c.Assert(changes, jc.DeepEquals, map[string]string{"ntp_servers": "ntp.example.com"})
+	c.Assert(controller.set, jc.DeepEquals, map[string]interface{}{"ntp_servers": "ntp.example.com"})
+}
+
+func (*configSuite) TestApplyConfigInvalidKeys(c *gc.C) {
+	controller := newFakeConfigController()
+	_, err := ApplyConfig(controller, Config{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "missing config keys not valid")
+	_, err = ApplyConfig(controller, Config{}, "wibble")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `config key "wibble" not valid`)
+	c.Check(controller.set, gc.HasLen, 0)
+}
+
+func (*configSuite) TestApplyConfigPartial(c *gc.C) {
+	controller := newFakeConfigController()
+	controller.setErrors = map[string]error{"ntp_servers": errors.New("boom")}
+	desired := Config{MAASName: "renamed", NTPServers: "ntp.example.com"}
+	changes, err := ApplyConfig(controller, desired, "maas_name", "ntp_servers")
+	c.Assert(err, gc.ErrorMatches, `setting "ntp_servers": boom`)
+	// The keys set before the failure are still reported.
+	c.Assert(changes, jc.DeepEquals, map[string]string{"maas_name": "renamed"})
+}
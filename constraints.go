@@ -0,0 +1,482 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// The sizes in constraints are given as a number with an optional unit
+// suffix. The multipliers convert the value to MB. The units are decimal,
+// as MAAS measures the storage sizes in decimal gigabytes (see
+// storageConstraintUnit), so 1G is 1000M.
+var sizeMultipliers = map[string]float64{
+	"M": 1,
+	"G": 1000,
+	"T": 1000 * 1000,
+	"P": 1000 * 1000 * 1000,
+}
+
+// constraintToken is a single key=value pair of a constraints string, along
+// with the position of the start of the token in the string.
+type constraintToken struct {
+	pos   int
+	key   string
+	value string
+}
+
+// ParseConstraints parses a space separated list of key=value constraints
+// into the arguments for AllocateMachine. The recognised keys are:
+//
+//	name, system-id, arch, cores, mem, tags, zones, pod, pod-type,
+//	spaces, subnets, fabrics, fabric-classes, root-disk, storage,
+//	interfaces, devices, mac, bridge-all, bridge-stp, bridge-fd
+//
+// The list values are comma separated, and values prefixed with ^ are
+// negative constraints. The mem and root-disk sizes are in MB unless they
+// have an M, G, T or P suffix, and the units are decimal, so 1G is 1000M,
+// for both the memory and the storage sizes. The storage and interfaces values use the
+// formats of StorageSpec.String and InterfaceSpec.String, separated by
+// commas and semicolons respectively, and the devices value uses the format
+// of DeviceSpec.String separated by semicolons.
+//
+// Errors satisfy errors.IsNotValid, and name the position of the offending
+// token in the string.
+func ParseConstraints(value string) (AllocateMachineArgs, error) {
+	var args AllocateMachineArgs
+	var rootDisk []StorageSpec
+	seen := make(map[string]constraintToken)
+	for _, token := range tokenizeConstraints(value) {
+		if token.key == "" {
+			return AllocateMachineArgs{}, errors.NotValidf("constraint %q at position %d", token.value, token.pos)
+		}
+		if _, ok := seen[token.key]; ok {
+			return AllocateMachineArgs{}, errors.NotValidf("repeated constraint %q at position %d", token.key, token.pos)
+		}
+		seen[token.key] = token
+		if err := parseConstraint(&args, &rootDisk, token); err != nil {
+			return AllocateMachineArgs{}, tokenError(token, err)
+		}
+	}
+	if !args.BridgeAll {
+		// The bridge options are checked against each other once they have
+		// all been read.
+		if args.BridgeSTP {
+			return AllocateMachineArgs{}, tokenError(seen["bridge-stp"], errors.New("bridge-all not set"))
+		}
+		if args.BridgeFD != 0 {
+			return AllocateMachineArgs{}, tokenError(seen["bridge-fd"], errors.New("bridge-all not set"))
+		}
+	}
+	args.Storage = append(rootDisk, args.Storage...)
+	if err := args.Validate(); err != nil {
+		return AllocateMachineArgs{}, errors.Trace(err)
+	}
+	return args, nil
+}
+
+// tokenError returns a NotValid error naming the token and its position.
+func tokenError(token constraintToken, err error) error {
+	return errors.NewNotValid(nil, fmt.Sprintf(
+		"%s=%s at position %d: %v", token.key, token.value, token.pos, err))
+}
+
+// parseConstraint sets the args from the token. The values are validated as
+// they are by AllocateMachineArgs.Validate, so that the errors can name the
+// token.
+func parseConstraint(args *AllocateMachineArgs, rootDisk *[]StorageSpec, token constraintToken) error {
+	var err error
+	switch token.key {
+	case "name":
+		args.Hostname = token.value
+	case "system-id":
+		args.SystemID = token.value
+	case "arch":
+		args.Architecture = token.value
+	case "cores":
+		args.MinCPUCount, err = strconv.Atoi(token.value)
+		if err == nil && args.MinCPUCount < 0 {
+			err = errors.New("negative count")
+		}
+	case "mem":
+		args.MinMemory, err = parseSize(token.value, "M", "M")
+	case "tags":
+		args.Tags, args.NotTags, err = splitNegated(token.value)
+	case "zones":
+		var zones []string
+		zones, args.NotInZone, err = splitNegated(token.value)
+		switch {
+		case err != nil:
+		case len(zones) == 0:
+		case len(zones) == 1:
+			args.Zone = zones[0]
+		default:
+			err = errors.New("only one positive zone allowed")
+		}
+	case "pod":
+		var pods, notPods []string
+		pods, notPods, err = splitNegated(token.value)
+		if err != nil {
+			break
+		}
+		if len(pods) > 1 || len(notPods) > 1 {
+			err = errors.New("only one pod and one negated pod allowed")
+			break
+		}
+		if len(pods) == 1 {
+			args.Pod = pods[0]
+		}
+		if len(notPods) == 1 {
+			args.NotPod = notPods[0]
+		}
+		if args.Pod != "" && args.Pod == args.NotPod {
+			err = errors.New("same pod wanted and not wanted")
+		}
+	case "pod-type":
+		args.PodType = token.value
+	case "spaces":
+		args.Space, args.NotSpace, err = splitNegated(token.value)
+	case "subnets":
+		args.Subnets, args.NotSubnets, err = splitNegated(token.value)
+	case "fabrics":
+		args.Fabrics, args.NotFabrics, err = splitNegated(token.value)
+	case "fabric-classes":
+		args.FabricClasses, err = splitList(token.value, ',')
+	case "root-disk":
+		var size int
+		size, err = parseSize(token.value, "M", "G")
+		*rootDisk = []StorageSpec{{Size: size}}
+		if err == nil && size == 0 {
+			err = errors.New("zero size")
+		}
+	case "storage":
+		args.Storage, err = parseStorageSpecs(token.value)
+	case "interfaces":
+		args.Interfaces, err = parseInterfaceSpecs(token.value)
+	case "devices":
+		args.Devices, err = parseDeviceSpecs(token.value)
+	case "mac":
+		args.MACAddress = token.value
+		if _, macErr := net.ParseMAC(token.value); macErr != nil {
+			err = errors.New("bad MAC address")
+		}
+	case "bridge-all":
+		args.BridgeAll, err = strconv.ParseBool(token.value)
+	case "bridge-stp":
+		args.BridgeSTP, err = strconv.ParseBool(token.value)
+	case "bridge-fd":
+		args.BridgeFD, err = strconv.Atoi(token.value)
+		if err == nil && args.BridgeFD < 0 {
+			err = errors.New("negative delay")
+		}
+	default:
+		err = errors.New("unknown constraint")
+	}
+	return err
+}
+
+// tokenizeConstraints splits the value on whitespace, and each of the
+// resulting tokens on the first equals sign. A token without an equals sign
+// has an empty key.
+func tokenizeConstraints(value string) []constraintToken {
+	var tokens []constraintToken
+	start := -1
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) && !isSpace(value[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		token := constraintToken{pos: start, value: value[start:i]}
+		if eq := strings.Index(token.value, "="); eq > 0 {
+			token.key, token.value = token.value[:eq], token.value[eq+1:]
+		}
+		tokens = append(tokens, token)
+		start = -1
+	}
+	return tokens
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// splitList splits the value on the separator, ignoring separators within
+// parentheses so that storage tags stay with their spec. An empty item, as
+// in "a,,b", is an error.
+func splitList(value string, sep byte) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var result []string
+	depth, start := 0, 0
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) {
+			switch value[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if value[i] != sep || depth > 0 {
+				continue
+			}
+		}
+		if start == i {
+			return nil, errors.Errorf("empty item in %q", value)
+		}
+		result = append(result, value[start:i])
+		start = i + 1
+	}
+	return result, nil
+}
+
+// splitNegated splits a comma separated list into the values and the values
+// that were prefixed with a ^.
+func splitNegated(value string) (positive, negative []string, err error) {
+	values, err := splitList(value, ',')
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	for _, v := range values {
+		if v == "^" {
+			return nil, nil, errors.Errorf("empty item in %q", value)
+		}
+		if strings.HasPrefix(v, "^") {
+			negative = append(negative, v[1:])
+		} else {
+			positive = append(positive, v)
+		}
+	}
+	return positive, negative, nil
+}
+
+// parseSize parses a number with an optional unit suffix, using the
+// defaultUnit if there is no suffix. The result is given in the resultUnit,
+// rounded up.
+func parseSize(value, defaultUnit, resultUnit string) (int, error) {
+	unit := defaultUnit
+	if value != "" {
+		if _, ok := sizeMultipliers[value[len(value)-1:]]; ok {
+			unit = value[len(value)-1:]
+			value = value[:len(value)-1]
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 || math.IsInf(number, 0) || math.IsNaN(number) {
+		return 0, errors.Errorf("bad size %q", value)
+	}
+	size := math.Ceil(number * sizeMultipliers[unit] / sizeMultipliers[resultUnit])
+	if size >= float64(maxInt) {
+		return 0, errors.Errorf("size %q too large", value)
+	}
+	return int(size), nil
+}
+
+// maxInt is the largest value of an int.
+const maxInt = int(^uint(0) >> 1)
+
+// formatSize returns the size, given in the sizeUnit, using the largest unit
+// that represents it exactly.
+func formatSize(size int, sizeUnit string) string {
+	value := float64(size) * sizeMultipliers[sizeUnit]
+	best := "M"
+	for unit, multiplier := range sizeMultipliers {
+		if math.Mod(value, multiplier) == 0 && multiplier > sizeMultipliers[best] {
+			best = unit
+		}
+	}
+	return fmt.Sprintf("%d%s", int64(value/sizeMultipliers[best]), best)
+}
+
+// parseStorageSpecs parses the comma separated output of StorageSpec.String.
+func parseStorageSpecs(value string) ([]StorageSpec, error) {
+	var result []StorageSpec
+	labels := set.NewStrings()
+	items, err := splitList(value, ',')
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, item := range items {
+		var spec StorageSpec
+		if open := strings.Index(item, "("); open >= 0 {
+			if !strings.HasSuffix(item, ")") {
+				return nil, errors.Errorf("missing ) in storage spec %q", item)
+			}
+			if spec.Tags, err = splitList(item[open+1:len(item)-1], ','); err != nil {
+				return nil, errors.Trace(err)
+			}
+			item = item[:open]
+		}
+		if colon := strings.Index(item, ":"); colon >= 0 {
+			spec.Label, item = item[:colon], item[colon+1:]
+		}
+		size, err := parseSize(item, "G", "G")
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if size == 0 {
+			return nil, errors.Errorf("zero size in storage spec %q", item)
+		}
+		if spec.Label != "" && labels.Contains(spec.Label) {
+			return nil, errors.Errorf("reusing storage label %q", spec.Label)
+		}
+		labels.Add(spec.Label)
+		spec.Size = size
+		result = append(result, spec)
+	}
+	return result, nil
+}
+
+// parseInterfaceSpecs parses the semicolon separated output of
+// InterfaceSpec.String.
+func parseInterfaceSpecs(value string) ([]InterfaceSpec, error) {
+	var result []InterfaceSpec
+	labels := set.NewStrings()
+	items, err := splitList(value, ';')
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, item := range items {
+		colon := strings.Index(item, ":")
+		if colon <= 0 {
+			return nil, errors.Errorf("missing label in interface spec %q", item)
+		}
+		spec := InterfaceSpec{Label: item[:colon]}
+		if labels.Contains(spec.Label) {
+			return nil, errors.Errorf("reusing interface label %q", spec.Label)
+		}
+		labels.Add(spec.Label)
+		attrs, err := splitList(item[colon+1:], ',')
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, attr := range attrs {
+			if !strings.HasPrefix(attr, "space=") {
+				return nil, errors.Errorf("unsupported interface constraint %q", attr)
+			}
+			spec.Space = strings.TrimPrefix(attr, "space=")
+		}
+		if spec.Space == "" {
+			return nil, errors.Errorf("missing space in interface spec %q", item)
+		}
+		result = append(result, spec)
+	}
+	return result, nil
+}
+
+// parseDeviceSpecs parses the semicolon separated output of
+// DeviceSpec.String.
+func parseDeviceSpecs(value string) ([]DeviceSpec, error) {
+	var result []DeviceSpec
+	items, err := splitList(value, ';')
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, item := range items {
+		var spec DeviceSpec
+		attrs, err := splitList(item, ',')
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, attr := range attrs {
+			parts := strings.SplitN(attr, "=", 2)
+			if len(parts) != 2 {
+				return nil, errors.Errorf("bad device constraint %q", attr)
+			}
+			switch parts[0] {
+			case "vendor_id":
+				spec.VendorID = parts[1]
+			case "product_id":
+				spec.ProductID = parts[1]
+			case "vendor_name":
+				spec.VendorName = parts[1]
+			case "product_name":
+				spec.ProductName = parts[1]
+			case "commissioning_driver":
+				spec.CommissioningDriver = parts[1]
+			default:
+				return nil, errors.Errorf("unknown device constraint %q", parts[0])
+			}
+		}
+		if err := spec.Validate(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		result = append(result, spec)
+	}
+	return result, nil
+}
+
+// String returns the args in the format accepted by ParseConstraints. The
+// AgentName, Comment and DryRun values are not constraints, and so are not
+// included.
+func (a *AllocateMachineArgs) String() string {
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+value)
+		}
+	}
+	negated := func(positive, negative []string) string {
+		values := append([]string(nil), positive...)
+		for _, v := range negative {
+			values = append(values, "^"+v)
+		}
+		return strings.Join(values, ",")
+	}
+	var zones []string
+	if a.Zone != "" {
+		zones = []string{a.Zone}
+	}
+	var pods, notPods []string
+	if a.Pod != "" {
+		pods = []string{a.Pod}
+	}
+	if a.NotPod != "" {
+		notPods = []string{a.NotPod}
+	}
+
+	add("name", a.Hostname)
+	add("system-id", a.SystemID)
+	add("arch", a.Architecture)
+	if a.MinCPUCount != 0 {
+		add("cores", strconv.Itoa(a.MinCPUCount))
+	}
+	if a.MinMemory != 0 {
+		add("mem", formatSize(a.MinMemory, "M"))
+	}
+	add("tags", negated(a.Tags, a.NotTags))
+	add("zones", negated(zones, a.NotInZone))
+	add("pod", negated(pods, notPods))
+	add("pod-type", a.PodType)
+	add("spaces", negated(a.Space, a.NotSpace))
+	add("subnets", negated(a.Subnets, a.NotSubnets))
+	add("fabrics", negated(a.Fabrics, a.NotFabrics))
+	add("fabric-classes", strings.Join(a.FabricClasses, ","))
+	add("storage", a.storage())
+	add("interfaces", a.interfaces())
+	add("devices", a.devices())
+	add("mac", a.MACAddress)
+	if a.BridgeAll {
+		add("bridge-all", "true")
+	}
+	if a.BridgeSTP {
+		add("bridge-stp", "true")
+	}
+	if a.BridgeFD != 0 {
+		add("bridge-fd", strconv.Itoa(a.BridgeFD))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,229 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type constraintsSuite struct{}
+
+var _ = gc.Suite(&constraintsSuite{})
+
+func (*constraintsSuite) TestParseEmpty(c *gc.C) {
+	args, err := ParseConstraints("  ")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(args, jc.DeepEquals, AllocateMachineArgs{})
+}
+
+func (*constraintsSuite) TestParse(c *gc.C) {
+	args, err := ParseConstraints("arch=amd64 cores=8 mem=32G tags=ssd,^gpu zones=az1 spaces=db,^dmz root-disk=100G")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(args, jc.DeepEquals, AllocateMachineArgs{
+		Architecture: "amd64",
+		MinCPUCount:  8,
+		MinMemory:    32 * 1000,
+		Tags:         []string{"ssd"},
+		NotTags:      []string{"gpu"},
+		Zone:         "az1",
+		Space:        []string{"db"},
+		NotSpace:     []string{"dmz"},
+		Storage:      []StorageSpec{{Size: 100}},
+	})
+}
+
+func (*constraintsSuite) TestParseSizes(c *gc.C) {
+	for i, test := range []struct {
+		value    string
+		memory   int
+		rootDisk int
+	}{
+		{value: "mem=512 root-disk=2048", memory: 512, rootDisk: 3},
+		{value: "mem=512M root-disk=1500M", memory: 512, rootDisk: 2},
+		{value: "mem=1.5G root-disk=1.5G", memory: 1500, rootDisk: 2},
+		{value: "mem=1T root-disk=1T", memory: 1000 * 1000, rootDisk: 1000},
+		{value: "mem=1P root-disk=1P", memory: 1000 * 1000 * 1000, rootDisk: 1000 * 1000},
+	} {
+		c.Logf("test %d: %s", i, test.value)
+		args, err := ParseConstraints(test.value)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(args.MinMemory, gc.Equals, test.memory)
+		c.Check(args.Storage, jc.DeepEquals, []StorageSpec{{Size: test.rootDisk}})
+	}
+}
+
+func (*constraintsSuite) TestParseSpecs(c *gc.C) {
+	args, err := ParseConstraints(
+		"root-disk=20G storage=data:200(ssd,fast),50 " +
+			"interfaces=db:space=db;dmz:space=dmz " +
+			"devices=vendor_id=10de,product_id=1eb8;commissioning_driver=nvme")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(args.Storage, jc.DeepEquals, []StorageSpec{
+		{Size: 20},
+		{Label: "data", Size: 200, Tags: []string{"ssd", "fast"}},
+		{Size: 50},
+	})
+	c.Check(args.Interfaces, jc.DeepEquals, []InterfaceSpec{
+		{Label: "db", Space: "db"},
+		{Label: "dmz", Space: "dmz"},
+	})
+	c.Check(args.Devices, jc.DeepEquals, []DeviceSpec{
+		{VendorID: "10de", ProductID: "1eb8"},
+		{CommissioningDriver: "nvme"},
+	})
+}
+
+func (*constraintsSuite) TestParseErrors(c *gc.C) {
+	for i, test := range []struct {
+		value string
+		err   string
+	}{{
+		value: "arch=amd64 cores",
+		err:   `constraint "cores" at position 11 not valid`,
+	}, {
+		value: "arch=amd64 =foo",
+		err:   `constraint "=foo" at position 11 not valid`,
+	}, {
+		value: "arch=amd64   cores=many",
+		err:   `cores=many at position 13: strconv.Atoi: parsing "many": invalid syntax`,
+	}, {
+		value: "mem=lots",
+		err:   `mem=lots at position 0: bad size "lots"`,
+	}, {
+		value: "cores=1 wibble=2",
+		err:   `wibble=2 at position 8: unknown constraint`,
+	}, {
+		value: "cores=1 cores=2",
+		err:   `repeated constraint "cores" at position 8 not valid`,
+	}, {
+		value: "zones=a,b",
+		err:   `zones=a,b at position 0: only one positive zone allowed`,
+	}, {
+		value: "storage=data:20(ssd",
+		err:   `storage=data:20\(ssd at position 0: missing \) in storage spec "data:20\(ssd"`,
+	}, {
+		value: "interfaces=db:vid=1",
+		err:   `interfaces=db:vid=1 at position 0: unsupported interface constraint "vid=1"`,
+	}, {
+		value: "cores=2 storage=a:20,a:30",
+		err:   `storage=a:20,a:30 at position 8: reusing storage label "a"`,
+	}, {
+		value: "storage=a:0",
+		err:   `storage=a:0 at position 0: zero size in storage spec "0"`,
+	}, {
+		value: "cores=2 root-disk=0",
+		err:   `root-disk=0 at position 8: zero size`,
+	}, {
+		value: "interfaces=a:space=db;a:space=dmz",
+		err:   `interfaces=a:space=db;a:space=dmz at position 0: reusing interface label "a"`,
+	}, {
+		value: "interfaces=:space=db",
+		err:   `interfaces=:space=db at position 0: missing label in interface spec ":space=db"`,
+	}, {
+		value: "interfaces=a:space=",
+		err:   `interfaces=a:space= at position 0: missing space in interface spec "a:space="`,
+	}, {
+		value: "devices=vendor_id=",
+		err:   `devices=vendor_id= at position 0: empty device spec not valid`,
+	}, {
+		value: "pod=a,^a",
+		err:   `pod=a,\^a at position 0: same pod wanted and not wanted`,
+	}, {
+		value: "mac=wat",
+		err:   `mac=wat at position 0: bad MAC address`,
+	}, {
+		value: "cores=2 bridge-stp=true",
+		err:   `bridge-stp=true at position 8: bridge-all not set`,
+	}, {
+		value: "bridge-fd=15",
+		err:   `bridge-fd=15 at position 0: bridge-all not set`,
+	}, {
+		value: "bridge-all=true bridge-fd=-1",
+		err:   `bridge-fd=-1 at position 16: negative delay`,
+	}, {
+		value: "mem=inf",
+		err:   `mem=inf at position 0: bad size "inf"`,
+	}, {
+		value: "mem=NaN",
+		err:   `mem=NaN at position 0: bad size "NaN"`,
+	}, {
+		value: "root-disk=-Inf",
+		err:   `root-disk=-Inf at position 0: bad size "-Inf"`,
+	}, {
+		value: "mem=1e300P",
+		err:   `mem=1e300P at position 0: size "1e300" too large`,
+	}, {
+		value: "tags=a,,b",
+		err:   `tags=a,,b at position 0: empty item in "a,,b"`,
+	}, {
+		value: "spaces=db,",
+		err:   `spaces=db, at position 0: empty item in "db,"`,
+	}, {
+		value: "fabrics=^",
+		err:   `fabrics=\^ at position 0: empty item in "\^"`,
+	}, {
+		value: "storage=data:20(ssd,,fast)",
+		err:   `storage=data:20\(ssd,,fast\) at position 0: empty item in "ssd,,fast"`,
+	}, {
+		value: "interfaces=db:space=db;;dmz:space=dmz",
+		err:   `interfaces=db:space=db;;dmz:space=dmz at position 0: empty item in "db:space=db;;dmz:space=dmz"`,
+	}} {
+		c.Logf("test %d: %s", i, test.value)
+		_, err := ParseConstraints(test.value)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (*constraintsSuite) TestString(c *gc.C) {
+	args := AllocateMachineArgs{
+		Architecture: "amd64",
+		MinCPUCount:  8,
+		MinMemory:    1536,
+		Tags:         []string{"ssd"},
+		NotTags:      []string{"gpu"},
+		Zone:         "az1",
+		NotInZone:    []string{"az2"},
+		Storage:      []StorageSpec{{Size: 100}, {Label: "data", Size: 20, Tags: []string{"a", "b"}}},
+		AgentName:    "not a constraint",
+	}
+	c.Assert(args.String(), gc.Equals,
+		"arch=amd64 cores=8 mem=1536M tags=ssd,^gpu zones=az1,^az2 storage=100,data:20(a,b)")
+}
+
+func (*constraintsSuite) TestRoundTrip(c *gc.C) {
+	args := AllocateMachineArgs{
+		Hostname:      "foo",
+		SystemID:      "4y3ha3",
+		Architecture:  "arm64",
+		MinCPUCount:   4,
+		MinMemory:     32 * 1024,
+		Tags:          []string{"a", "b"},
+		NotTags:       []string{"c"},
+		Zone:          "az1",
+		NotInZone:     []string{"az2", "az3"},
+		Pod:           "pod-1",
+		NotPod:        "pod-2",
+		PodType:       "virsh",
+		Storage:       []StorageSpec{{Label: "root", Size: 100}, {Size: 50, Tags: []string{"ssd"}}},
+		Interfaces:    []InterfaceSpec{{Label: "a", Space: "db"}, {Label: "b", Space: "dmz"}},
+		Space:         []string{"db"},
+		NotSpace:      []string{"dmz"},
+		Subnets:       []string{"cidr:10.0.0.0/24"},
+		NotSubnets:    []string{"vlan:42"},
+		Fabrics:       []string{"fabric-0"},
+		NotFabrics:    []string{"fabric-1"},
+		FabricClasses: []string{"10g", "40g"},
+		Devices:       []DeviceSpec{{VendorID: "10de"}, {ProductName: "gpu"}},
+		MACAddress:    "52:54:00:55:b6:80",
+		BridgeAll:     true,
+		BridgeSTP:     true,
+		BridgeFD:      15,
+	}
+	parsed, err := ParseConstraints(args.String())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(parsed, jc.DeepEquals, args)
+}
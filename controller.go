@@ -8,9 +8,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"regexp"
 	"strings"
 	"sync/atomic"
 
@@ -42,6 +44,10 @@ var (
 type ControllerArgs struct {
 	BaseURL string
 	APIKey  string
+
+	// Transport, if not nil, makes the HTTP requests to the MAAS API, see
+	// Client.Transport.
+	Transport http.RoundTripper
 }
 
 // NewController creates an authenticated client to the MAAS API, and checks
@@ -69,6 +75,7 @@ func NewController(args ControllerArgs) (Controller, error) {
 			// is an unexpected error and return now.
 			return nil, NewUnexpectedError(err)
 		}
+		client.Transport = args.Transport
 		controllerVersion := version.Number{
 			Major: major,
 			Minor: minor,
@@ -147,6 +154,9 @@ func (c *controller) Spaces() ([]Space, error) {
 	}
 	var result []Space
 	for _, space := range spaces {
+		for _, subnet := range space.subnets {
+			subnet.controller = c
+		}
 		result = append(result, space)
 	}
 	return result, nil
@@ -169,6 +179,744 @@ func (c *controller) Zones() ([]Zone, error) {
 	return result, nil
 }
 
+// RackControllers implements Controller.
+func (c *controller) RackControllers() ([]RackController, error) {
+	source, err := c.get("rackcontrollers")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	racks, err := readRackControllers(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	servedVLANs, err := c.rackVLANs()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []RackController
+	for _, r := range racks {
+		r.controller = c
+		r.vlans = servedVLANs[r.systemID]
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// RegionControllers implements Controller.
+func (c *controller) RegionControllers() ([]RegionController, error) {
+	source, err := c.get("regioncontrollers")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	regions, err := readRegionControllers(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	servedVLANs, err := c.rackVLANs()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []RegionController
+	for _, r := range regions {
+		r.controller = c
+		r.vlans = servedVLANs[r.systemID]
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// rackVLANs returns the VLANs of all the fabrics keyed by the system IDs of
+// their primary and secondary racks.
+func (c *controller) rackVLANs() (map[string][]*vlan, error) {
+	source, err := c.get("fabrics")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	fabrics, err := readFabrics(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make(map[string][]*vlan)
+	for _, f := range fabrics {
+		for _, v := range f.vlans {
+			if v.primaryRack != "" {
+				result[v.primaryRack] = append(result[v.primaryRack], v)
+			}
+			if v.secondaryRack != "" {
+				result[v.secondaryRack] = append(result[v.secondaryRack], v)
+			}
+		}
+	}
+	return result, nil
+}
+
+// IPRanges implements Controller.
+func (c *controller) IPRanges() ([]IPRange, error) {
+	ranges, err := c.ipRanges()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []IPRange
+	for _, r := range ranges {
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (c *controller) ipRanges() ([]*ipRange, error) {
+	source, err := c.get("ipranges")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	ranges, err := readIPRanges(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, r := range ranges {
+		r.controller = c
+	}
+	return ranges, nil
+}
+
+// CreateIPRangeArgs is an argument struct for Controller.CreateIPRange.
+type CreateIPRangeArgs struct {
+	// Type is either IPRangeTypeDynamic or IPRangeTypeReserved (required).
+	Type string
+	// StartIP and EndIP are the first and last addresses of the
+	// range (required).
+	StartIP string
+	EndIP   string
+	// Subnet is the subnet the range is in (required).
+	Subnet Subnet
+	// Comment describes the purpose of the range (optional).
+	Comment string
+}
+
+// Validate checks the required fields are set for the arg structure.
+func (a *CreateIPRangeArgs) Validate() error {
+	switch a.Type {
+	case IPRangeTypeDynamic, IPRangeTypeReserved:
+	case "":
+		return errors.NotValidf("missing Type")
+	default:
+		return errors.NotValidf("Type %q", a.Type)
+	}
+	if a.StartIP == "" {
+		return errors.NotValidf("missing StartIP")
+	}
+	if a.EndIP == "" {
+		return errors.NotValidf("missing EndIP")
+	}
+	if a.Subnet == nil {
+		return errors.NotValidf("missing Subnet")
+	}
+	return nil
+}
+
+// CreateIPRange implements Controller.
+func (c *controller) CreateIPRange(args CreateIPRangeArgs) (IPRange, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	existing, err := c.ipRanges()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// There is no range with an ID of zero.
+	if err := validateIPRange(args.StartIP, args.EndIP, args.Subnet, existing, 0); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("type", args.Type)
+	params.Values.Add("start_ip", args.StartIP)
+	params.Values.Add("end_ip", args.EndIP)
+	params.Values.Add("subnet", fmt.Sprint(args.Subnet.ID()))
+	params.MaybeAdd("comment", args.Comment)
+	result, err := c.post("ipranges", "", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+
+	ipRange, err := readIPRange(c.apiVersion, result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ipRange.controller = c
+	return ipRange, nil
+}
+
+// StaticRoutes implements Controller.
+func (c *controller) StaticRoutes() ([]StaticRoute, error) {
+	routes, err := c.staticRoutes()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []StaticRoute
+	for _, r := range routes {
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (c *controller) staticRoutes() ([]*staticRoute, error) {
+	source, err := c.get("static-routes")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	routes, err := readStaticRoutes(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, r := range routes {
+		r.controller = c
+	}
+	return routes, nil
+}
+
+// CreateStaticRouteArgs is an argument struct for Controller.CreateStaticRoute.
+type CreateStaticRouteArgs struct {
+	// Source is the subnet the route applies to (required).
+	Source Subnet
+	// Destination is the subnet to route to (required).
+	Destination Subnet
+	// GatewayIP is the address in the source subnet to route
+	// through (required).
+	GatewayIP string
+	// Metric is the weight of the route. It is always sent, as 0 is
+	// a valid metric.
+	Metric int
+}
+
+// Validate checks the required fields are set for the arg structure.
+func (a *CreateStaticRouteArgs) Validate() error {
+	if a.Source == nil {
+		return errors.NotValidf("missing Source")
+	}
+	if a.Destination == nil {
+		return errors.NotValidf("missing Destination")
+	}
+	if a.GatewayIP == "" {
+		return errors.NotValidf("missing GatewayIP")
+	}
+	return nil
+}
+
+// CreateStaticRoute implements Controller.
+func (c *controller) CreateStaticRoute(args CreateStaticRouteArgs) (StaticRoute, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("source", fmt.Sprint(args.Source.ID()))
+	params.Values.Add("destination", fmt.Sprint(args.Destination.ID()))
+	params.Values.Add("gateway_ip", args.GatewayIP)
+	params.Values.Add("metric", fmt.Sprint(args.Metric))
+	result, err := c.post("static-routes", "", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+
+	route, err := readStaticRoute(c.apiVersion, result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	route.controller = c
+	return route, nil
+}
+
+// GetConfig implements Controller.
+func (c *controller) GetConfig(name string) (interface{}, error) {
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	value, err := c._get("maas", "get_config", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	return value, nil
+}
+
+// SetConfig implements Controller.
+func (c *controller) SetConfig(name string, value interface{}) error {
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	if value != nil {
+		params.Values.Add("value", fmt.Sprint(value))
+	} else {
+		params.Values.Add("value", "")
+	}
+	// The response is empty on success, so it isn't parsed.
+	_, err := c._postRaw("maas", "set_config", params.Values, nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+// DHCPSnippets implements Controller.
+func (c *controller) DHCPSnippets() ([]DHCPSnippet, error) {
+	source, err := c.get("dhcp-snippets")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	snippets, err := readDHCPSnippets(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []DHCPSnippet
+	for _, s := range snippets {
+		s.controller = c
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// CreateDHCPSnippetArgs is an argument struct for Controller.CreateDHCPSnippet.
+// If neither Node nor Subnet is set, the snippet is global.
+type CreateDHCPSnippetArgs struct {
+	// Name of the snippet (required).
+	Name string
+	// Value is the DHCP configuration (required).
+	Value string
+	// Description of the snippet (optional).
+	Description string
+	// Node is the system ID of the node to scope the snippet to (optional).
+	Node string
+	// Subnet to scope the snippet to (optional).
+	Subnet Subnet
+	// Disabled creates the snippet without enabling it.
+	Disabled bool
+}
+
+// Validate checks the required fields are set for the arg structure.
+func (a *CreateDHCPSnippetArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if a.Value == "" {
+		return errors.NotValidf("missing Value")
+	}
+	if a.Node != "" && a.Subnet != nil {
+		return errors.NotValidf("both Node and Subnet")
+	}
+	return nil
+}
+
+// CreateDHCPSnippet implements Controller.
+func (c *controller) CreateDHCPSnippet(args CreateDHCPSnippetArgs) (DHCPSnippet, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("name", args.Name)
+	params.Values.Add("value", args.Value)
+	params.MaybeAdd("description", args.Description)
+	params.MaybeAdd("node", args.Node)
+	if args.Subnet != nil {
+		params.Values.Add("subnet", fmt.Sprint(args.Subnet.ID()))
+	}
+	if args.Node == "" && args.Subnet == nil {
+		params.Values.Add("global_snippet", "true")
+	}
+	if args.Disabled {
+		params.Values.Add("enabled", "false")
+	}
+	result, err := c.post("dhcp-snippets", "", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+
+	snippet, err := readDHCPSnippet(c.apiVersion, result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	snippet.controller = c
+	return snippet, nil
+}
+
+// PackageRepositories implements Controller.
+func (c *controller) PackageRepositories() ([]PackageRepository, error) {
+	source, err := c.get("package-repositories")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	repositories, err := readPackageRepositories(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []PackageRepository
+	for _, r := range repositories {
+		r.controller = c
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// CreatePackageRepositoryArgs is an argument struct for
+// Controller.CreatePackageRepository.
+type CreatePackageRepositoryArgs struct {
+	// Name of the repository (required).
+	Name string
+	// URL of the repository (required).
+	URL string
+	// Distributions, Components and Arches restrict what is used from
+	// the repository (optional).
+	Distributions []string
+	Components    []string
+	Arches        []string
+	// Key is the GPG public key used to verify the repository (optional).
+	Key string
+	// Disabled creates the repository without enabling it.
+	Disabled bool
+}
+
+// Validate checks the required fields are set for the arg structure.
+func (a *CreatePackageRepositoryArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if a.URL == "" {
+		return errors.NotValidf("missing URL")
+	}
+	return nil
+}
+
+// CreatePackageRepository implements Controller.
+func (c *controller) CreatePackageRepository(args CreatePackageRepositoryArgs) (PackageRepository, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("name", args.Name)
+	params.Values.Add("url", args.URL)
+	params.MaybeAdd("distributions", strings.Join(args.Distributions, ","))
+	params.MaybeAdd("components", strings.Join(args.Components, ","))
+	params.MaybeAdd("arches", strings.Join(args.Arches, ","))
+	params.MaybeAdd("key", args.Key)
+	if args.Disabled {
+		params.Values.Add("enabled", "false")
+	}
+	result, err := c.post("package-repositories", "", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+
+	repository, err := readPackageRepository(c.apiVersion, result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	repository.controller = c
+	return repository, nil
+}
+
+// Discoveries implements Controller.
+func (c *controller) Discoveries() ([]Discovery, error) {
+	discoveries, err := c.discoveries()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Discovery
+	for _, d := range discoveries {
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func (c *controller) discoveries() ([]*discovery, error) {
+	source, err := c.get("discovery")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	discoveries, err := readDiscoveries(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, d := range discoveries {
+		d.controller = c
+	}
+	return discoveries, nil
+}
+
+// ClearDiscoveriesArgs is an argument struct for Controller.ClearDiscoveries.
+// Either the MACAddress and IPAddress filters, or the Neighbours, MDNS and
+// All flags may be used, but not both.
+type ClearDiscoveriesArgs struct {
+	// MACAddress and IPAddress clear the discoveries with the given MAC
+	// address, IP address, or both.
+	MACAddress string
+	IPAddress  string
+
+	// Neighbours clears all the discoveries seen with ARP.
+	Neighbours bool
+	// MDNS clears all the discoveries seen with mDNS.
+	MDNS bool
+	// All clears all the discoveries.
+	All bool
+}
+
+// Validate checks the arg structure selects some discoveries.
+func (a *ClearDiscoveriesArgs) Validate() error {
+	filtered := a.MACAddress != "" || a.IPAddress != ""
+	flagged := a.Neighbours || a.MDNS || a.All
+	if filtered && flagged {
+		return errors.NotValidf("MACAddress or IPAddress with Neighbours, MDNS or All")
+	}
+	if !filtered && !flagged {
+		return errors.NotValidf("missing filter")
+	}
+	return nil
+}
+
+// ClearDiscoveries implements Controller.
+func (c *controller) ClearDiscoveries(args ClearDiscoveriesArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.Neighbours || args.MDNS || args.All {
+		params := NewURLParams()
+		params.MaybeAddBool("neighbours", args.Neighbours)
+		params.MaybeAddBool("mdns", args.MDNS)
+		params.MaybeAddBool("all", args.All)
+		return errors.Trace(c.clearDiscoveries("clear", params))
+	}
+	// MAAS needs both the MAC and IP address to clear a discovery, so
+	// match the known discoveries against the filters.
+	discoveries, err := c.discoveries()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, d := range discoveries {
+		if args.MACAddress != "" && args.MACAddress != d.macAddress {
+			continue
+		}
+		if args.IPAddress != "" && args.IPAddress != d.ip {
+			continue
+		}
+		params := NewURLParams()
+		params.Values.Add("mac", d.macAddress)
+		params.Values.Add("ip", d.ip)
+		if err := c.clearDiscoveries("clear_by_mac_and_ip", params); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (c *controller) clearDiscoveries(op string, params *URLParams) error {
+	_, err := c._postRaw("discovery", op, params.Values, nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+// ScanDiscoveriesArgs is an argument struct for Controller.ScanDiscoveries.
+type ScanDiscoveriesArgs struct {
+	// CIDRs limits the scan to the given networks. If empty, all the
+	// networks attached to rack controllers are scanned.
+	CIDRs []string
+	// Force scans networks even if discovery is disabled on them.
+	Force bool
+	// AlwaysUsePing uses ping rather than nmap, even if nmap is installed.
+	AlwaysUsePing bool
+	// Slow scans at a lower rate to reduce network load.
+	Slow bool
+	// Threads is the number of concurrent scans per rack (optional).
+	Threads int
+}
+
+// ScanDiscoveries implements Controller.
+func (c *controller) ScanDiscoveries(args ScanDiscoveriesArgs) error {
+	params := NewURLParams()
+	params.MaybeAddMany("cidr", args.CIDRs)
+	params.MaybeAddBool("force", args.Force)
+	params.MaybeAddBool("always_use_ping", args.AlwaysUsePing)
+	params.MaybeAddBool("slow", args.Slow)
+	params.MaybeAddInt("threads", args.Threads)
+	_, err := c._postRaw("discovery", "scan", params.Values, nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+// Machine implements Controller.
+func (c *controller) Machine(systemID string) (Machine, error) {
+	source, err := c.get("machines/" + systemID)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusNotFound {
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	machine, err := readMachine(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	machine.controller = c
+	return machine, nil
+}
+
+// Device implements Controller.
+func (c *controller) Device(systemID string) (Device, error) {
+	source, err := c.get("devices/" + systemID)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusNotFound {
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	device, err := readDevice(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	device.controller = c
+	return device, nil
+}
+
+// Zone implements Controller.
+func (c *controller) Zone(name string) (Zone, error) {
+	source, err := c.get("zones/" + name)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusNotFound {
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	zone, err := readZone(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return zone, nil
+}
+
+// Fabric implements Controller.
+func (c *controller) Fabric(id int) (Fabric, error) {
+	source, err := c.get(fmt.Sprintf("fabrics/%d", id))
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusNotFound {
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	fabric, err := readFabric(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return fabric, nil
+}
+
+// Space implements Controller.
+func (c *controller) Space(id int) (Space, error) {
+	source, err := c.get(fmt.Sprintf("spaces/%d", id))
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusNotFound {
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	space, err := readSpace(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, subnet := range space.subnets {
+		subnet.controller = c
+	}
+	return space, nil
+}
+
+// Subnet implements Controller.
+func (c *controller) Subnet(id int) (Subnet, error) {
+	source, err := c.get(fmt.Sprintf("subnets/%d", id))
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusNotFound {
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	subnet, err := readSubnet(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	subnet.controller = c
+	return subnet, nil
+}
+
 // DevicesArgs is a argument struct for selecting Devices.
 // Only devices that match the specified criteria are returned.
 type DevicesArgs struct {
@@ -370,8 +1118,55 @@ func (a *InterfaceSpec) String() string {
 	return fmt.Sprintf("%s:space=%s", a.Label, a.Space)
 }
 
+// DeviceSpec represents one element of the devices constraint, matching a
+// machine with a device that has all of the non-empty values.
+type DeviceSpec struct {
+	VendorID            string
+	ProductID           string
+	VendorName          string
+	ProductName         string
+	CommissioningDriver string
+}
+
+// Validate ensures that at least one of the values is set, and that none of
+// them contain the separators used in the constraint.
+func (s *DeviceSpec) Validate() error {
+	values := s.values()
+	if len(values) == 0 {
+		return errors.NotValidf("empty device spec")
+	}
+	for _, v := range values {
+		if strings.ContainsAny(v, ",;") {
+			return errors.NotValidf("device spec value %q", v)
+		}
+	}
+	return nil
+}
+
+func (s *DeviceSpec) values() []string {
+	var values []string
+	add := func(key, value string) {
+		if value != "" {
+			values = append(values, key+"="+value)
+		}
+	}
+	add("vendor_id", s.VendorID)
+	add("product_id", s.ProductID)
+	add("vendor_name", s.VendorName)
+	add("product_name", s.ProductName)
+	add("commissioning_driver", s.CommissioningDriver)
+	return values
+}
+
+// String returns the device spec as MaaS requires it.
+func (s *DeviceSpec) String() string {
+	return strings.Join(s.values(), ",")
+}
+
 // AllocateMachineArgs is an argument struct for passing args into Machine.Allocate.
 type AllocateMachineArgs struct {
+	// SystemID restricts the allocation to the machine with that system ID.
+	SystemID     string
 	Hostname     string
 	Architecture string
 	MinCPUCount  int
@@ -381,22 +1176,47 @@ type AllocateMachineArgs struct {
 	NotTags   []string
 	Zone      string
 	NotInZone []string
+	// Pod and NotPod are the names of pods to allocate from, or not.
+	Pod     string
+	NotPod  string
+	PodType string
 	// Storage represents the required disks on the Machine. If any are specified
 	// the first value is used for the root disk.
 	Storage []StorageSpec
 	// Interfaces represents a number of required interfaces on the machine.
 	// Each InterfaceSpec relates to an individual network interface.
 	Interfaces []InterfaceSpec
-	// NotSpace is a machine level constraint, and applies to the entire machine
-	// rather than specific interfaces.
-	NotSpace  []string
+	// Space and NotSpace are machine level constraints, and apply to the
+	// entire machine rather than specific interfaces.
+	Space    []string
+	NotSpace []string
+	// Subnets and NotSubnets are passed through as subnet specifiers, so
+	// can be given as "cidr:10.0.0.0/24", "vlan:42" or simply the subnet ID.
+	Subnets       []string
+	NotSubnets    []string
+	Fabrics       []string
+	NotFabrics    []string
+	FabricClasses []string
+	// Devices requires the machine to have a device matching each of the
+	// specs.
+	Devices []DeviceSpec
+	// MACAddress restricts the allocation to the machine with an interface
+	// with that MAC address.
+	MACAddress string
+	// BridgeAll creates a bridge for every interface configured on the
+	// machine when it is deployed. BridgeSTP and BridgeFD (the forward delay
+	// in seconds) configure those bridges, and so require BridgeAll.
+	BridgeAll bool
+	BridgeSTP bool
+	BridgeFD  int
 	AgentName string
 	Comment   string
 	DryRun    bool
 }
 
 // Validate makes sure that any labels specifed in Storage or Interfaces
-// are unique, and that the required specifications are valid.
+// are unique, that the required specifications are valid, and that the
+// bridge options are only given along with BridgeAll.
 func (a *AllocateMachineArgs) Validate() error {
 	storageLabels := set.NewStrings()
 	for _, spec := range a.Storage {
@@ -420,11 +1240,43 @@ func (a *AllocateMachineArgs) Validate() error {
 		}
 		interfaceLabels.Add(spec.Label)
 	}
-	for _, v := range a.NotSpace {
-		if v == "" {
-			return errors.NotValidf("empty NotSpace constraint")
+	for _, constraint := range []struct {
+		name   string
+		values []string
+	}{
+		{"Space", a.Space},
+		{"NotSpace", a.NotSpace},
+		{"Subnets", a.Subnets},
+		{"NotSubnets", a.NotSubnets},
+		{"Fabrics", a.Fabrics},
+		{"NotFabrics", a.NotFabrics},
+		{"FabricClasses", a.FabricClasses},
+	} {
+		for _, v := range constraint.values {
+			if v == "" {
+				return errors.NotValidf("empty %s constraint", constraint.name)
+			}
+		}
+	}
+	if a.Pod != "" && a.Pod == a.NotPod {
+		return errors.NotValidf("Pod and NotPod both %q", a.Pod)
+	}
+	for _, spec := range a.Devices {
+		if err := spec.Validate(); err != nil {
+			return errors.Annotate(err, "Devices")
 		}
 	}
+	if a.MACAddress != "" {
+		if _, err := net.ParseMAC(a.MACAddress); err != nil {
+			return errors.NotValidf("MACAddress %q", a.MACAddress)
+		}
+	}
+	if !a.BridgeAll && (a.BridgeSTP || a.BridgeFD != 0) {
+		return errors.NotValidf("BridgeSTP or BridgeFD without BridgeAll")
+	}
+	if a.BridgeFD < 0 {
+		return errors.NotValidf("BridgeFD value %d", a.BridgeFD)
+	}
 	return nil
 }
 
@@ -444,12 +1296,28 @@ func (a *AllocateMachineArgs) interfaces() string {
 	return strings.Join(values, ";")
 }
 
+func (a *AllocateMachineArgs) subnets() []string {
+	var values []string
+	for _, v := range a.Space {
+		values = append(values, "space:"+v)
+	}
+	return append(values, a.Subnets...)
+}
+
 func (a *AllocateMachineArgs) notSubnets() []string {
 	var values []string
 	for _, v := range a.NotSpace {
 		values = append(values, "space:"+v)
 	}
-	return values
+	return append(values, a.NotSubnets...)
+}
+
+func (a *AllocateMachineArgs) devices() string {
+	var values []string
+	for _, spec := range a.Devices {
+		values = append(values, spec.String())
+	}
+	return strings.Join(values, ";")
 }
 
 // ConstraintMatches provides a way for the caller of AllocateMachine to determine
@@ -472,15 +1340,28 @@ type ConstraintMatches struct {
 func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, ConstraintMatches, error) {
 	var matches ConstraintMatches
 	params := NewURLParams()
+	params.MaybeAdd("system_id", args.SystemID)
 	params.MaybeAdd("name", args.Hostname)
 	params.MaybeAdd("arch", args.Architecture)
 	params.MaybeAddInt("cpu_count", args.MinCPUCount)
 	params.MaybeAddInt("mem", args.MinMemory)
 	params.MaybeAddMany("tags", args.Tags)
 	params.MaybeAddMany("not_tags", args.NotTags)
+	params.MaybeAdd("pod", args.Pod)
+	params.MaybeAdd("not_pod", args.NotPod)
+	params.MaybeAdd("pod_type", args.PodType)
 	params.MaybeAdd("storage", args.storage())
 	params.MaybeAdd("interfaces", args.interfaces())
+	params.MaybeAddMany("subnets", args.subnets())
 	params.MaybeAddMany("not_subnets", args.notSubnets())
+	params.MaybeAddMany("fabrics", args.Fabrics)
+	params.MaybeAddMany("not_fabrics", args.NotFabrics)
+	params.MaybeAddMany("fabric_classes", args.FabricClasses)
+	params.MaybeAdd("devices", args.devices())
+	params.MaybeAdd("mac_address", args.MACAddress)
+	params.MaybeAddBool("bridge_all", args.BridgeAll)
+	params.MaybeAddBool("bridge_stp", args.BridgeSTP)
+	params.MaybeAddInt("bridge_fd", args.BridgeFD)
 	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAddMany("not_in_zone", args.NotInZone)
 	params.MaybeAdd("agent_name", args.AgentName)
@@ -514,32 +1395,80 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 }
 
 // ReleaseMachinesArgs is an argument struct for passing the machine system IDs
-// and an optional comment into the ReleaseMachines method.
+// and an optional comment into the ReleaseMachines method. The erase options
+// are described on ReleaseArgs.
 type ReleaseMachinesArgs struct {
-	SystemIDs []string
-	Comment   string
+	SystemIDs   []string
+	Comment     string
+	Erase       bool
+	SecureErase bool
+	QuickErase  bool
+	Force       bool
+}
+
+// Validate checks that the erase options are only used along with Erase.
+func (a *ReleaseMachinesArgs) Validate() error {
+	return validateEraseArgs(a.Erase, a.SecureErase, a.QuickErase)
+}
+
+func validateEraseArgs(erase, secureErase, quickErase bool) error {
+	if !erase && (secureErase || quickErase) {
+		return errors.NotValidf("SecureErase or QuickErase without Erase")
+	}
+	return nil
 }
 
+func addReleaseParams(params *URLParams, comment string, erase, secureErase, quickErase, force bool) {
+	params.MaybeAdd("comment", comment)
+	params.MaybeAddBool("erase", erase)
+	params.MaybeAddBool("secure_erase", secureErase)
+	params.MaybeAddBool("quick_erase", quickErase)
+	params.MaybeAddBool("force", force)
+}
+
+var (
+	// MAAS lists the machines it could not release as "system-id ('Status')".
+	releaseFailedRE = regexp.MustCompile(`([\w-]+) \('([^']*)'\)`)
+	// MAAS lists the machines it does not know as "Unknown machine(s): a, b."
+	releaseUnknownRE = regexp.MustCompile(`^Unknown machine\(s\): (.*?)\.?$`)
+)
+
 // ReleaseMachines implements Controller.
 //
 // Release multiple machines at once. Returns
+//  - NotValid if the args are not valid
 //  - BadRequestError if any of the machines cannot be found
 //  - PermissionError if the user does not have permission to release any of the machines
 //  - CannotCompleteError if any of the machines could not be released due to their current state
+// None of the machines are released if any of them cannot be. When MAAS
+// names the machines that are unknown or in a state that cannot be released,
+// the BadRequestError or CannotCompleteError is also a ReleaseMachinesError,
+// see ReleaseMachinesFailures.
 func (c *controller) ReleaseMachines(args ReleaseMachinesArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
 	params := NewURLParams()
 	params.MaybeAddMany("machines", args.SystemIDs)
-	params.MaybeAdd("comment", args.Comment)
+	addReleaseParams(params, args.Comment, args.Erase, args.SecureErase, args.QuickErase, args.Force)
 	_, err := c.post("machines", "release", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
 			switch svrErr.StatusCode {
 			case http.StatusBadRequest:
-				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+				err = errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+				if failed := unknownReleaseMachines(svrErr.BodyMessage); len(failed) > 0 {
+					return NewReleaseMachinesError(err, failed)
+				}
+				return err
 			case http.StatusForbidden:
 				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
 			case http.StatusConflict:
-				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+				err = errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+				if failed := failedReleaseMachines(svrErr.BodyMessage); len(failed) > 0 {
+					return NewReleaseMachinesError(err, failed)
+				}
+				return err
 			}
 		}
 		return NewUnexpectedError(err)
@@ -548,6 +1477,34 @@ func (c *controller) ReleaseMachines(args ReleaseMachinesArgs) error {
 	return nil
 }
 
+func unknownReleaseMachines(message string) map[string]error {
+	match := releaseUnknownRE.FindStringSubmatch(strings.TrimSpace(message))
+	if match == nil {
+		return nil
+	}
+	failed := make(map[string]error)
+	for _, systemID := range strings.Split(match[1], ",") {
+		systemID = strings.TrimSpace(systemID)
+		if systemID != "" {
+			failed[systemID] = NewNoMatchError(fmt.Sprintf("machine %q not found", systemID))
+		}
+	}
+	return failed
+}
+
+func failedReleaseMachines(message string) map[string]error {
+	matches := releaseFailedRE.FindAllStringSubmatch(message, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	failed := make(map[string]error)
+	for _, match := range matches {
+		failed[match[1]] = NewCannotCompleteError(fmt.Sprintf(
+			"machine %q cannot be released in state %q", match[1], match[2]))
+	}
+	return failed
+}
+
 // Files implements Controller.
 func (c *controller) Files(prefix string) ([]File, error) {
 	params := NewURLParams()
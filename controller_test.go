@@ -44,11 +44,20 @@ func (s *controllerSuite) SetUpTest(c *gc.C) {
 	server := NewSimpleServer()
 	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
 	server.AddGetResponse("/api/2.0/devices/", http.StatusOK, devicesResponse)
+	server.AddGetResponse("/api/2.0/dhcp-snippets/", http.StatusOK, dhcpSnippetsResponse)
+	server.AddGetResponse("/api/2.0/discovery/", http.StatusOK, discoveriesResponse)
 	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, fabricResponse)
 	server.AddGetResponse("/api/2.0/files/", http.StatusOK, filesResponse)
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, ipRangesResponse)
+	// CreateIPRange reads the existing ranges before creating a new one.
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, ipRangesResponse)
 	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
 	server.AddGetResponse("/api/2.0/machines/?hostname=untasted-markita", http.StatusOK, "["+machineResponse+"]")
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, rackControllersResponse)
+	server.AddGetResponse("/api/2.0/regioncontrollers/", http.StatusOK, regionControllersResponse)
+	server.AddGetResponse("/api/2.0/package-repositories/", http.StatusOK, packageRepositoriesResponse)
 	server.AddGetResponse("/api/2.0/spaces/", http.StatusOK, spacesResponse)
+	server.AddGetResponse("/api/2.0/static-routes/", http.StatusOK, staticRoutesResponse)
 	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
 	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
 	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
@@ -215,6 +224,572 @@ func (s *controllerSuite) TestFabrics(c *gc.C) {
 	c.Assert(fabrics, gc.HasLen, 2)
 }
 
+func (s *controllerSuite) TestRackControllers(c *gc.C) {
+	controller := s.getController(c)
+	racks, err := controller.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+	c.Assert(racks[0].VLANs(), gc.HasLen, 1)
+}
+
+func (s *controllerSuite) TestRegionControllers(c *gc.C) {
+	controller := s.getController(c)
+	regions, err := controller.RegionControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(regions, gc.HasLen, 1)
+	c.Assert(regions[0].VLANs(), gc.HasLen, 1)
+}
+
+func (s *controllerSuite) TestIPRanges(c *gc.C) {
+	controller := s.getController(c)
+	ranges, err := controller.IPRanges()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ranges, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) ipRangeSubnet(c *gc.C) Subnet {
+	subnets, err := readSubnets(twoDotOh, parseJSON(c, subnetResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	return subnets[0]
+}
+
+func (s *controllerSuite) TestCreateIPRangeArgsValidate(c *gc.C) {
+	subnet := s.ipRangeSubnet(c)
+	for i, test := range []struct {
+		args    CreateIPRangeArgs
+		errText string
+	}{{
+		errText: "missing Type not valid",
+	}, {
+		args:    CreateIPRangeArgs{Type: "wat"},
+		errText: `Type "wat" not valid`,
+	}, {
+		args:    CreateIPRangeArgs{Type: IPRangeTypeDynamic},
+		errText: "missing StartIP not valid",
+	}, {
+		args:    CreateIPRangeArgs{Type: IPRangeTypeDynamic, StartIP: "192.168.100.200"},
+		errText: "missing EndIP not valid",
+	}, {
+		args:    CreateIPRangeArgs{Type: IPRangeTypeDynamic, StartIP: "192.168.100.200", EndIP: "192.168.100.250"},
+		errText: "missing Subnet not valid",
+	}, {
+		args: CreateIPRangeArgs{Type: IPRangeTypeReserved, StartIP: "192.168.100.200", EndIP: "192.168.100.250", Subnet: subnet},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *controllerSuite) TestCreateIPRange(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/ipranges/?op=", http.StatusOK, ipRangeResponse)
+	controller := s.getController(c)
+	r, err := controller.CreateIPRange(CreateIPRangeArgs{
+		Type:    IPRangeTypeDynamic,
+		StartIP: "192.168.100.200",
+		EndIP:   "192.168.100.250",
+		Subnet:  s.ipRangeSubnet(c),
+		Comment: "rack 2 pool",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(r.ID(), gc.Equals, 1)
+
+	request := s.server.LastRequest()
+	form := request.PostForm
+	c.Check(form.Get("type"), gc.Equals, "dynamic")
+	c.Check(form.Get("start_ip"), gc.Equals, "192.168.100.200")
+	c.Check(form.Get("end_ip"), gc.Equals, "192.168.100.250")
+	c.Check(form.Get("subnet"), gc.Equals, "1")
+	c.Check(form.Get("comment"), gc.Equals, "rack 2 pool")
+}
+
+func (s *controllerSuite) TestCreateIPRangeOverlaps(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.CreateIPRange(CreateIPRangeArgs{
+		Type:    IPRangeTypeReserved,
+		StartIP: "192.168.100.150",
+		EndIP:   "192.168.100.250",
+		Subnet:  s.ipRangeSubnet(c),
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, "range .* overlaps dynamic range .*")
+}
+
+func (s *controllerSuite) TestCreateIPRangeOutsideSubnet(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.CreateIPRange(CreateIPRangeArgs{
+		Type:    IPRangeTypeReserved,
+		StartIP: "10.0.0.1",
+		EndIP:   "10.0.0.9",
+		Subnet:  s.ipRangeSubnet(c),
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, "range .* outside subnet 192.168.100.0/24 not valid")
+}
+
+func (s *controllerSuite) TestCreateIPRangeBadRequest(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/ipranges/?op=", http.StatusBadRequest, "no good")
+	controller := s.getController(c)
+	_, err := controller.CreateIPRange(CreateIPRangeArgs{
+		Type:    IPRangeTypeReserved,
+		StartIP: "192.168.100.200",
+		EndIP:   "192.168.100.250",
+		Subnet:  s.ipRangeSubnet(c),
+	})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "no good")
+}
+
+func (s *controllerSuite) TestStaticRoutes(c *gc.C) {
+	controller := s.getController(c)
+	routes, err := controller.StaticRoutes()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(routes, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) TestCreateStaticRouteArgsValidate(c *gc.C) {
+	subnet := s.ipRangeSubnet(c)
+	for i, test := range []struct {
+		args    CreateStaticRouteArgs
+		errText string
+	}{{
+		errText: "missing Source not valid",
+	}, {
+		args:    CreateStaticRouteArgs{Source: subnet},
+		errText: "missing Destination not valid",
+	}, {
+		args:    CreateStaticRouteArgs{Source: subnet, Destination: subnet},
+		errText: "missing GatewayIP not valid",
+	}, {
+		args: CreateStaticRouteArgs{Source: subnet, Destination: subnet, GatewayIP: "192.168.100.254"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *controllerSuite) TestCreateStaticRoute(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/static-routes/?op=", http.StatusOK, staticRouteResponse)
+	controller := s.getController(c)
+	subnets, err := readSubnets(twoDotOh, parseJSON(c, subnetResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	route, err := controller.CreateStaticRoute(CreateStaticRouteArgs{
+		Source:      subnets[0],
+		Destination: subnets[1],
+		GatewayIP:   "192.168.100.254",
+		Metric:      10,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(route.ID(), gc.Equals, 1)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("source"), gc.Equals, "1")
+	c.Check(form.Get("destination"), gc.Equals, "34")
+	c.Check(form.Get("gateway_ip"), gc.Equals, "192.168.100.254")
+	c.Check(form.Get("metric"), gc.Equals, "10")
+}
+
+func (s *controllerSuite) TestCreateStaticRouteZeroMetric(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/static-routes/?op=", http.StatusOK, staticRouteResponse)
+	controller := s.getController(c)
+	subnets, err := readSubnets(twoDotOh, parseJSON(c, subnetResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.CreateStaticRoute(CreateStaticRouteArgs{
+		Source:      subnets[0],
+		Destination: subnets[1],
+		GatewayIP:   "192.168.100.254",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form["metric"], jc.DeepEquals, []string{"0"})
+}
+
+func (s *controllerSuite) TestCreateStaticRouteBadRequest(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/static-routes/?op=", http.StatusBadRequest, "no good")
+	controller := s.getController(c)
+	subnet := s.ipRangeSubnet(c)
+	_, err := controller.CreateStaticRoute(CreateStaticRouteArgs{
+		Source:      subnet,
+		Destination: subnet,
+		GatewayIP:   "192.168.100.254",
+	})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "no good")
+}
+
+func (s *controllerSuite) TestGetConfig(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=default_distro_series&op=get_config", http.StatusOK, `"xenial"`)
+	s.server.AddGetResponse("/api/2.0/maas/?name=enable_http_proxy&op=get_config", http.StatusOK, `true`)
+	controller := s.getController(c)
+	value, err := controller.GetConfig("default_distro_series")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(value, gc.Equals, "xenial")
+	value, err = controller.GetConfig("enable_http_proxy")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(value, gc.Equals, true)
+}
+
+func (s *controllerSuite) TestGetConfigBadName(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=wat&op=get_config", http.StatusBadRequest, "wat is not a valid config name")
+	controller := s.getController(c)
+	_, err := controller.GetConfig("wat")
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "wat is not a valid config name")
+}
+
+func (s *controllerSuite) TestSetConfig(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusOK, "")
+	controller := s.getController(c)
+	err := controller.SetConfig("enable_disk_erasing_on_release", true)
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "enable_disk_erasing_on_release")
+	c.Check(form.Get("value"), gc.Equals, "true")
+}
+
+func (s *controllerSuite) TestSetConfigForbidden(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusForbidden, "admin only")
+	controller := s.getController(c)
+	err := controller.SetConfig("kernel_opts", nil)
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "admin only")
+}
+
+func (s *controllerSuite) TestDHCPSnippets(c *gc.C) {
+	controller := s.getController(c)
+	snippets, err := controller.DHCPSnippets()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(snippets, gc.HasLen, 3)
+}
+
+func (s *controllerSuite) TestCreateDHCPSnippetArgsValidate(c *gc.C) {
+	subnet := s.ipRangeSubnet(c)
+	for i, test := range []struct {
+		args    CreateDHCPSnippetArgs
+		errText string
+	}{{
+		errText: "missing Name not valid",
+	}, {
+		args:    CreateDHCPSnippetArgs{Name: "ipxe"},
+		errText: "missing Value not valid",
+	}, {
+		args:    CreateDHCPSnippetArgs{Name: "ipxe", Value: "option", Node: "4y3ha3", Subnet: subnet},
+		errText: "both Node and Subnet not valid",
+	}, {
+		args: CreateDHCPSnippetArgs{Name: "ipxe", Value: "option", Subnet: subnet},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *controllerSuite) TestCreateDHCPSnippetGlobal(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/dhcp-snippets/?op=", http.StatusOK, dhcpSnippetResponse)
+	controller := s.getController(c)
+	snippet, err := controller.CreateDHCPSnippet(CreateDHCPSnippetArgs{
+		Name:        "ipxe",
+		Value:       "option ipxe.no-pxedhcp 1;",
+		Description: "chain load ipxe",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(snippet.ID(), gc.Equals, 1)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "ipxe")
+	c.Check(form.Get("value"), gc.Equals, "option ipxe.no-pxedhcp 1;")
+	c.Check(form.Get("description"), gc.Equals, "chain load ipxe")
+	c.Check(form.Get("global_snippet"), gc.Equals, "true")
+	c.Check(form.Get("enabled"), gc.Equals, "")
+}
+
+func (s *controllerSuite) TestCreateDHCPSnippetSubnet(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/dhcp-snippets/?op=", http.StatusOK, dhcpSnippetResponse)
+	controller := s.getController(c)
+	_, err := controller.CreateDHCPSnippet(CreateDHCPSnippetArgs{
+		Name:     "ipxe",
+		Value:    "option ipxe.no-pxedhcp 1;",
+		Subnet:   s.ipRangeSubnet(c),
+		Disabled: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("subnet"), gc.Equals, "1")
+	c.Check(form.Get("global_snippet"), gc.Equals, "")
+	c.Check(form.Get("enabled"), gc.Equals, "false")
+}
+
+func (s *controllerSuite) TestCreateDHCPSnippetBadRequest(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/dhcp-snippets/?op=", http.StatusBadRequest, "bad snippet")
+	controller := s.getController(c)
+	_, err := controller.CreateDHCPSnippet(CreateDHCPSnippetArgs{Name: "ipxe", Value: "wat"})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "bad snippet")
+}
+
+func (s *controllerSuite) TestPackageRepositories(c *gc.C) {
+	controller := s.getController(c)
+	repositories, err := controller.PackageRepositories()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(repositories, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) TestCreatePackageRepositoryArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    CreatePackageRepositoryArgs
+		errText string
+	}{{
+		errText: "missing Name not valid",
+	}, {
+		args:    CreatePackageRepositoryArgs{Name: "mirror"},
+		errText: "missing URL not valid",
+	}, {
+		args: CreatePackageRepositoryArgs{Name: "mirror", URL: "http://mirror.internal/ubuntu"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *controllerSuite) TestCreatePackageRepository(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/package-repositories/?op=", http.StatusOK, packageRepositoryResponse)
+	controller := s.getController(c)
+	repository, err := controller.CreatePackageRepository(CreatePackageRepositoryArgs{
+		Name:          "mirror",
+		URL:           "http://mirror.internal/ubuntu",
+		Distributions: []string{"xenial", "trusty"},
+		Components:    []string{"main"},
+		Arches:        []string{"amd64"},
+		Key:           "some key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(repository.ID(), gc.Equals, 1)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "mirror")
+	c.Check(form.Get("url"), gc.Equals, "http://mirror.internal/ubuntu")
+	c.Check(form.Get("distributions"), gc.Equals, "xenial,trusty")
+	c.Check(form.Get("components"), gc.Equals, "main")
+	c.Check(form.Get("arches"), gc.Equals, "amd64")
+	c.Check(form.Get("key"), gc.Equals, "some key")
+	c.Check(form.Get("enabled"), gc.Equals, "")
+}
+
+func (s *controllerSuite) TestCreatePackageRepositoryForbidden(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/package-repositories/?op=", http.StatusForbidden, "admin only")
+	controller := s.getController(c)
+	_, err := controller.CreatePackageRepository(CreatePackageRepositoryArgs{Name: "mirror", URL: "http://mirror"})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "admin only")
+}
+
+func (s *controllerSuite) TestDiscoveries(c *gc.C) {
+	controller := s.getController(c)
+	discoveries, err := controller.Discoveries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(discoveries, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) TestClearDiscoveriesArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    ClearDiscoveriesArgs
+		errText string
+	}{{
+		errText: "missing filter not valid",
+	}, {
+		args:    ClearDiscoveriesArgs{MACAddress: "52:54:00:9a:13:d6", All: true},
+		errText: "MACAddress or IPAddress with Neighbours, MDNS or All not valid",
+	}, {
+		args: ClearDiscoveriesArgs{IPAddress: "192.168.100.50"},
+	}, {
+		args: ClearDiscoveriesArgs{Neighbours: true, MDNS: true},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *controllerSuite) TestClearDiscoveriesFlags(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/discovery/?op=clear", http.StatusNoContent, "")
+	controller := s.getController(c)
+	err := controller.ClearDiscoveries(ClearDiscoveriesArgs{MDNS: true})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("mdns"), gc.Equals, "true")
+}
+
+func (s *controllerSuite) TestClearDiscoveriesByMAC(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/discovery/?op=clear_by_mac_and_ip", http.StatusNoContent, "")
+	controller := s.getController(c)
+	err := controller.ClearDiscoveries(ClearDiscoveriesArgs{MACAddress: "52:54:00:9a:13:d7"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("mac"), gc.Equals, "52:54:00:9a:13:d7")
+	c.Check(form.Get("ip"), gc.Equals, "192.168.100.51")
+}
+
+func (s *controllerSuite) TestClearDiscoveriesNoMatch(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.ClearDiscoveries(ClearDiscoveriesArgs{IPAddress: "10.0.0.1"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.LastRequest().Method, gc.Equals, "GET")
+}
+
+func (s *controllerSuite) TestClearDiscoveriesForbidden(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/discovery/?op=clear", http.StatusForbidden, "admin only")
+	controller := s.getController(c)
+	err := controller.ClearDiscoveries(ClearDiscoveriesArgs{All: true})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "admin only")
+}
+
+func (s *controllerSuite) TestScanDiscoveries(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/discovery/?op=scan", http.StatusOK, `{"result": "Scanning 2 networks."}`)
+	controller := s.getController(c)
+	err := controller.ScanDiscoveries(ScanDiscoveriesArgs{
+		CIDRs:   []string{"192.168.100.0/24", "10.0.0.0/24"},
+		Slow:    true,
+		Threads: 4,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form["cidr"], jc.DeepEquals, []string{"192.168.100.0/24", "10.0.0.0/24"})
+	c.Check(form.Get("slow"), gc.Equals, "true")
+	c.Check(form.Get("threads"), gc.Equals, "4")
+	c.Check(form.Get("force"), gc.Equals, "")
+}
+
+func (s *controllerSuite) TestScanDiscoveriesBadRequest(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/discovery/?op=scan", http.StatusBadRequest, "bad cidr")
+	controller := s.getController(c)
+	err := controller.ScanDiscoveries(ScanDiscoveriesArgs{CIDRs: []string{"wat"}})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "bad cidr")
+}
+
+func (s *controllerSuite) TestMachine(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/4y3ha3/", http.StatusOK, machineResponse)
+	controller := s.getController(c)
+	result, err := controller.Machine("4y3ha3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.SystemID(), gc.Equals, "4y3ha3")
+	c.Assert(result.(*machine).controller, gc.NotNil)
+}
+
+func (s *controllerSuite) TestMachineNotFound(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Machine("missing")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *controllerSuite) TestDevice(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/devices/4y3haf/", http.StatusOK, deviceResponse)
+	controller := s.getController(c)
+	result, err := controller.Device("4y3haf")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.SystemID(), gc.Equals, "4y3haf")
+	c.Assert(result.(*device).controller, gc.NotNil)
+}
+
+func (s *controllerSuite) TestDeviceNotFound(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Device("missing")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *controllerSuite) TestZone(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/zones/default/", http.StatusOK, firstJSONElement(c, zoneResponse))
+	controller := s.getController(c)
+	zone, err := controller.Zone("default")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zone.Name(), gc.Equals, "default")
+}
+
+func (s *controllerSuite) TestZoneNotFound(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Zone("missing")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *controllerSuite) TestFabric(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/fabrics/0/", http.StatusOK, firstJSONElement(c, fabricResponse))
+	controller := s.getController(c)
+	fabric, err := controller.Fabric(0)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fabric.Name(), gc.Equals, "fabric-0")
+}
+
+func (s *controllerSuite) TestFabricNotFound(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Fabric(42)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *controllerSuite) TestSpace(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/spaces/0/", http.StatusOK, firstJSONElement(c, spacesResponse))
+	controller := s.getController(c)
+	space, err := controller.Space(0)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(space.Name(), gc.Equals, "space-0")
+	c.Assert(space.Subnets()[0].(*subnet).controller, gc.NotNil)
+}
+
+func (s *controllerSuite) TestSpaceNotFound(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Space(42)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *controllerSuite) TestSubnet(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/1/", http.StatusOK, firstJSONElement(c, subnetResponse))
+	controller := s.getController(c)
+	result, err := controller.Subnet(1)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.CIDR(), gc.Equals, "192.168.100.0/24")
+	c.Assert(result.(*subnet).controller, gc.NotNil)
+}
+
+func (s *controllerSuite) TestSubnetNotFound(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Subnet(42)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
 func (s *controllerSuite) TestSpaces(c *gc.C) {
 	controller := s.getController(c)
 	spaces, err := controller.Spaces()
@@ -374,7 +949,9 @@ func (s *controllerSuite) TestAllocateMachineArgs(c *gc.C) {
 		err        string
 		storage    string
 		interfaces string
+		subnets    []string
 		notSubnets []string
+		devices    string
 	}{{
 		args: AllocateMachineArgs{},
 	}, {
@@ -431,6 +1008,83 @@ func (s *controllerSuite) TestAllocateMachineArgs(c *gc.C) {
 			NotSpace: []string{"foo", "bar"},
 		},
 		notSubnets: []string{"space:foo", "space:bar"},
+	}, {
+		args: AllocateMachineArgs{
+			Space:   []string{"foo"},
+			Subnets: []string{"cidr:10.0.0.0/24"},
+		},
+		subnets: []string{"space:foo", "cidr:10.0.0.0/24"},
+	}, {
+		args: AllocateMachineArgs{
+			NotSpace:   []string{"foo"},
+			NotSubnets: []string{"vlan:42"},
+		},
+		notSubnets: []string{"space:foo", "vlan:42"},
+	}, {
+		args: AllocateMachineArgs{
+			Space: []string{""},
+		},
+		err: "empty Space constraint not valid",
+	}, {
+		args: AllocateMachineArgs{
+			Fabrics: []string{"fabric-0", ""},
+		},
+		err: "empty Fabrics constraint not valid",
+	}, {
+		args: AllocateMachineArgs{
+			FabricClasses: []string{""},
+		},
+		err: "empty FabricClasses constraint not valid",
+	}, {
+		args: AllocateMachineArgs{
+			Pod:    "pod-1",
+			NotPod: "pod-1",
+		},
+		err: `Pod and NotPod both "pod-1" not valid`,
+	}, {
+		args: AllocateMachineArgs{
+			Devices: []DeviceSpec{{}},
+		},
+		err: "Devices: empty device spec not valid",
+	}, {
+		args: AllocateMachineArgs{
+			Devices: []DeviceSpec{{VendorName: "a,b"}},
+		},
+		err: `Devices: device spec value "vendor_name=a,b" not valid`,
+	}, {
+		args: AllocateMachineArgs{
+			Devices: []DeviceSpec{
+				{VendorID: "10de", ProductID: "1eb8"},
+				{CommissioningDriver: "nvme"},
+			},
+		},
+		devices: "vendor_id=10de,product_id=1eb8;commissioning_driver=nvme",
+	}, {
+		args: AllocateMachineArgs{
+			MACAddress: "not-a-mac",
+		},
+		err: `MACAddress "not-a-mac" not valid`,
+	}, {
+		args: AllocateMachineArgs{
+			MACAddress: "52:54:00:55:b6:80",
+		},
+	}, {
+		args: AllocateMachineArgs{
+			BridgeSTP: true,
+		},
+		err: "BridgeSTP or BridgeFD without BridgeAll not valid",
+	}, {
+		args: AllocateMachineArgs{
+			BridgeAll: true,
+			BridgeFD:  -1,
+		},
+		err: "BridgeFD value -1 not valid",
+	}, {
+		args: AllocateMachineArgs{
+			BridgeAll: true,
+			BridgeSTP: true,
+			BridgeFD:  15,
+		},
 	}} {
 		c.Logf("test %d", i)
 		err := test.args.Validate()
@@ -438,7 +1092,9 @@ func (s *controllerSuite) TestAllocateMachineArgs(c *gc.C) {
 			c.Check(err, jc.ErrorIsNil)
 			c.Check(test.args.storage(), gc.Equals, test.storage)
 			c.Check(test.args.interfaces(), gc.Equals, test.interfaces)
+			c.Check(test.args.subnets(), jc.DeepEquals, test.subnets)
 			c.Check(test.args.notSubnets(), jc.DeepEquals, test.notSubnets)
+			c.Check(test.args.devices(), gc.Equals, test.devices)
 		} else {
 			c.Check(err, jc.Satisfies, errors.IsNotValid)
 			c.Check(err.Error(), gc.Equals, test.err)
@@ -576,6 +1232,45 @@ func (s *controllerSuite) TestAllocateMachineArgsForm(c *gc.C) {
 	c.Assert(form.Get("not_subnets"), gc.Equals, "space:special")
 }
 
+func (s *controllerSuite) TestAllocateMachineArgsFormExtended(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	controller := s.getController(c)
+	args := AllocateMachineArgs{
+		SystemID:      "4y3ha3",
+		Pod:           "pod-1",
+		NotPod:        "pod-2",
+		PodType:       "virsh",
+		Space:         []string{"magic"},
+		Subnets:       []string{"cidr:10.0.0.0/24"},
+		Fabrics:       []string{"fabric-0"},
+		NotFabrics:    []string{"fabric-1"},
+		FabricClasses: []string{"10g"},
+		Devices:       []DeviceSpec{{VendorID: "10de"}},
+		MACAddress:    "52:54:00:55:b6:80",
+		BridgeAll:     true,
+		BridgeSTP:     true,
+		BridgeFD:      15,
+	}
+	_, _, err := controller.AllocateMachine(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Assert(form, gc.HasLen, 13)
+	c.Check(form.Get("system_id"), gc.Equals, "4y3ha3")
+	c.Check(form.Get("pod"), gc.Equals, "pod-1")
+	c.Check(form.Get("not_pod"), gc.Equals, "pod-2")
+	c.Check(form.Get("pod_type"), gc.Equals, "virsh")
+	c.Check(form["subnets"], jc.DeepEquals, []string{"space:magic", "cidr:10.0.0.0/24"})
+	c.Check(form.Get("fabrics"), gc.Equals, "fabric-0")
+	c.Check(form.Get("not_fabrics"), gc.Equals, "fabric-1")
+	c.Check(form.Get("fabric_classes"), gc.Equals, "10g")
+	c.Check(form.Get("devices"), gc.Equals, "vendor_id=10de")
+	c.Check(form.Get("mac_address"), gc.Equals, "52:54:00:55:b6:80")
+	c.Check(form.Get("bridge_all"), gc.Equals, "true")
+	c.Check(form.Get("bridge_stp"), gc.Equals, "true")
+	c.Check(form.Get("bridge_fd"), gc.Equals, "15")
+}
+
 func (s *controllerSuite) TestAllocateMachineNoMatch(c *gc.C) {
 	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "boo")
 	controller := s.getController(c)
@@ -605,6 +1300,67 @@ func (s *controllerSuite) TestReleaseMachines(c *gc.C) {
 	c.Assert(request.PostForm.Get("comment"), gc.Equals, "all good")
 }
 
+func (s *controllerSuite) TestReleaseMachinesErase(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs:   []string{"this"},
+		Erase:       true,
+		SecureErase: true,
+		QuickErase:  true,
+		Force:       true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Assert(form, gc.HasLen, 5)
+	c.Check(form.Get("erase"), gc.Equals, "true")
+	c.Check(form.Get("secure_erase"), gc.Equals, "true")
+	c.Check(form.Get("quick_erase"), gc.Equals, "true")
+	c.Check(form.Get("force"), gc.Equals, "true")
+}
+
+func (s *controllerSuite) TestReleaseMachinesValidate(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs:   []string{"this"},
+		SecureErase: true,
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, "SecureErase or QuickErase without Erase not valid")
+}
+
+func (s *controllerSuite) TestReleaseMachinesUnknown(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusBadRequest, "Unknown machine(s): this, that.")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: []string{"this", "that", "other"},
+	})
+	c.Assert(err, jc.Satisfies, IsReleaseMachinesError)
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "Unknown machine(s): this, that.")
+	failed := ReleaseMachinesFailures(errors.Annotate(err, "releasing"))
+	c.Assert(failed, gc.HasLen, 2)
+	c.Check(failed["this"], jc.Satisfies, IsNoMatchError)
+	c.Check(failed["that"], gc.ErrorMatches, `machine "that" not found`)
+}
+
+func (s *controllerSuite) TestReleaseMachinesFailedStates(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusConflict,
+		"Machine(s) cannot be released in their current state: 4y3ha3 ('Commissioning'), 4y3ha4 ('Failed testing').")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: []string{"4y3ha3", "4y3ha4", "4y3ha6"},
+	})
+	c.Assert(err, jc.Satisfies, IsReleaseMachinesError)
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	failed := ReleaseMachinesFailures(err)
+	c.Assert(failed, gc.HasLen, 2)
+	c.Check(failed["4y3ha3"], jc.Satisfies, IsCannotCompleteError)
+	c.Check(failed["4y3ha3"], gc.ErrorMatches, `machine "4y3ha3" cannot be released in state "Commissioning"`)
+	c.Check(failed["4y3ha4"], gc.ErrorMatches, `machine "4y3ha4" cannot be released in state "Failed testing"`)
+}
+
 func (s *controllerSuite) TestReleaseMachinesBadRequest(c *gc.C) {
 	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusBadRequest, "unknown machines")
 	controller := s.getController(c)
@@ -0,0 +1,214 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// defaultDeployPollInterval is how often DeployGroup refreshes the
+	// machines while waiting for them to be deployed.
+	defaultDeployPollInterval = 15 * time.Second
+
+	machineStatusDeployed         = "Deployed"
+	machineStatusFailedDeployment = "Failed deployment"
+)
+
+// DeployGroupItem holds the arguments to allocate and then start a single
+// machine of a DeployGroup.
+type DeployGroupItem struct {
+	Allocate AllocateMachineArgs
+	Start    StartArgs
+}
+
+// DeployGroupArgs is an argument struct for DeployGroup.
+type DeployGroupArgs struct {
+	Items []DeployGroupItem
+	// MaxConcurrent limits the number of items that are being allocated,
+	// started and waited on at once. If zero, all the items are run at once.
+	MaxConcurrent int
+	// WaitForDeployed makes each item wait until its machine is Deployed,
+	// refreshing the machine every PollInterval. Timeout limits the time
+	// spent waiting for each machine, and is unlimited if zero.
+	WaitForDeployed bool
+	PollInterval    time.Duration
+	Timeout         time.Duration
+	// ReleaseComment is passed to ReleaseMachines if the group fails.
+	ReleaseComment string
+}
+
+// Validate checks that there are items, and that their allocation args are
+// valid.
+func (a *DeployGroupArgs) Validate() error {
+	if len(a.Items) == 0 {
+		return errors.NotValidf("missing Items")
+	}
+	if a.MaxConcurrent < 0 {
+		return errors.NotValidf("MaxConcurrent value %d", a.MaxConcurrent)
+	}
+	for i, item := range a.Items {
+		if err := item.Allocate.Validate(); err != nil {
+			return errors.Annotatef(err, "item %d", i)
+		}
+	}
+	return nil
+}
+
+// DeployGroupResult reports what happened to a single item of a DeployGroup.
+type DeployGroupResult struct {
+	// Machine is the machine allocated for the item, if there was one.
+	Machine Machine
+	Matches ConstraintMatches
+	// Err is the reason the item failed, if it did.
+	Err error
+	// Skipped is true if the item was not attempted because another item
+	// had already failed.
+	Skipped bool
+	// Stopped is true if the item stopped waiting for its machine to be
+	// deployed because another item failed.
+	Stopped bool
+	// Released is true if the machine was released because the group
+	// failed.
+	Released bool
+}
+
+// DeployGroup allocates and starts a machine for each of the items, running
+// at most args.MaxConcurrent of them at once. If any item fails, no more are
+// attempted, the items waiting for their machines to be deployed stop
+// waiting, and all the machines that were allocated are released with
+// ReleaseMachines. The results are in the same order as the items, and are
+// returned even when there is an error. The error is that of the first
+// failed item, or of the release.
+func DeployGroup(controller Controller, args DeployGroupArgs) ([]DeployGroupResult, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	maxConcurrent := args.MaxConcurrent
+	if maxConcurrent == 0 {
+		maxConcurrent = len(args.Items)
+	}
+
+	results := make([]DeployGroupResult, len(args.Items))
+	var (
+		mu     sync.Mutex
+		failed bool
+		wg     sync.WaitGroup
+		// stop is closed when the first item fails.
+		stop = make(chan struct{})
+	)
+	hasFailed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed
+	}
+	slots := make(chan struct{}, maxConcurrent)
+	for i := range args.Items {
+		slots <- struct{}{}
+		if hasFailed() {
+			<-slots
+			results[i].Skipped = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result := deployGroupItem(controller, args, args.Items[i], stop)
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = result
+			if result.Err != nil && !failed {
+				failed = true
+				close(stop)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var firstErr error
+	var allocated []string
+	for i, result := range results {
+		if result.Err != nil && firstErr == nil {
+			firstErr = errors.Annotatef(result.Err, "item %d", i)
+		}
+		if result.Machine != nil {
+			allocated = append(allocated, result.Machine.SystemID())
+		}
+	}
+	if firstErr == nil {
+		return results, nil
+	}
+	if len(allocated) > 0 {
+		err := controller.ReleaseMachines(ReleaseMachinesArgs{
+			SystemIDs: allocated,
+			Comment:   args.ReleaseComment,
+		})
+		if err != nil {
+			return results, errors.Annotatef(err, "releasing machines after %v", firstErr)
+		}
+		for i := range results {
+			results[i].Released = results[i].Machine != nil
+		}
+	}
+	return results, firstErr
+}
+
+func deployGroupItem(controller Controller, args DeployGroupArgs, item DeployGroupItem, stop <-chan struct{}) DeployGroupResult {
+	var result DeployGroupResult
+	result.Machine, result.Matches, result.Err = controller.AllocateMachine(item.Allocate)
+	if result.Err != nil {
+		result.Err = errors.Annotate(result.Err, "allocating")
+		return result
+	}
+	if err := result.Machine.Start(item.Start); err != nil {
+		result.Err = errors.Annotatef(err, "starting %s", result.Machine.SystemID())
+		return result
+	}
+	if args.WaitForDeployed {
+		result.Err = waitForDeployed(result.Machine, args.PollInterval, args.Timeout, stop)
+		if result.Err == errDeployStopped {
+			result.Err = nil
+			result.Stopped = true
+		}
+	}
+	return result
+}
+
+// errDeployStopped is returned by waitForDeployed when it is stopped.
+var errDeployStopped = errors.New("stopped waiting for deployment")
+
+// waitForDeployed refreshes the machine until it is Deployed, has failed to
+// deploy, the timeout has passed or the stop channel is closed.
+func waitForDeployed(machine Machine, pollInterval, timeout time.Duration, stop <-chan struct{}) error {
+	if pollInterval <= 0 {
+		pollInterval = defaultDeployPollInterval
+	}
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	for {
+		switch machine.StatusName() {
+		case machineStatusDeployed:
+			return nil
+		case machineStatusFailedDeployment:
+			return NewCannotCompleteError(machine.SystemID() + ": " + machine.StatusMessage())
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-deadline:
+			return NewCannotCompleteError(fmt.Sprintf("timed out waiting for %s to deploy", machine.SystemID()))
+		case <-stop:
+			return errDeployStopped
+		}
+		if err := machine.Refresh(); err != nil {
+			return errors.Annotatef(err, "refreshing %s", machine.SystemID())
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/set"
+	gc "gopkg.in/check.v1"
+)
+
+type deployGroupSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&deployGroupSuite{})
+
+const allocateURI = "/api/2.0/machines/?op=allocate"
+
+func deployMachineURI(systemID string) string {
+	return "/MAAS/api/2.0/machines/" + systemID + "/"
+}
+
+func deployMachineJSON(c *gc.C, systemID, status string) string {
+	return updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":           systemID,
+		"resource_uri":        deployMachineURI(systemID),
+		"status_name":         status,
+		"constraints_by_type": map[string]interface{}{},
+	})
+}
+
+func (s *deployGroupSuite) addMachine(c *gc.C, server *SimpleTestServer, systemID string) {
+	server.AddPostResponse(allocateURI, http.StatusOK, deployMachineJSON(c, systemID, "Allocated"))
+	server.AddPostResponse(deployMachineURI(systemID)+"?op=deploy", http.StatusOK, deployMachineJSON(c, systemID, "Deploying"))
+}
+
+func deployItems(n int) []DeployGroupItem {
+	items := make([]DeployGroupItem, n)
+	for i := range items {
+		items[i].Start.DistroSeries = "xenial"
+	}
+	return items
+}
+
+func (s *deployGroupSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		args DeployGroupArgs
+		err  string
+	}{{
+		err: "missing Items not valid",
+	}, {
+		args: DeployGroupArgs{Items: deployItems(1), MaxConcurrent: -1},
+		err:  "MaxConcurrent value -1 not valid",
+	}, {
+		args: DeployGroupArgs{Items: []DeployGroupItem{{}, {
+			Allocate: AllocateMachineArgs{NotSpace: []string{""}},
+		}}},
+		err: "item 1: empty NotSpace constraint not valid",
+	}, {
+		args: DeployGroupArgs{Items: deployItems(2)},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.err)
+		}
+	}
+}
+
+func (s *deployGroupSuite) TestDeployGroup(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for _, id := range []string{"aaaaaa", "bbbbbb", "cccccc"} {
+		s.addMachine(c, server, id)
+	}
+	results, err := DeployGroup(controller, DeployGroupArgs{
+		Items:         deployItems(3),
+		MaxConcurrent: 2,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 3)
+	ids := set.NewStrings()
+	for _, result := range results {
+		c.Check(result.Err, jc.ErrorIsNil)
+		c.Check(result.Skipped, jc.IsFalse)
+		c.Check(result.Released, jc.IsFalse)
+		c.Check(result.Machine.StatusName(), gc.Equals, "Deploying")
+		ids.Add(result.Machine.SystemID())
+	}
+	c.Check(ids.SortedValues(), jc.DeepEquals, []string{"aaaaaa", "bbbbbb", "cccccc"})
+}
+
+func (s *deployGroupSuite) TestDeployGroupWaitForDeployed(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	s.addMachine(c, server, "aaaaaa")
+	server.AddGetResponse(deployMachineURI("aaaaaa"), http.StatusOK, deployMachineJSON(c, "aaaaaa", "Deploying"))
+	server.AddGetResponse(deployMachineURI("aaaaaa"), http.StatusOK, deployMachineJSON(c, "aaaaaa", "Deployed"))
+	results, err := DeployGroup(controller, DeployGroupArgs{
+		Items:           deployItems(1),
+		WaitForDeployed: true,
+		PollInterval:    time.Millisecond,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 1)
+	c.Check(results[0].Machine.StatusName(), gc.Equals, "Deployed")
+}
+
+func (s *deployGroupSuite) TestDeployGroupAllocateFailureReleases(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	s.addMachine(c, server, "aaaaaa")
+	server.AddPostResponse(allocateURI, http.StatusConflict, "no more machines")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	results, err := DeployGroup(controller, DeployGroupArgs{
+		Items:          deployItems(3),
+		MaxConcurrent:  1,
+		ReleaseComment: "rollback",
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, "item 1: allocating: no more machines")
+	c.Assert(results, gc.HasLen, 3)
+
+	c.Check(results[0].Err, jc.ErrorIsNil)
+	c.Check(results[0].Released, jc.IsTrue)
+	c.Check(results[1].Machine, gc.IsNil)
+	c.Check(results[1].Err, jc.Satisfies, IsNoMatchError)
+	c.Check(results[1].Released, jc.IsFalse)
+	c.Check(results[2].Skipped, jc.IsTrue)
+
+	form := server.LastRequest().PostForm
+	c.Check(form["machines"], jc.DeepEquals, []string{"aaaaaa"})
+	c.Check(form.Get("comment"), gc.Equals, "rollback")
+}
+
+func (s *deployGroupSuite) TestDeployGroupNothingToRelease(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(allocateURI, http.StatusConflict, "no machines")
+	results, err := DeployGroup(controller, DeployGroupArgs{
+		Items:         deployItems(2),
+		MaxConcurrent: 1,
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(results[1].Skipped, jc.IsTrue)
+	// The version, whoami and allocate requests, but no release.
+	c.Check(server.RequestCount(), gc.Equals, 3)
+}
+
+func (s *deployGroupSuite) TestDeployGroupFailedDeployment(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	s.addMachine(c, server, "aaaaaa")
+	failed := updateJSONMap(c, deployMachineJSON(c, "aaaaaa", "Failed deployment"), map[string]interface{}{
+		"status_message": "curtin failed",
+	})
+	server.AddGetResponse(deployMachineURI("aaaaaa"), http.StatusOK, failed)
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	results, err := DeployGroup(controller, DeployGroupArgs{
+		Items:           deployItems(1),
+		WaitForDeployed: true,
+		PollInterval:    time.Millisecond,
+	})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(err.Error(), gc.Equals, "item 0: aaaaaa: curtin failed")
+	c.Check(results[0].Released, jc.IsTrue)
+}
+
+func (s *deployGroupSuite) TestDeployGroupTimeout(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	s.addMachine(c, server, "aaaaaa")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	results, err := DeployGroup(controller, DeployGroupArgs{
+		Items:           deployItems(1),
+		WaitForDeployed: true,
+		PollInterval:    time.Hour,
+		Timeout:         time.Millisecond,
+	})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(err.Error(), gc.Equals, "item 0: timed out waiting for aaaaaa to deploy")
+	c.Check(results[0].Released, jc.IsTrue)
+}
+
+func (s *deployGroupSuite) TestDeployGroupReleaseFailure(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	s.addMachine(c, server, "aaaaaa")
+	server.AddPostResponse(allocateURI, http.StatusConflict, "no more machines")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusForbidden, "nope")
+	results, err := DeployGroup(controller, DeployGroupArgs{
+		Items:         deployItems(2),
+		MaxConcurrent: 1,
+	})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "releasing machines after item 1: allocating: no more machines: nope")
+	c.Check(results[0].Released, jc.IsFalse)
+}
+
+func (s *deployGroupSuite) TestDeployGroupFailureStopsWaiting(c *gc.C) {
+	server := NewFakeMAASServer()
+	defer server.Close()
+	failing := server.AddMachine(FakeMachine{Hostname: "failing"})
+	stuck := server.AddMachine(FakeMachine{Hostname: "stuck"})
+	controller, err := NewController(ControllerArgs{BaseURL: server.URL, APIKey: "fake:as:key"})
+	c.Assert(err, jc.ErrorIsNil)
+	items := deployItems(2)
+	items[0].Allocate.Hostname = "failing"
+	items[1].Allocate.Hostname = "stuck"
+
+	go func() {
+		// Fail the first machine once it is deploying, leaving the
+		// other one deploying for ever.
+		for {
+			if machine, _ := server.Machine(failing); machine.StatusName == "Deploying" {
+				server.SetMachineStatus(failing, "Failed deployment", "curtin failed")
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	type outcome struct {
+		results []DeployGroupResult
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		results, err := DeployGroup(controller, DeployGroupArgs{
+			Items:           items,
+			WaitForDeployed: true,
+			PollInterval:    time.Millisecond,
+		})
+		done <- outcome{results, err}
+	}()
+	var result outcome
+	select {
+	case result = <-done:
+	case <-time.After(10 * time.Second):
+		c.Fatalf("DeployGroup still waiting after a failure")
+	}
+
+	c.Assert(result.err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(result.err.Error(), gc.Equals, "item 0: "+failing+": curtin failed")
+	c.Check(result.results[1].Err, jc.ErrorIsNil)
+	c.Check(result.results[1].Stopped, jc.IsTrue)
+	for _, systemID := range []string{failing, stuck} {
+		machine, _ := server.Machine(systemID)
+		c.Check(machine.StatusName, gc.Equals, "Ready")
+	}
+}
@@ -6,6 +6,8 @@ package gomaasapi
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/juju/errors"
@@ -22,8 +24,9 @@ type device struct {
 	hostname string
 	fqdn     string
 
-	parent string
-	owner  string
+	parent    string
+	owner     string
+	ownerData map[string]string
 
 	ipAddresses  []string
 	interfaceSet []*interface_
@@ -68,6 +71,124 @@ func (d *device) Zone() Zone {
 	return d.zone
 }
 
+// OwnerData implements OwnerDataHolder.
+func (d *device) OwnerData() map[string]string {
+	result := make(map[string]string)
+	for key, value := range d.ownerData {
+		result[key] = value
+	}
+	return result
+}
+
+// SetOwnerData implements OwnerDataHolder.
+func (d *device) SetOwnerData(ownerData map[string]string) error {
+	params := make(url.Values)
+	for key, value := range ownerData {
+		params.Add(key, value)
+	}
+	result, err := d.controller.post(d.resourceURI, "set_owner_data", params)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return errors.Trace(d.updateFrom(result))
+}
+
+// hostnameRE matches a single DNS label, which is what MAAS expects a
+// hostname to be. The domain is set separately.
+var hostnameRE = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// UpdateDeviceArgs is an argument struct for Device.Update. Only the
+// non-empty values are changed.
+type UpdateDeviceArgs struct {
+	// Hostname is the new hostname, without the domain.
+	Hostname string
+	// Domain is the name of the domain to move the device into.
+	Domain string
+	// Zone is the name of the zone to move the device into.
+	Zone string
+	// Parent is the system ID of the new parent of the device.
+	Parent string
+}
+
+// Validate checks the values in the arg structure are well formed.
+func (a *UpdateDeviceArgs) Validate() error {
+	if a.Hostname != "" && !hostnameRE.MatchString(a.Hostname) {
+		return errors.NotValidf("Hostname %q", a.Hostname)
+	}
+	return nil
+}
+
+// Update implements Device.
+func (d *device) Update(args UpdateDeviceArgs) error {
+	var empty UpdateDeviceArgs
+	if args == empty {
+		return nil
+	}
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.Parent == d.systemID {
+		return errors.NotValidf("device %q as its own Parent", d.systemID)
+	}
+	params := NewURLParams()
+	params.MaybeAdd("hostname", args.Hostname)
+	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("zone", args.Zone)
+	params.MaybeAdd("parent", args.Parent)
+	result, err := d.controller.put(d.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return errors.Trace(d.updateFrom(result))
+}
+
+// Refresh implements Device.
+func (d *device) Refresh() error {
+	source, err := d.controller.get(d.resourceURI)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return errors.Trace(d.updateFrom(source))
+}
+
+// updateFrom replaces the state of the device with the device in the
+// source response.
+func (d *device) updateFrom(source interface{}) error {
+	updated, err := readDevice(d.controller.apiVersion, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updated.controller = d.controller
+	*d = *updated
+	return nil
+}
+
 // InterfaceSet implements Device.
 func (d *device) InterfaceSet() []Interface {
 	result := make([]Interface, len(d.interfaceSet))
@@ -248,14 +369,17 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 		"fqdn":      schema.String(),
 		"parent":    schema.OneOf(schema.Nil(""), schema.String()),
 		"owner":     schema.OneOf(schema.Nil(""), schema.String()),
+		// Older versions of MAAS don't have owner data for devices.
+		"owner_data": schema.StringMap(schema.String()),
 
 		"ip_addresses":  schema.List(schema.String()),
 		"interface_set": schema.List(schema.StringMap(schema.Any())),
 		"zone":          schema.StringMap(schema.Any()),
 	}
 	defaults := schema.Defaults{
-		"owner":  "",
-		"parent": "",
+		"owner":      "",
+		"parent":     "",
+		"owner_data": map[string]interface{}{},
 	}
 	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
@@ -279,11 +403,12 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 	result := &device{
 		resourceURI: valid["resource_uri"].(string),
 
-		systemID: valid["system_id"].(string),
-		hostname: valid["hostname"].(string),
-		fqdn:     valid["fqdn"].(string),
-		parent:   parent,
-		owner:    owner,
+		systemID:  valid["system_id"].(string),
+		hostname:  valid["hostname"].(string),
+		fqdn:      valid["fqdn"].(string),
+		parent:    parent,
+		owner:     owner,
+		ownerData: convertToStringMap(valid["owner_data"]),
 
 		ipAddresses:  convertToStringSlice(valid["ip_addresses"]),
 		interfaceSet: interfaceSet,
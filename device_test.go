@@ -59,6 +59,20 @@ func (*deviceSuite) TestReadDevicesNils(c *gc.C) {
 	c.Check(device.Parent(), gc.Equals, "")
 }
 
+func (*deviceSuite) TestReadDevicesOwnerData(c *gc.C) {
+	json := parseJSON(c, devicesResponse)
+	deviceMap := json.([]interface{})[0].(map[string]interface{})
+	c.Assert(deviceMap["owner_data"], gc.IsNil)
+	devices, err := readDevices(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(devices[0].OwnerData(), gc.DeepEquals, map[string]string{})
+
+	deviceMap["owner_data"] = map[string]interface{}{"app": "mysql"}
+	devices, err = readDevices(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(devices[0].OwnerData(), gc.DeepEquals, map[string]string{"app": "mysql"})
+}
+
 func (*deviceSuite) TestLowVersion(c *gc.C) {
 	_, err := readDevices(version.MustParse("1.9.0"), parseJSON(c, devicesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -226,6 +240,139 @@ func (s *deviceSuite) TestDeleteUnknown(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsUnexpectedError)
 }
 
+func (s *deviceSuite) TestRefresh(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"parent": "4y3ha6",
+	})
+	server.AddGetResponse(device.resourceURI, http.StatusOK, response)
+	err := device.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.Parent(), gc.Equals, "4y3ha6")
+	c.Check(device.controller, gc.NotNil)
+}
+
+func (s *deviceSuite) TestRefreshNotFound(c *gc.C) {
+	_, device := s.getServerAndDevice(c)
+	// No path, so 404
+	err := device.Refresh()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *deviceSuite) TestOwnerDataCopies(c *gc.C) {
+	device := device{ownerData: make(map[string]string)}
+	ownerData := device.OwnerData()
+	ownerData["sad"] = "children"
+	c.Assert(device.OwnerData(), gc.DeepEquals, map[string]string{})
+}
+
+func (s *deviceSuite) TestSetOwnerData(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"owner_data": map[string]string{"returned": "data"},
+	})
+	server.AddPostResponse(device.resourceURI+"?op=set_owner_data", http.StatusOK, response)
+	err := device.SetOwnerData(map[string]string{
+		"draco": "malfoy",
+		"empty": "", // Check that empty strings get passed along.
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(device.OwnerData(), gc.DeepEquals, map[string]string{"returned": "data"})
+	form := server.LastRequest().PostForm
+	c.Check(form["draco"], gc.DeepEquals, []string{"malfoy"})
+	c.Check(form["empty"], gc.DeepEquals, []string{""})
+}
+
+func (s *deviceSuite) TestSetOwnerDataForbidden(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse(device.resourceURI+"?op=set_owner_data", http.StatusForbidden, "not yours")
+	err := device.SetOwnerData(map[string]string{"app": "mysql"})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "not yours")
+}
+
+func (s *deviceSuite) TestUpdateDeviceArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    UpdateDeviceArgs
+		errText string
+	}{{
+		args: UpdateDeviceArgs{Hostname: "juju-machine-0-lxd-1"},
+	}, {
+		args:    UpdateDeviceArgs{Hostname: "lxd.maas"},
+		errText: `Hostname "lxd.maas" not valid`,
+	}, {
+		args:    UpdateDeviceArgs{Hostname: "-lxd"},
+		errText: `Hostname "-lxd" not valid`,
+	}, {
+		args: UpdateDeviceArgs{Zone: "special", Parent: "4y3ha3"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *deviceSuite) TestUpdate(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"hostname": "moved",
+		"fqdn":     "moved.maas",
+		"parent":   "4y3ha6",
+	})
+	server.AddPutResponse(device.resourceURI, http.StatusOK, response)
+	err := device.Update(UpdateDeviceArgs{
+		Hostname: "moved",
+		Parent:   "4y3ha6",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.Hostname(), gc.Equals, "moved")
+	c.Check(device.FQDN(), gc.Equals, "moved.maas")
+	c.Check(device.Parent(), gc.Equals, "4y3ha6")
+	// The controller is kept so the device can still be used.
+	c.Check(device.controller, gc.NotNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 2)
+	c.Check(form.Get("hostname"), gc.Equals, "moved")
+	c.Check(form.Get("parent"), gc.Equals, "4y3ha6")
+}
+
+func (s *deviceSuite) TestUpdateNoChanges(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	count := server.RequestCount()
+	err := device.Update(UpdateDeviceArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *deviceSuite) TestUpdateValidates(c *gc.C) {
+	_, device := s.getServerAndDevice(c)
+	err := device.Update(UpdateDeviceArgs{Hostname: "bad.name"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	err = device.Update(UpdateDeviceArgs{Parent: device.SystemID()})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *deviceSuite) TestUpdateNotFound(c *gc.C) {
+	_, device := s.getServerAndDevice(c)
+	// No path, so 404
+	err := device.Update(UpdateDeviceArgs{Zone: "special"})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *deviceSuite) TestUpdateBadRequest(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPutResponse(device.resourceURI, http.StatusBadRequest, "unknown zone")
+	err := device.Update(UpdateDeviceArgs{Zone: "wat"})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "unknown zone")
+}
+
 const (
 	deviceResponse = `
     {
@@ -0,0 +1,297 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type dhcpSnippet struct {
+	controller *controller
+
+	resourceURI string
+
+	id          int
+	name        string
+	value       string
+	description string
+	enabled     bool
+	node        string
+	subnet      *subnet
+	global      bool
+}
+
+// ID implements DHCPSnippet.
+func (d *dhcpSnippet) ID() int {
+	return d.id
+}
+
+// Name implements DHCPSnippet.
+func (d *dhcpSnippet) Name() string {
+	return d.name
+}
+
+// Value implements DHCPSnippet.
+func (d *dhcpSnippet) Value() string {
+	return d.value
+}
+
+// Description implements DHCPSnippet.
+func (d *dhcpSnippet) Description() string {
+	return d.description
+}
+
+// Enabled implements DHCPSnippet.
+func (d *dhcpSnippet) Enabled() bool {
+	return d.enabled
+}
+
+// Node implements DHCPSnippet.
+func (d *dhcpSnippet) Node() string {
+	return d.node
+}
+
+// Subnet implements DHCPSnippet.
+func (d *dhcpSnippet) Subnet() Subnet {
+	if d.subnet == nil {
+		return nil
+	}
+	d.subnet.controller = d.controller
+	return d.subnet
+}
+
+// Global implements DHCPSnippet.
+func (d *dhcpSnippet) Global() bool {
+	return d.global
+}
+
+// UpdateDHCPSnippetArgs is an argument struct for DHCPSnippet.Update. Only
+// the non-empty values are changed. At most one of Node, Subnet and Global
+// may be set, to move the snippet to that scope.
+type UpdateDHCPSnippetArgs struct {
+	Name        string
+	Value       string
+	Description string
+	// Node is the system ID of the node to scope the snippet to.
+	Node string
+	// Subnet to scope the snippet to.
+	Subnet Subnet
+	// Global makes the snippet global.
+	Global bool
+}
+
+// Validate checks that at most one scope is set for the arg structure.
+func (a *UpdateDHCPSnippetArgs) Validate() error {
+	scopes := 0
+	if a.Node != "" {
+		scopes++
+	}
+	if a.Subnet != nil {
+		scopes++
+	}
+	if a.Global {
+		scopes++
+	}
+	if scopes > 1 {
+		return errors.NotValidf("more than one of Node, Subnet and Global")
+	}
+	return nil
+}
+
+// Update implements DHCPSnippet.
+func (d *dhcpSnippet) Update(args UpdateDHCPSnippetArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAdd("name", args.Name)
+	params.MaybeAdd("value", args.Value)
+	params.MaybeAdd("description", args.Description)
+	params.MaybeAdd("node", args.Node)
+	if args.Subnet != nil {
+		params.Values.Add("subnet", fmt.Sprint(args.Subnet.ID()))
+	}
+	if args.Global {
+		params.Values.Add("global_snippet", "true")
+	}
+	if len(params.Values) == 0 {
+		return nil
+	}
+	return d.update(params)
+}
+
+// SetEnabled implements DHCPSnippet.
+func (d *dhcpSnippet) SetEnabled(enabled bool) error {
+	params := NewURLParams()
+	params.Values.Add("enabled", fmt.Sprint(enabled))
+	return d.update(params)
+}
+
+func (d *dhcpSnippet) update(params *URLParams) error {
+	source, err := d.controller.put(d.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	updated, err := readDHCPSnippet(d.controller.apiVersion, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updated.controller = d.controller
+	*d = *updated
+	return nil
+}
+
+// Delete implements DHCPSnippet.
+func (d *dhcpSnippet) Delete() error {
+	err := d.controller.delete(d.resourceURI)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+func readDHCPSnippet(controllerVersion version.Number, source interface{}) (*dhcpSnippet, error) {
+	readFunc, err := getDHCPSnippetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dhcp snippet base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readDHCPSnippets(controllerVersion version.Number, source interface{}) ([]*dhcpSnippet, error) {
+	readFunc, err := getDHCPSnippetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dhcp snippet base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	return readDHCPSnippetList(valid, readFunc)
+}
+
+func getDHCPSnippetDeserializationFunc(controllerVersion version.Number) (dhcpSnippetDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range dhcpSnippetDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no dhcp snippet read func for version %s", controllerVersion)
+	}
+	return dhcpSnippetDeserializationFuncs[deserialisationVersion], nil
+}
+
+// readDHCPSnippetList expects the values of the sourceList to be string maps.
+func readDHCPSnippetList(sourceList []interface{}, readFunc dhcpSnippetDeserializationFunc) ([]*dhcpSnippet, error) {
+	result := make([]*dhcpSnippet, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for dhcp snippet %d, %T", i, value)
+		}
+		snippet, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "dhcp snippet %d", i)
+		}
+		result = append(result, snippet)
+	}
+	return result, nil
+}
+
+type dhcpSnippetDeserializationFunc func(map[string]interface{}) (*dhcpSnippet, error)
+
+var dhcpSnippetDeserializationFuncs = map[version.Number]dhcpSnippetDeserializationFunc{
+	twoDotOh: dhcpSnippet_2_0,
+}
+
+func dhcpSnippet_2_0(source map[string]interface{}) (*dhcpSnippet, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"id":           schema.ForceInt(),
+		"name":         schema.String(),
+		"value":        schema.String(),
+		"description":  schema.OneOf(schema.Nil(""), schema.String()),
+		"enabled":      schema.Bool(),
+		// The node is given as either the system ID or the whole node.
+		"node":           schema.OneOf(schema.Nil(""), schema.String(), schema.StringMap(schema.Any())),
+		"subnet":         schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"global_snippet": schema.Bool(),
+	}
+	defaults := schema.Defaults{
+		"description": "",
+		"node":        nil,
+		"subnet":      nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dhcp snippet 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var subnet *subnet
+	if subnetMap, ok := valid["subnet"].(map[string]interface{}); ok {
+		subnet, err = subnet_2_0(subnetMap)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	var node string
+	switch value := valid["node"].(type) {
+	case string:
+		node = value
+	case map[string]interface{}:
+		node, _ = value["system_id"].(string)
+	}
+	description, _ := valid["description"].(string)
+	result := &dhcpSnippet{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		name:        valid["name"].(string),
+		value:       valid["value"].(string),
+		description: description,
+		enabled:     valid["enabled"].(bool),
+		node:        node,
+		subnet:      subnet,
+		global:      valid["global_snippet"].(bool),
+	}
+	return result, nil
+}
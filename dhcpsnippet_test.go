@@ -0,0 +1,284 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type dhcpSnippetSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&dhcpSnippetSuite{})
+
+func (*dhcpSnippetSuite) TestReadDHCPSnippetsBadSchema(c *gc.C) {
+	_, err := readDHCPSnippets(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `dhcp snippet base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readDHCPSnippets(twoDotOh, []map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `dhcp snippet 0: dhcp snippet 2.0 schema check failed: .*`)
+}
+
+func (*dhcpSnippetSuite) TestReadDHCPSnippets(c *gc.C) {
+	snippets, err := readDHCPSnippets(twoDotOh, parseJSON(c, dhcpSnippetsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(snippets, gc.HasLen, 3)
+
+	snippet := snippets[0]
+	c.Check(snippet.ID(), gc.Equals, 1)
+	c.Check(snippet.Name(), gc.Equals, "ipxe")
+	c.Check(snippet.Value(), gc.Equals, `option ipxe.no-pxedhcp 1;`)
+	c.Check(snippet.Description(), gc.Equals, "chain load ipxe")
+	c.Check(snippet.Enabled(), jc.IsTrue)
+	c.Check(snippet.Global(), jc.IsTrue)
+	c.Check(snippet.Node(), gc.Equals, "")
+	c.Check(snippet.Subnet(), gc.IsNil)
+
+	snippet = snippets[1]
+	c.Check(snippet.Description(), gc.Equals, "")
+	c.Check(snippet.Enabled(), jc.IsFalse)
+	c.Check(snippet.Global(), jc.IsFalse)
+	c.Check(snippet.Subnet().CIDR(), gc.Equals, "192.168.100.0/24")
+
+	snippet = snippets[2]
+	c.Check(snippet.Node(), gc.Equals, "4y3ha3")
+	c.Check(snippet.Subnet(), gc.IsNil)
+}
+
+func (*dhcpSnippetSuite) TestLowVersion(c *gc.C) {
+	_, err := readDHCPSnippets(version.MustParse("1.9.0"), parseJSON(c, dhcpSnippetsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no dhcp snippet read func for version 1.9.0`)
+}
+
+func (*dhcpSnippetSuite) TestHighVersion(c *gc.C) {
+	snippets, err := readDHCPSnippets(version.MustParse("2.1.9"), parseJSON(c, dhcpSnippetsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(snippets, gc.HasLen, 3)
+}
+
+func (s *dhcpSnippetSuite) getServerAndSnippet(c *gc.C) (*SimpleTestServer, *dhcpSnippet) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/dhcp-snippets/", http.StatusOK, dhcpSnippetsResponse)
+
+	snippets, err := controller.DHCPSnippets()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(snippets, gc.HasLen, 3)
+	return server, snippets[0].(*dhcpSnippet)
+}
+
+func (s *dhcpSnippetSuite) TestUpdate(c *gc.C) {
+	server, snippet := s.getServerAndSnippet(c)
+	response := updateJSONMap(c, dhcpSnippetResponse, map[string]interface{}{
+		"value": "option ipxe.no-pxedhcp 0;",
+	})
+	server.AddPutResponse(snippet.resourceURI, http.StatusOK, response)
+	err := snippet.Update(UpdateDHCPSnippetArgs{Value: "option ipxe.no-pxedhcp 0;"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(snippet.Value(), gc.Equals, "option ipxe.no-pxedhcp 0;")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("value"), gc.Equals, "option ipxe.no-pxedhcp 0;")
+}
+
+func (s *dhcpSnippetSuite) TestUpdateNoChanges(c *gc.C) {
+	server, snippet := s.getServerAndSnippet(c)
+	count := server.RequestCount()
+	err := snippet.Update(UpdateDHCPSnippetArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *dhcpSnippetSuite) snippetSubnet(c *gc.C) Subnet {
+	snippets, err := readDHCPSnippets(twoDotOh, parseJSON(c, dhcpSnippetsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	subnet := snippets[1].Subnet()
+	c.Assert(subnet, gc.NotNil)
+	return subnet
+}
+
+func (s *dhcpSnippetSuite) TestUpdateArgsValidate(c *gc.C) {
+	subnet := s.snippetSubnet(c)
+	for i, test := range []struct {
+		args    UpdateDHCPSnippetArgs
+		errText string
+	}{{
+		args: UpdateDHCPSnippetArgs{Node: "4y3ha3"},
+	}, {
+		args: UpdateDHCPSnippetArgs{Subnet: subnet},
+	}, {
+		args: UpdateDHCPSnippetArgs{Global: true},
+	}, {
+		args:    UpdateDHCPSnippetArgs{Node: "4y3ha3", Subnet: subnet},
+		errText: "more than one of Node, Subnet and Global not valid",
+	}, {
+		args:    UpdateDHCPSnippetArgs{Subnet: subnet, Global: true},
+		errText: "more than one of Node, Subnet and Global not valid",
+	}, {
+		args:    UpdateDHCPSnippetArgs{Node: "4y3ha3", Global: true},
+		errText: "more than one of Node, Subnet and Global not valid",
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *dhcpSnippetSuite) TestUpdateScope(c *gc.C) {
+	subnet := s.snippetSubnet(c)
+	for i, test := range []struct {
+		args UpdateDHCPSnippetArgs
+		key  string
+		want string
+	}{{
+		args: UpdateDHCPSnippetArgs{Node: "4y3ha3"},
+		key:  "node",
+		want: "4y3ha3",
+	}, {
+		args: UpdateDHCPSnippetArgs{Subnet: subnet},
+		key:  "subnet",
+		want: fmt.Sprint(subnet.ID()),
+	}, {
+		args: UpdateDHCPSnippetArgs{Global: true},
+		key:  "global_snippet",
+		want: "true",
+	}} {
+		c.Logf("test %d", i)
+		server, snippet := s.getServerAndSnippet(c)
+		server.AddPutResponse(snippet.resourceURI, http.StatusOK, dhcpSnippetResponse)
+		err := snippet.Update(test.args)
+		c.Assert(err, jc.ErrorIsNil)
+
+		form := server.LastRequest().PostForm
+		c.Check(form, gc.HasLen, 1)
+		c.Check(form.Get(test.key), gc.Equals, test.want)
+	}
+}
+
+func (s *dhcpSnippetSuite) TestUpdateTwoScopes(c *gc.C) {
+	server, snippet := s.getServerAndSnippet(c)
+	count := server.RequestCount()
+	err := snippet.Update(UpdateDHCPSnippetArgs{Node: "4y3ha3", Global: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *dhcpSnippetSuite) TestSetEnabled(c *gc.C) {
+	server, snippet := s.getServerAndSnippet(c)
+	response := updateJSONMap(c, dhcpSnippetResponse, map[string]interface{}{
+		"enabled": false,
+	})
+	server.AddPutResponse(snippet.resourceURI, http.StatusOK, response)
+	err := snippet.SetEnabled(false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(snippet.Enabled(), jc.IsFalse)
+	c.Check(server.LastRequest().PostForm.Get("enabled"), gc.Equals, "false")
+}
+
+func (s *dhcpSnippetSuite) TestSetEnabledForbidden(c *gc.C) {
+	server, snippet := s.getServerAndSnippet(c)
+	server.AddPutResponse(snippet.resourceURI, http.StatusForbidden, "admin only")
+	err := snippet.SetEnabled(false)
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "admin only")
+}
+
+func (s *dhcpSnippetSuite) TestDelete(c *gc.C) {
+	server, snippet := s.getServerAndSnippet(c)
+	// Successful delete is 204 - StatusNoContent
+	server.AddDeleteResponse(snippet.resourceURI, http.StatusNoContent, "")
+	err := snippet.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *dhcpSnippetSuite) TestDelete404(c *gc.C) {
+	_, snippet := s.getServerAndSnippet(c)
+	// No path, so 404
+	err := snippet.Delete()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+const (
+	dhcpSnippetResponse = `
+    {
+        "id": 1,
+        "name": "ipxe",
+        "value": "option ipxe.no-pxedhcp 1;",
+        "description": "chain load ipxe",
+        "history": [],
+        "enabled": true,
+        "node": null,
+        "subnet": null,
+        "global_snippet": true,
+        "resource_uri": "/MAAS/api/2.0/dhcp-snippets/1/"
+    }`
+	dhcpSnippetsResponse = `
+[` + dhcpSnippetResponse + `,
+    {
+        "id": 2,
+        "name": "subnet options",
+        "value": "option domain-search \"maas\";",
+        "description": null,
+        "history": [],
+        "enabled": false,
+        "node": null,
+        "subnet": {
+            "gateway_ip": "192.168.100.1",
+            "name": "192.168.100.0/24",
+            "vlan": {
+                "fabric": "fabric-0",
+                "resource_uri": "/MAAS/api/2.0/vlans/1/",
+                "name": "untagged",
+                "secondary_rack": null,
+                "primary_rack": "4y3h7n",
+                "vid": 0,
+                "dhcp_on": true,
+                "id": 1,
+                "mtu": 1500
+            },
+            "space": "space-0",
+            "id": 1,
+            "resource_uri": "/MAAS/api/2.0/subnets/1/",
+            "dns_servers": [],
+            "cidr": "192.168.100.0/24",
+            "rdns_mode": 2
+        },
+        "global_snippet": false,
+        "resource_uri": "/MAAS/api/2.0/dhcp-snippets/2/"
+    },
+    {
+        "id": 3,
+        "name": "node options",
+        "value": "filename \"special.efi\";",
+        "description": "",
+        "history": [],
+        "enabled": true,
+        "node": "4y3ha3",
+        "subnet": null,
+        "global_snippet": false,
+        "resource_uri": "/MAAS/api/2.0/dhcp-snippets/3/"
+    }
+]
+`
+)
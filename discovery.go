@@ -0,0 +1,230 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type discovery struct {
+	controller *controller
+
+	discoveryID     string
+	ip              string
+	macAddress      string
+	macOrganization string
+	hostname        string
+	lastSeen        string
+
+	observerSystemID      string
+	observerHostname      string
+	observerInterfaceName string
+
+	fabric string
+	vid    int
+	vlan   *vlan
+	subnet *subnet
+}
+
+// DiscoveryID implements Discovery.
+func (d *discovery) DiscoveryID() string {
+	return d.discoveryID
+}
+
+// IP implements Discovery.
+func (d *discovery) IP() string {
+	return d.ip
+}
+
+// MACAddress implements Discovery.
+func (d *discovery) MACAddress() string {
+	return d.macAddress
+}
+
+// MACOrganization implements Discovery.
+func (d *discovery) MACOrganization() string {
+	return d.macOrganization
+}
+
+// Hostname implements Discovery.
+func (d *discovery) Hostname() string {
+	return d.hostname
+}
+
+// LastSeen implements Discovery.
+func (d *discovery) LastSeen() string {
+	return d.lastSeen
+}
+
+// ObserverSystemID implements Discovery.
+func (d *discovery) ObserverSystemID() string {
+	return d.observerSystemID
+}
+
+// ObserverHostname implements Discovery.
+func (d *discovery) ObserverHostname() string {
+	return d.observerHostname
+}
+
+// ObserverInterfaceName implements Discovery.
+func (d *discovery) ObserverInterfaceName() string {
+	return d.observerInterfaceName
+}
+
+// Fabric implements Discovery.
+func (d *discovery) Fabric() string {
+	return d.fabric
+}
+
+// VID implements Discovery.
+func (d *discovery) VID() int {
+	return d.vid
+}
+
+// VLAN implements Discovery.
+func (d *discovery) VLAN() VLAN {
+	if d.vlan == nil {
+		return nil
+	}
+	return d.vlan
+}
+
+// Subnet implements Discovery.
+func (d *discovery) Subnet() Subnet {
+	if d.subnet == nil {
+		return nil
+	}
+	d.subnet.controller = d.controller
+	return d.subnet
+}
+
+func readDiscoveries(controllerVersion version.Number, source interface{}) ([]*discovery, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "discovery base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range discoveryDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no discovery read func for version %s", controllerVersion)
+	}
+	readFunc := discoveryDeserializationFuncs[deserialisationVersion]
+	return readDiscoveryList(valid, readFunc)
+}
+
+// readDiscoveryList expects the values of the sourceList to be string maps.
+func readDiscoveryList(sourceList []interface{}, readFunc discoveryDeserializationFunc) ([]*discovery, error) {
+	result := make([]*discovery, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for discovery %d, %T", i, value)
+		}
+		discovery, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "discovery %d", i)
+		}
+		result = append(result, discovery)
+	}
+	return result, nil
+}
+
+type discoveryDeserializationFunc func(map[string]interface{}) (*discovery, error)
+
+var discoveryDeserializationFuncs = map[version.Number]discoveryDeserializationFunc{
+	twoDotOh: discovery_2_0,
+}
+
+func discovery_2_0(source map[string]interface{}) (*discovery, error) {
+	observerFields := schema.Fields{
+		"system_id":      schema.String(),
+		"hostname":       schema.String(),
+		"interface_name": schema.OneOf(schema.Nil(""), schema.String()),
+	}
+	observerDefaults := schema.Defaults{
+		"interface_name": "",
+	}
+	fields := schema.Fields{
+		"discovery_id":     schema.String(),
+		"ip":               schema.String(),
+		"mac_address":      schema.String(),
+		"mac_organization": schema.OneOf(schema.Nil(""), schema.String()),
+		"hostname":         schema.OneOf(schema.Nil(""), schema.String()),
+		"last_seen":        schema.OneOf(schema.Nil(""), schema.String()),
+		"observer":         schema.FieldMap(observerFields, observerDefaults),
+		"fabric_name":      schema.OneOf(schema.Nil(""), schema.String()),
+		"vid":              schema.OneOf(schema.Nil(""), schema.ForceInt()),
+		"vlan":             schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"subnet":           schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"mac_organization": "",
+		"hostname":         "",
+		"last_seen":        "",
+		"fabric_name":      "",
+		"vid":              nil,
+		"vlan":             nil,
+		"subnet":           nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "discovery 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var vlan *vlan
+	if vlanMap, ok := valid["vlan"].(map[string]interface{}); ok {
+		vlan, err = vlan_2_0(vlanMap)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	var subnet *subnet
+	if subnetMap, ok := valid["subnet"].(map[string]interface{}); ok {
+		subnet, err = subnet_2_0(subnetMap)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	observer := valid["observer"].(map[string]interface{})
+	interfaceName, _ := observer["interface_name"].(string)
+
+	// The optional values use the two part cast assignment, which gives the
+	// zero value for nil.
+	macOrganization, _ := valid["mac_organization"].(string)
+	hostname, _ := valid["hostname"].(string)
+	lastSeen, _ := valid["last_seen"].(string)
+	fabric, _ := valid["fabric_name"].(string)
+	vid, _ := valid["vid"].(int)
+	result := &discovery{
+		discoveryID:     valid["discovery_id"].(string),
+		ip:              valid["ip"].(string),
+		macAddress:      valid["mac_address"].(string),
+		macOrganization: macOrganization,
+		hostname:        hostname,
+		lastSeen:        lastSeen,
+
+		observerSystemID:      observer["system_id"].(string),
+		observerHostname:      observer["hostname"].(string),
+		observerInterfaceName: interfaceName,
+
+		fabric: fabric,
+		vid:    vid,
+		vlan:   vlan,
+		subnet: subnet,
+	}
+	return result, nil
+}
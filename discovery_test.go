@@ -0,0 +1,140 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type discoverySuite struct{}
+
+var _ = gc.Suite(&discoverySuite{})
+
+func (*discoverySuite) TestReadDiscoveriesBadSchema(c *gc.C) {
+	_, err := readDiscoveries(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `discovery base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readDiscoveries(twoDotOh, []map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `discovery 0: discovery 2.0 schema check failed: .*`)
+}
+
+func (*discoverySuite) TestReadDiscoveries(c *gc.C) {
+	discoveries, err := readDiscoveries(twoDotOh, parseJSON(c, discoveriesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(discoveries, gc.HasLen, 2)
+
+	d := discoveries[0]
+	c.Check(d.DiscoveryID(), gc.Equals, "MTkyLjE2OC4xMDAuNTAsNTI6NTQ6MDA6OWE6MTM6ZDY=")
+	c.Check(d.IP(), gc.Equals, "192.168.100.50")
+	c.Check(d.MACAddress(), gc.Equals, "52:54:00:9a:13:d6")
+	c.Check(d.MACOrganization(), gc.Equals, "QEMU virtual NIC")
+	c.Check(d.Hostname(), gc.Equals, "printer")
+	c.Check(d.LastSeen(), gc.Equals, "2016-11-02T09:18:43.181")
+	c.Check(d.ObserverSystemID(), gc.Equals, "4y3h7n")
+	c.Check(d.ObserverHostname(), gc.Equals, "karura")
+	c.Check(d.ObserverInterfaceName(), gc.Equals, "eth0")
+	c.Check(d.Fabric(), gc.Equals, "fabric-0")
+	c.Check(d.VID(), gc.Equals, 0)
+	c.Check(d.VLAN().ID(), gc.Equals, 1)
+	c.Check(d.Subnet().CIDR(), gc.Equals, "192.168.100.0/24")
+
+	d = discoveries[1]
+	c.Check(d.MACOrganization(), gc.Equals, "")
+	c.Check(d.Hostname(), gc.Equals, "")
+	c.Check(d.ObserverInterfaceName(), gc.Equals, "")
+	c.Check(d.VID(), gc.Equals, 0)
+	c.Check(d.VLAN(), gc.IsNil)
+	c.Check(d.Subnet(), gc.IsNil)
+}
+
+func (*discoverySuite) TestLowVersion(c *gc.C) {
+	_, err := readDiscoveries(version.MustParse("1.9.0"), parseJSON(c, discoveriesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no discovery read func for version 1.9.0`)
+}
+
+func (*discoverySuite) TestHighVersion(c *gc.C) {
+	discoveries, err := readDiscoveries(version.MustParse("2.1.9"), parseJSON(c, discoveriesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(discoveries, gc.HasLen, 2)
+}
+
+var discoveriesResponse = `
+[
+    {
+        "discovery_id": "MTkyLjE2OC4xMDAuNTAsNTI6NTQ6MDA6OWE6MTM6ZDY=",
+        "ip": "192.168.100.50",
+        "mac_address": "52:54:00:9a:13:d6",
+        "mac_organization": "QEMU virtual NIC",
+        "hostname": "printer",
+        "last_seen": "2016-11-02T09:18:43.181",
+        "observer": {
+            "system_id": "4y3h7n",
+            "hostname": "karura",
+            "interface_id": 40,
+            "interface_name": "eth0"
+        },
+        "fabric_name": "fabric-0",
+        "vid": 0,
+        "vlan": {
+            "resource_uri": "/MAAS/api/2.0/vlans/1/",
+            "id": 1,
+            "secondary_rack": null,
+            "mtu": 1500,
+            "primary_rack": "4y3h7n",
+            "name": "untagged",
+            "fabric": "fabric-0",
+            "dhcp_on": true,
+            "vid": 0
+        },
+        "subnet": {
+            "gateway_ip": "192.168.100.1",
+            "name": "192.168.100.0/24",
+            "vlan": {
+                "resource_uri": "/MAAS/api/2.0/vlans/1/",
+                "id": 1,
+                "secondary_rack": null,
+                "mtu": 1500,
+                "primary_rack": "4y3h7n",
+                "name": "untagged",
+                "fabric": "fabric-0",
+                "dhcp_on": true,
+                "vid": 0
+            },
+            "space": "space-0",
+            "id": 1,
+            "resource_uri": "/MAAS/api/2.0/subnets/1/",
+            "dns_servers": [],
+            "cidr": "192.168.100.0/24",
+            "rdns_mode": 2
+        },
+        "resource_uri": "/MAAS/api/2.0/discovery/MTkyLjE2OC4xMDAuNTAsNTI6NTQ6MDA6OWE6MTM6ZDY=/"
+    },
+    {
+        "discovery_id": "MTkyLjE2OC4xMDAuNTEsNTI6NTQ6MDA6OWE6MTM6ZDc=",
+        "ip": "192.168.100.51",
+        "mac_address": "52:54:00:9a:13:d7",
+        "mac_organization": null,
+        "hostname": null,
+        "last_seen": "2016-11-02T09:20:01.003",
+        "observer": {
+            "system_id": "4y3h7n",
+            "hostname": "karura",
+            "interface_id": 40,
+            "interface_name": null
+        },
+        "fabric_name": "fabric-0",
+        "vid": null,
+        "resource_uri": "/MAAS/api/2.0/discovery/MTkyLjE2OC4xMDAuNTEsNTI6NTQ6MDA6OWE6MTM6ZDc=/"
+    }
+]
+`
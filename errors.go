@@ -159,3 +159,56 @@ func IsCannotCompleteError(err error) bool {
 	_, ok := errors.Cause(err).(*CannotCompleteError)
 	return ok
 }
+
+// ReleaseMachinesError is returned by ReleaseMachines when MAAS reports which
+// of the machines could not be released. MAAS releases the machines all
+// together or not at all, so none of the machines were released, including
+// those that are not in Failed.
+type ReleaseMachinesError struct {
+	errors.Err
+
+	// Failed maps the system ID of each machine that blocked the release
+	// of the batch to the reason. Machines that MAAS does not know about have errors that
+	// satisfy IsNoMatchError, and those that are in a state that cannot be
+	// released have errors that satisfy IsCannotCompleteError.
+	Failed map[string]error
+}
+
+// NewReleaseMachinesError constructs a new ReleaseMachinesError wrapping
+// err, which stays the cause, and sets the location.
+func NewReleaseMachinesError(err error, failed map[string]error) error {
+	releaseErr := &ReleaseMachinesError{Err: errors.NewErrWithCause(err, ""), Failed: failed}
+	releaseErr.SetLocation(1)
+	return releaseErr
+}
+
+// IsReleaseMachinesError returns true if err is, or was annotated from, a
+// ReleaseMachinesError.
+func IsReleaseMachinesError(err error) bool {
+	return findReleaseMachinesError(err) != nil
+}
+
+// ReleaseMachinesFailures returns the Failed map of the ReleaseMachinesError
+// that err is, or was annotated from, or nil if there is none.
+func ReleaseMachinesFailures(err error) map[string]error {
+	if releaseErr := findReleaseMachinesError(err); releaseErr != nil {
+		return releaseErr.Failed
+	}
+	return nil
+}
+
+func findReleaseMachinesError(err error) *ReleaseMachinesError {
+	for err != nil {
+		if releaseErr, ok := err.(*ReleaseMachinesError); ok {
+			return releaseErr
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			return nil
+		}
+		err = wrapper.Underlying()
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// MAAS measures the storage constraint sizes in decimal gigabytes, and
+// ParseConstraints uses the same decimal units.
+const storageConstraintUnit = 1000 * 1000 * 1000
+
+// AllocationReport describes how a single machine compares to the
+// constraints of an AllocateMachineArgs.
+type AllocationReport struct {
+	SystemID string
+	Hostname string
+	// Failed holds a description of each constraint that the machine does
+	// not meet, prefixed with the name of the constraint.
+	Failed []string
+	// ConstraintMatches maps the labels of the storage and interface
+	// constraints to the disks and interfaces that were matched with them,
+	// when the machine meets those constraints. Storage constraints without
+	// a label use their index as the label, as MAAS does.
+	ConstraintMatches ConstraintMatches
+}
+
+// Matches returns true if the machine met all the evaluated constraints.
+func (r AllocationReport) Matches() bool {
+	return len(r.Failed) == 0
+}
+
+// ExplainAllocation evaluates the args against each of the machines known to
+// the controller, to explain why AllocateMachine could not find a match. Only
+// the status, system ID, hostname, architecture, CPU, memory, tags, zone,
+// storage, interfaces, spaces, subnets, fabrics and MAC address constraints
+// are evaluated; the others are ignored. As the evaluation is done locally it is
+// only an approximation of what MAAS does.
+func ExplainAllocation(controller Controller, args AllocateMachineArgs) ([]AllocationReport, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	machines, err := controller.Machines(MachinesArgs{})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make([]AllocationReport, len(machines))
+	for i, machine := range machines {
+		result[i] = explainMachine(machine, args)
+	}
+	return result, nil
+}
+
+func explainMachine(machine Machine, args AllocateMachineArgs) AllocationReport {
+	report := AllocationReport{
+		SystemID: machine.SystemID(),
+		Hostname: machine.Hostname(),
+	}
+	fail := func(name, format string, a ...interface{}) {
+		report.Failed = append(report.Failed, name+": "+fmt.Sprintf(format, a...))
+	}
+
+	if status := machine.StatusName(); status != "Ready" {
+		fail("status", "%s, not Ready", status)
+	}
+	if args.SystemID != "" && args.SystemID != machine.SystemID() {
+		fail("system_id", "not %s", args.SystemID)
+	}
+	if args.Hostname != "" && args.Hostname != machine.Hostname() {
+		fail("name", "not %s", args.Hostname)
+	}
+	if args.Architecture != "" && !architectureMatches(machine.Architecture(), args.Architecture) {
+		fail("arch", "%s, not %s", machine.Architecture(), args.Architecture)
+	}
+	if machine.CPUCount() < args.MinCPUCount {
+		fail("cpu_count", "%d, less than %d", machine.CPUCount(), args.MinCPUCount)
+	}
+	if machine.Memory() < args.MinMemory {
+		fail("mem", "%d MB, less than %d MB", machine.Memory(), args.MinMemory)
+	}
+
+	tags := set.NewStrings(machine.Tags()...)
+	if missing := set.NewStrings(args.Tags...).Difference(tags); !missing.IsEmpty() {
+		fail("tags", "missing %s", strings.Join(missing.SortedValues(), ", "))
+	}
+	if present := set.NewStrings(args.NotTags...).Intersection(tags); !present.IsEmpty() {
+		fail("not_tags", "has %s", strings.Join(present.SortedValues(), ", "))
+	}
+
+	var zone string
+	if machine.Zone() != nil {
+		zone = machine.Zone().Name()
+	}
+	if args.Zone != "" && args.Zone != zone {
+		fail("zone", "%s, not %s", zone, args.Zone)
+	}
+	if set.NewStrings(args.NotInZone...).Contains(zone) {
+		fail("not_in_zone", "in %s", zone)
+	}
+
+	if len(args.Storage) > 0 {
+		disks := machine.PhysicalBlockDevices()
+		matches := func(spec, disk int) bool {
+			return storageMatches(args.Storage[spec], disks[disk])
+		}
+		if assignment, ok := assignDistinct(len(args.Storage), len(disks), matches); !ok {
+			fail("storage", "no distinct disks for %s", args.storage())
+		} else {
+			report.ConstraintMatches.Storage = make(map[string][]BlockDevice)
+			for spec, disk := range assignment {
+				label := args.Storage[spec].Label
+				if label == "" {
+					label = fmt.Sprint(spec)
+				}
+				report.ConstraintMatches.Storage[label] = []BlockDevice{disks[disk]}
+			}
+		}
+	}
+
+	interfaces := machine.InterfaceSet()
+	spaces := set.NewStrings()
+	fabrics := set.NewStrings()
+	macs := set.NewStrings()
+	var subnets []Subnet
+	for _, iface := range interfaces {
+		spaces = spaces.Union(interfaceSpaces(iface))
+		if vlan := iface.VLAN(); vlan != nil {
+			fabrics.Add(vlan.Fabric())
+		}
+		macs.Add(strings.ToLower(iface.MACAddress()))
+		for _, link := range iface.Links() {
+			if subnet := link.Subnet(); subnet != nil {
+				subnets = append(subnets, subnet)
+			}
+		}
+	}
+	if len(args.Interfaces) > 0 {
+		matches := func(spec, iface int) bool {
+			return interfaceSpaces(interfaces[iface]).Contains(args.Interfaces[spec].Space)
+		}
+		if assignment, ok := assignDistinct(len(args.Interfaces), len(interfaces), matches); !ok {
+			fail("interfaces", "no distinct interfaces for %s", args.interfaces())
+		} else {
+			report.ConstraintMatches.Interfaces = make(map[string][]Interface)
+			for spec, iface := range assignment {
+				label := args.Interfaces[spec].Label
+				report.ConstraintMatches.Interfaces[label] = []Interface{interfaces[iface]}
+			}
+		}
+	}
+	if missing := set.NewStrings(args.Space...).Difference(spaces); !missing.IsEmpty() {
+		fail("spaces", "not in %s", strings.Join(missing.SortedValues(), ", "))
+	}
+	if present := set.NewStrings(args.NotSpace...).Intersection(spaces); !present.IsEmpty() {
+		fail("not_spaces", "in %s", strings.Join(present.SortedValues(), ", "))
+	}
+	onSubnet := func(specifier string) bool {
+		for _, subnet := range subnets {
+			if subnetMatches(subnet, specifier) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, specifier := range args.Subnets {
+		if !onSubnet(specifier) {
+			fail("subnets", "not on %s", specifier)
+		}
+	}
+	for _, specifier := range args.NotSubnets {
+		if onSubnet(specifier) {
+			fail("not_subnets", "on %s", specifier)
+		}
+	}
+	if missing := set.NewStrings(args.Fabrics...).Difference(fabrics); !missing.IsEmpty() {
+		fail("fabrics", "not on %s", strings.Join(missing.SortedValues(), ", "))
+	}
+	if present := set.NewStrings(args.NotFabrics...).Intersection(fabrics); !present.IsEmpty() {
+		fail("not_fabrics", "on %s", strings.Join(present.SortedValues(), ", "))
+	}
+	if args.MACAddress != "" && !macs.Contains(strings.ToLower(args.MACAddress)) {
+		fail("mac_address", "no interface with %s", args.MACAddress)
+	}
+	return report
+}
+
+// architectureMatches allows the constraint to leave off the subarchitecture,
+// so "amd64" matches "amd64/generic".
+func architectureMatches(arch, constraint string) bool {
+	if strings.Contains(constraint, "/") {
+		return arch == constraint
+	}
+	return strings.SplitN(arch, "/", 2)[0] == constraint
+}
+
+func storageMatches(spec StorageSpec, disk BlockDevice) bool {
+	if disk.Size() < uint64(spec.Size)*storageConstraintUnit {
+		return false
+	}
+	return set.NewStrings(spec.Tags...).Difference(set.NewStrings(disk.Tags()...)).IsEmpty()
+}
+
+// subnetMatches evaluates a MAAS subnet specifier, such as
+// "cidr:10.0.0.0/24", "vlan:42" (by VID), "ip:10.0.0.1", "id:3" or a bare
+// ID, name or CIDR.
+func subnetMatches(subnet Subnet, specifier string) bool {
+	kind, value := "", specifier
+	if colon := strings.Index(specifier, ":"); colon >= 0 {
+		kind, value = specifier[:colon], specifier[colon+1:]
+	}
+	id := fmt.Sprint(subnet.ID())
+	switch kind {
+	case "id":
+		return id == value
+	case "cidr":
+		return subnet.CIDR() == value
+	case "name":
+		return subnet.Name() == value
+	case "vlan", "vid":
+		return subnet.VLAN() != nil && fmt.Sprint(subnet.VLAN().VID()) == value
+	case "space":
+		return subnet.Space() == value
+	case "ip":
+		_, network, err := net.ParseCIDR(subnet.CIDR())
+		return err == nil && network.Contains(net.ParseIP(value))
+	}
+	return id == value || subnet.Name() == value || subnet.CIDR() == value
+}
+
+func interfaceSpaces(iface Interface) set.Strings {
+	spaces := set.NewStrings()
+	for _, link := range iface.Links() {
+		if subnet := link.Subnet(); subnet != nil {
+			spaces.Add(subnet.Space())
+		}
+	}
+	return spaces
+}
+
+// assignDistinct returns true if each of the specs can be matched with a
+// different one of the items, along with the index of the item matched with
+// each spec. The counts are small, so a simple backtracking search is fine.
+func assignDistinct(specs, items int, matches func(spec, item int) bool) ([]int, bool) {
+	used := make([]bool, items)
+	assignment := make([]int, specs)
+	var assign func(spec int) bool
+	assign = func(spec int) bool {
+		if spec == specs {
+			return true
+		}
+		for item := 0; item < items; item++ {
+			if used[item] || !matches(spec, item) {
+				continue
+			}
+			used[item] = true
+			assignment[spec] = item
+			if assign(spec + 1) {
+				return true
+			}
+			used[item] = false
+		}
+		return false
+	}
+	if !assign(0) {
+		return nil, false
+	}
+	return assignment, true
+}
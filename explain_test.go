@@ -0,0 +1,176 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type explainSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&explainSuite{})
+
+func (s *explainSuite) explain(c *gc.C, args AllocateMachineArgs) []AllocationReport {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	reports, err := ExplainAllocation(controller, args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(reports, gc.HasLen, 3)
+	return reports
+}
+
+func (s *explainSuite) TestNoConstraints(c *gc.C) {
+	reports := s.explain(c, AllocateMachineArgs{})
+	c.Check(reports[0], jc.DeepEquals, AllocationReport{
+		SystemID: "4y3ha3",
+		Hostname: "untasted-markita",
+		Failed:   []string{"status: Deployed, not Ready"},
+	})
+	c.Check(reports[0].Matches(), jc.IsFalse)
+	c.Check(reports[1], jc.DeepEquals, AllocationReport{
+		SystemID: "4y3ha4",
+		Hostname: "lowlier-glady",
+	})
+	c.Check(reports[1].Matches(), jc.IsTrue)
+	c.Check(reports[2].Matches(), jc.IsTrue)
+}
+
+func (s *explainSuite) TestFailures(c *gc.C) {
+	reports := s.explain(c, AllocateMachineArgs{
+		Architecture: "arm64",
+		MinCPUCount:  8,
+		MinMemory:    4096,
+		Tags:         []string{"virtual", "ssd"},
+		NotTags:      []string{"magic"},
+		Zone:         "az1",
+		NotInZone:    []string{"default"},
+		Storage:      []StorageSpec{{Size: 8}, {Size: 8, Tags: []string{"rotary"}}},
+		Interfaces:   []InterfaceSpec{{Label: "a", Space: "space-0"}, {Label: "b", Space: "space-0"}},
+		Space:        []string{"space-1"},
+		NotSpace:     []string{"space-0"},
+		Fabrics:      []string{"fabric-1"},
+		NotFabrics:   []string{"fabric-0"},
+		MACAddress:   "52:54:00:55:B6:80",
+	})
+	// The first machine has two disks and two interfaces, so meets those
+	// constraints, and has the MAC address.
+	c.Check(reports[0].Failed, jc.DeepEquals, []string{
+		"status: Deployed, not Ready",
+		"arch: amd64/generic, not arm64",
+		"cpu_count: 1, less than 8",
+		"mem: 1024 MB, less than 4096 MB",
+		"tags: missing ssd",
+		"not_tags: has magic",
+		"zone: default, not az1",
+		"not_in_zone: in default",
+		"spaces: not in space-1",
+		"not_spaces: in space-0",
+		"fabrics: not on fabric-1",
+		"not_fabrics: on fabric-0",
+	})
+	c.Check(reports[1].Failed, jc.DeepEquals, []string{
+		"arch: amd64/generic, not arm64",
+		"cpu_count: 1, less than 8",
+		"mem: 1024 MB, less than 4096 MB",
+		"tags: missing ssd",
+		"zone: default, not az1",
+		"not_in_zone: in default",
+		"storage: no distinct disks for 8,8(rotary)",
+		"interfaces: no distinct interfaces for a:space=space-0;b:space=space-0",
+		"spaces: not in space-1",
+		"not_spaces: in space-0",
+		"fabrics: not on fabric-1",
+		"not_fabrics: on fabric-0",
+		"mac_address: no interface with 52:54:00:55:B6:80",
+	})
+}
+
+func (s *explainSuite) TestMatching(c *gc.C) {
+	reports := s.explain(c, AllocateMachineArgs{
+		Architecture: "amd64",
+		Tags:         []string{"virtual"},
+		Zone:         "default",
+		Storage:      []StorageSpec{{Size: 8, Tags: []string{"rotary"}}},
+		Interfaces:   []InterfaceSpec{{Label: "a", Space: "space-0"}},
+		Space:        []string{"space-0"},
+		Fabrics:      []string{"fabric-0"},
+		MACAddress:   "52:54:00:c9:6a:45",
+	})
+	c.Check(reports[1].Failed, jc.DeepEquals, []string{
+		"mac_address: no interface with 52:54:00:c9:6a:45",
+	})
+	c.Check(reports[2].Matches(), jc.IsTrue)
+}
+
+func (s *explainSuite) TestStorageSize(c *gc.C) {
+	reports := s.explain(c, AllocateMachineArgs{
+		Storage: []StorageSpec{{Size: 9}},
+	})
+	c.Check(reports[1].Failed, jc.DeepEquals, []string{
+		"storage: no distinct disks for 9",
+	})
+}
+
+func (s *explainSuite) TestSubnets(c *gc.C) {
+	reports := s.explain(c, AllocateMachineArgs{
+		Subnets:    []string{"cidr:192.168.100.0/24", "vlan:0", "ip:192.168.100.7"},
+		NotSubnets: []string{"10.0.0.0/8"},
+	})
+	c.Check(reports[1].Matches(), jc.IsTrue)
+	reports = s.explain(c, AllocateMachineArgs{
+		Subnets:    []string{"vlan:42"},
+		NotSubnets: []string{"192.168.100.0/24"},
+	})
+	c.Check(reports[1].Failed, jc.DeepEquals, []string{
+		"subnets: not on vlan:42",
+		"not_subnets: on 192.168.100.0/24",
+	})
+}
+
+func (s *explainSuite) TestConstraintMatches(c *gc.C) {
+	reports := s.explain(c, AllocateMachineArgs{
+		Storage:    []StorageSpec{{Size: 8}, {Label: "data", Size: 8}},
+		Interfaces: []InterfaceSpec{{Label: "a", Space: "space-0"}},
+	})
+	matches := reports[0].ConstraintMatches
+	c.Assert(matches.Storage, gc.HasLen, 2)
+	c.Assert(matches.Storage["0"], gc.HasLen, 1)
+	c.Assert(matches.Storage["data"], gc.HasLen, 1)
+	c.Check(matches.Storage["0"][0].ID(), gc.Not(gc.Equals), matches.Storage["data"][0].ID())
+	c.Assert(matches.Interfaces["a"], gc.HasLen, 1)
+	c.Check(matches.Interfaces["a"][0].Name(), gc.Equals, "eth0")
+
+	// The second machine has only one disk, so there are no matches.
+	c.Check(reports[1].ConstraintMatches.Storage, gc.IsNil)
+	c.Check(reports[1].ConstraintMatches.Interfaces, gc.HasLen, 1)
+}
+
+func (s *explainSuite) TestInvalidArgs(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := ExplainAllocation(controller, AllocateMachineArgs{NotSpace: []string{""}})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *explainSuite) TestAssignDistinct(c *gc.C) {
+	// The first spec matches both items, the second only the first item, so
+	// a greedy assignment would fail.
+	matches := func(spec, item int) bool {
+		return spec == 0 || item == 0
+	}
+	assignment, ok := assignDistinct(2, 2, matches)
+	c.Check(ok, jc.IsTrue)
+	c.Check(assignment, jc.DeepEquals, []int{1, 0})
+	_, ok = assignDistinct(3, 2, matches)
+	c.Check(ok, jc.IsFalse)
+	assignment, ok = assignDistinct(0, 0, matches)
+	c.Check(ok, jc.IsTrue)
+	c.Check(assignment, gc.HasLen, 0)
+}
@@ -46,14 +46,37 @@ func (f *fabric) VLANs() []VLAN {
 	return result
 }
 
+func readFabric(controllerVersion version.Number, source interface{}) (*fabric, error) {
+	readFunc, err := getFabricDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, errors.Annotatef(err, "fabric base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
 func readFabrics(controllerVersion version.Number, source interface{}) ([]*fabric, error) {
+	readFunc, err := getFabricDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "fabric base schema check failed")
 	}
 	valid := coerced.([]interface{})
+	return readFabricList(valid, readFunc)
+}
 
+func getFabricDeserializationFunc(controllerVersion version.Number) (fabricDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range fabricDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -63,8 +86,7 @@ func readFabrics(controllerVersion version.Number, source interface{}) ([]*fabri
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no fabric read func for version %s", controllerVersion)
 	}
-	readFunc := fabricDeserializationFuncs[deserialisationVersion]
-	return readFabricList(valid, readFunc)
+	return fabricDeserializationFuncs[deserialisationVersion], nil
 }
 
 // readFabricList expects the values of the sourceList to be string maps.
@@ -0,0 +1,938 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// fakeAPIPrefix is the path of the 2.0 API on a FakeMAASServer.
+	fakeAPIPrefix = "/api/2.0/"
+
+	// DefaultFakeFabric is the ID of the fabric that every FakeMAASServer
+	// starts with. Its untagged VLAN is used for interfaces and subnets
+	// that do not specify one.
+	DefaultFakeFabric = 0
+	// DefaultFakeSpace is the ID of the space that every FakeMAASServer
+	// starts with, and that subnets are in unless they specify another.
+	DefaultFakeSpace = 0
+	// DefaultFakeZone is the name of the zone that every FakeMAASServer
+	// starts with, and that machines and devices are in unless they
+	// specify another.
+	DefaultFakeZone = "default"
+
+	machineStatusReady     = "Ready"
+	machineStatusAllocated = "Allocated"
+	machineStatusDeploying = "Deploying"
+)
+
+var fakeVersionJSON = map[string]interface{}{
+	"version":    "2.0.0",
+	"subversion": "fake",
+	"capabilities": []string{
+		"networks-management",
+		"static-ipaddresses",
+		"ipv6-deployment-ubuntu",
+		"devices-management",
+		"storage-deployment-ubuntu",
+		"network-deployment-ubuntu",
+	},
+}
+
+// FakeZone describes a zone of a FakeMAASServer.
+type FakeZone struct {
+	Name        string
+	Description string
+}
+
+// FakeFabric describes a fabric of a FakeMAASServer.
+type FakeFabric struct {
+	ID        int
+	Name      string
+	ClassType string
+}
+
+// FakeVLAN describes a VLAN of a FakeMAASServer.
+type FakeVLAN struct {
+	ID     int
+	Fabric int
+	VID    int
+	Name   string
+	MTU    int
+	DHCPOn bool
+}
+
+// FakeSpace describes a space of a FakeMAASServer.
+type FakeSpace struct {
+	ID   int
+	Name string
+}
+
+// FakeSubnet describes a subnet of a FakeMAASServer. VLAN and Space are the
+// IDs of the VLAN and space the subnet is on.
+type FakeSubnet struct {
+	ID         int
+	Name       string
+	CIDR       string
+	VLAN       int
+	Space      int
+	GatewayIP  string
+	DNSServers []string
+}
+
+// FakeLink describes the link of an interface to a subnet. Mode is one of
+// the lower case link modes reported by MAAS, such as "static" or "dhcp".
+type FakeLink struct {
+	ID        int
+	Mode      string
+	Subnet    int
+	IPAddress string
+}
+
+// FakeInterface describes a physical interface of a machine or device. If
+// VLAN is zero the interface is on the untagged VLAN of the default fabric.
+type FakeInterface struct {
+	ID         int
+	Name       string
+	MACAddress string
+	VLAN       int
+	Disabled   bool
+	Tags       []string
+	MTU        int
+	Links      []FakeLink
+}
+
+// FakeBlockDevice describes a physical disk of a machine. Size is in bytes.
+type FakeBlockDevice struct {
+	ID        int
+	Name      string
+	Model     string
+	Path      string
+	Size      uint64
+	BlockSize uint64
+	Tags      []string
+}
+
+// FakeMachine describes a machine of a FakeMAASServer. Memory is in MB.
+type FakeMachine struct {
+	SystemID      string
+	Hostname      string
+	Domain        string
+	Architecture  string
+	CPUCount      int
+	Memory        int
+	Tags          []string
+	Zone          string
+	StatusName    string
+	StatusMessage string
+	PowerState    string
+	OSystem       string
+	DistroSeries  string
+	Owner         string
+	OwnerData     map[string]string
+	AgentName     string
+	Interfaces    []FakeInterface
+	BlockDevices  []FakeBlockDevice
+}
+
+// FakeDevice describes a device of a FakeMAASServer. Parent is the system
+// ID of the machine the device belongs to, if any.
+type FakeDevice struct {
+	SystemID   string
+	Hostname   string
+	Domain     string
+	Parent     string
+	Owner      string
+	OwnerData  map[string]string
+	Zone       string
+	Interfaces []FakeInterface
+}
+
+// FakeMAASServer is a stateful fake of the MAAS 2.0 API, for testing code
+// that uses a Controller without writing the JSON responses by hand. It is
+// seeded with the Add methods, and then changes its state in response to
+// the requests it serves, such as allocating, deploying and releasing
+// machines, creating devices and linking interfaces to subnets. Only the
+// endpoints and parameters used by the Controller are supported. See
+// SetLifecycle for having machines move through the statuses over time,
+// AddFault for injecting faults into the responses, and State and Restore
+// for saving the state.
+type FakeMAASServer struct {
+	*httptest.Server
+	*faultInjector
+
+	mu       sync.Mutex
+	user     string
+	nextID   int
+	nextNode int
+	zones    []*FakeZone
+	fabrics  []*FakeFabric
+	vlans    []*FakeVLAN
+	spaces   []*FakeSpace
+	subnets  []*FakeSubnet
+	machines []*FakeMachine
+	devices  []*FakeDevice
+	files    map[string][]byte
+
+	// The simulated lifecycle of the machines, see SetLifecycle.
+	lifecycleSimulator
+}
+
+// NewFakeMAASServer starts and returns a new FakeMAASServer. It has the
+// default zone, fabric and space, and nothing else. Requests are made as
+// the user "admin", see SetUser.
+func NewFakeMAASServer() *FakeMAASServer {
+	server := &FakeMAASServer{
+		faultInjector: newFaultInjector(),
+		user:          "admin",
+		nextID:        1,
+		zones:         []*FakeZone{{Name: DefaultFakeZone, Description: ""}},
+		fabrics: []*FakeFabric{{
+			ID:   DefaultFakeFabric,
+			Name: fmt.Sprintf("fabric-%d", DefaultFakeFabric),
+		}},
+		spaces: []*FakeSpace{{
+			ID:   DefaultFakeSpace,
+			Name: fmt.Sprintf("space-%d", DefaultFakeSpace),
+		}},
+		files:              make(map[string][]byte),
+		lifecycleSimulator: newLifecycleSimulator(),
+	}
+	server.addUntaggedVLAN(DefaultFakeFabric)
+
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc(fakeAPIPrefix, server.handle)
+	server.Server = httptest.NewServer(server.wrap(serveMux))
+	return server
+}
+
+// SetUser sets the name of the user making the requests, which owns the
+// machines it allocates and the devices it creates.
+func (server *FakeMAASServer) SetUser(name string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.user = name
+}
+
+// AddZone adds the zone.
+func (server *FakeMAASServer) AddZone(zone FakeZone) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.zone(zone.Name) != nil {
+		panic(fmt.Sprintf("zone %q already exists", zone.Name))
+	}
+	server.zones = append(server.zones, &zone)
+}
+
+// AddFabric adds the fabric along with its untagged VLAN, and returns the
+// ID of the fabric. The ID is allocated if it is zero.
+func (server *FakeMAASServer) AddFabric(fabric FakeFabric) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if fabric.ID == 0 {
+		fabric.ID = server.newID()
+	}
+	if server.fabric(fabric.ID) != nil {
+		panic(fmt.Sprintf("fabric %d already exists", fabric.ID))
+	}
+	if fabric.Name == "" {
+		fabric.Name = fmt.Sprintf("fabric-%d", fabric.ID)
+	}
+	server.fabrics = append(server.fabrics, &fabric)
+	server.addUntaggedVLAN(fabric.ID)
+	return fabric.ID
+}
+
+// UntaggedVLAN returns the ID of the untagged VLAN of the fabric.
+func (server *FakeMAASServer) UntaggedVLAN(fabricID int) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, vlan := range server.vlans {
+		if vlan.Fabric == fabricID && vlan.VID == 0 {
+			return vlan.ID
+		}
+	}
+	panic(fmt.Sprintf("no fabric %d", fabricID))
+}
+
+// AddVLAN adds the VLAN and returns its ID, which is allocated if zero.
+func (server *FakeMAASServer) AddVLAN(vlan FakeVLAN) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.fabric(vlan.Fabric) == nil {
+		panic(fmt.Sprintf("no fabric %d", vlan.Fabric))
+	}
+	if vlan.ID == 0 {
+		vlan.ID = server.newID()
+	}
+	if vlan.MTU == 0 {
+		vlan.MTU = 1500
+	}
+	server.vlans = append(server.vlans, &vlan)
+	return vlan.ID
+}
+
+// AddSpace adds the space and returns its ID, which is allocated if zero.
+func (server *FakeMAASServer) AddSpace(space FakeSpace) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if space.ID == 0 {
+		space.ID = server.newID()
+	}
+	if server.space(space.ID) != nil {
+		panic(fmt.Sprintf("space %d already exists", space.ID))
+	}
+	if space.Name == "" {
+		space.Name = fmt.Sprintf("space-%d", space.ID)
+	}
+	server.spaces = append(server.spaces, &space)
+	return space.ID
+}
+
+// AddSubnet adds the subnet and returns its ID, which is allocated if zero.
+// The subnet is on the untagged VLAN of the default fabric if VLAN is zero.
+func (server *FakeMAASServer) AddSubnet(subnet FakeSubnet) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if subnet.ID == 0 {
+		subnet.ID = server.newID()
+	}
+	if subnet.VLAN == 0 {
+		subnet.VLAN = server.defaultVLAN().ID
+	}
+	if server.vlan(subnet.VLAN) == nil {
+		panic(fmt.Sprintf("no VLAN %d", subnet.VLAN))
+	}
+	if server.space(subnet.Space) == nil {
+		panic(fmt.Sprintf("no space %d", subnet.Space))
+	}
+	if subnet.Name == "" {
+		subnet.Name = subnet.CIDR
+	}
+	server.subnets = append(server.subnets, &subnet)
+	return subnet.ID
+}
+
+// AddMachine adds the machine and returns its system ID. Missing system,
+// interface, link and block device IDs are allocated, and the machine is
+// Ready in the default zone unless it says otherwise.
+func (server *FakeMAASServer) AddMachine(machine FakeMachine) string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	machine = machine.clone()
+	if machine.SystemID == "" {
+		machine.SystemID = server.newSystemID("m")
+	}
+	if server.machine(machine.SystemID) != nil || server.device(machine.SystemID) != nil {
+		panic(fmt.Sprintf("node %q already exists", machine.SystemID))
+	}
+	if machine.Hostname == "" {
+		machine.Hostname = "machine-" + machine.SystemID
+	}
+	if machine.Domain == "" {
+		machine.Domain = "maas"
+	}
+	if machine.Architecture == "" {
+		machine.Architecture = "amd64/generic"
+	}
+	if machine.Zone == "" {
+		machine.Zone = DefaultFakeZone
+	}
+	if machine.StatusName == "" {
+		machine.StatusName = machineStatusReady
+	}
+	if machine.PowerState == "" {
+		machine.PowerState = "off"
+	}
+	server.setupInterfaces(machine.Interfaces)
+	for i := range machine.BlockDevices {
+		disk := &machine.BlockDevices[i]
+		if disk.ID == 0 {
+			disk.ID = server.newID()
+		}
+		if disk.Name == "" {
+			disk.Name = fmt.Sprintf("sd%c", 'a'+i)
+		}
+		if disk.Path == "" {
+			disk.Path = "/dev/disk/by-dname/" + disk.Name
+		}
+		if disk.BlockSize == 0 {
+			disk.BlockSize = 4096
+		}
+	}
+	server.machines = append(server.machines, &machine)
+	return machine.SystemID
+}
+
+// AddDevice adds the device and returns its system ID. Missing system,
+// interface and link IDs are allocated.
+func (server *FakeMAASServer) AddDevice(device FakeDevice) string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	device = device.clone()
+	if device.SystemID == "" {
+		device.SystemID = server.newSystemID("d")
+	}
+	if server.machine(device.SystemID) != nil || server.device(device.SystemID) != nil {
+		panic(fmt.Sprintf("node %q already exists", device.SystemID))
+	}
+	server.addDevice(&device)
+	return device.SystemID
+}
+
+// AddFile adds the file, replacing any file with the same name.
+func (server *FakeMAASServer) AddFile(filename string, content []byte) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.files[filename] = append([]byte(nil), content...)
+}
+
+// SetMachineStatus changes the status of the machine, such as to simulate
+// it finishing or failing its deployment.
+func (server *FakeMAASServer) SetMachineStatus(systemID, status, message string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	machine := server.machine(systemID)
+	if machine == nil {
+		panic(fmt.Sprintf("no machine %q", systemID))
+	}
+	server.setStatus(machine, status, message)
+}
+
+// Machine returns a copy of the current state of the machine.
+func (server *FakeMAASServer) Machine(systemID string) (FakeMachine, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if machine := server.machine(systemID); machine != nil {
+		return machine.clone(), true
+	}
+	return FakeMachine{}, false
+}
+
+// Device returns a copy of the current state of the device.
+func (server *FakeMAASServer) Device(systemID string) (FakeDevice, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if device := server.device(systemID); device != nil {
+		return device.clone(), true
+	}
+	return FakeDevice{}, false
+}
+
+// File returns the content of the file.
+func (server *FakeMAASServer) File(filename string) ([]byte, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	content, ok := server.files[filename]
+	return append([]byte(nil), content...), ok
+}
+
+func (m *FakeMachine) clone() FakeMachine {
+	result := *m
+	result.Tags = append([]string(nil), m.Tags...)
+	result.OwnerData = cloneOwnerData(m.OwnerData)
+	result.Interfaces = cloneInterfaces(m.Interfaces)
+	result.BlockDevices = make([]FakeBlockDevice, len(m.BlockDevices))
+	for i, disk := range m.BlockDevices {
+		disk.Tags = append([]string(nil), disk.Tags...)
+		result.BlockDevices[i] = disk
+	}
+	return result
+}
+
+func (d *FakeDevice) clone() FakeDevice {
+	result := *d
+	result.OwnerData = cloneOwnerData(d.OwnerData)
+	result.Interfaces = cloneInterfaces(d.Interfaces)
+	return result
+}
+
+func cloneOwnerData(ownerData map[string]string) map[string]string {
+	result := make(map[string]string)
+	for key, value := range ownerData {
+		result[key] = value
+	}
+	return result
+}
+
+func cloneInterfaces(interfaces []FakeInterface) []FakeInterface {
+	result := make([]FakeInterface, len(interfaces))
+	for i, iface := range interfaces {
+		iface.Tags = append([]string(nil), iface.Tags...)
+		iface.Links = append([]FakeLink(nil), iface.Links...)
+		result[i] = iface
+	}
+	return result
+}
+
+// The methods below expect the caller to hold the lock.
+
+func (server *FakeMAASServer) newID() int {
+	id := server.nextID
+	server.nextID++
+	return id
+}
+
+func (server *FakeMAASServer) newSystemID(prefix string) string {
+	server.nextNode++
+	return fmt.Sprintf("%s%05d", prefix, server.nextNode)
+}
+
+func (server *FakeMAASServer) addUntaggedVLAN(fabricID int) {
+	server.vlans = append(server.vlans, &FakeVLAN{
+		ID:     server.newID(),
+		Fabric: fabricID,
+		Name:   "untagged",
+		MTU:    1500,
+	})
+}
+
+func (server *FakeMAASServer) addDevice(device *FakeDevice) {
+	if device.Hostname == "" {
+		device.Hostname = "device-" + device.SystemID
+	}
+	if device.Domain == "" {
+		device.Domain = "maas"
+	}
+	if device.Zone == "" {
+		device.Zone = DefaultFakeZone
+	}
+	if device.OwnerData == nil {
+		device.OwnerData = make(map[string]string)
+	}
+	server.setupInterfaces(device.Interfaces)
+	server.devices = append(server.devices, device)
+}
+
+func (server *FakeMAASServer) setupInterfaces(interfaces []FakeInterface) {
+	for i := range interfaces {
+		iface := &interfaces[i]
+		if iface.ID == 0 {
+			iface.ID = server.newID()
+		}
+		if iface.Name == "" {
+			iface.Name = fmt.Sprintf("eth%d", i)
+		}
+		if iface.VLAN == 0 {
+			iface.VLAN = server.defaultVLAN().ID
+		}
+		if server.vlan(iface.VLAN) == nil {
+			panic(fmt.Sprintf("no VLAN %d", iface.VLAN))
+		}
+		for j := range iface.Links {
+			link := &iface.Links[j]
+			if link.ID == 0 {
+				link.ID = server.newID()
+			}
+			if server.subnet(link.Subnet) == nil {
+				panic(fmt.Sprintf("no subnet %d", link.Subnet))
+			}
+		}
+	}
+}
+
+func (server *FakeMAASServer) zone(name string) *FakeZone {
+	for _, zone := range server.zones {
+		if zone.Name == name {
+			return zone
+		}
+	}
+	return nil
+}
+
+func (server *FakeMAASServer) fabric(id int) *FakeFabric {
+	for _, fabric := range server.fabrics {
+		if fabric.ID == id {
+			return fabric
+		}
+	}
+	return nil
+}
+
+func (server *FakeMAASServer) vlan(id int) *FakeVLAN {
+	for _, vlan := range server.vlans {
+		if vlan.ID == id {
+			return vlan
+		}
+	}
+	return nil
+}
+
+func (server *FakeMAASServer) defaultVLAN() *FakeVLAN {
+	for _, vlan := range server.vlans {
+		if vlan.Fabric == DefaultFakeFabric && vlan.VID == 0 {
+			return vlan
+		}
+	}
+	panic("missing default VLAN")
+}
+
+func (server *FakeMAASServer) space(id int) *FakeSpace {
+	for _, space := range server.spaces {
+		if space.ID == id {
+			return space
+		}
+	}
+	return nil
+}
+
+func (server *FakeMAASServer) subnet(id int) *FakeSubnet {
+	for _, subnet := range server.subnets {
+		if subnet.ID == id {
+			return subnet
+		}
+	}
+	return nil
+}
+
+func (server *FakeMAASServer) machine(systemID string) *FakeMachine {
+	for _, machine := range server.machines {
+		if machine.SystemID == systemID {
+			return machine
+		}
+	}
+	return nil
+}
+
+func (server *FakeMAASServer) device(systemID string) *FakeDevice {
+	for _, device := range server.devices {
+		if device.SystemID == systemID {
+			return device
+		}
+	}
+	return nil
+}
+
+// nodeInterfaces returns the interfaces of the machine or device.
+func (server *FakeMAASServer) nodeInterfaces(systemID string) (*[]FakeInterface, bool) {
+	if machine := server.machine(systemID); machine != nil {
+		return &machine.Interfaces, true
+	}
+	if device := server.device(systemID); device != nil {
+		return &device.Interfaces, true
+	}
+	return nil, false
+}
+
+// handle serves all the requests, dispatching on the first element of the
+// path after the API prefix.
+func (server *FakeMAASServer) handle(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		fakeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, fakeAPIPrefix), "/")
+	parts := strings.Split(path, "/")
+	op := r.URL.Query().Get("op")
+	switch parts[0] {
+	case "version":
+		server.handleVersion(w, r, parts[1:], op)
+	case "users":
+		server.handleUsers(w, r, parts[1:], op)
+	case "machines":
+		server.handleMachines(w, r, parts[1:], op)
+	case "devices":
+		server.handleDevices(w, r, parts[1:], op)
+	case "nodes":
+		server.handleNodes(w, r, parts[1:], op)
+	case "files":
+		server.handleFiles(w, r, parts[1:], op)
+	case "zones":
+		server.handleZones(w, r, parts[1:], op)
+	case "fabrics":
+		server.handleFabrics(w, r, parts[1:], op)
+	case "spaces":
+		server.handleSpaces(w, r, parts[1:], op)
+	case "subnets":
+		server.handleSubnets(w, r, parts[1:], op)
+	case "events":
+		server.handleEvents(w, r, parts[1:], op)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (server *FakeMAASServer) handleVersion(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if len(parts) != 0 || r.Method != "GET" || op != "" {
+		fakeBadSignature(w, r, op)
+		return
+	}
+	fakeJSON(w, fakeVersionJSON)
+}
+
+func (server *FakeMAASServer) handleUsers(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if len(parts) != 0 || r.Method != "GET" || op != "whoami" {
+		fakeBadSignature(w, r, op)
+		return
+	}
+	fakeJSON(w, server.user)
+}
+
+// fakeJSON writes the value as a JSON response.
+func fakeJSON(w http.ResponseWriter, value interface{}) {
+	bytes, err := json.Marshal(value)
+	checkError(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+// fakeError writes a plain text error response, as MAAS does.
+func fakeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, format, args...)
+}
+
+// fakeBadSignature reports a method and op that the endpoint does not
+// support, with the message used by MAAS.
+func fakeBadSignature(w http.ResponseWriter, r *http.Request, op string) {
+	signature := "method=" + r.Method
+	if op != "" {
+		signature += " op=" + op
+	}
+	fakeError(w, http.StatusBadRequest, "Unrecognised signature: %s", signature)
+}
+
+// fakeIntID parses the ID in the path, reporting a 404 if it is not a
+// number.
+func fakeIntID(w http.ResponseWriter, value string) (int, bool) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		fakeError(w, http.StatusNotFound, "Not Found")
+		return 0, false
+	}
+	return id, true
+}
+
+// filterValues returns true if there are no filter values, or the value
+// is one of them.
+func filterValues(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedFilenames(files map[string][]byte) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JSON rendering, matching the fields read by the 2.0 deserialization
+// functions.
+
+func (server *FakeMAASServer) zoneJSON(name string) map[string]interface{} {
+	var description string
+	if zone := server.zone(name); zone != nil {
+		description = zone.Description
+	}
+	return map[string]interface{}{
+		"name":         name,
+		"description":  description,
+		"resource_uri": fakeAPIPrefix + "zones/" + name + "/",
+	}
+}
+
+func (server *FakeMAASServer) vlanJSON(vlan *FakeVLAN) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             vlan.ID,
+		"resource_uri":   fmt.Sprintf("%sfabrics/%d/vlans/%d/", fakeAPIPrefix, vlan.Fabric, vlan.ID),
+		"name":           vlan.Name,
+		"fabric":         server.fabric(vlan.Fabric).Name,
+		"vid":            vlan.VID,
+		"mtu":            vlan.MTU,
+		"dhcp_on":        vlan.DHCPOn,
+		"primary_rack":   nil,
+		"secondary_rack": nil,
+	}
+}
+
+func (server *FakeMAASServer) fabricJSON(fabric *FakeFabric) map[string]interface{} {
+	vlans := []interface{}{}
+	for _, vlan := range server.vlans {
+		if vlan.Fabric == fabric.ID {
+			vlans = append(vlans, server.vlanJSON(vlan))
+		}
+	}
+	var classType interface{}
+	if fabric.ClassType != "" {
+		classType = fabric.ClassType
+	}
+	return map[string]interface{}{
+		"resource_uri": fmt.Sprintf("%sfabrics/%d/", fakeAPIPrefix, fabric.ID),
+		"id":           fabric.ID,
+		"name":         fabric.Name,
+		"class_type":   classType,
+		"vlans":        vlans,
+	}
+}
+
+func (server *FakeMAASServer) subnetJSON(subnet *FakeSubnet) map[string]interface{} {
+	var gateway interface{}
+	if subnet.GatewayIP != "" {
+		gateway = subnet.GatewayIP
+	}
+	return map[string]interface{}{
+		"resource_uri": fmt.Sprintf("%ssubnets/%d/", fakeAPIPrefix, subnet.ID),
+		"id":           subnet.ID,
+		"name":         subnet.Name,
+		"space":        server.space(subnet.Space).Name,
+		"gateway_ip":   gateway,
+		"cidr":         subnet.CIDR,
+		"vlan":         server.vlanJSON(server.vlan(subnet.VLAN)),
+		"dns_servers":  append([]string{}, subnet.DNSServers...),
+	}
+}
+
+func (server *FakeMAASServer) spaceJSON(space *FakeSpace) map[string]interface{} {
+	subnets := []interface{}{}
+	for _, subnet := range server.subnets {
+		if subnet.Space == space.ID {
+			subnets = append(subnets, server.subnetJSON(subnet))
+		}
+	}
+	return map[string]interface{}{
+		"resource_uri": fmt.Sprintf("%sspaces/%d/", fakeAPIPrefix, space.ID),
+		"id":           space.ID,
+		"name":         space.Name,
+		"subnets":      subnets,
+	}
+}
+
+func (server *FakeMAASServer) interfaceJSON(systemID string, iface *FakeInterface) map[string]interface{} {
+	links := []interface{}{}
+	for _, link := range iface.Links {
+		links = append(links, map[string]interface{}{
+			"id":         link.ID,
+			"mode":       link.Mode,
+			"subnet":     server.subnetJSON(server.subnet(link.Subnet)),
+			"ip_address": link.IPAddress,
+		})
+	}
+	vlan := server.vlan(iface.VLAN)
+	mtu := iface.MTU
+	if mtu == 0 {
+		mtu = vlan.MTU
+	}
+	return map[string]interface{}{
+		"resource_uri":  fmt.Sprintf("%snodes/%s/interfaces/%d/", fakeAPIPrefix, systemID, iface.ID),
+		"id":            iface.ID,
+		"name":          iface.Name,
+		"type":          "physical",
+		"enabled":       !iface.Disabled,
+		"tags":          append([]string{}, iface.Tags...),
+		"vlan":          server.vlanJSON(vlan),
+		"links":         links,
+		"mac_address":   iface.MACAddress,
+		"effective_mtu": mtu,
+		"parents":       []string{},
+		"children":      []string{},
+	}
+}
+
+func (server *FakeMAASServer) interfacesJSON(systemID string, interfaces []FakeInterface) ([]interface{}, []string) {
+	result := []interface{}{}
+	addresses := []string{}
+	for i := range interfaces {
+		result = append(result, server.interfaceJSON(systemID, &interfaces[i]))
+		for _, link := range interfaces[i].Links {
+			if link.IPAddress != "" {
+				addresses = append(addresses, link.IPAddress)
+			}
+		}
+	}
+	return result, addresses
+}
+
+func blockDeviceJSON(systemID string, disk FakeBlockDevice) map[string]interface{} {
+	return map[string]interface{}{
+		"resource_uri": fmt.Sprintf("%snodes/%s/blockdevices/%d/", fakeAPIPrefix, systemID, disk.ID),
+		"id":           disk.ID,
+		"name":         disk.Name,
+		"model":        disk.Model,
+		"path":         disk.Path,
+		"used_for":     "",
+		"tags":         append([]string{}, disk.Tags...),
+		"block_size":   disk.BlockSize,
+		"used_size":    0,
+		"size":         disk.Size,
+		"partitions":   []interface{}{},
+	}
+}
+
+func (server *FakeMAASServer) machineJSON(machine *FakeMachine) map[string]interface{} {
+	interfaces, addresses := server.interfacesJSON(machine.SystemID, machine.Interfaces)
+	var bootInterface interface{}
+	if len(interfaces) > 0 {
+		bootInterface = interfaces[0]
+	}
+	disks := []interface{}{}
+	for _, disk := range machine.BlockDevices {
+		disks = append(disks, blockDeviceJSON(machine.SystemID, disk))
+	}
+	return map[string]interface{}{
+		"resource_uri":            fakeAPIPrefix + "machines/" + machine.SystemID + "/",
+		"system_id":               machine.SystemID,
+		"hostname":                machine.Hostname,
+		"fqdn":                    machine.Hostname + "." + machine.Domain,
+		"tag_names":               append([]string{}, machine.Tags...),
+		"owner_data":              cloneOwnerData(machine.OwnerData),
+		"osystem":                 machine.OSystem,
+		"distro_series":           machine.DistroSeries,
+		"architecture":            machine.Architecture,
+		"memory":                  machine.Memory,
+		"cpu_count":               machine.CPUCount,
+		"ip_addresses":            addresses,
+		"power_state":             machine.PowerState,
+		"status_name":             machine.StatusName,
+		"status_message":          machine.StatusMessage,
+		"boot_interface":          bootInterface,
+		"interface_set":           interfaces,
+		"zone":                    server.zoneJSON(machine.Zone),
+		"physicalblockdevice_set": disks,
+		"blockdevice_set":         disks,
+	}
+}
+
+func (server *FakeMAASServer) deviceJSON(device *FakeDevice) map[string]interface{} {
+	interfaces, addresses := server.interfacesJSON(device.SystemID, device.Interfaces)
+	var parent, owner interface{}
+	if device.Parent != "" {
+		parent = device.Parent
+	}
+	if device.Owner != "" {
+		owner = device.Owner
+	}
+	return map[string]interface{}{
+		"resource_uri":  fakeAPIPrefix + "devices/" + device.SystemID + "/",
+		"system_id":     device.SystemID,
+		"hostname":      device.Hostname,
+		"fqdn":          device.Hostname + "." + device.Domain,
+		"parent":        parent,
+		"owner":         owner,
+		"owner_data":    cloneOwnerData(device.OwnerData),
+		"ip_addresses":  addresses,
+		"interface_set": interfaces,
+		"zone":          server.zoneJSON(device.Zone),
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleDevices handles requests for '/api/2.0/devices/*'.
+func (server *FakeMAASServer) handleDevices(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET" && op == "":
+		server.listDevices(w, r)
+	case len(parts) == 0 && r.Method == "POST" && op == "":
+		server.createDevice(w, r)
+	case len(parts) == 1:
+		device := server.device(parts[0])
+		if device == nil {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		server.handleDevice(w, r, device, op)
+	default:
+		fakeBadSignature(w, r, op)
+	}
+}
+
+func (server *FakeMAASServer) handleDevice(w http.ResponseWriter, r *http.Request, device *FakeDevice, op string) {
+	switch {
+	case r.Method == "GET" && op == "":
+	case r.Method == "PUT" && op == "":
+		if !server.checkOwner(w, device.Owner) || !server.updateDevice(w, r, device) {
+			return
+		}
+	case r.Method == "DELETE" && op == "":
+		if !server.checkOwner(w, device.Owner) {
+			return
+		}
+		for i, d := range server.devices {
+			if d == device {
+				server.devices = append(server.devices[:i], server.devices[i+1:]...)
+				break
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == "POST" && op == "set_owner_data":
+		if !server.checkOwner(w, device.Owner) {
+			return
+		}
+		setOwnerData(device.OwnerData, r.PostForm)
+	default:
+		fakeBadSignature(w, r, op)
+		return
+	}
+	fakeJSON(w, server.deviceJSON(device))
+}
+
+func (server *FakeMAASServer) listDevices(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result := []interface{}{}
+	for _, device := range server.devices {
+		if !filterValues(query["hostname"], device.Hostname) ||
+			!filterValues(query["id"], device.SystemID) ||
+			!filterInterfaceMACs(query["mac_address"], device.Interfaces) ||
+			!filterValues(query["domain"], device.Domain) ||
+			!filterValues(query["zone"], device.Zone) {
+			continue
+		}
+		result = append(result, server.deviceJSON(device))
+	}
+	fakeJSON(w, result)
+}
+
+func (server *FakeMAASServer) createDevice(w http.ResponseWriter, r *http.Request) {
+	form := r.PostForm
+	macs := form["mac_addresses"]
+	if len(macs) == 0 {
+		fakeError(w, http.StatusBadRequest, "mac_addresses: This field is required.")
+		return
+	}
+	parent := form.Get("parent")
+	if parent != "" && server.machine(parent) == nil {
+		fakeError(w, http.StatusBadRequest, "parent: Node %q not found.", parent)
+		return
+	}
+	device := &FakeDevice{
+		SystemID: server.newSystemID("d"),
+		Hostname: form.Get("hostname"),
+		Domain:   form.Get("domain"),
+		Parent:   parent,
+		Owner:    server.user,
+	}
+	for _, mac := range macs {
+		if _, err := net.ParseMAC(mac); err != nil {
+			fakeError(w, http.StatusBadRequest, "mac_addresses: %q is not a valid MAC address.", mac)
+			return
+		}
+		if server.macInUse(mac) {
+			fakeError(w, http.StatusBadRequest, "mac_addresses: This MAC address is already in use by %s.", mac)
+			return
+		}
+		device.Interfaces = append(device.Interfaces, FakeInterface{MACAddress: mac})
+	}
+	server.addDevice(device)
+	fakeJSON(w, server.deviceJSON(device))
+}
+
+func (server *FakeMAASServer) updateDevice(w http.ResponseWriter, r *http.Request, device *FakeDevice) bool {
+	form := r.PostForm
+	if zone := form.Get("zone"); zone != "" {
+		if server.zone(zone) == nil {
+			fakeError(w, http.StatusBadRequest, "zone: Select a valid choice.")
+			return false
+		}
+		device.Zone = zone
+	}
+	if parent := form.Get("parent"); parent != "" {
+		if server.machine(parent) == nil {
+			fakeError(w, http.StatusBadRequest, "parent: Node %q not found.", parent)
+			return false
+		}
+		device.Parent = parent
+	}
+	if hostname := form.Get("hostname"); hostname != "" {
+		device.Hostname = hostname
+	}
+	if domain := form.Get("domain"); domain != "" {
+		device.Domain = domain
+	}
+	return true
+}
+
+// macInUse returns true if any interface of a machine or device has the MAC
+// address.
+func (server *FakeMAASServer) macInUse(mac string) bool {
+	for _, machine := range server.machines {
+		if filterInterfaceMACs([]string{mac}, machine.Interfaces) {
+			return true
+		}
+	}
+	for _, device := range server.devices {
+		if filterInterfaceMACs([]string{mac}, device.Interfaces) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleNodes handles requests for '/api/2.0/nodes/<system_id>/interfaces/*',
+// which are the interfaces of both machines and devices.
+func (server *FakeMAASServer) handleNodes(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != "interfaces" {
+		http.NotFound(w, r)
+		return
+	}
+	systemID := parts[0]
+	interfaces, ok := server.nodeInterfaces(systemID)
+	if !ok {
+		fakeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if len(parts) == 2 {
+		switch {
+		case r.Method == "GET" && op == "":
+			result, _ := server.interfacesJSON(systemID, *interfaces)
+			fakeJSON(w, result)
+		case r.Method == "POST" && op == "create_physical":
+			server.createInterface(w, r, systemID, interfaces)
+		default:
+			fakeBadSignature(w, r, op)
+		}
+		return
+	}
+	id, ok := fakeIntID(w, parts[2])
+	if !ok {
+		return
+	}
+	for i := range *interfaces {
+		if iface := &(*interfaces)[i]; iface.ID == id {
+			server.handleInterface(w, r, systemID, interfaces, i, op)
+			return
+		}
+	}
+	fakeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (server *FakeMAASServer) handleInterface(w http.ResponseWriter, r *http.Request, systemID string, interfaces *[]FakeInterface, index int, op string) {
+	iface := &(*interfaces)[index]
+	switch {
+	case r.Method == "GET" && op == "":
+	case r.Method == "PUT" && op == "":
+		if !server.updateInterface(w, r, iface) {
+			return
+		}
+	case r.Method == "DELETE" && op == "":
+		*interfaces = append((*interfaces)[:index], (*interfaces)[index+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == "POST" && op == "link_subnet":
+		if !server.linkSubnet(w, r, iface) {
+			return
+		}
+	case r.Method == "POST" && op == "unlink_subnet":
+		if !unlinkSubnet(w, r, iface) {
+			return
+		}
+	default:
+		fakeBadSignature(w, r, op)
+		return
+	}
+	fakeJSON(w, server.interfaceJSON(systemID, iface))
+}
+
+func (server *FakeMAASServer) createInterface(w http.ResponseWriter, r *http.Request, systemID string, interfaces *[]FakeInterface) {
+	form := r.PostForm
+	iface := FakeInterface{
+		Name:       form.Get("name"),
+		MACAddress: form.Get("mac_address"),
+	}
+	if iface.Name == "" || iface.MACAddress == "" {
+		fakeError(w, http.StatusBadRequest, "name and mac_address are required.")
+		return
+	}
+	for _, existing := range *interfaces {
+		if existing.Name == iface.Name {
+			fakeError(w, http.StatusBadRequest, "name: Interface %q already exists on this node.", iface.Name)
+			return
+		}
+	}
+	if server.macInUse(iface.MACAddress) {
+		fakeError(w, http.StatusBadRequest, "mac_address: This MAC address is already in use by %s.", iface.MACAddress)
+		return
+	}
+	vlan, ok := server.formVLAN(w, form.Get("vlan"))
+	if !ok {
+		return
+	}
+	iface.VLAN = vlan
+	if tags := form.Get("tags"); tags != "" {
+		iface.Tags = strings.Split(tags, ",")
+	}
+	if mtu := form.Get("mtu"); mtu != "" {
+		var err error
+		if iface.MTU, err = strconv.Atoi(mtu); err != nil {
+			fakeError(w, http.StatusBadRequest, "mtu: Enter a whole number.")
+			return
+		}
+	}
+	iface.ID = server.newID()
+	*interfaces = append(*interfaces, iface)
+	fakeJSON(w, server.interfaceJSON(systemID, &(*interfaces)[len(*interfaces)-1]))
+}
+
+// formVLAN checks the VLAN ID from a form, which must be given.
+func (server *FakeMAASServer) formVLAN(w http.ResponseWriter, value string) (int, bool) {
+	id, err := strconv.Atoi(value)
+	if err != nil || server.vlan(id) == nil {
+		fakeError(w, http.StatusBadRequest, "vlan: Select a valid choice.")
+		return 0, false
+	}
+	return id, true
+}
+
+func (server *FakeMAASServer) updateInterface(w http.ResponseWriter, r *http.Request, iface *FakeInterface) bool {
+	form := r.PostForm
+	if value := form.Get("vlan"); value != "" {
+		vlan, ok := server.formVLAN(w, value)
+		if !ok {
+			return false
+		}
+		iface.VLAN = vlan
+	}
+	if name := form.Get("name"); name != "" {
+		iface.Name = name
+	}
+	if mac := form.Get("mac_address"); mac != "" {
+		iface.MACAddress = mac
+	}
+	return true
+}
+
+func (server *FakeMAASServer) linkSubnet(w http.ResponseWriter, r *http.Request, iface *FakeInterface) bool {
+	form := r.PostForm
+	mode := strings.ToLower(form.Get("mode"))
+	switch mode {
+	case "dhcp", "static", "link_up":
+	default:
+		fakeError(w, http.StatusBadRequest, "mode: Select a valid choice.")
+		return false
+	}
+	id, err := strconv.Atoi(form.Get("subnet"))
+	subnet := server.subnet(id)
+	if err != nil || subnet == nil {
+		fakeError(w, http.StatusBadRequest, "subnet: Select a valid choice.")
+		return false
+	}
+	link := FakeLink{Mode: mode, Subnet: subnet.ID}
+	if mode == "static" {
+		address, ok := server.staticAddress(w, subnet, form.Get("ip_address"))
+		if !ok {
+			return false
+		}
+		link.IPAddress = address
+	}
+	link.ID = server.newID()
+	iface.Links = append(iface.Links, link)
+	return true
+}
+
+func unlinkSubnet(w http.ResponseWriter, r *http.Request, iface *FakeInterface) bool {
+	id, err := strconv.Atoi(r.PostForm.Get("id"))
+	if err == nil {
+		for i, link := range iface.Links {
+			if link.ID == id {
+				iface.Links = append(iface.Links[:i], iface.Links[i+1:]...)
+				return true
+			}
+		}
+	}
+	fakeError(w, http.StatusBadRequest, "id: Select a valid choice.")
+	return false
+}
+
+// staticAddress checks that the requested address is in the subnet and not
+// in use, or picks the first free address after the gateway if none was
+// requested.
+func (server *FakeMAASServer) staticAddress(w http.ResponseWriter, subnet *FakeSubnet, requested string) (string, bool) {
+	_, network, err := net.ParseCIDR(subnet.CIDR)
+	if err != nil {
+		fakeError(w, http.StatusBadRequest, "subnet %d has a bad CIDR %q", subnet.ID, subnet.CIDR)
+		return "", false
+	}
+	used := server.usedAddresses()
+	used[subnet.GatewayIP] = true
+	if requested != "" {
+		ip := net.ParseIP(requested)
+		if ip == nil || !network.Contains(ip) {
+			fakeError(w, http.StatusBadRequest, "ip_address: IP address %s is not within subnet %s.", requested, subnet.CIDR)
+			return "", false
+		}
+		if used[ip.String()] {
+			fakeError(w, http.StatusBadRequest, "ip_address: IP address %s is already in use.", requested)
+			return "", false
+		}
+		return ip.String(), true
+	}
+	ip := nextIP(network.IP)
+	for ; network.Contains(ip); ip = nextIP(ip) {
+		if !used[ip.String()] {
+			return ip.String(), true
+		}
+	}
+	fakeError(w, http.StatusServiceUnavailable, "No more IPs available in subnet: %s.", subnet.CIDR)
+	return "", false
+}
+
+// usedAddresses returns the link addresses of all the machines and devices.
+func (server *FakeMAASServer) usedAddresses() map[string]bool {
+	used := make(map[string]bool)
+	add := func(interfaces []FakeInterface) {
+		for _, iface := range interfaces {
+			for _, link := range iface.Links {
+				if ip := net.ParseIP(link.IPAddress); ip != nil {
+					used[ip.String()] = true
+				}
+			}
+		}
+	}
+	for _, machine := range server.machines {
+		add(machine.Interfaces)
+	}
+	for _, device := range server.devices {
+		add(device.Interfaces)
+	}
+	return used
+}
+
+// nextIP returns the address after ip.
+func nextIP(ip net.IP) net.IP {
+	result := append(net.IP(nil), ip...)
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i]++
+		if result[i] != 0 {
+			break
+		}
+	}
+	return result
+}
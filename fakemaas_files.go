@@ -0,0 +1,85 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// handleFiles handles requests for '/api/2.0/files/*'.
+func (server *FakeMAASServer) handleFiles(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET" && op == "":
+		prefix := r.URL.Query().Get("prefix")
+		result := []interface{}{}
+		for _, name := range sortedFilenames(server.files) {
+			if strings.HasPrefix(name, prefix) {
+				result = append(result, fileJSON(name, nil))
+			}
+		}
+		fakeJSON(w, result)
+	case len(parts) == 0 && r.Method == "GET" && op == "get":
+		content, ok := server.files[r.URL.Query().Get("filename")]
+		if !ok {
+			fakeError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		w.Write(content)
+	case len(parts) == 0 && r.Method == "POST" && op == "":
+		server.addFile(w, r)
+	case len(parts) == 1 && r.Method == "GET" && op == "":
+		content, ok := server.files[parts[0]]
+		if !ok {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		fakeJSON(w, fileJSON(parts[0], content))
+	case len(parts) == 1 && r.Method == "DELETE" && op == "":
+		if _, ok := server.files[parts[0]]; !ok {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		delete(server.files, parts[0])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeBadSignature(w, r, op)
+	}
+}
+
+func (server *FakeMAASServer) addFile(w http.ResponseWriter, r *http.Request) {
+	filename := r.PostForm.Get("filename")
+	if filename == "" {
+		fakeError(w, http.StatusBadRequest, "Filename not supplied")
+		return
+	}
+	if r.MultipartForm == nil || len(r.MultipartForm.File["file"]) != 1 {
+		fakeError(w, http.StatusBadRequest, "Exactly one file must be supplied")
+		return
+	}
+	file, err := r.MultipartForm.File["file"][0].Open()
+	checkError(err)
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	checkError(err)
+	server.files[filename] = content
+	fakeJSON(w, fileJSON(filename, content))
+}
+
+// fileJSON renders the file, with the content only if it is given, as
+// MAAS does not include it in listings.
+func fileJSON(filename string, content []byte) map[string]interface{} {
+	result := map[string]interface{}{
+		"resource_uri":      fakeAPIPrefix + "files/" + url.QueryEscape(filename) + "/",
+		"filename":          filename,
+		"anon_resource_uri": fakeAPIPrefix + "files/?op=get_by_key&key=" + url.QueryEscape(filename),
+	}
+	if content != nil {
+		result["content"] = base64.StdEncoding.EncodeToString(content)
+	}
+	return result
+}
@@ -0,0 +1,368 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/utils/set"
+)
+
+const (
+	machineStatusCommissioning       = "Commissioning"
+	machineStatusFailedCommissioning = "Failed commissioning"
+	machineStatusReleasing           = "Releasing"
+	machineStatusDiskErasing         = "Disk erasing"
+	machineStatusFailedDiskErasing   = "Failed disk erasing"
+
+	// fakeEventTimeFormat is the format of the times of MAAS events.
+	fakeEventTimeFormat = "Mon, 02 Jan. 2006 15:04:05"
+)
+
+// fakeEpoch is the time the clock of every FakeMAASServer and TestServer
+// starts at.
+var fakeEpoch = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// commissionableStatuses are the machine statuses from which a machine can
+// be commissioned.
+var commissionableStatuses = set.NewStrings(
+	"New",
+	machineStatusReady,
+	machineStatusFailedCommissioning,
+	"Broken",
+)
+
+// FakeLifecycle configures the simulated lifecycle of the machines of a
+// FakeMAASServer or the nodes of a TestServer, see their SetLifecycle.
+// Commissioning, deploying, releasing and erasing a machine take the given
+// times on the clock of the server, which only moves when Advance is called.
+type FakeLifecycle struct {
+	CommissionTime time.Duration
+	DeployTime     time.Duration
+	ReleaseTime    time.Duration
+	EraseTime      time.Duration
+
+	// FailureRate is the probability that commissioning, deploying or
+	// erasing the disks of a machine fails.
+	FailureRate float64
+	// FailMachines are the system IDs of the machines for which
+	// commissioning, deploying and erasing the disks always fail.
+	FailMachines []string
+}
+
+// FakeEvent is an event of a FakeMAASServer or TestServer, recorded whenever
+// a machine changes status. Type is the new status, and Level is "ERROR" for the
+// failed statuses and "INFO" otherwise.
+type FakeEvent struct {
+	ID          int
+	SystemID    string
+	Hostname    string
+	Type        string
+	Level       string
+	Description string
+	Created     time.Time
+}
+
+// fakeTransition completes a transition of a machine when the clock reaches
+// the due time, if the machine has not changed status since the transition
+// started. The generation tells a machine that is back in the same status
+// from one that never left it.
+type fakeTransition struct {
+	systemID   string
+	from       string
+	generation int
+	due        time.Time
+	complete   func()
+}
+
+// lifecycleSimulator holds the clock, the pending transitions and the
+// events of a simulated lifecycle. The servers embedding it decide what the
+// transitions do to their machines.
+type lifecycleSimulator struct {
+	lifecycle *FakeLifecycle
+	now       time.Time
+	rand      *rand.Rand
+	pending   []*fakeTransition
+	events    []FakeEvent
+	// generations counts the status changes of each machine.
+	generations map[string]int
+}
+
+func newLifecycleSimulator() lifecycleSimulator {
+	return lifecycleSimulator{
+		now:         fakeEpoch,
+		generations: make(map[string]int),
+		// A fixed seed keeps the failures the same on every run.
+		rand: rand.New(rand.NewSource(1)),
+	}
+}
+
+func (sim *lifecycleSimulator) setLifecycle(lifecycle FakeLifecycle) {
+	lifecycle.FailMachines = append([]string(nil), lifecycle.FailMachines...)
+	sim.lifecycle = &lifecycle
+}
+
+// advance moves the clock forward, completing the transitions that are due
+// by the new time in the order they are due, including those scheduled by
+// the transitions completed. The status function returns the current status
+// of a machine, or "" if there is no such machine.
+func (sim *lifecycleSimulator) advance(d time.Duration, status func(systemID string) string) {
+	target := sim.now.Add(d)
+	for {
+		next := -1
+		for i, t := range sim.pending {
+			if !t.due.After(target) && (next < 0 || t.due.Before(sim.pending[next].due)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		t := sim.pending[next]
+		sim.pending = append(sim.pending[:next], sim.pending[next+1:]...)
+		sim.now = t.due
+		if sim.generations[t.systemID] == t.generation && status(t.systemID) == t.from {
+			t.complete()
+		}
+	}
+	sim.now = target
+}
+
+// scheduleTransition calls complete after the duration, if the machine is
+// still in the from status and has not changed status in the meantime.
+func (sim *lifecycleSimulator) scheduleTransition(systemID, from string, d time.Duration, complete func()) {
+	sim.pending = append(sim.pending, &fakeTransition{
+		systemID:   systemID,
+		from:       from,
+		generation: sim.generations[systemID],
+		due:        sim.now.Add(d),
+		complete:   complete,
+	})
+}
+
+// statusChanged stops the pending transitions of the machine from
+// completing, as they started from a status the machine has left.
+func (sim *lifecycleSimulator) statusChanged(systemID string) {
+	sim.generations[systemID]++
+}
+
+// transitionFails decides whether a transition of the machine fails.
+func (sim *lifecycleSimulator) transitionFails(systemID string) bool {
+	if set.NewStrings(sim.lifecycle.FailMachines...).Contains(systemID) {
+		return true
+	}
+	rate := sim.lifecycle.FailureRate
+	return rate > 0 && sim.rand.Float64() < rate
+}
+
+// recordEvent records the change of a machine to the status.
+func (sim *lifecycleSimulator) recordEvent(systemID, hostname, status, message string) {
+	sim.statusChanged(systemID)
+	level := "INFO"
+	if strings.HasPrefix(status, "Failed") {
+		level = "ERROR"
+	}
+	sim.events = append(sim.events, FakeEvent{
+		ID:          len(sim.events) + 1,
+		SystemID:    systemID,
+		Hostname:    hostname,
+		Type:        status,
+		Level:       level,
+		Description: message,
+		Created:     sim.now,
+	})
+}
+
+// machineEvents returns the events of the machine, or of all the machines
+// if the system ID is empty, oldest first.
+func (sim *lifecycleSimulator) machineEvents(systemID string) []FakeEvent {
+	var result []FakeEvent
+	for _, event := range sim.events {
+		if systemID == "" || event.SystemID == systemID {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// SetLifecycle enables the simulated lifecycle of the machines. Without it,
+// deploying and commissioning a machine leave it Deploying or Commissioning
+// until SetMachineStatus is called, and releasing it makes it Ready
+// straight away.
+func (server *FakeMAASServer) SetLifecycle(lifecycle FakeLifecycle) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.setLifecycle(lifecycle)
+}
+
+// Now returns the time on the clock of the server.
+func (server *FakeMAASServer) Now() time.Time {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.now
+}
+
+// Advance moves the clock of the server forward, completing the transitions
+// that are due by the new time in the order they are due. The transitions
+// that follow on from them, such as releasing a machine once its disks are
+// erased, are completed too if they are due.
+func (server *FakeMAASServer) Advance(d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.advance(d, func(systemID string) string {
+		if machine := server.machine(systemID); machine != nil {
+			return machine.StatusName
+		}
+		return ""
+	})
+}
+
+// Events returns the events of the machine, or of all the machines if the
+// system ID is empty, oldest first.
+func (server *FakeMAASServer) Events(systemID string) []FakeEvent {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.machineEvents(systemID)
+}
+
+// setStatus changes the status of the machine and records an event for it.
+func (server *FakeMAASServer) setStatus(machine *FakeMachine, status, message string) {
+	machine.StatusName = status
+	machine.StatusMessage = message
+	server.recordEvent(machine.SystemID, machine.Hostname, status, message)
+}
+
+// schedule completes a transition of the machine after the duration, if the
+// machine is still in its current status.
+func (server *FakeMAASServer) schedule(machine *FakeMachine, d time.Duration, complete func(*FakeMachine)) {
+	systemID := machine.SystemID
+	server.scheduleTransition(systemID, machine.StatusName, d, func() {
+		complete(server.machine(systemID))
+	})
+}
+
+// fails decides whether a transition of the machine fails.
+func (server *FakeMAASServer) fails(machine *FakeMachine) bool {
+	return server.transitionFails(machine.SystemID)
+}
+
+// startDeploy starts deploying the allocated machine.
+func (server *FakeMAASServer) startDeploy(machine *FakeMachine, comment string) {
+	server.setStatus(machine, machineStatusDeploying, comment)
+	if server.lifecycle == nil {
+		return
+	}
+	server.schedule(machine, server.lifecycle.DeployTime, func(machine *FakeMachine) {
+		if server.fails(machine) {
+			server.setStatus(machine, machineStatusFailedDeployment, "Installation failed")
+			return
+		}
+		server.setStatus(machine, machineStatusDeployed, "")
+	})
+}
+
+// startRelease starts releasing the machine, erasing its disks first if
+// asked to.
+func (server *FakeMAASServer) startRelease(machine *FakeMachine, comment string, erase bool) {
+	if server.lifecycle == nil {
+		server.release(machine, comment)
+		return
+	}
+	if !erase {
+		server.startReleasing(machine, comment)
+		return
+	}
+	server.setStatus(machine, machineStatusDiskErasing, comment)
+	server.schedule(machine, server.lifecycle.EraseTime, func(machine *FakeMachine) {
+		if server.fails(machine) {
+			server.setStatus(machine, machineStatusFailedDiskErasing, "Failed to erase disks")
+			return
+		}
+		server.startReleasing(machine, comment)
+	})
+}
+
+func (server *FakeMAASServer) startReleasing(machine *FakeMachine, comment string) {
+	server.setStatus(machine, machineStatusReleasing, comment)
+	server.schedule(machine, server.lifecycle.ReleaseTime, func(machine *FakeMachine) {
+		server.release(machine, comment)
+	})
+}
+
+// release returns the machine to the pool.
+func (server *FakeMAASServer) release(machine *FakeMachine, comment string) {
+	server.setStatus(machine, machineStatusReady, comment)
+	machine.PowerState = "off"
+	machine.Owner = ""
+	machine.OwnerData = make(map[string]string)
+	machine.AgentName = ""
+	machine.OSystem = ""
+	machine.DistroSeries = ""
+}
+
+// commissionMachine starts commissioning the machine.
+func (server *FakeMAASServer) commissionMachine(w http.ResponseWriter, r *http.Request, machine *FakeMachine) bool {
+	if !commissionableStatuses.Contains(machine.StatusName) {
+		fakeError(w, http.StatusConflict, "Can't commission a machine that is in the '%s' state.", machine.StatusName)
+		return false
+	}
+	server.setStatus(machine, machineStatusCommissioning, r.PostForm.Get("comment"))
+	machine.PowerState = "on"
+	if server.lifecycle == nil {
+		return true
+	}
+	server.schedule(machine, server.lifecycle.CommissionTime, func(machine *FakeMachine) {
+		machine.PowerState = "off"
+		if server.fails(machine) {
+			server.setStatus(machine, machineStatusFailedCommissioning, "Commissioning failed")
+			return
+		}
+		server.setStatus(machine, machineStatusReady, "")
+	})
+	return true
+}
+
+// handleEvents handles requests for '/api/2.0/events/', listing the newest
+// events first.
+func (server *FakeMAASServer) handleEvents(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if len(parts) != 0 || r.Method != "GET" || op != "query" {
+		fakeBadSignature(w, r, op)
+		return
+	}
+	query := r.URL.Query()
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			fakeError(w, http.StatusBadRequest, "limit: Enter a whole number.")
+			return
+		}
+	}
+	events := []interface{}{}
+	for i := len(server.events) - 1; i >= 0 && len(events) < limit; i-- {
+		event := server.events[i]
+		if !filterValues(query["id"], event.SystemID) || !filterValues(query["hostname"], event.Hostname) {
+			continue
+		}
+		events = append(events, map[string]interface{}{
+			"id":          event.ID,
+			"node":        event.SystemID,
+			"hostname":    event.Hostname,
+			"type":        event.Type,
+			"level":       event.Level,
+			"description": event.Description,
+			"created":     event.Created.Format(fakeEventTimeFormat),
+		})
+	}
+	fakeJSON(w, map[string]interface{}{
+		"count":    len(events),
+		"events":   events,
+		"next_uri": "",
+		"prev_uri": "",
+	})
+}
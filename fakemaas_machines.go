@@ -0,0 +1,350 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// releasableStatuses are the machine statuses from which a machine can be
+// released. Releasing a Ready machine does nothing.
+var releasableStatuses = set.NewStrings(
+	machineStatusReady,
+	machineStatusAllocated,
+	machineStatusDeploying,
+	machineStatusDeployed,
+	machineStatusFailedDeployment,
+)
+
+// handleMachines handles requests for '/api/2.0/machines/*'.
+func (server *FakeMAASServer) handleMachines(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET" && op == "":
+		server.listMachines(w, r)
+	case len(parts) == 0 && r.Method == "POST" && op == "allocate":
+		server.allocateMachine(w, r)
+	case len(parts) == 0 && r.Method == "POST" && op == "release":
+		server.releaseMachines(w, r)
+	case len(parts) == 1:
+		machine := server.machine(parts[0])
+		if machine == nil {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		server.handleMachine(w, r, machine, op)
+	default:
+		fakeBadSignature(w, r, op)
+	}
+}
+
+func (server *FakeMAASServer) handleMachine(w http.ResponseWriter, r *http.Request, machine *FakeMachine, op string) {
+	switch {
+	case r.Method == "GET" && op == "":
+	case r.Method == "POST" && op == "deploy":
+		if !server.deployMachine(w, r, machine) {
+			return
+		}
+	case r.Method == "POST" && op == "release":
+		if !server.releaseMachine(w, r, machine) {
+			return
+		}
+	case r.Method == "POST" && op == "commission":
+		if !server.commissionMachine(w, r, machine) {
+			return
+		}
+	case r.Method == "POST" && op == "set_owner_data":
+		if !server.checkOwner(w, machine.Owner) {
+			return
+		}
+		setOwnerData(machine.OwnerData, r.PostForm)
+	default:
+		fakeBadSignature(w, r, op)
+		return
+	}
+	fakeJSON(w, server.machineJSON(machine))
+}
+
+// checkOwner reports a 403 if the node is owned by another user.
+func (server *FakeMAASServer) checkOwner(w http.ResponseWriter, owner string) bool {
+	if owner != "" && owner != server.user {
+		fakeError(w, http.StatusForbidden, "You do not have permission to modify this node.")
+		return false
+	}
+	return true
+}
+
+func setOwnerData(ownerData map[string]string, values url.Values) {
+	for key := range values {
+		if value := values.Get(key); value == "" {
+			delete(ownerData, key)
+		} else {
+			ownerData[key] = value
+		}
+	}
+}
+
+func (server *FakeMAASServer) listMachines(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result := []interface{}{}
+	for _, machine := range server.machines {
+		if !filterValues(query["hostname"], machine.Hostname) ||
+			!filterValues(query["id"], machine.SystemID) ||
+			!filterInterfaceMACs(query["mac_address"], machine.Interfaces) ||
+			!filterValues(query["domain"], machine.Domain) ||
+			!filterValues(query["zone"], machine.Zone) ||
+			!filterValues(query["agent_name"], machine.AgentName) {
+			continue
+		}
+		result = append(result, server.machineJSON(machine))
+	}
+	fakeJSON(w, result)
+}
+
+// filterInterfaceMACs returns true if there are no MAC addresses to filter
+// on, or one of the interfaces has one of them.
+func filterInterfaceMACs(macs []string, interfaces []FakeInterface) bool {
+	if len(macs) == 0 {
+		return true
+	}
+	for _, iface := range interfaces {
+		for _, mac := range macs {
+			if strings.EqualFold(mac, iface.MACAddress) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (server *FakeMAASServer) deployMachine(w http.ResponseWriter, r *http.Request, machine *FakeMachine) bool {
+	if !server.checkOwner(w, machine.Owner) {
+		return false
+	}
+	if machine.StatusName != machineStatusAllocated {
+		fakeError(w, http.StatusConflict, "Can't deploy a machine that is in the '%s' state.", machine.StatusName)
+		return false
+	}
+	server.startDeploy(machine, r.PostForm.Get("comment"))
+	machine.PowerState = "on"
+	machine.OSystem = "ubuntu"
+	machine.DistroSeries = r.PostForm.Get("distro_series")
+	if machine.DistroSeries == "" {
+		machine.DistroSeries = "xenial"
+	}
+	if agentName := r.PostForm.Get("agent_name"); agentName != "" {
+		machine.AgentName = agentName
+	}
+	return true
+}
+
+func (server *FakeMAASServer) releaseMachine(w http.ResponseWriter, r *http.Request, machine *FakeMachine) bool {
+	if !server.checkOwner(w, machine.Owner) {
+		return false
+	}
+	if !releasableStatuses.Contains(machine.StatusName) {
+		fakeError(w, http.StatusConflict, "Machine cannot be released in its current state ('%s').", machine.StatusName)
+		return false
+	}
+	server.startRelease(machine, r.PostForm.Get("comment"), eraseRequested(r))
+	return true
+}
+
+func (server *FakeMAASServer) releaseMachines(w http.ResponseWriter, r *http.Request) {
+	var (
+		toRelease []*FakeMachine
+		unknown   []string
+		forbidden []string
+		failed    []string
+	)
+	for _, systemID := range r.PostForm["machines"] {
+		machine := server.machine(systemID)
+		switch {
+		case machine == nil:
+			unknown = append(unknown, systemID)
+		case machine.Owner != "" && machine.Owner != server.user:
+			forbidden = append(forbidden, systemID)
+		case !releasableStatuses.Contains(machine.StatusName):
+			failed = append(failed, fmt.Sprintf("%s ('%s')", systemID, machine.StatusName))
+		default:
+			toRelease = append(toRelease, machine)
+		}
+	}
+	switch {
+	case len(unknown) > 0:
+		fakeError(w, http.StatusBadRequest, "Unknown machine(s): %s.", strings.Join(unknown, ", "))
+		return
+	case len(forbidden) > 0:
+		fakeError(w, http.StatusForbidden,
+			"You don't have the required permission to release the following machine(s): %s.",
+			strings.Join(forbidden, ", "))
+		return
+	case len(failed) > 0:
+		fakeError(w, http.StatusConflict,
+			"Machine(s) cannot be released in their current state: %s.", strings.Join(failed, ", "))
+		return
+	}
+	released := []string{}
+	for _, machine := range toRelease {
+		server.startRelease(machine, r.PostForm.Get("comment"), eraseRequested(r))
+		released = append(released, machine.SystemID)
+	}
+	fakeJSON(w, released)
+}
+
+// eraseRequested returns true if the release request asks for the disks to
+// be erased.
+func eraseRequested(r *http.Request) bool {
+	erase, _ := strconv.ParseBool(r.PostForm.Get("erase"))
+	return erase
+}
+
+// allocateMachine allocates the first Ready machine that matches the
+// constraints, as evaluated by explainMachine. The pod and device
+// constraints cannot be met, as the fake has neither.
+func (server *FakeMAASServer) allocateMachine(w http.ResponseWriter, r *http.Request) {
+	args, err := fakeAllocateArgs(r.PostForm)
+	if err != nil {
+		fakeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	for _, machine := range server.machines {
+		source := server.machineJSON(machine)
+		candidate, err := readMachine(twoDotOh, source)
+		checkError(err)
+		report := explainMachine(candidate, args)
+		if !report.Matches() || !server.extraConstraintsMatch(machine, args) {
+			continue
+		}
+		if !args.DryRun {
+			server.setStatus(machine, machineStatusAllocated, args.Comment)
+			machine.Owner = server.user
+			machine.AgentName = args.AgentName
+			source = server.machineJSON(machine)
+		}
+		source["constraints_by_type"] = constraintsByType(report.ConstraintMatches)
+		fakeJSON(w, source)
+		return
+	}
+	fakeError(w, http.StatusConflict, "No available machine matches constraints: %s", args.String())
+}
+
+// fakeAllocateArgs reverses the conversion of AllocateMachineArgs into
+// parameters done by AllocateMachine.
+func fakeAllocateArgs(form url.Values) (AllocateMachineArgs, error) {
+	var args AllocateMachineArgs
+	var err error
+	atoi := func(name string) int {
+		value := form.Get(name)
+		if value == "" || err != nil {
+			return 0
+		}
+		var result int
+		if result, err = strconv.Atoi(value); err != nil {
+			err = errors.Errorf("bad %s value %q", name, value)
+		}
+		return result
+	}
+	args.SystemID = form.Get("system_id")
+	args.Hostname = form.Get("name")
+	args.Architecture = form.Get("arch")
+	args.MinCPUCount = atoi("cpu_count")
+	args.MinMemory = atoi("mem")
+	args.BridgeFD = atoi("bridge_fd")
+	args.Tags = form["tags"]
+	args.NotTags = form["not_tags"]
+	args.Zone = form.Get("zone")
+	args.NotInZone = form["not_in_zone"]
+	args.Pod = form.Get("pod")
+	args.NotPod = form.Get("not_pod")
+	args.PodType = form.Get("pod_type")
+	args.Fabrics = form["fabrics"]
+	args.NotFabrics = form["not_fabrics"]
+	args.FabricClasses = form["fabric_classes"]
+	args.MACAddress = form.Get("mac_address")
+	args.AgentName = form.Get("agent_name")
+	args.Comment = form.Get("comment")
+	args.BridgeAll = form.Get("bridge_all") == "true"
+	args.BridgeSTP = form.Get("bridge_stp") == "true"
+	args.DryRun = form.Get("dry_run") == "true"
+	if err != nil {
+		return args, err
+	}
+	args.Space, args.Subnets = splitSpaceSubnets(form["subnets"])
+	args.NotSpace, args.NotSubnets = splitSpaceSubnets(form["not_subnets"])
+	if value := form.Get("storage"); value != "" {
+		if args.Storage, err = parseStorageSpecs(value); err != nil {
+			return args, errors.Annotate(err, "storage")
+		}
+	}
+	if value := form.Get("interfaces"); value != "" {
+		if args.Interfaces, err = parseInterfaceSpecs(value); err != nil {
+			return args, errors.Annotate(err, "interfaces")
+		}
+	}
+	if value := form.Get("devices"); value != "" {
+		if args.Devices, err = parseDeviceSpecs(value); err != nil {
+			return args, errors.Annotate(err, "devices")
+		}
+	}
+	return args, errors.Trace(args.Validate())
+}
+
+// splitSpaceSubnets separates the "space:" subnet specifiers from the
+// others.
+func splitSpaceSubnets(values []string) (spaces, subnets []string) {
+	for _, value := range values {
+		if strings.HasPrefix(value, "space:") {
+			spaces = append(spaces, strings.TrimPrefix(value, "space:"))
+		} else {
+			subnets = append(subnets, value)
+		}
+	}
+	return spaces, subnets
+}
+
+// extraConstraintsMatch evaluates the constraints that explainMachine
+// ignores.
+func (server *FakeMAASServer) extraConstraintsMatch(machine *FakeMachine, args AllocateMachineArgs) bool {
+	if args.Pod != "" || args.PodType != "" || len(args.Devices) > 0 {
+		return false
+	}
+	classes := set.NewStrings()
+	for _, iface := range machine.Interfaces {
+		classes.Add(server.fabric(server.vlan(iface.VLAN).Fabric).ClassType)
+	}
+	return set.NewStrings(args.FabricClasses...).Difference(classes).IsEmpty()
+}
+
+// constraintsByType returns the "constraints_by_type" value of the allocate
+// response, mapping the storage and interface labels to the IDs of the
+// disks and interfaces that matched them.
+func constraintsByType(matches ConstraintMatches) map[string]interface{} {
+	result := make(map[string]interface{})
+	if matches.Storage != nil {
+		storage := make(map[string][]int)
+		for label, disks := range matches.Storage {
+			for _, disk := range disks {
+				storage[label] = append(storage[label], disk.ID())
+			}
+		}
+		result["storage"] = storage
+	}
+	if matches.Interfaces != nil {
+		interfaces := make(map[string][]int)
+		for label, ifaces := range matches.Interfaces {
+			for _, iface := range ifaces {
+				interfaces[label] = append(interfaces[label], iface.ID())
+			}
+		}
+		result["interfaces"] = interfaces
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+)
+
+// handleZones handles requests for '/api/2.0/zones/*'.
+func (server *FakeMAASServer) handleZones(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if r.Method != "GET" || op != "" || len(parts) > 1 {
+		fakeBadSignature(w, r, op)
+		return
+	}
+	if len(parts) == 1 {
+		if server.zone(parts[0]) == nil {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		fakeJSON(w, server.zoneJSON(parts[0]))
+		return
+	}
+	result := []interface{}{}
+	for _, zone := range server.zones {
+		result = append(result, server.zoneJSON(zone.Name))
+	}
+	fakeJSON(w, result)
+}
+
+// handleFabrics handles requests for '/api/2.0/fabrics/*'.
+func (server *FakeMAASServer) handleFabrics(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if r.Method != "GET" || op != "" || len(parts) > 1 {
+		fakeBadSignature(w, r, op)
+		return
+	}
+	if len(parts) == 1 {
+		id, ok := fakeIntID(w, parts[0])
+		if !ok {
+			return
+		}
+		fabric := server.fabric(id)
+		if fabric == nil {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		fakeJSON(w, server.fabricJSON(fabric))
+		return
+	}
+	result := []interface{}{}
+	for _, fabric := range server.fabrics {
+		result = append(result, server.fabricJSON(fabric))
+	}
+	fakeJSON(w, result)
+}
+
+// handleSpaces handles requests for '/api/2.0/spaces/*'.
+func (server *FakeMAASServer) handleSpaces(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if r.Method != "GET" || op != "" || len(parts) > 1 {
+		fakeBadSignature(w, r, op)
+		return
+	}
+	if len(parts) == 1 {
+		id, ok := fakeIntID(w, parts[0])
+		if !ok {
+			return
+		}
+		space := server.space(id)
+		if space == nil {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		fakeJSON(w, server.spaceJSON(space))
+		return
+	}
+	result := []interface{}{}
+	for _, space := range server.spaces {
+		result = append(result, server.spaceJSON(space))
+	}
+	fakeJSON(w, result)
+}
+
+// handleSubnets handles requests for '/api/2.0/subnets/*'.
+func (server *FakeMAASServer) handleSubnets(w http.ResponseWriter, r *http.Request, parts []string, op string) {
+	if r.Method != "GET" || op != "" || len(parts) > 1 {
+		fakeBadSignature(w, r, op)
+		return
+	}
+	if len(parts) == 1 {
+		id, ok := fakeIntID(w, parts[0])
+		if !ok {
+			return
+		}
+		subnet := server.subnet(id)
+		if subnet == nil {
+			fakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		fakeJSON(w, server.subnetJSON(subnet))
+		return
+	}
+	result := []interface{}{}
+	for _, subnet := range server.subnets {
+		result = append(result, server.subnetJSON(subnet))
+	}
+	fakeJSON(w, result)
+}
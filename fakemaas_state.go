@@ -0,0 +1,162 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strconv"
+)
+
+// FakeMAASState is the state of a FakeMAASServer, see State and Restore. It
+// holds everything the server has been seeded with or has changed, with
+// the IDs it allocated.
+type FakeMAASState struct {
+	Zones    []FakeZone
+	Fabrics  []FakeFabric
+	VLANs    []FakeVLAN
+	Spaces   []FakeSpace
+	Subnets  []FakeSubnet
+	Machines []FakeMachine
+	Devices  []FakeDevice
+	Files    map[string][]byte
+}
+
+// State returns a copy of the current state of the server.
+func (server *FakeMAASServer) State() FakeMAASState {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	state := FakeMAASState{Files: make(map[string][]byte)}
+	for _, zone := range server.zones {
+		state.Zones = append(state.Zones, *zone)
+	}
+	for _, fabric := range server.fabrics {
+		state.Fabrics = append(state.Fabrics, *fabric)
+	}
+	for _, vlan := range server.vlans {
+		state.VLANs = append(state.VLANs, *vlan)
+	}
+	for _, space := range server.spaces {
+		state.Spaces = append(state.Spaces, *space)
+	}
+	for _, subnet := range server.subnets {
+		s := *subnet
+		s.DNSServers = append([]string(nil), subnet.DNSServers...)
+		state.Subnets = append(state.Subnets, s)
+	}
+	for _, machine := range server.machines {
+		state.Machines = append(state.Machines, machine.clone())
+	}
+	for _, device := range server.devices {
+		state.Devices = append(state.Devices, device.clone())
+	}
+	for filename, content := range server.files {
+		state.Files[filename] = append([]byte(nil), content...)
+	}
+	return state
+}
+
+// Restore replaces the state of the server with one returned by State,
+// such as to go back to a known state or to carry on from a saved one. The
+// pending transitions of the lifecycle and the events are discarded, so
+// the machines that were on their way to another status stay where they
+// are. The state is not checked for consistency.
+func (server *FakeMAASServer) Restore(state FakeMAASState) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.zones = nil
+	for _, zone := range state.Zones {
+		zone := zone
+		server.zones = append(server.zones, &zone)
+	}
+	server.fabrics = nil
+	for _, fabric := range state.Fabrics {
+		fabric := fabric
+		server.fabrics = append(server.fabrics, &fabric)
+	}
+	server.vlans = nil
+	for _, vlan := range state.VLANs {
+		vlan := vlan
+		server.vlans = append(server.vlans, &vlan)
+	}
+	server.spaces = nil
+	for _, space := range state.Spaces {
+		space := space
+		server.spaces = append(server.spaces, &space)
+	}
+	server.subnets = nil
+	for _, subnet := range state.Subnets {
+		subnet := subnet
+		subnet.DNSServers = append([]string(nil), subnet.DNSServers...)
+		server.subnets = append(server.subnets, &subnet)
+	}
+	server.machines = nil
+	for _, machine := range state.Machines {
+		machine := machine.clone()
+		server.machines = append(server.machines, &machine)
+	}
+	server.devices = nil
+	for _, device := range state.Devices {
+		device := device.clone()
+		server.devices = append(server.devices, &device)
+	}
+	server.files = make(map[string][]byte)
+	for filename, content := range state.Files {
+		server.files[filename] = append([]byte(nil), content...)
+	}
+	server.pending = nil
+	server.events = nil
+	server.restoreNextIDs()
+}
+
+// restoreNextIDs makes the next IDs and system IDs follow on from the
+// largest ones in use.
+func (server *FakeMAASServer) restoreNextIDs() {
+	maxID := 0
+	useID := func(id int) {
+		if id > maxID {
+			maxID = id
+		}
+	}
+	useInterfaces := func(interfaces []FakeInterface) {
+		for _, iface := range interfaces {
+			useID(iface.ID)
+			for _, link := range iface.Links {
+				useID(link.ID)
+			}
+		}
+	}
+	for _, fabric := range server.fabrics {
+		useID(fabric.ID)
+	}
+	for _, vlan := range server.vlans {
+		useID(vlan.ID)
+	}
+	for _, space := range server.spaces {
+		useID(space.ID)
+	}
+	for _, subnet := range server.subnets {
+		useID(subnet.ID)
+	}
+	maxNode := 0
+	useSystemID := func(systemID string) {
+		// The allocated system IDs are a letter and a number.
+		if len(systemID) > 1 {
+			if n, err := strconv.Atoi(systemID[1:]); err == nil && n > maxNode {
+				maxNode = n
+			}
+		}
+	}
+	for _, machine := range server.machines {
+		useSystemID(machine.SystemID)
+		useInterfaces(machine.Interfaces)
+		for _, disk := range machine.BlockDevices {
+			useID(disk.ID)
+		}
+	}
+	for _, device := range server.devices {
+		useSystemID(device.SystemID)
+		useInterfaces(device.Interfaces)
+	}
+	server.nextID = maxID + 1
+	server.nextNode = maxNode
+}
@@ -0,0 +1,607 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type fakeMAASSuite struct {
+	testing.CleanupSuite
+	server     *FakeMAASServer
+	controller Controller
+	space      int
+	subnet     int
+}
+
+var _ = gc.Suite(&fakeMAASSuite{})
+
+func (s *fakeMAASSuite) SetUpTest(c *gc.C) {
+	s.CleanupSuite.SetUpTest(c)
+	s.server = NewFakeMAASServer()
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.space = s.server.AddSpace(FakeSpace{Name: "db"})
+	s.subnet = s.server.AddSubnet(FakeSubnet{
+		CIDR:      "10.20.0.0/24",
+		Space:     s.space,
+		GatewayIP: "10.20.0.1",
+	})
+	controller, err := NewController(ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.controller = controller
+}
+
+// addMachines adds a small machine with no disks, and a bigger one with a
+// tagged disk and an interface on the db space.
+func (s *fakeMAASSuite) addMachines() (small, big string) {
+	small = s.server.AddMachine(FakeMachine{
+		Hostname: "small",
+		CPUCount: 1,
+		Memory:   1024,
+		Interfaces: []FakeInterface{{
+			MACAddress: "52:54:00:00:00:01",
+		}},
+	})
+	big = s.server.AddMachine(FakeMachine{
+		Hostname: "big",
+		CPUCount: 8,
+		Memory:   16384,
+		Tags:     []string{"virtual"},
+		Interfaces: []FakeInterface{{
+			MACAddress: "52:54:00:00:00:02",
+		}, {
+			MACAddress: "52:54:00:00:00:03",
+			Links:      []FakeLink{{Mode: "static", Subnet: s.subnet, IPAddress: "10.20.0.5"}},
+		}},
+		BlockDevices: []FakeBlockDevice{{
+			Size: 100 * storageConstraintUnit,
+			Tags: []string{"ssd"},
+		}},
+	})
+	return small, big
+}
+
+func (s *fakeMAASSuite) TestMachines(c *gc.C) {
+	_, big := s.addMachines()
+	machines, err := s.controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 2)
+	c.Check(machines[0].Hostname(), gc.Equals, "small")
+	c.Check(machines[0].StatusName(), gc.Equals, "Ready")
+	c.Check(machines[0].Zone().Name(), gc.Equals, "default")
+
+	machines, err = s.controller.Machines(MachinesArgs{MACAddresses: []string{"52:54:00:00:00:03"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	machine := machines[0]
+	c.Check(machine.SystemID(), gc.Equals, big)
+	c.Check(machine.FQDN(), gc.Equals, "big.maas")
+	c.Check(machine.IPAddresses(), jc.DeepEquals, []string{"10.20.0.5"})
+	c.Assert(machine.InterfaceSet(), gc.HasLen, 2)
+	links := machine.InterfaceSet()[1].Links()
+	c.Assert(links, gc.HasLen, 1)
+	c.Check(links[0].Subnet().Space(), gc.Equals, "db")
+	c.Check(links[0].Subnet().VLAN().Fabric(), gc.Equals, "fabric-0")
+	c.Assert(machine.PhysicalBlockDevices(), gc.HasLen, 1)
+	c.Check(machine.PhysicalBlockDevices()[0].Name(), gc.Equals, "sda")
+
+	result, err := s.controller.Machine(big)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.Hostname(), gc.Equals, "big")
+	_, err = s.controller.Machine("missing")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *fakeMAASSuite) TestAllocateMachine(c *gc.C) {
+	_, big := s.addMachines()
+	machine, matches, err := s.controller.AllocateMachine(AllocateMachineArgs{
+		MinMemory:  2048,
+		Tags:       []string{"virtual"},
+		Storage:    []StorageSpec{{Label: "root", Size: 50, Tags: []string{"ssd"}}},
+		Interfaces: []InterfaceSpec{{Label: "db", Space: "db"}},
+		AgentName:  "agent",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, big)
+	c.Check(machine.StatusName(), gc.Equals, "Allocated")
+	c.Assert(matches.Storage["root"], gc.HasLen, 1)
+	c.Check(matches.Storage["root"][0].Tags(), jc.DeepEquals, []string{"ssd"})
+	c.Assert(matches.Interfaces["db"], gc.HasLen, 1)
+	c.Check(matches.Interfaces["db"][0].MACAddress(), gc.Equals, "52:54:00:00:00:03")
+
+	state, ok := s.server.Machine(big)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(state.Owner, gc.Equals, "admin")
+	c.Check(state.AgentName, gc.Equals, "agent")
+
+	// The machine is no longer available.
+	_, _, err = s.controller.AllocateMachine(AllocateMachineArgs{Tags: []string{"virtual"}})
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, "No available machine matches constraints: tags=virtual")
+}
+
+func (s *fakeMAASSuite) TestAllocateMachineConstraints(c *gc.C) {
+	small, big := s.addMachines()
+	for i, test := range []struct {
+		args     AllocateMachineArgs
+		systemID string
+	}{{
+		args:     AllocateMachineArgs{},
+		systemID: small,
+	}, {
+		args:     AllocateMachineArgs{MinCPUCount: 2},
+		systemID: big,
+	}, {
+		args:     AllocateMachineArgs{NotSpace: []string{"db"}},
+		systemID: small,
+	}, {
+		args:     AllocateMachineArgs{Subnets: []string{"cidr:10.20.0.0/24"}},
+		systemID: big,
+	}, {
+		args:     AllocateMachineArgs{MACAddress: "52:54:00:00:00:02"},
+		systemID: big,
+	}, {
+		args: AllocateMachineArgs{Architecture: "arm64"},
+	}, {
+		args: AllocateMachineArgs{Storage: []StorageSpec{{Size: 200}}},
+	}, {
+		args: AllocateMachineArgs{Pod: "pod"},
+	}, {
+		args: AllocateMachineArgs{FabricClasses: []string{"10g"}},
+	}} {
+		c.Logf("test %d", i)
+		test.args.DryRun = true
+		machine, _, err := s.controller.AllocateMachine(test.args)
+		if test.systemID == "" {
+			c.Check(err, jc.Satisfies, IsNoMatchError)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(machine.SystemID(), gc.Equals, test.systemID)
+		// A dry run leaves the machine Ready.
+		state, _ := s.server.Machine(test.systemID)
+		c.Check(state.StatusName, gc.Equals, "Ready")
+	}
+}
+
+func (s *fakeMAASSuite) TestStartAndRelease(c *gc.C) {
+	s.addMachines()
+	machine, _, err := s.controller.AllocateMachine(AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	err = machine.SetOwnerData(map[string]string{"owner": "me"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.OwnerData(), jc.DeepEquals, map[string]string{"owner": "me"})
+
+	err = machine.Start(StartArgs{DistroSeries: "trusty"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Deploying")
+	c.Check(machine.DistroSeries(), gc.Equals, "trusty")
+
+	err = machine.Start(StartArgs{})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err, gc.ErrorMatches, "Can't deploy a machine that is in the 'Deploying' state.")
+
+	s.server.SetMachineStatus(machine.SystemID(), "Deployed", "")
+	c.Assert(machine.Refresh(), jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+
+	err = machine.Release(ReleaseArgs{Comment: "done"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Ready")
+	c.Check(machine.StatusMessage(), gc.Equals, "done")
+	c.Check(machine.OwnerData(), gc.HasLen, 0)
+}
+
+func (s *fakeMAASSuite) TestStartUnallocated(c *gc.C) {
+	small, _ := s.addMachines()
+	machine, err := s.controller.Machine(small)
+	c.Assert(err, jc.ErrorIsNil)
+	err = machine.Start(StartArgs{})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *fakeMAASSuite) TestOwnedByAnotherUser(c *gc.C) {
+	small, _ := s.addMachines()
+	s.server.SetUser("other")
+	_, _, err := s.controller.AllocateMachine(AllocateMachineArgs{SystemID: small})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.SetUser("admin")
+
+	machine, err := s.controller.Machine(small)
+	c.Assert(err, jc.ErrorIsNil)
+	err = machine.Release(ReleaseArgs{})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	err = s.controller.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{small}})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *fakeMAASSuite) TestReleaseMachines(c *gc.C) {
+	small, big := s.addMachines()
+	for _, id := range []string{small, big} {
+		_, _, err := s.controller.AllocateMachine(AllocateMachineArgs{SystemID: id})
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	err := s.controller.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{small, "missing"}})
+	c.Assert(err, jc.Satisfies, IsReleaseMachinesError)
+	failed := ReleaseMachinesFailures(err)
+	c.Assert(failed, gc.HasLen, 1)
+	c.Check(failed["missing"], jc.Satisfies, IsNoMatchError)
+
+	s.server.SetMachineStatus(big, "Commissioning", "")
+	err = s.controller.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{small, big}})
+	c.Assert(err, jc.Satisfies, IsReleaseMachinesError)
+	failed = ReleaseMachinesFailures(err)
+	c.Assert(failed, gc.HasLen, 1)
+	c.Check(failed[big], gc.ErrorMatches, `machine ".*" cannot be released in state "Commissioning"`)
+
+	// Nothing is released if any machine fails.
+	state, _ := s.server.Machine(small)
+	c.Check(state.StatusName, gc.Equals, "Allocated")
+	err = s.controller.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{small}})
+	c.Assert(err, jc.ErrorIsNil)
+	state, _ = s.server.Machine(small)
+	c.Check(state.StatusName, gc.Equals, "Ready")
+	c.Check(state.Owner, gc.Equals, "")
+}
+
+func (s *fakeMAASSuite) TestMachinesOwnerData(c *gc.C) {
+	small, _ := s.addMachines()
+	machine, err := s.controller.Machine(small)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.SetOwnerData(map[string]string{"a": "1", "b": "2"}), jc.ErrorIsNil)
+	c.Assert(machine.SetOwnerData(map[string]string{"b": ""}), jc.ErrorIsNil)
+	c.Check(machine.OwnerData(), jc.DeepEquals, map[string]string{"a": "1"})
+
+	machines, err := s.controller.Machines(MachinesArgs{OwnerData: map[string]string{"a": "1"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].SystemID(), gc.Equals, small)
+}
+
+func (s *fakeMAASSuite) TestCreateMachineDevice(c *gc.C) {
+	_, big := s.addMachines()
+	machine, err := s.controller.Machine(big)
+	c.Assert(err, jc.ErrorIsNil)
+	subnet, err := s.controller.Subnet(s.subnet)
+	c.Assert(err, jc.ErrorIsNil)
+	device, err := machine.CreateDevice(CreateMachineDeviceArgs{
+		Hostname:      "container",
+		InterfaceName: "eth1",
+		MACAddress:    "52:54:00:00:01:01",
+		Subnet:        subnet,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.Parent(), gc.Equals, big)
+
+	c.Assert(device.Refresh(), jc.ErrorIsNil)
+	interfaces := device.InterfaceSet()
+	c.Assert(interfaces, gc.HasLen, 1)
+	c.Check(interfaces[0].Name(), gc.Equals, "eth1")
+	links := interfaces[0].Links()
+	c.Assert(links, gc.HasLen, 1)
+	// The gateway has the first address.
+	c.Check(links[0].IPAddress(), gc.Equals, "10.20.0.2")
+
+	devices, err := machine.Devices(DevicesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+	c.Check(devices[0].Hostname(), gc.Equals, "container")
+
+	c.Assert(interfaces[0].UnlinkSubnet(subnet), jc.ErrorIsNil)
+	c.Check(interfaces[0].Links(), gc.HasLen, 0)
+
+	c.Assert(device.Delete(), jc.ErrorIsNil)
+	_, ok := s.server.Device(device.SystemID())
+	c.Check(ok, jc.IsFalse)
+}
+
+func (s *fakeMAASSuite) TestCreateDeviceErrors(c *gc.C) {
+	s.addMachines()
+	_, err := s.controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"52:54:00:00:00:01"},
+	})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	_, err = s.controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"52:54:00:00:01:01"},
+		Parent:       "missing",
+	})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *fakeMAASSuite) TestDeviceInterfaces(c *gc.C) {
+	device, err := s.controller.CreateDevice(CreateDeviceArgs{
+		Hostname:     "thing",
+		MACAddresses: []string{"52:54:00:00:01:01"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	fabric, err := s.controller.Fabric(DefaultFakeFabric)
+	c.Assert(err, jc.ErrorIsNil)
+	iface, err := device.CreateInterface(CreateInterfaceArgs{
+		Name:       "eth1",
+		MACAddress: "52:54:00:00:01:02",
+		VLAN:       fabric.VLANs()[0],
+		MTU:        9000,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.EffectiveMTU(), gc.Equals, 9000)
+
+	subnet, err := s.controller.Subnet(s.subnet)
+	c.Assert(err, jc.ErrorIsNil)
+	err = iface.LinkSubnet(LinkSubnetArgs{Mode: LinkModeStatic, Subnet: subnet, IPAddress: "10.20.0.50"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Links()[0].IPAddress(), gc.Equals, "10.20.0.50")
+	err = iface.LinkSubnet(LinkSubnetArgs{Mode: LinkModeStatic, Subnet: subnet, IPAddress: "10.30.0.1"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+
+	c.Assert(device.Refresh(), jc.ErrorIsNil)
+	c.Check(device.InterfaceSet(), gc.HasLen, 2)
+	c.Check(device.IPAddresses(), jc.DeepEquals, []string{"10.20.0.50"})
+
+	state, ok := s.server.Device(device.SystemID())
+	c.Assert(ok, jc.IsTrue)
+	c.Check(state.Owner, gc.Equals, "admin")
+	c.Check(state.Interfaces[1].Links[0].Mode, gc.Equals, "static")
+}
+
+func (s *fakeMAASSuite) TestFiles(c *gc.C) {
+	s.server.AddFile("seeded", []byte("seed"))
+	err := s.controller.AddFile(AddFileArgs{Filename: "added", Content: []byte("content")})
+	c.Assert(err, jc.ErrorIsNil)
+	content, ok := s.server.File("added")
+	c.Assert(ok, jc.IsTrue)
+	c.Check(string(content), gc.Equals, "content")
+
+	files, err := s.controller.Files("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(files, gc.HasLen, 2)
+	c.Check(files[0].Filename(), gc.Equals, "added")
+	// The listing has no content, so it is read from the server.
+	bytes, err := files[1].ReadAll()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(bytes), gc.Equals, "seed")
+
+	file, err := s.controller.GetFile("added")
+	c.Assert(err, jc.ErrorIsNil)
+	bytes, err = file.ReadAll()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(bytes), gc.Equals, "content")
+	c.Assert(file.Delete(), jc.ErrorIsNil)
+	_, err = s.controller.GetFile("added")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *fakeMAASSuite) TestNetworks(c *gc.C) {
+	s.server.AddZone(FakeZone{Name: "az1", Description: "first"})
+	fabric := s.server.AddFabric(FakeFabric{Name: "fast", ClassType: "10g"})
+	s.server.AddVLAN(FakeVLAN{Fabric: fabric, VID: 42, Name: "tagged"})
+
+	zones, err := s.controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zones, gc.HasLen, 2)
+	c.Check(zones[1].Description(), gc.Equals, "first")
+
+	fabrics, err := s.controller.Fabrics()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fabrics, gc.HasLen, 2)
+	c.Check(fabrics[1].Name(), gc.Equals, "fast")
+	c.Check(fabrics[1].ClassType(), gc.Equals, "10g")
+	c.Assert(fabrics[1].VLANs(), gc.HasLen, 2)
+	c.Check(fabrics[1].VLANs()[1].VID(), gc.Equals, 42)
+
+	spaces, err := s.controller.Spaces()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spaces, gc.HasLen, 2)
+	c.Check(spaces[1].Name(), gc.Equals, "db")
+	c.Assert(spaces[1].Subnets(), gc.HasLen, 1)
+	c.Check(spaces[1].Subnets()[0].CIDR(), gc.Equals, "10.20.0.0/24")
+	c.Check(spaces[1].Subnets()[0].Gateway(), gc.Equals, "10.20.0.1")
+
+	space, err := s.controller.Space(s.space)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(space.Name(), gc.Equals, "db")
+	_, err = s.controller.Zone("missing")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	_, err = s.controller.Fabric(1000)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+// deployedMachine allocates and starts a machine.
+func (s *fakeMAASSuite) deployedMachine(c *gc.C) Machine {
+	machine, _, err := s.controller.AllocateMachine(AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.Start(StartArgs{}), jc.ErrorIsNil)
+	return machine
+}
+
+func (s *fakeMAASSuite) checkStatus(c *gc.C, machine Machine, status string) {
+	c.Assert(machine.Refresh(), jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, status)
+}
+
+func (s *fakeMAASSuite) TestLifecycleDeploy(c *gc.C) {
+	s.addMachines()
+	s.server.SetLifecycle(FakeLifecycle{DeployTime: 10 * time.Minute})
+	machine := s.deployedMachine(c)
+	s.checkStatus(c, machine, "Deploying")
+	s.server.Advance(9 * time.Minute)
+	s.checkStatus(c, machine, "Deploying")
+	s.server.Advance(time.Minute)
+	s.checkStatus(c, machine, "Deployed")
+
+	events := s.server.Events(machine.SystemID())
+	c.Assert(events, gc.HasLen, 3)
+	c.Check(events[0].Type, gc.Equals, "Allocated")
+	c.Check(events[1].Type, gc.Equals, "Deploying")
+	c.Check(events[2].Type, gc.Equals, "Deployed")
+	c.Check(events[2].Created.Sub(events[1].Created), gc.Equals, 10*time.Minute)
+}
+
+func (s *fakeMAASSuite) TestLifecycleRedeployIgnoresEarlierDeploy(c *gc.C) {
+	s.addMachines()
+	s.server.SetLifecycle(FakeLifecycle{DeployTime: 10 * time.Minute, ReleaseTime: time.Minute})
+	machine := s.deployedMachine(c)
+	s.server.Advance(time.Minute)
+	c.Assert(machine.Release(ReleaseArgs{}), jc.ErrorIsNil)
+	s.server.Advance(time.Minute)
+	s.checkStatus(c, machine, "Ready")
+
+	redeployed, _, err := s.controller.AllocateMachine(AllocateMachineArgs{Hostname: machine.Hostname()})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(redeployed.SystemID(), gc.Equals, machine.SystemID())
+	c.Assert(redeployed.Start(StartArgs{}), jc.ErrorIsNil)
+	// The first deploy would have finished now.
+	s.server.Advance(8 * time.Minute)
+	s.checkStatus(c, machine, "Deploying")
+	s.server.Advance(2 * time.Minute)
+	s.checkStatus(c, machine, "Deployed")
+}
+
+func (s *fakeMAASSuite) TestLifecycleFailures(c *gc.C) {
+	small, _ := s.addMachines()
+	s.server.SetLifecycle(FakeLifecycle{FailMachines: []string{small}})
+	machine := s.deployedMachine(c)
+	s.server.Advance(0)
+	s.checkStatus(c, machine, "Failed deployment")
+	events := s.server.Events(small)
+	c.Check(events[len(events)-1].Level, gc.Equals, "ERROR")
+
+	// Failed machines can still be released.
+	c.Assert(machine.Release(ReleaseArgs{}), jc.ErrorIsNil)
+	s.server.Advance(0)
+	s.checkStatus(c, machine, "Ready")
+
+	s.server.SetLifecycle(FakeLifecycle{FailureRate: 1})
+	machine = s.deployedMachine(c)
+	s.server.Advance(0)
+	s.checkStatus(c, machine, "Failed deployment")
+}
+
+func (s *fakeMAASSuite) TestLifecycleReleaseWithErase(c *gc.C) {
+	s.addMachines()
+	s.server.SetLifecycle(FakeLifecycle{ReleaseTime: time.Minute, EraseTime: time.Hour})
+	machine := s.deployedMachine(c)
+	s.server.Advance(0)
+	s.checkStatus(c, machine, "Deployed")
+
+	c.Assert(machine.Release(ReleaseArgs{Erase: true}), jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Disk erasing")
+	// The machine cannot be released again until it is Ready.
+	c.Check(machine.Release(ReleaseArgs{}), jc.Satisfies, IsCannotCompleteError)
+	s.server.Advance(time.Hour)
+	s.checkStatus(c, machine, "Releasing")
+	s.server.Advance(time.Minute)
+	s.checkStatus(c, machine, "Ready")
+	c.Check(s.server.Now(), gc.Equals, fakeEpoch.Add(time.Hour+time.Minute))
+
+	state, _ := s.server.Machine(machine.SystemID())
+	c.Check(state.Owner, gc.Equals, "")
+}
+
+func (s *fakeMAASSuite) TestLifecycleAdvancesThroughTransitions(c *gc.C) {
+	s.addMachines()
+	s.server.SetLifecycle(FakeLifecycle{DeployTime: time.Minute, ReleaseTime: time.Minute, EraseTime: time.Minute})
+	machine := s.deployedMachine(c)
+	s.server.Advance(time.Minute)
+	c.Assert(machine.Release(ReleaseArgs{Erase: true}), jc.ErrorIsNil)
+	s.server.Advance(time.Hour)
+	s.checkStatus(c, machine, "Ready")
+	var types []string
+	for _, event := range s.server.Events(machine.SystemID()) {
+		types = append(types, event.Type)
+	}
+	c.Check(types, jc.DeepEquals, []string{
+		"Allocated", "Deploying", "Deployed", "Disk erasing", "Releasing", "Ready",
+	})
+}
+
+func (s *fakeMAASSuite) TestCommissionAndEvents(c *gc.C) {
+	small, _ := s.addMachines()
+	s.server.SetLifecycle(FakeLifecycle{CommissionTime: time.Minute})
+	client, err := NewAnonymousClient(s.server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Post(&url.URL{Path: "machines/" + small + "/"}, "commission", nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	state, _ := s.server.Machine(small)
+	c.Check(state.StatusName, gc.Equals, "Commissioning")
+	_, err = client.Post(&url.URL{Path: "machines/" + small + "/"}, "commission", nil, nil)
+	svrErr, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.StatusCode, gc.Equals, http.StatusConflict)
+
+	s.server.Advance(time.Minute)
+	state, _ = s.server.Machine(small)
+	c.Check(state.StatusName, gc.Equals, "Ready")
+
+	content, err := client.Get(&url.URL{Path: "events/"}, "query", url.Values{"id": {small}, "limit": {"1"}})
+	c.Assert(err, jc.ErrorIsNil)
+	var result struct {
+		Count  int
+		Events []map[string]interface{}
+	}
+	c.Assert(json.Unmarshal(content, &result), jc.ErrorIsNil)
+	c.Check(result.Count, gc.Equals, 1)
+	c.Check(result.Events[0]["type"], gc.Equals, "Ready")
+	c.Check(result.Events[0]["node"], gc.Equals, small)
+	c.Check(result.Events[0]["created"], gc.Equals, "Fri, 01 Jan. 2016 00:01:00")
+}
+
+func (s *fakeMAASSuite) TestStateAndRestore(c *gc.C) {
+	_, big := s.addMachines()
+	_, _, err := s.controller.AllocateMachine(AllocateMachineArgs{Tags: []string{"virtual"}})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.AddFile("config", []byte("content"))
+
+	// The state survives being saved as JSON.
+	data, err := json.Marshal(s.server.State())
+	c.Assert(err, jc.ErrorIsNil)
+	var state FakeMAASState
+	c.Assert(json.Unmarshal(data, &state), jc.ErrorIsNil)
+
+	server := NewFakeMAASServer()
+	defer server.Close()
+	server.Restore(state)
+	c.Check(server.State(), jc.DeepEquals, s.server.State())
+	machine, ok := server.Machine(big)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(machine.StatusName, gc.Equals, "Allocated")
+	content, ok := server.File("config")
+	c.Assert(ok, jc.IsTrue)
+	c.Check(string(content), gc.Equals, "content")
+
+	// New IDs don't clash with the restored ones.
+	systemID := server.AddMachine(FakeMachine{Interfaces: []FakeInterface{{}}})
+	c.Check(systemID, gc.Equals, "m00003")
+	added, _ := server.Machine(systemID)
+	for _, iface := range machine.Interfaces {
+		c.Check(added.Interfaces[0].ID, gc.Not(gc.Equals), iface.ID)
+	}
+}
+
+func (s *fakeMAASSuite) TestStateIsACopy(c *gc.C) {
+	s.server.AddSubnet(FakeSubnet{CIDR: "10.0.0.0/24", DNSServers: []string{"10.0.0.2"}})
+	state := s.server.State()
+	last := len(state.Subnets) - 1
+	c.Assert(state.Subnets[last].DNSServers, jc.DeepEquals, []string{"10.0.0.2"})
+	state.Subnets[last].DNSServers[0] = "10.0.0.3"
+	c.Check(s.server.State().Subnets[last].DNSServers, jc.DeepEquals, []string{"10.0.0.2"})
+}
+
+func (s *fakeMAASSuite) TestFaults(c *gc.C) {
+	s.addMachines()
+	s.server.AddFault(FaultRule{Path: "/machines/$", Status: http.StatusServiceUnavailable, Times: 1})
+	machines, err := s.controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 2)
+	c.Check(s.server.FaultsInjected(), gc.Equals, 1)
+}
@@ -0,0 +1,262 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapitest
+
+import (
+	"github.com/juju/gomaasapi"
+)
+
+// MachineBuilder describes a machine to add with Controller.AddMachine.
+type MachineBuilder struct {
+	systemID      string
+	hostname      string
+	architecture  string
+	cpuCount      int
+	memory        int
+	tags          []string
+	zone          string
+	statusName    string
+	statusMessage string
+	owner         string
+	ownerData     map[string]string
+	interfaces    []*InterfaceBuilder
+	blockDevices  []*BlockDeviceBuilder
+}
+
+// NewMachine returns a builder for a Ready amd64 machine in the default
+// zone, with 1 CPU and 1024 MB of memory, and the system ID as its
+// hostname.
+func NewMachine(systemID string) *MachineBuilder {
+	return &MachineBuilder{
+		systemID:     systemID,
+		hostname:     systemID,
+		architecture: "amd64/generic",
+		cpuCount:     1,
+		memory:       1024,
+		zone:         DefaultZone,
+		statusName:   statusReady,
+	}
+}
+
+// Hostname sets the hostname of the machine.
+func (b *MachineBuilder) Hostname(hostname string) *MachineBuilder {
+	b.hostname = hostname
+	return b
+}
+
+// Architecture sets the architecture of the machine, such as "arm64/generic".
+func (b *MachineBuilder) Architecture(architecture string) *MachineBuilder {
+	b.architecture = architecture
+	return b
+}
+
+// CPUCount sets the number of CPUs of the machine.
+func (b *MachineBuilder) CPUCount(count int) *MachineBuilder {
+	b.cpuCount = count
+	return b
+}
+
+// Memory sets the memory of the machine in MB.
+func (b *MachineBuilder) Memory(memory int) *MachineBuilder {
+	b.memory = memory
+	return b
+}
+
+// Tags adds tags to the machine.
+func (b *MachineBuilder) Tags(tags ...string) *MachineBuilder {
+	b.tags = append(b.tags, tags...)
+	return b
+}
+
+// Zone sets the name of the zone of the machine, which must have been
+// added to the Controller.
+func (b *MachineBuilder) Zone(name string) *MachineBuilder {
+	b.zone = name
+	return b
+}
+
+// Status sets the status of the machine. Only Ready machines can be
+// allocated.
+func (b *MachineBuilder) Status(name, message string) *MachineBuilder {
+	b.statusName = name
+	b.statusMessage = message
+	return b
+}
+
+// Owner sets the user that the machine is allocated to.
+func (b *MachineBuilder) Owner(user string) *MachineBuilder {
+	b.owner = user
+	return b
+}
+
+// OwnerData sets the owner data of the machine.
+func (b *MachineBuilder) OwnerData(ownerData map[string]string) *MachineBuilder {
+	b.ownerData = ownerData
+	return b
+}
+
+// Interface adds an interface to the machine. The first interface is the
+// boot interface.
+func (b *MachineBuilder) Interface(iface *InterfaceBuilder) *MachineBuilder {
+	b.interfaces = append(b.interfaces, iface)
+	return b
+}
+
+// BlockDevice adds a physical block device to the machine.
+func (b *MachineBuilder) BlockDevice(device *BlockDeviceBuilder) *MachineBuilder {
+	b.blockDevices = append(b.blockDevices, device)
+	return b
+}
+
+type linkSpec struct {
+	mode      gomaasapi.InterfaceLinkMode
+	subnet    gomaasapi.Subnet
+	ipAddress string
+}
+
+// InterfaceBuilder describes a physical interface of a machine, see
+// MachineBuilder.Interface.
+type InterfaceBuilder struct {
+	name       string
+	macAddress string
+	vlan       gomaasapi.VLAN
+	tags       []string
+	mtu        int
+	disabled   bool
+	links      []linkSpec
+}
+
+// NewInterface returns a builder for an enabled interface on the default
+// VLAN.
+func NewInterface(name, macAddress string) *InterfaceBuilder {
+	return &InterfaceBuilder{name: name, macAddress: macAddress}
+}
+
+// VLAN sets the VLAN of the interface, which must have been added to the
+// Controller.
+func (b *InterfaceBuilder) VLAN(vlan gomaasapi.VLAN) *InterfaceBuilder {
+	b.vlan = vlan
+	return b
+}
+
+// Tags adds tags to the interface.
+func (b *InterfaceBuilder) Tags(tags ...string) *InterfaceBuilder {
+	b.tags = append(b.tags, tags...)
+	return b
+}
+
+// MTU sets the MTU of the interface. By default the interface uses the MTU
+// of its VLAN.
+func (b *InterfaceBuilder) MTU(mtu int) *InterfaceBuilder {
+	b.mtu = mtu
+	return b
+}
+
+// Disabled disables the interface.
+func (b *InterfaceBuilder) Disabled() *InterfaceBuilder {
+	b.disabled = true
+	return b
+}
+
+// Link links the interface to a subnet of the Controller. The IP address is
+// only used for static links.
+func (b *InterfaceBuilder) Link(mode gomaasapi.InterfaceLinkMode, subnet gomaasapi.Subnet, ipAddress string) *InterfaceBuilder {
+	b.links = append(b.links, linkSpec{mode: mode, subnet: subnet, ipAddress: ipAddress})
+	return b
+}
+
+// BlockDeviceBuilder describes a physical block device of a machine, see
+// MachineBuilder.BlockDevice.
+type BlockDeviceBuilder struct {
+	name      string
+	model     string
+	path      string
+	tags      []string
+	blockSize uint64
+	size      uint64
+}
+
+// NewBlockDevice returns a builder for a block device of the given size in
+// bytes, with a path of "/dev/" and the name.
+func NewBlockDevice(name string, size uint64) *BlockDeviceBuilder {
+	return &BlockDeviceBuilder{
+		name:      name,
+		path:      "/dev/" + name,
+		blockSize: 4096,
+		size:      size,
+	}
+}
+
+// Model sets the model of the block device.
+func (b *BlockDeviceBuilder) Model(model string) *BlockDeviceBuilder {
+	b.model = model
+	return b
+}
+
+// Path sets the path of the block device.
+func (b *BlockDeviceBuilder) Path(path string) *BlockDeviceBuilder {
+	b.path = path
+	return b
+}
+
+// BlockSize sets the block size of the block device in bytes.
+func (b *BlockDeviceBuilder) BlockSize(blockSize uint64) *BlockDeviceBuilder {
+	b.blockSize = blockSize
+	return b
+}
+
+// Tags adds tags to the block device, such as "ssd".
+func (b *BlockDeviceBuilder) Tags(tags ...string) *BlockDeviceBuilder {
+	b.tags = append(b.tags, tags...)
+	return b
+}
+
+// SubnetBuilder describes a subnet to add with Controller.AddSubnet.
+type SubnetBuilder struct {
+	cidr       string
+	name       string
+	space      string
+	vlan       gomaasapi.VLAN
+	gateway    string
+	dnsServers []string
+}
+
+// NewSubnet returns a builder for a subnet named after the CIDR, in the
+// default space and on the default VLAN.
+func NewSubnet(cidr string) *SubnetBuilder {
+	return &SubnetBuilder{cidr: cidr}
+}
+
+// Name sets the name of the subnet.
+func (b *SubnetBuilder) Name(name string) *SubnetBuilder {
+	b.name = name
+	return b
+}
+
+// Space sets the name of the space of the subnet, which must have been
+// added to the Controller.
+func (b *SubnetBuilder) Space(name string) *SubnetBuilder {
+	b.space = name
+	return b
+}
+
+// VLAN sets the VLAN of the subnet, which must have been added to the
+// Controller.
+func (b *SubnetBuilder) VLAN(vlan gomaasapi.VLAN) *SubnetBuilder {
+	b.vlan = vlan
+	return b
+}
+
+// Gateway sets the gateway IP address of the subnet, which is never given
+// to a static link.
+func (b *SubnetBuilder) Gateway(ip string) *SubnetBuilder {
+	b.gateway = ip
+	return b
+}
+
+// DNSServers adds DNS servers to the subnet.
+func (b *SubnetBuilder) DNSServers(servers ...string) *SubnetBuilder {
+	b.dnsServers = append(b.dnsServers, servers...)
+	return b
+}
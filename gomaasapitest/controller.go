@@ -0,0 +1,1084 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package gomaasapitest provides an in-memory implementation of the
+// gomaasapi.Controller interface, for unit testing code that uses a
+// Controller without a MAAS server or HTTP.
+//
+// The Controller is seeded with zones, fabrics, VLANs, spaces, subnets and
+// machines, and then allocates, starts and releases machines and creates
+// devices and interfaces much as MAAS does, returning the same errors as
+// the gomaasapi Controller. The calls made to the Controller and to the
+// machines, devices, interfaces and files it returns are recorded with a
+// testing.Stub, which can also be used to inject errors.
+package gomaasapitest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+	"github.com/juju/testing"
+	"github.com/juju/utils/set"
+)
+
+const (
+	// DefaultZone is the name of the zone every Controller starts with.
+	DefaultZone = "default"
+	// DefaultFabric is the ID of the fabric every Controller starts with.
+	DefaultFabric = 0
+	// DefaultSpace is the ID of the space every Controller starts with.
+	DefaultSpace = 0
+
+	statusReady            = "Ready"
+	statusAllocated        = "Allocated"
+	statusDeploying        = "Deploying"
+	statusDeployed         = "Deployed"
+	statusFailedDeployment = "Failed deployment"
+)
+
+var (
+	capabilities = []string{
+		"networks-management",
+		"static-ipaddresses",
+		"ipv6-deployment-ubuntu",
+		"devices-management",
+		"storage-deployment-ubuntu",
+		"network-deployment-ubuntu",
+	}
+
+	// releasableStatuses are the machine statuses from which a machine can
+	// be released. Releasing a Ready machine does nothing.
+	releasableStatuses = set.NewStrings(
+		statusReady,
+		statusAllocated,
+		statusDeploying,
+		statusDeployed,
+		statusFailedDeployment,
+	)
+)
+
+// Controller is an in-memory gomaasapi.Controller. The machines, devices,
+// interfaces and other values it returns are snapshots of its state, and
+// are only updated by their own methods or by Refresh, as with a real
+// Controller. A Controller is safe for concurrent use.
+//
+// Every call to a Controller method, and to the methods of the machines,
+// devices, interfaces and files that change them, is recorded with the
+// Stub and then returns the Stub's next error if there is one.
+type Controller struct {
+	*testing.Stub
+
+	mu       sync.Mutex
+	user     string
+	nextID   int
+	nextNode int
+	zones    []*zone
+	fabrics  []*fabric
+	vlans    []*vlan
+	spaces   []*space
+	subnets  []*subnet
+	machines []*machine
+	devices  []*device
+	files    map[string][]byte
+	config   map[string]interface{}
+}
+
+var _ gomaasapi.Controller = (*Controller)(nil)
+
+// NewController returns a Controller with the default zone, fabric and
+// space, and nothing else. The requests are made as the user "admin", see
+// SetUser.
+func NewController() *Controller {
+	c := &Controller{
+		Stub:   &testing.Stub{},
+		user:   "admin",
+		nextID: 1,
+		zones:  []*zone{{name: DefaultZone}},
+		fabrics: []*fabric{{
+			id:   DefaultFabric,
+			name: fmt.Sprintf("fabric-%d", DefaultFabric),
+		}},
+		spaces: []*space{{
+			id:   DefaultSpace,
+			name: fmt.Sprintf("space-%d", DefaultSpace),
+		}},
+		files:  make(map[string][]byte),
+		config: make(map[string]interface{}),
+	}
+	c.addVLAN(c.fabrics[0], 0, "untagged")
+	return c
+}
+
+// SetUser sets the name of the user making the calls, which owns the
+// machines it allocates and the devices it creates.
+func (c *Controller) SetUser(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.user = name
+}
+
+// AddZone adds a zone.
+func (c *Controller) AddZone(name, description string) gomaasapi.Zone {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.zone(name) != nil {
+		panic(fmt.Sprintf("zone %q already exists", name))
+	}
+	result := &zone{name: name, description: description}
+	c.zones = append(c.zones, result)
+	return result
+}
+
+// AddFabric adds a fabric, along with its untagged VLAN.
+func (c *Controller) AddFabric(name, classType string) gomaasapi.Fabric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := &fabric{id: c.newID(), name: name, classType: classType}
+	if record.name == "" {
+		record.name = fmt.Sprintf("fabric-%d", record.id)
+	}
+	c.fabrics = append(c.fabrics, record)
+	c.addVLAN(record, 0, "untagged")
+	return c.fabricValue(record)
+}
+
+// AddVLAN adds a tagged VLAN to the fabric.
+func (c *Controller) AddVLAN(fabricID, vid int, name string) gomaasapi.VLAN {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.fabric(fabricID)
+	if record == nil {
+		panic(fmt.Sprintf("no fabric %d", fabricID))
+	}
+	return c.addVLAN(record, vid, name)
+}
+
+// DefaultVLAN returns the untagged VLAN of the default fabric, which is used
+// for the interfaces and subnets that do not specify a VLAN.
+func (c *Controller) DefaultVLAN() gomaasapi.VLAN {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.defaultVLAN()
+}
+
+// AddSpace adds a space.
+func (c *Controller) AddSpace(name string) gomaasapi.Space {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := &space{id: c.newID(), name: name}
+	c.spaces = append(c.spaces, record)
+	return c.spaceValue(record)
+}
+
+// AddSubnet adds the subnet described by the builder.
+func (c *Controller) AddSubnet(b *SubnetBuilder) gomaasapi.Subnet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, _, err := net.ParseCIDR(b.cidr); err != nil {
+		panic(err)
+	}
+	record := &subnet{
+		id:         c.newID(),
+		name:       b.name,
+		space:      fmt.Sprintf("space-%d", DefaultSpace),
+		vlan:       c.defaultVLAN(),
+		gateway:    b.gateway,
+		cidr:       b.cidr,
+		dnsServers: append([]string(nil), b.dnsServers...),
+	}
+	if record.name == "" {
+		record.name = b.cidr
+	}
+	if b.space != "" {
+		if c.spaceByName(b.space) == nil {
+			panic(fmt.Sprintf("no space %q", b.space))
+		}
+		record.space = b.space
+	}
+	if b.vlan != nil {
+		record.vlan = c.mustVLAN(b.vlan.ID())
+	}
+	c.subnets = append(c.subnets, record)
+	return record
+}
+
+// AddMachine adds the machine described by the builder, and returns a
+// snapshot of it. Adding a machine is not recorded as a call.
+func (c *Controller) AddMachine(b *MachineBuilder) gomaasapi.Machine {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.machine(b.systemID) != nil || c.device(b.systemID) != nil {
+		panic(fmt.Sprintf("node %q already exists", b.systemID))
+	}
+	record := &machine{
+		controller:    c,
+		systemID:      b.systemID,
+		hostname:      b.hostname,
+		domain:        "maas",
+		architecture:  b.architecture,
+		cpuCount:      b.cpuCount,
+		memory:        b.memory,
+		tags:          append([]string(nil), b.tags...),
+		powerState:    "off",
+		statusName:    b.statusName,
+		statusMessage: b.statusMessage,
+		owner:         b.owner,
+		ownerData:     copyOwnerData(b.ownerData),
+		zone:          c.mustZone(b.zone),
+	}
+	if record.statusName != statusReady {
+		record.powerState = "on"
+	}
+	for _, ib := range b.interfaces {
+		record.interfaces = append(record.interfaces, c.buildInterface(b.systemID, ib))
+	}
+	for _, db := range b.blockDevices {
+		record.blockDevices = append(record.blockDevices, &blockDevice{
+			id:        c.newID(),
+			name:      db.name,
+			model:     db.model,
+			path:      db.path,
+			tags:      append([]string(nil), db.tags...),
+			blockSize: db.blockSize,
+			size:      db.size,
+		})
+	}
+	c.machines = append(c.machines, record)
+	return record.clone()
+}
+
+// MachineState returns a snapshot of the machine without recording a call,
+// for checking the effect of the code being tested. It returns nil if there
+// is no such machine.
+func (c *Controller) MachineState(systemID string) gomaasapi.Machine {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if record := c.machine(systemID); record != nil {
+		return record.clone()
+	}
+	return nil
+}
+
+// DeviceState returns a snapshot of the device without recording a call.
+// It returns nil if there is no such device.
+func (c *Controller) DeviceState(systemID string) gomaasapi.Device {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if record := c.device(systemID); record != nil {
+		return record.clone()
+	}
+	return nil
+}
+
+// SetMachineStatus changes the status of the machine without recording a
+// call, such as to simulate it finishing or failing its deployment.
+func (c *Controller) SetMachineStatus(systemID, status, message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.machine(systemID)
+	if record == nil {
+		panic(fmt.Sprintf("no machine %q", systemID))
+	}
+	record.statusName = status
+	record.statusMessage = message
+}
+
+// Capabilities implements gomaasapi.Controller.
+func (c *Controller) Capabilities() set.Strings {
+	c.MethodCall(c, "Capabilities")
+	return set.NewStrings(capabilities...)
+}
+
+// BootResources implements gomaasapi.Controller. There are none.
+func (c *Controller) BootResources() ([]gomaasapi.BootResource, error) {
+	c.MethodCall(c, "BootResources")
+	return nil, c.NextErr()
+}
+
+// Fabrics implements gomaasapi.Controller.
+func (c *Controller) Fabrics() ([]gomaasapi.Fabric, error) {
+	c.MethodCall(c, "Fabrics")
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []gomaasapi.Fabric
+	for _, record := range c.fabrics {
+		result = append(result, c.fabricValue(record))
+	}
+	return result, nil
+}
+
+// Spaces implements gomaasapi.Controller.
+func (c *Controller) Spaces() ([]gomaasapi.Space, error) {
+	c.MethodCall(c, "Spaces")
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []gomaasapi.Space
+	for _, record := range c.spaces {
+		result = append(result, c.spaceValue(record))
+	}
+	return result, nil
+}
+
+// Zones implements gomaasapi.Controller.
+func (c *Controller) Zones() ([]gomaasapi.Zone, error) {
+	c.MethodCall(c, "Zones")
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []gomaasapi.Zone
+	for _, record := range c.zones {
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// GetConfig implements gomaasapi.Controller. The values are those set with
+// SetConfig.
+func (c *Controller) GetConfig(name string) (interface{}, error) {
+	c.MethodCall(c, "GetConfig", name)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config[name], nil
+}
+
+// SetConfig implements gomaasapi.Controller.
+func (c *Controller) SetConfig(name string, value interface{}) error {
+	c.MethodCall(c, "SetConfig", name, value)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config[name] = value
+	return nil
+}
+
+// RackControllers implements gomaasapi.Controller. There are none.
+func (c *Controller) RackControllers() ([]gomaasapi.RackController, error) {
+	c.MethodCall(c, "RackControllers")
+	return nil, c.NextErr()
+}
+
+// RegionControllers implements gomaasapi.Controller. There are none.
+func (c *Controller) RegionControllers() ([]gomaasapi.RegionController, error) {
+	c.MethodCall(c, "RegionControllers")
+	return nil, c.NextErr()
+}
+
+// IPRanges implements gomaasapi.Controller. There are none.
+func (c *Controller) IPRanges() ([]gomaasapi.IPRange, error) {
+	c.MethodCall(c, "IPRanges")
+	return nil, c.NextErr()
+}
+
+// CreateIPRange implements gomaasapi.Controller. It is not supported.
+func (c *Controller) CreateIPRange(args gomaasapi.CreateIPRangeArgs) (gomaasapi.IPRange, error) {
+	c.MethodCall(c, "CreateIPRange", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	return nil, errors.NotSupportedf("CreateIPRange")
+}
+
+// StaticRoutes implements gomaasapi.Controller. There are none.
+func (c *Controller) StaticRoutes() ([]gomaasapi.StaticRoute, error) {
+	c.MethodCall(c, "StaticRoutes")
+	return nil, c.NextErr()
+}
+
+// CreateStaticRoute implements gomaasapi.Controller. It is not supported.
+func (c *Controller) CreateStaticRoute(args gomaasapi.CreateStaticRouteArgs) (gomaasapi.StaticRoute, error) {
+	c.MethodCall(c, "CreateStaticRoute", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	return nil, errors.NotSupportedf("CreateStaticRoute")
+}
+
+// DHCPSnippets implements gomaasapi.Controller. There are none.
+func (c *Controller) DHCPSnippets() ([]gomaasapi.DHCPSnippet, error) {
+	c.MethodCall(c, "DHCPSnippets")
+	return nil, c.NextErr()
+}
+
+// CreateDHCPSnippet implements gomaasapi.Controller. It is not supported.
+func (c *Controller) CreateDHCPSnippet(args gomaasapi.CreateDHCPSnippetArgs) (gomaasapi.DHCPSnippet, error) {
+	c.MethodCall(c, "CreateDHCPSnippet", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	return nil, errors.NotSupportedf("CreateDHCPSnippet")
+}
+
+// PackageRepositories implements gomaasapi.Controller. There are none.
+func (c *Controller) PackageRepositories() ([]gomaasapi.PackageRepository, error) {
+	c.MethodCall(c, "PackageRepositories")
+	return nil, c.NextErr()
+}
+
+// CreatePackageRepository implements gomaasapi.Controller. It is not
+// supported.
+func (c *Controller) CreatePackageRepository(args gomaasapi.CreatePackageRepositoryArgs) (gomaasapi.PackageRepository, error) {
+	c.MethodCall(c, "CreatePackageRepository", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	return nil, errors.NotSupportedf("CreatePackageRepository")
+}
+
+// Discoveries implements gomaasapi.Controller. There are none.
+func (c *Controller) Discoveries() ([]gomaasapi.Discovery, error) {
+	c.MethodCall(c, "Discoveries")
+	return nil, c.NextErr()
+}
+
+// ClearDiscoveries implements gomaasapi.Controller.
+func (c *Controller) ClearDiscoveries(args gomaasapi.ClearDiscoveriesArgs) error {
+	c.MethodCall(c, "ClearDiscoveries", args)
+	return c.NextErr()
+}
+
+// ScanDiscoveries implements gomaasapi.Controller.
+func (c *Controller) ScanDiscoveries(args gomaasapi.ScanDiscoveriesArgs) error {
+	c.MethodCall(c, "ScanDiscoveries", args)
+	return c.NextErr()
+}
+
+// Machines implements gomaasapi.Controller.
+func (c *Controller) Machines(args gomaasapi.MachinesArgs) ([]gomaasapi.Machine, error) {
+	c.MethodCall(c, "Machines", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []gomaasapi.Machine
+	for _, record := range c.machines {
+		if !filterValues(args.Hostnames, record.hostname) ||
+			!filterValues(args.SystemIDs, record.systemID) ||
+			!filterMACs(args.MACAddresses, record.interfaces) ||
+			!filterValue(args.Domain, record.domain) ||
+			!filterValue(args.Zone, record.zone.name) ||
+			!filterValue(args.AgentName, record.agentName) ||
+			!ownerDataMatches(record.ownerData, args.OwnerData) {
+			continue
+		}
+		result = append(result, record.clone())
+	}
+	return result, nil
+}
+
+// Machine implements gomaasapi.Controller.
+func (c *Controller) Machine(systemID string) (gomaasapi.Machine, error) {
+	c.MethodCall(c, "Machine", systemID)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.machine(systemID)
+	if record == nil {
+		return nil, notFound()
+	}
+	return record.clone(), nil
+}
+
+// Device implements gomaasapi.Controller.
+func (c *Controller) Device(systemID string) (gomaasapi.Device, error) {
+	c.MethodCall(c, "Device", systemID)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.device(systemID)
+	if record == nil {
+		return nil, notFound()
+	}
+	return record.clone(), nil
+}
+
+// Zone implements gomaasapi.Controller.
+func (c *Controller) Zone(name string) (gomaasapi.Zone, error) {
+	c.MethodCall(c, "Zone", name)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.zone(name)
+	if record == nil {
+		return nil, notFound()
+	}
+	return record, nil
+}
+
+// Fabric implements gomaasapi.Controller.
+func (c *Controller) Fabric(id int) (gomaasapi.Fabric, error) {
+	c.MethodCall(c, "Fabric", id)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.fabric(id)
+	if record == nil {
+		return nil, notFound()
+	}
+	return c.fabricValue(record), nil
+}
+
+// Space implements gomaasapi.Controller.
+func (c *Controller) Space(id int) (gomaasapi.Space, error) {
+	c.MethodCall(c, "Space", id)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, record := range c.spaces {
+		if record.id == id {
+			return c.spaceValue(record), nil
+		}
+	}
+	return nil, notFound()
+}
+
+// Subnet implements gomaasapi.Controller.
+func (c *Controller) Subnet(id int) (gomaasapi.Subnet, error) {
+	c.MethodCall(c, "Subnet", id)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.subnet(id)
+	if record == nil {
+		return nil, notFound()
+	}
+	return record, nil
+}
+
+// machineLister supplies a fixed list of machines to ExplainAllocation.
+type machineLister struct {
+	gomaasapi.Controller
+	machines []gomaasapi.Machine
+}
+
+// Machines implements gomaasapi.Controller.
+func (l machineLister) Machines(gomaasapi.MachinesArgs) ([]gomaasapi.Machine, error) {
+	return l.machines, nil
+}
+
+// AllocateMachine implements gomaasapi.Controller. The first Ready machine
+// that meets the constraints, as evaluated by gomaasapi.ExplainAllocation,
+// is allocated. The pod and device constraints are never met.
+func (c *Controller) AllocateMachine(args gomaasapi.AllocateMachineArgs) (gomaasapi.Machine, gomaasapi.ConstraintMatches, error) {
+	c.MethodCall(c, "AllocateMachine", args)
+	var matches gomaasapi.ConstraintMatches
+	if err := c.NextErr(); err != nil {
+		return nil, matches, err
+	}
+	if err := args.Validate(); err != nil {
+		// MAAS rejects the request as a bad one, which AllocateMachine
+		// does not expect.
+		return nil, matches, gomaasapi.NewUnexpectedError(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	candidates := make([]gomaasapi.Machine, len(c.machines))
+	for i, record := range c.machines {
+		candidates[i] = record.clone()
+	}
+	reports, err := gomaasapi.ExplainAllocation(machineLister{c, candidates}, args)
+	if err != nil {
+		return nil, matches, errors.Trace(err)
+	}
+	for i, report := range reports {
+		record := c.machines[i]
+		if !report.Matches() || !c.extraConstraintsMatch(record, args) {
+			continue
+		}
+		if !args.DryRun {
+			record.statusName = statusAllocated
+			record.statusMessage = args.Comment
+			record.owner = c.user
+			record.agentName = args.AgentName
+		}
+		result := record.clone()
+		return result, constraintMatches(result, report.ConstraintMatches), nil
+	}
+	return nil, matches, gomaasapi.NewNoMatchError("No available machine matches constraints: " + args.String())
+}
+
+// extraConstraintsMatch evaluates the constraints that ExplainAllocation
+// ignores.
+func (c *Controller) extraConstraintsMatch(record *machine, args gomaasapi.AllocateMachineArgs) bool {
+	if args.Pod != "" || args.PodType != "" || len(args.Devices) > 0 {
+		return false
+	}
+	classes := set.NewStrings()
+	for _, iface := range record.interfaces {
+		for _, f := range c.fabrics {
+			if f.name == iface.vlan.fabric {
+				classes.Add(f.classType)
+			}
+		}
+	}
+	return set.NewStrings(args.FabricClasses...).Difference(classes).IsEmpty()
+}
+
+// constraintMatches maps the matched disks and interfaces to those of the
+// allocated machine, and makes sure the maps are not nil, as with the
+// gomaasapi Controller.
+func constraintMatches(m *machine, matched gomaasapi.ConstraintMatches) gomaasapi.ConstraintMatches {
+	result := gomaasapi.ConstraintMatches{
+		Interfaces: make(map[string][]gomaasapi.Interface),
+		Storage:    make(map[string][]gomaasapi.BlockDevice),
+	}
+	for label, ifaces := range matched.Interfaces {
+		for _, iface := range ifaces {
+			result.Interfaces[label] = append(result.Interfaces[label], m.Interface(iface.ID()))
+		}
+	}
+	for label, disks := range matched.Storage {
+		for _, disk := range disks {
+			result.Storage[label] = append(result.Storage[label], m.PhysicalBlockDevice(disk.ID()))
+		}
+	}
+	return result
+}
+
+// ReleaseMachines implements gomaasapi.Controller. No machines are released
+// if any of them cannot be.
+func (c *Controller) ReleaseMachines(args gomaasapi.ReleaseMachinesArgs) error {
+	c.MethodCall(c, "ReleaseMachines", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var (
+		toRelease  []*machine
+		unknownIDs []string
+		unknown    = make(map[string]error)
+		forbidden  []string
+		failed     = make(map[string]error)
+	)
+	for _, systemID := range args.SystemIDs {
+		record := c.machine(systemID)
+		switch {
+		case record == nil:
+			unknownIDs = append(unknownIDs, systemID)
+			unknown[systemID] = gomaasapi.NewNoMatchError(fmt.Sprintf("machine %q not found", systemID))
+		case !c.owns(record.owner):
+			forbidden = append(forbidden, systemID)
+		case !releasableStatuses.Contains(record.statusName):
+			failed[systemID] = gomaasapi.NewCannotCompleteError(fmt.Sprintf(
+				"machine %q cannot be released in state %q", systemID, record.statusName))
+		default:
+			toRelease = append(toRelease, record)
+		}
+	}
+	switch {
+	case len(unknown) > 0:
+		return gomaasapi.NewReleaseMachinesError(gomaasapi.NewBadRequestError(
+			fmt.Sprintf("Unknown machine(s): %s.", strings.Join(unknownIDs, ", "))), unknown)
+	case len(forbidden) > 0:
+		return gomaasapi.NewPermissionError(fmt.Sprintf(
+			"You don't have the required permission to release the following machine(s): %s.",
+			strings.Join(forbidden, ", ")))
+	case len(failed) > 0:
+		return gomaasapi.NewReleaseMachinesError(gomaasapi.NewCannotCompleteError(
+			"Machine(s) cannot be released in their current state."), failed)
+	}
+	for _, record := range toRelease {
+		record.release(args.Comment)
+	}
+	return nil
+}
+
+// Devices implements gomaasapi.Controller.
+func (c *Controller) Devices(args gomaasapi.DevicesArgs) ([]gomaasapi.Device, error) {
+	c.MethodCall(c, "Devices", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []gomaasapi.Device
+	for _, record := range c.matchingDevices(args) {
+		result = append(result, record.clone())
+	}
+	return result, nil
+}
+
+// CreateDevice implements gomaasapi.Controller.
+func (c *Controller) CreateDevice(args gomaasapi.CreateDeviceArgs) (gomaasapi.Device, error) {
+	c.MethodCall(c, "CreateDevice", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, err := c.createDevice(args)
+	if err != nil {
+		return nil, err
+	}
+	return record.clone(), nil
+}
+
+func (c *Controller) createDevice(args gomaasapi.CreateDeviceArgs) (*device, error) {
+	if len(args.MACAddresses) == 0 {
+		return nil, gomaasapi.NewBadRequestError("at least one MAC address must be specified")
+	}
+	if args.Parent != "" && c.machine(args.Parent) == nil {
+		return nil, gomaasapi.NewBadRequestError(fmt.Sprintf("parent: Node %q not found.", args.Parent))
+	}
+	c.nextNode++
+	record := &device{
+		controller: c,
+		systemID:   fmt.Sprintf("d%05d", c.nextNode),
+		hostname:   args.Hostname,
+		domain:     args.Domain,
+		parent:     args.Parent,
+		owner:      c.user,
+		ownerData:  make(map[string]string),
+		zone:       c.zone(DefaultZone),
+	}
+	if record.hostname == "" {
+		record.hostname = "device-" + record.systemID
+	}
+	if record.domain == "" {
+		record.domain = "maas"
+	}
+	for i, mac := range args.MACAddresses {
+		if _, err := net.ParseMAC(mac); err != nil {
+			return nil, gomaasapi.NewBadRequestError(fmt.Sprintf("mac_addresses: %q is not a valid MAC address.", mac))
+		}
+		if c.macInUse(mac) {
+			return nil, gomaasapi.NewBadRequestError(fmt.Sprintf("mac_addresses: This MAC address is already in use by %s.", mac))
+		}
+		record.interfaces = append(record.interfaces, &iface{
+			controller: c,
+			systemID:   record.systemID,
+			id:         c.newID(),
+			name:       fmt.Sprintf("eth%d", i),
+			enabled:    true,
+			vlan:       c.defaultVLAN(),
+			macAddress: mac,
+		})
+	}
+	c.devices = append(c.devices, record)
+	return record, nil
+}
+
+// Files implements gomaasapi.Controller.
+func (c *Controller) Files(prefix string) ([]gomaasapi.File, error) {
+	c.MethodCall(c, "Files", prefix)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []gomaasapi.File
+	var names []string
+	for name := range c.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, c.fileValue(name))
+		}
+	}
+	return result, nil
+}
+
+// GetFile implements gomaasapi.Controller.
+func (c *Controller) GetFile(filename string) (gomaasapi.File, error) {
+	c.MethodCall(c, "GetFile", filename)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return nil, errors.NotValidf("missing filename")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.files[filename]; !ok {
+		return nil, notFound()
+	}
+	return c.fileValue(filename), nil
+}
+
+// AddFile implements gomaasapi.Controller.
+func (c *Controller) AddFile(args gomaasapi.AddFileArgs) error {
+	c.MethodCall(c, "AddFile", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	content := args.Content
+	if content == nil {
+		var err error
+		content, err = ioutil.ReadAll(io.LimitReader(args.Reader, args.Length))
+		if err != nil {
+			return errors.Annotatef(err, "cannot read file content")
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[args.Filename] = append([]byte(nil), content...)
+	return nil
+}
+
+// The methods below expect the caller to hold the lock.
+
+func (c *Controller) newID() int {
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+func (c *Controller) owns(owner string) bool {
+	return owner == "" || owner == c.user
+}
+
+func (c *Controller) addVLAN(f *fabric, vid int, name string) *vlan {
+	record := &vlan{id: c.newID(), name: name, fabric: f.name, vid: vid, mtu: 1500}
+	c.vlans = append(c.vlans, record)
+	return record
+}
+
+func (c *Controller) defaultVLAN() *vlan {
+	for _, record := range c.vlans {
+		if record.fabric == c.fabric(DefaultFabric).name && record.vid == 0 {
+			return record
+		}
+	}
+	panic("missing default VLAN")
+}
+
+func (c *Controller) mustVLAN(id int) *vlan {
+	if record := c.vlan(id); record != nil {
+		return record
+	}
+	panic(fmt.Sprintf("no VLAN %d", id))
+}
+
+func (c *Controller) mustZone(name string) *zone {
+	if record := c.zone(name); record != nil {
+		return record
+	}
+	panic(fmt.Sprintf("no zone %q", name))
+}
+
+func (c *Controller) zone(name string) *zone {
+	for _, record := range c.zones {
+		if record.name == name {
+			return record
+		}
+	}
+	return nil
+}
+
+func (c *Controller) fabric(id int) *fabric {
+	for _, record := range c.fabrics {
+		if record.id == id {
+			return record
+		}
+	}
+	return nil
+}
+
+func (c *Controller) spaceByName(name string) *space {
+	for _, record := range c.spaces {
+		if record.name == name {
+			return record
+		}
+	}
+	return nil
+}
+
+func (c *Controller) subnet(id int) *subnet {
+	for _, record := range c.subnets {
+		if record.id == id {
+			return record
+		}
+	}
+	return nil
+}
+
+func (c *Controller) machine(systemID string) *machine {
+	for _, record := range c.machines {
+		if record.systemID == systemID {
+			return record
+		}
+	}
+	return nil
+}
+
+func (c *Controller) device(systemID string) *device {
+	for _, record := range c.devices {
+		if record.systemID == systemID {
+			return record
+		}
+	}
+	return nil
+}
+
+// nodeInterfaces returns the interfaces of the machine or device.
+func (c *Controller) nodeInterfaces(systemID string) *[]*iface {
+	if record := c.machine(systemID); record != nil {
+		return &record.interfaces
+	}
+	if record := c.device(systemID); record != nil {
+		return &record.interfaces
+	}
+	return nil
+}
+
+// macInUse returns true if any interface of a machine or device has the MAC
+// address.
+func (c *Controller) macInUse(mac string) bool {
+	for _, record := range c.machines {
+		if filterMACs([]string{mac}, record.interfaces) {
+			return true
+		}
+	}
+	for _, record := range c.devices {
+		if filterMACs([]string{mac}, record.interfaces) {
+			return true
+		}
+	}
+	return false
+}
+
+// usedAddresses returns the link addresses of all the machines and devices.
+func (c *Controller) usedAddresses() map[string]bool {
+	used := make(map[string]bool)
+	add := func(interfaces []*iface) {
+		for _, record := range interfaces {
+			for _, l := range record.links {
+				if ip := net.ParseIP(l.ipAddress); ip != nil {
+					used[ip.String()] = true
+				}
+			}
+		}
+	}
+	for _, record := range c.machines {
+		add(record.interfaces)
+	}
+	for _, record := range c.devices {
+		add(record.interfaces)
+	}
+	return used
+}
+
+func (c *Controller) fabricValue(record *fabric) *fabric {
+	result := *record
+	result.vlans = nil
+	for _, v := range c.vlans {
+		if v.fabric == record.name {
+			result.vlans = append(result.vlans, v)
+		}
+	}
+	return &result
+}
+
+func (c *Controller) spaceValue(record *space) *space {
+	result := *record
+	result.subnets = nil
+	for _, s := range c.subnets {
+		if s.space == record.name {
+			result.subnets = append(result.subnets, s)
+		}
+	}
+	return &result
+}
+
+func (c *Controller) fileValue(name string) *file {
+	return &file{
+		controller: c,
+		filename:   name,
+		content:    append([]byte(nil), c.files[name]...),
+	}
+}
+
+// notFound returns the error the gomaasapi Controller returns for a 404.
+func notFound() error {
+	return gomaasapi.NewNoMatchError("Not Found")
+}
+
+// permissionDenied returns the error for a node owned by another user.
+func permissionDenied() error {
+	return gomaasapi.NewPermissionError("You do not have permission to modify this node.")
+}
+
+func filterValue(filter, value string) bool {
+	return filter == "" || filter == value
+}
+
+func filterValues(filter []string, value string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	return set.NewStrings(filter...).Contains(value)
+}
+
+// filterMACs returns true if there are no MAC addresses to filter on, or
+// one of the interfaces has one of them.
+func filterMACs(macs []string, interfaces []*iface) bool {
+	if len(macs) == 0 {
+		return true
+	}
+	for _, record := range interfaces {
+		for _, mac := range macs {
+			if strings.EqualFold(mac, record.macAddress) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ownerDataMatches(ownerData, filter map[string]string) bool {
+	for key, value := range filter {
+		if ownerData[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func copyOwnerData(ownerData map[string]string) map[string]string {
+	result := make(map[string]string)
+	for key, value := range ownerData {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,299 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapitest
+
+import (
+	"io"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type controllerSuite struct {
+	testing.CleanupSuite
+	controller *Controller
+	subnet     gomaasapi.Subnet
+}
+
+var _ = gc.Suite(&controllerSuite{})
+
+func (s *controllerSuite) SetUpTest(c *gc.C) {
+	s.CleanupSuite.SetUpTest(c)
+	s.controller = NewController()
+	s.controller.AddSpace("db")
+	s.subnet = s.controller.AddSubnet(NewSubnet("10.20.0.0/24").Space("db").Gateway("10.20.0.1"))
+}
+
+// addMachines adds a small machine, and a bigger one with a tagged disk and
+// an interface on the db space.
+func (s *controllerSuite) addMachines() (small, big gomaasapi.Machine) {
+	small = s.controller.AddMachine(NewMachine("small").
+		Interface(NewInterface("eth0", "52:54:00:00:00:01")))
+	big = s.controller.AddMachine(NewMachine("big").
+		CPUCount(8).
+		Memory(16384).
+		Interface(NewInterface("eth0", "52:54:00:00:00:02")).
+		Interface(NewInterface("eth1", "52:54:00:00:00:03").
+			Link(gomaasapi.LinkModeStatic, s.subnet, "10.20.0.10")).
+		BlockDevice(NewBlockDevice("sda", 100<<30).Tags("ssd")))
+	return small, big
+}
+
+func (s *controllerSuite) TestAllocateStartRelease(c *gc.C) {
+	_, big := s.addMachines()
+	machine, matches, err := s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{
+		MinCPUCount: 4,
+		Storage:     []gomaasapi.StorageSpec{{Label: "root", Size: 50, Tags: []string{"ssd"}}},
+		Interfaces:  []gomaasapi.InterfaceSpec{{Label: "db", Space: "db"}},
+		AgentName:   "agent",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.SystemID(), gc.Equals, "big")
+	c.Check(machine.StatusName(), gc.Equals, "Allocated")
+	c.Check(machine.IPAddresses(), jc.DeepEquals, []string{"10.20.0.10"})
+	c.Assert(matches.Storage["root"], gc.HasLen, 1)
+	c.Check(matches.Storage["root"][0].Name(), gc.Equals, "sda")
+	c.Assert(matches.Interfaces["db"], gc.HasLen, 1)
+	c.Check(matches.Interfaces["db"][0].Name(), gc.Equals, "eth1")
+	c.Check(matches.Interfaces["db"][0], gc.Equals, machine.Interface(matches.Interfaces["db"][0].ID()))
+
+	// The snapshot taken when the machine was added is unchanged.
+	c.Check(big.StatusName(), gc.Equals, "Ready")
+
+	err = machine.Start(gomaasapi.StartArgs{DistroSeries: "bionic"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Deploying")
+	c.Check(machine.DistroSeries(), gc.Equals, "bionic")
+
+	err = machine.Release(gomaasapi.ReleaseArgs{Comment: "done"})
+	c.Assert(err, jc.ErrorIsNil)
+	state := s.controller.MachineState("big")
+	c.Check(state.StatusName(), gc.Equals, "Ready")
+	c.Check(state.StatusMessage(), gc.Equals, "done")
+
+	s.controller.CheckCallNames(c, "AllocateMachine", "Start", "Release")
+	s.controller.CheckCall(c, 2, "Release", gomaasapi.ReleaseArgs{Comment: "done"})
+}
+
+func (s *controllerSuite) TestAllocateNoMatch(c *gc.C) {
+	s.addMachines()
+	_, _, err := s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{MinMemory: 32768})
+	c.Check(err, jc.Satisfies, gomaasapi.IsNoMatchError)
+	_, _, err = s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{Pod: "pod"})
+	c.Check(err, jc.Satisfies, gomaasapi.IsNoMatchError)
+}
+
+func (s *controllerSuite) TestAllocateDryRun(c *gc.C) {
+	s.addMachines()
+	machine, _, err := s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{DryRun: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "small")
+	c.Check(machine.StatusName(), gc.Equals, "Ready")
+	c.Check(s.controller.MachineState("small").StatusName(), gc.Equals, "Ready")
+}
+
+func (s *controllerSuite) TestAllocateSkipsAllocatedMachines(c *gc.C) {
+	s.addMachines()
+	first, _, err := s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	second, _, err := s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(first.SystemID(), gc.Equals, "small")
+	c.Check(second.SystemID(), gc.Equals, "big")
+	_, _, err = s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{})
+	c.Check(err, jc.Satisfies, gomaasapi.IsNoMatchError)
+}
+
+func (s *controllerSuite) TestStartNotAllocated(c *gc.C) {
+	small, _ := s.addMachines()
+	err := small.Start(gomaasapi.StartArgs{})
+	c.Check(err, jc.Satisfies, gomaasapi.IsBadRequestError)
+}
+
+func (s *controllerSuite) TestReleaseMachinesErrors(c *gc.C) {
+	s.controller.AddMachine(NewMachine("mine").Status("Allocated", "").Owner("admin"))
+	s.controller.AddMachine(NewMachine("theirs").Status("Allocated", "").Owner("other"))
+	s.controller.AddMachine(NewMachine("broken").Status("Broken", "").Owner("admin"))
+
+	err := s.controller.ReleaseMachines(gomaasapi.ReleaseMachinesArgs{SystemIDs: []string{"mine", "missing"}})
+	c.Assert(err, jc.Satisfies, gomaasapi.IsReleaseMachinesError)
+	c.Check(err, jc.Satisfies, gomaasapi.IsBadRequestError)
+	failed := gomaasapi.ReleaseMachinesFailures(err)
+	c.Check(failed, gc.HasLen, 1)
+	c.Check(failed["missing"], jc.Satisfies, gomaasapi.IsNoMatchError)
+
+	err = s.controller.ReleaseMachines(gomaasapi.ReleaseMachinesArgs{SystemIDs: []string{"mine", "theirs"}})
+	c.Check(err, jc.Satisfies, gomaasapi.IsPermissionError)
+
+	err = s.controller.ReleaseMachines(gomaasapi.ReleaseMachinesArgs{SystemIDs: []string{"mine", "broken"}})
+	c.Assert(err, jc.Satisfies, gomaasapi.IsReleaseMachinesError)
+	failed = gomaasapi.ReleaseMachinesFailures(err)
+	c.Check(failed["broken"], jc.Satisfies, gomaasapi.IsCannotCompleteError)
+
+	// Nothing is released unless everything can be.
+	c.Check(s.controller.MachineState("mine").StatusName(), gc.Equals, "Allocated")
+	err = s.controller.ReleaseMachines(gomaasapi.ReleaseMachinesArgs{SystemIDs: []string{"mine"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.controller.MachineState("mine").StatusName(), gc.Equals, "Ready")
+}
+
+func (s *controllerSuite) TestMachinesFilters(c *gc.C) {
+	s.addMachines()
+	machines, err := s.controller.Machines(gomaasapi.MachinesArgs{MACAddresses: []string{"52:54:00:00:00:03"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].SystemID(), gc.Equals, "big")
+	s.controller.CheckCall(c, 0, "Machines", gomaasapi.MachinesArgs{MACAddresses: []string{"52:54:00:00:00:03"}})
+
+	_, err = s.controller.Machine("missing")
+	c.Check(err, jc.Satisfies, gomaasapi.IsNoMatchError)
+}
+
+func (s *controllerSuite) TestInjectedErrors(c *gc.C) {
+	small, _ := s.addMachines()
+	s.controller.SetErrors(nil, errors.New("boom"))
+	_, err := s.controller.Machines(gomaasapi.MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	err = small.Refresh()
+	c.Check(err, gc.ErrorMatches, "boom")
+	s.controller.CheckCallNames(c, "Machines", "Refresh")
+}
+
+func (s *controllerSuite) TestMachineCreateDevice(c *gc.C) {
+	_, big := s.addMachines()
+	device, err := big.CreateDevice(gomaasapi.CreateMachineDeviceArgs{
+		InterfaceName: "eth0",
+		MACAddress:    "52:54:00:00:01:01",
+		Subnet:        s.subnet,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.Parent(), gc.Equals, "big")
+	c.Check(device.Owner(), gc.Equals, "admin")
+	// The first free address is after the gateway.
+	c.Check(device.IPAddresses(), jc.DeepEquals, []string{"10.20.0.2"})
+	c.Check(device.InterfaceSet()[0].Name(), gc.Equals, "eth0")
+
+	devices, err := big.Devices(gomaasapi.DevicesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+	c.Check(devices[0].SystemID(), gc.Equals, device.SystemID())
+
+	_, err = big.CreateDevice(gomaasapi.CreateMachineDeviceArgs{
+		InterfaceName: "eth0",
+		MACAddress:    "52:54:00:00:01:01",
+	})
+	c.Check(err, jc.Satisfies, gomaasapi.IsBadRequestError)
+	_, err = s.controller.CreateDevice(gomaasapi.CreateDeviceArgs{})
+	c.Check(err, jc.Satisfies, gomaasapi.IsBadRequestError)
+
+	err = device.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.controller.DeviceState(device.SystemID()), gc.IsNil)
+}
+
+func (s *controllerSuite) TestDeviceInterfaces(c *gc.C) {
+	device, err := s.controller.CreateDevice(gomaasapi.CreateDeviceArgs{
+		MACAddresses: []string{"52:54:00:00:01:01"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	iface, err := device.CreateInterface(gomaasapi.CreateInterfaceArgs{
+		Name:       "eth1",
+		MACAddress: "52:54:00:00:01:02",
+		VLAN:       s.controller.DefaultVLAN(),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.InterfaceSet(), gc.HasLen, 1)
+	c.Assert(device.Refresh(), jc.ErrorIsNil)
+	c.Check(device.InterfaceSet(), gc.HasLen, 2)
+
+	err = iface.LinkSubnet(gomaasapi.LinkSubnetArgs{
+		Mode:      gomaasapi.LinkModeStatic,
+		Subnet:    s.subnet,
+		IPAddress: "10.20.0.1",
+	})
+	c.Check(err, jc.Satisfies, gomaasapi.IsBadRequestError)
+	err = iface.LinkSubnet(gomaasapi.LinkSubnetArgs{
+		Mode:      gomaasapi.LinkModeStatic,
+		Subnet:    s.subnet,
+		IPAddress: "10.20.0.5",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(iface.Links(), gc.HasLen, 1)
+	c.Check(iface.Links()[0].Mode(), gc.Equals, "static")
+	c.Check(iface.Links()[0].IPAddress(), gc.Equals, "10.20.0.5")
+
+	err = iface.UnlinkSubnet(s.subnet)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Links(), gc.HasLen, 0)
+
+	s.controller.SetUser("other")
+	err = iface.Delete()
+	c.Check(err, jc.Satisfies, gomaasapi.IsPermissionError)
+}
+
+func (s *controllerSuite) TestFiles(c *gc.C) {
+	err := s.controller.AddFile(gomaasapi.AddFileArgs{Filename: "config", Content: []byte("data")})
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.controller.AddFile(gomaasapi.AddFileArgs{
+		Filename: "other",
+		Reader:   strings.NewReader("more"),
+		Length:   4,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The content is read in full, however the reader returns it.
+	err = s.controller.AddFile(gomaasapi.AddFileArgs{
+		Filename: "chunked",
+		Reader:   io.MultiReader(strings.NewReader("abc"), strings.NewReader("def")),
+		Length:   6,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	file, err := s.controller.GetFile("chunked")
+	c.Assert(err, jc.ErrorIsNil)
+	content, err := file.ReadAll()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "abcdef")
+
+	files, err := s.controller.Files("con")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(files, gc.HasLen, 1)
+	content, err = files[0].ReadAll()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "data")
+
+	_, err = s.controller.GetFile("")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(files[0].Delete(), jc.ErrorIsNil)
+	_, err = s.controller.GetFile("config")
+	c.Check(err, jc.Satisfies, gomaasapi.IsNoMatchError)
+}
+
+func (s *controllerSuite) TestNetworks(c *gc.C) {
+	spaces, err := s.controller.Spaces()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spaces, gc.HasLen, 2)
+	c.Check(spaces[1].Name(), gc.Equals, "db")
+	c.Assert(spaces[1].Subnets(), gc.HasLen, 1)
+	c.Check(spaces[1].Subnets()[0].CIDR(), gc.Equals, "10.20.0.0/24")
+
+	fabric := s.controller.AddFabric("", "10g")
+	vlan := s.controller.AddVLAN(fabric.ID(), 42, "storage")
+	fabrics, err := s.controller.Fabrics()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fabrics, gc.HasLen, 2)
+	c.Check(fabrics[1].VLANs(), gc.HasLen, 2)
+	c.Check(vlan.Fabric(), gc.Equals, fabric.Name())
+
+	s.controller.AddMachine(NewMachine("fast").
+		Interface(NewInterface("eth0", "52:54:00:00:00:04").VLAN(vlan)))
+	machine, _, err := s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{
+		FabricClasses: []string{"10g"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "fast")
+}
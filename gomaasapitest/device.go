@@ -0,0 +1,269 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapitest
+
+import (
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+// device implements gomaasapi.Device. The Controller holds a record for each
+// device, and returns clones of it.
+type device struct {
+	controller *Controller
+
+	systemID   string
+	hostname   string
+	domain     string
+	parent     string
+	owner      string
+	ownerData  map[string]string
+	zone       *zone
+	interfaces []*iface
+}
+
+func (d *device) clone() *device {
+	result := *d
+	result.ownerData = copyOwnerData(d.ownerData)
+	result.interfaces = make([]*iface, len(d.interfaces))
+	for i, record := range d.interfaces {
+		result.interfaces[i] = record.clone()
+	}
+	return &result
+}
+
+// SystemID implements gomaasapi.Device.
+func (d *device) SystemID() string {
+	return d.systemID
+}
+
+// Hostname implements gomaasapi.Device.
+func (d *device) Hostname() string {
+	return d.hostname
+}
+
+// FQDN implements gomaasapi.Device.
+func (d *device) FQDN() string {
+	return d.hostname + "." + d.domain
+}
+
+// IPAddresses implements gomaasapi.Device.
+func (d *device) IPAddresses() []string {
+	return linkAddresses(d.interfaces)
+}
+
+// Zone implements gomaasapi.Device.
+func (d *device) Zone() gomaasapi.Zone {
+	if d.zone == nil {
+		return nil
+	}
+	return d.zone
+}
+
+// Parent implements gomaasapi.Device.
+func (d *device) Parent() string {
+	return d.parent
+}
+
+// Owner implements gomaasapi.Device.
+func (d *device) Owner() string {
+	return d.owner
+}
+
+// InterfaceSet implements gomaasapi.Device.
+func (d *device) InterfaceSet() []gomaasapi.Interface {
+	result := make([]gomaasapi.Interface, len(d.interfaces))
+	for i, record := range d.interfaces {
+		result[i] = record
+	}
+	return result
+}
+
+// CreateInterface implements gomaasapi.Device. As with the gomaasapi
+// Device, the new interface is only in the InterfaceSet after a Refresh.
+func (d *device) CreateInterface(args gomaasapi.CreateInterfaceArgs) (gomaasapi.Interface, error) {
+	c := d.controller
+	c.MethodCall(d, "CreateInterface", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.device(d.systemID)
+	switch {
+	case record == nil:
+		return nil, gomaasapi.NewBadRequestError("Not Found")
+	case !c.owns(record.owner):
+		return nil, permissionDenied()
+	}
+	for _, existing := range record.interfaces {
+		if existing.name == args.Name {
+			return nil, gomaasapi.NewBadRequestError(fmt.Sprintf(
+				"name: Interface %q already exists on this node.", args.Name))
+		}
+	}
+	if c.macInUse(args.MACAddress) {
+		return nil, gomaasapi.NewBadRequestError(fmt.Sprintf(
+			"mac_address: This MAC address is already in use by %s.", args.MACAddress))
+	}
+	v := c.vlan(args.VLAN.ID())
+	if v == nil {
+		return nil, gomaasapi.NewBadRequestError("vlan: Select a valid choice.")
+	}
+	result := &iface{
+		controller: c,
+		systemID:   record.systemID,
+		id:         c.newID(),
+		name:       args.Name,
+		macAddress: args.MACAddress,
+		enabled:    true,
+		tags:       append([]string(nil), args.Tags...),
+		mtu:        args.MTU,
+		vlan:       v,
+	}
+	record.interfaces = append(record.interfaces, result)
+	return result.clone(), nil
+}
+
+// Update implements gomaasapi.Device.
+func (d *device) Update(args gomaasapi.UpdateDeviceArgs) error {
+	c := d.controller
+	c.MethodCall(d, "Update", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	var empty gomaasapi.UpdateDeviceArgs
+	if args == empty {
+		return nil
+	}
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.Parent == d.systemID {
+		return errors.NotValidf("device %q as its own Parent", d.systemID)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.device(d.systemID)
+	switch {
+	case record == nil:
+		return notFound()
+	case !c.owns(record.owner):
+		return permissionDenied()
+	}
+	var newZone *zone
+	if args.Zone != "" {
+		if newZone = c.zone(args.Zone); newZone == nil {
+			return gomaasapi.NewBadRequestError("zone: Select a valid choice.")
+		}
+	}
+	if args.Parent != "" && c.machine(args.Parent) == nil {
+		return gomaasapi.NewBadRequestError(fmt.Sprintf("parent: Node %q not found.", args.Parent))
+	}
+	if args.Hostname != "" {
+		record.hostname = args.Hostname
+	}
+	if args.Domain != "" {
+		record.domain = args.Domain
+	}
+	if newZone != nil {
+		record.zone = newZone
+	}
+	if args.Parent != "" {
+		record.parent = args.Parent
+	}
+	d.updateFrom(record)
+	return nil
+}
+
+// Refresh implements gomaasapi.Device.
+func (d *device) Refresh() error {
+	c := d.controller
+	c.MethodCall(d, "Refresh")
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.device(d.systemID)
+	if record == nil {
+		return notFound()
+	}
+	d.updateFrom(record)
+	return nil
+}
+
+// Delete implements gomaasapi.Device.
+func (d *device) Delete() error {
+	c := d.controller
+	c.MethodCall(d, "Delete")
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, record := range c.devices {
+		if record.systemID != d.systemID {
+			continue
+		}
+		if !c.owns(record.owner) {
+			return permissionDenied()
+		}
+		c.devices = append(c.devices[:i], c.devices[i+1:]...)
+		return nil
+	}
+	return notFound()
+}
+
+// OwnerData implements gomaasapi.OwnerDataHolder.
+func (d *device) OwnerData() map[string]string {
+	return copyOwnerData(d.ownerData)
+}
+
+// SetOwnerData implements gomaasapi.OwnerDataHolder.
+func (d *device) SetOwnerData(ownerData map[string]string) error {
+	c := d.controller
+	c.MethodCall(d, "SetOwnerData", ownerData)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.device(d.systemID)
+	switch {
+	case record == nil:
+		return notFound()
+	case !c.owns(record.owner):
+		return permissionDenied()
+	}
+	setOwnerData(record.ownerData, ownerData)
+	d.updateFrom(record)
+	return nil
+}
+
+func (d *device) updateFrom(record *device) {
+	*d = *record.clone()
+}
+
+// matchingDevices returns the device records that match the filters. The
+// caller must hold the lock.
+func (c *Controller) matchingDevices(args gomaasapi.DevicesArgs) []*device {
+	var result []*device
+	for _, record := range c.devices {
+		if !filterValues(args.Hostname, record.hostname) ||
+			!filterValues(args.SystemIDs, record.systemID) ||
+			!filterMACs(args.MACAddresses, record.interfaces) ||
+			!filterValue(args.Domain, record.domain) ||
+			!filterValue(args.Zone, record.zone.name) {
+			continue
+		}
+		result = append(result, record)
+	}
+	return result
+}
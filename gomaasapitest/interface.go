@@ -0,0 +1,373 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapitest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+// iface implements gomaasapi.Interface, for the interfaces of machines and
+// devices.
+type iface struct {
+	controller *Controller
+	systemID   string
+	id         int
+	name       string
+	macAddress string
+	enabled    bool
+	tags       []string
+	mtu        int
+	vlan       *vlan
+	links      []*link
+}
+
+func (i *iface) clone() *iface {
+	result := *i
+	result.tags = append([]string(nil), i.tags...)
+	result.links = append([]*link(nil), i.links...)
+	return &result
+}
+
+// ID implements gomaasapi.Interface.
+func (i *iface) ID() int {
+	return i.id
+}
+
+// Name implements gomaasapi.Interface.
+func (i *iface) Name() string {
+	return i.name
+}
+
+// Parents implements gomaasapi.Interface. Only physical interfaces are
+// supported, so there are none.
+func (i *iface) Parents() []string {
+	return nil
+}
+
+// Children implements gomaasapi.Interface.
+func (i *iface) Children() []string {
+	return nil
+}
+
+// Type implements gomaasapi.Interface.
+func (i *iface) Type() string {
+	return "physical"
+}
+
+// Enabled implements gomaasapi.Interface.
+func (i *iface) Enabled() bool {
+	return i.enabled
+}
+
+// Tags implements gomaasapi.Interface.
+func (i *iface) Tags() []string {
+	return append([]string(nil), i.tags...)
+}
+
+// VLAN implements gomaasapi.Interface.
+func (i *iface) VLAN() gomaasapi.VLAN {
+	if i.vlan == nil {
+		return nil
+	}
+	return i.vlan
+}
+
+// Links implements gomaasapi.Interface.
+func (i *iface) Links() []gomaasapi.Link {
+	var result []gomaasapi.Link
+	for _, l := range i.links {
+		result = append(result, l)
+	}
+	return result
+}
+
+// MACAddress implements gomaasapi.Interface.
+func (i *iface) MACAddress() string {
+	return i.macAddress
+}
+
+// EffectiveMTU implements gomaasapi.Interface.
+func (i *iface) EffectiveMTU() int {
+	if i.mtu == 0 && i.vlan != nil {
+		return i.vlan.mtu
+	}
+	return i.mtu
+}
+
+// Update implements gomaasapi.Interface.
+func (i *iface) Update(args gomaasapi.UpdateInterfaceArgs) error {
+	c := i.controller
+	c.MethodCall(i, "Update", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	var empty gomaasapi.UpdateInterfaceArgs
+	if args == empty {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, err := c.modifiableInterface(i.systemID, i.id)
+	if err != nil {
+		return err
+	}
+	var newVLAN *vlan
+	if args.VLAN != nil {
+		if newVLAN = c.vlan(args.VLAN.ID()); newVLAN == nil {
+			return gomaasapi.NewUnexpectedError(errors.New("vlan: Select a valid choice."))
+		}
+	}
+	if args.MACAddress != "" && !strings.EqualFold(args.MACAddress, record.macAddress) && c.macInUse(args.MACAddress) {
+		return gomaasapi.NewUnexpectedError(errors.Errorf(
+			"mac_address: This MAC address is already in use by %s.", args.MACAddress))
+	}
+	if newVLAN != nil {
+		record.vlan = newVLAN
+	}
+	if args.Name != "" {
+		record.name = args.Name
+	}
+	if args.MACAddress != "" {
+		record.macAddress = args.MACAddress
+	}
+	i.updateFrom(record)
+	return nil
+}
+
+// Refresh implements gomaasapi.Interface.
+func (i *iface) Refresh() error {
+	c := i.controller
+	c.MethodCall(i, "Refresh")
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, _ := c.nodeInterface(i.systemID, i.id)
+	if record == nil {
+		return notFound()
+	}
+	i.updateFrom(record)
+	return nil
+}
+
+// Delete implements gomaasapi.Interface.
+func (i *iface) Delete() error {
+	c := i.controller
+	c.MethodCall(i, "Delete")
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.modifiableInterface(i.systemID, i.id); err != nil {
+		return err
+	}
+	interfaces := c.nodeInterfaces(i.systemID)
+	for index, record := range *interfaces {
+		if record.id == i.id {
+			*interfaces = append((*interfaces)[:index], (*interfaces)[index+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// LinkSubnet implements gomaasapi.Interface. A static link without an IP
+// address gets the first free address in the subnet.
+func (i *iface) LinkSubnet(args gomaasapi.LinkSubnetArgs) error {
+	c := i.controller
+	c.MethodCall(i, "LinkSubnet", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, err := c.modifiableInterface(i.systemID, i.id)
+	if err != nil {
+		return err
+	}
+	subnet := c.subnet(args.Subnet.ID())
+	if subnet == nil {
+		return gomaasapi.NewBadRequestError("subnet: Select a valid choice.")
+	}
+	newLink := &link{mode: strings.ToLower(string(args.Mode)), subnet: subnet}
+	if args.Mode == gomaasapi.LinkModeStatic {
+		if newLink.ipAddress, err = c.staticAddress(subnet, args.IPAddress); err != nil {
+			return err
+		}
+	}
+	newLink.id = c.newID()
+	record.links = append(record.links, newLink)
+	i.updateFrom(record)
+	return nil
+}
+
+// UnlinkSubnet implements gomaasapi.Interface.
+func (i *iface) UnlinkSubnet(s gomaasapi.Subnet) error {
+	c := i.controller
+	c.MethodCall(i, "UnlinkSubnet", s)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	if s == nil {
+		return errors.NotValidf("missing Subnet")
+	}
+	var linkID int
+	for _, l := range i.links {
+		if l.subnet != nil && l.subnet.id == s.ID() {
+			linkID = l.id
+		}
+	}
+	if linkID == 0 {
+		return errors.NotValidf("unlinked Subnet")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, err := c.modifiableInterface(i.systemID, i.id)
+	if err != nil {
+		return err
+	}
+	for index, l := range record.links {
+		if l.id == linkID {
+			record.links = append(record.links[:index], record.links[index+1:]...)
+			i.updateFrom(record)
+			return nil
+		}
+	}
+	return gomaasapi.NewBadRequestError("id: Select a valid choice.")
+}
+
+func (i *iface) updateFrom(record *iface) {
+	*i = *record.clone()
+}
+
+// buildInterface creates the interface described by the builder for the
+// node.
+func (c *Controller) buildInterface(systemID string, b *InterfaceBuilder) *iface {
+	if c.macInUse(b.macAddress) {
+		panic(fmt.Sprintf("MAC address %q already in use", b.macAddress))
+	}
+	result := &iface{
+		controller: c,
+		systemID:   systemID,
+		id:         c.newID(),
+		name:       b.name,
+		macAddress: b.macAddress,
+		enabled:    !b.disabled,
+		tags:       append([]string(nil), b.tags...),
+		mtu:        b.mtu,
+		vlan:       c.defaultVLAN(),
+	}
+	if b.vlan != nil {
+		result.vlan = c.mustVLAN(b.vlan.ID())
+	}
+	for _, spec := range b.links {
+		s := c.subnet(spec.subnet.ID())
+		if s == nil {
+			panic(fmt.Sprintf("no subnet %d", spec.subnet.ID()))
+		}
+		result.links = append(result.links, &link{
+			id:        c.newID(),
+			mode:      strings.ToLower(string(spec.mode)),
+			subnet:    s,
+			ipAddress: spec.ipAddress,
+		})
+	}
+	return result
+}
+
+// The methods below expect the caller to hold the lock.
+
+func (c *Controller) vlan(id int) *vlan {
+	for _, record := range c.vlans {
+		if record.id == id {
+			return record
+		}
+	}
+	return nil
+}
+
+// nodeInterface returns the interface of the machine or device, along with
+// the owner of the node.
+func (c *Controller) nodeInterface(systemID string, id int) (*iface, string) {
+	var owner string
+	if record := c.machine(systemID); record != nil {
+		owner = record.owner
+	} else if record := c.device(systemID); record != nil {
+		owner = record.owner
+	}
+	interfaces := c.nodeInterfaces(systemID)
+	if interfaces == nil {
+		return nil, ""
+	}
+	for _, record := range *interfaces {
+		if record.id == id {
+			return record, owner
+		}
+	}
+	return nil, ""
+}
+
+// modifiableInterface returns the interface if it exists and the user may
+// change it.
+func (c *Controller) modifiableInterface(systemID string, id int) (*iface, error) {
+	record, owner := c.nodeInterface(systemID, id)
+	if record == nil {
+		return nil, notFound()
+	}
+	if !c.owns(owner) {
+		return nil, permissionDenied()
+	}
+	return record, nil
+}
+
+// staticAddress checks that the requested address is in the subnet and not
+// in use, or picks the first free address if none was requested.
+func (c *Controller) staticAddress(s *subnet, requested string) (string, error) {
+	_, network, err := net.ParseCIDR(s.cidr)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	used := c.usedAddresses()
+	used[s.gateway] = true
+	if requested != "" {
+		ip := net.ParseIP(requested)
+		if ip == nil || !network.Contains(ip) {
+			return "", gomaasapi.NewBadRequestError(fmt.Sprintf(
+				"ip_address: IP address %s is not within subnet %s.", requested, s.cidr))
+		}
+		if used[ip.String()] {
+			return "", gomaasapi.NewBadRequestError(fmt.Sprintf(
+				"ip_address: IP address %s is already in use.", requested))
+		}
+		return ip.String(), nil
+	}
+	for ip := nextIP(network.IP); network.Contains(ip); ip = nextIP(ip) {
+		if !used[ip.String()] {
+			return ip.String(), nil
+		}
+	}
+	return "", gomaasapi.NewCannotCompleteError(fmt.Sprintf("No more IPs available in subnet: %s.", s.cidr))
+}
+
+// nextIP returns the address after ip.
+func nextIP(ip net.IP) net.IP {
+	result := append(net.IP(nil), ip...)
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i]++
+		if result[i] != 0 {
+			break
+		}
+	}
+	return result
+}
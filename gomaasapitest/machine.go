@@ -0,0 +1,414 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapitest
+
+import (
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+// machine implements gomaasapi.Machine. The Controller holds a record for
+// each machine, and returns clones of it.
+type machine struct {
+	controller *Controller
+
+	systemID      string
+	hostname      string
+	domain        string
+	architecture  string
+	cpuCount      int
+	memory        int
+	tags          []string
+	powerState    string
+	statusName    string
+	statusMessage string
+	owner         string
+	ownerData     map[string]string
+	agentName     string
+	osystem       string
+	distroSeries  string
+	zone          *zone
+	interfaces    []*iface
+	blockDevices  []*blockDevice
+}
+
+func (m *machine) clone() *machine {
+	result := *m
+	result.tags = append([]string(nil), m.tags...)
+	result.ownerData = copyOwnerData(m.ownerData)
+	result.interfaces = make([]*iface, len(m.interfaces))
+	for i, record := range m.interfaces {
+		result.interfaces[i] = record.clone()
+	}
+	result.blockDevices = append([]*blockDevice(nil), m.blockDevices...)
+	return &result
+}
+
+// release returns the machine to the pool. Erasing the disks is not
+// simulated, so the machine is Ready straight away.
+func (m *machine) release(comment string) {
+	m.statusName = statusReady
+	m.statusMessage = comment
+	m.powerState = "off"
+	m.owner = ""
+	m.ownerData = make(map[string]string)
+	m.agentName = ""
+	m.osystem = ""
+	m.distroSeries = ""
+}
+
+// SystemID implements gomaasapi.Machine.
+func (m *machine) SystemID() string {
+	return m.systemID
+}
+
+// Hostname implements gomaasapi.Machine.
+func (m *machine) Hostname() string {
+	return m.hostname
+}
+
+// FQDN implements gomaasapi.Machine.
+func (m *machine) FQDN() string {
+	return m.hostname + "." + m.domain
+}
+
+// Tags implements gomaasapi.Machine.
+func (m *machine) Tags() []string {
+	return append([]string(nil), m.tags...)
+}
+
+// OperatingSystem implements gomaasapi.Machine.
+func (m *machine) OperatingSystem() string {
+	return m.osystem
+}
+
+// DistroSeries implements gomaasapi.Machine.
+func (m *machine) DistroSeries() string {
+	return m.distroSeries
+}
+
+// Architecture implements gomaasapi.Machine.
+func (m *machine) Architecture() string {
+	return m.architecture
+}
+
+// Memory implements gomaasapi.Machine.
+func (m *machine) Memory() int {
+	return m.memory
+}
+
+// CPUCount implements gomaasapi.Machine.
+func (m *machine) CPUCount() int {
+	return m.cpuCount
+}
+
+// IPAddresses implements gomaasapi.Machine.
+func (m *machine) IPAddresses() []string {
+	return linkAddresses(m.interfaces)
+}
+
+// PowerState implements gomaasapi.Machine.
+func (m *machine) PowerState() string {
+	return m.powerState
+}
+
+// Devices implements gomaasapi.Machine.
+func (m *machine) Devices(args gomaasapi.DevicesArgs) ([]gomaasapi.Device, error) {
+	c := m.controller
+	c.MethodCall(m, "Devices", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []gomaasapi.Device
+	for _, record := range c.matchingDevices(args) {
+		if record.parent == m.systemID {
+			result = append(result, record.clone())
+		}
+	}
+	return result, nil
+}
+
+// StatusName implements gomaasapi.Machine.
+func (m *machine) StatusName() string {
+	return m.statusName
+}
+
+// StatusMessage implements gomaasapi.Machine.
+func (m *machine) StatusMessage() string {
+	return m.statusMessage
+}
+
+// BootInterface implements gomaasapi.Machine. It is the first interface.
+func (m *machine) BootInterface() gomaasapi.Interface {
+	if len(m.interfaces) == 0 {
+		return nil
+	}
+	return m.interfaces[0]
+}
+
+// InterfaceSet implements gomaasapi.Machine.
+func (m *machine) InterfaceSet() []gomaasapi.Interface {
+	result := make([]gomaasapi.Interface, len(m.interfaces))
+	for i, record := range m.interfaces {
+		result[i] = record
+	}
+	return result
+}
+
+// Interface implements gomaasapi.Machine.
+func (m *machine) Interface(id int) gomaasapi.Interface {
+	for _, record := range m.interfaces {
+		if record.id == id {
+			return record
+		}
+	}
+	return nil
+}
+
+// PhysicalBlockDevices implements gomaasapi.Machine.
+func (m *machine) PhysicalBlockDevices() []gomaasapi.BlockDevice {
+	result := make([]gomaasapi.BlockDevice, len(m.blockDevices))
+	for i, record := range m.blockDevices {
+		result[i] = record
+	}
+	return result
+}
+
+// PhysicalBlockDevice implements gomaasapi.Machine.
+func (m *machine) PhysicalBlockDevice(id int) gomaasapi.BlockDevice {
+	for _, record := range m.blockDevices {
+		if record.id == id {
+			return record
+		}
+	}
+	return nil
+}
+
+// BlockDevices implements gomaasapi.Machine. There are only physical block
+// devices.
+func (m *machine) BlockDevices() []gomaasapi.BlockDevice {
+	return m.PhysicalBlockDevices()
+}
+
+// Zone implements gomaasapi.Machine.
+func (m *machine) Zone() gomaasapi.Zone {
+	if m.zone == nil {
+		return nil
+	}
+	return m.zone
+}
+
+// Start implements gomaasapi.Machine. The machine must be Allocated, and is
+// left Deploying; see Controller.SetMachineStatus.
+func (m *machine) Start(args gomaasapi.StartArgs) error {
+	c := m.controller
+	c.MethodCall(m, "Start", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.machine(m.systemID)
+	switch {
+	case record == nil:
+		return gomaasapi.NewBadRequestError("Not Found")
+	case !c.owns(record.owner):
+		return permissionDenied()
+	case record.statusName != statusAllocated:
+		return gomaasapi.NewBadRequestError(fmt.Sprintf(
+			"Can't deploy a machine that is in the '%s' state.", record.statusName))
+	}
+	record.statusName = statusDeploying
+	record.statusMessage = args.Comment
+	record.powerState = "on"
+	record.osystem = "ubuntu"
+	record.distroSeries = args.DistroSeries
+	if record.distroSeries == "" {
+		record.distroSeries = "xenial"
+	}
+	if args.AgentName != "" {
+		record.agentName = args.AgentName
+	}
+	m.updateFrom(record)
+	return nil
+}
+
+// Release implements gomaasapi.Machine.
+func (m *machine) Release(args gomaasapi.ReleaseArgs) error {
+	c := m.controller
+	c.MethodCall(m, "Release", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.machine(m.systemID)
+	switch {
+	case record == nil:
+		return notFound()
+	case !c.owns(record.owner):
+		return permissionDenied()
+	case !releasableStatuses.Contains(record.statusName):
+		return gomaasapi.NewCannotCompleteError(fmt.Sprintf(
+			"Machine cannot be released in its current state ('%s').", record.statusName))
+	}
+	record.release(args.Comment)
+	m.updateFrom(record)
+	return nil
+}
+
+// CreateDevice implements gomaasapi.Machine. The device is deleted again if
+// its interface cannot be set up.
+func (m *machine) CreateDevice(args gomaasapi.CreateMachineDeviceArgs) (gomaasapi.Device, error) {
+	c := m.controller
+	c.MethodCall(m, "CreateDevice", args)
+	if err := c.NextErr(); err != nil {
+		return nil, err
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, err := c.createDevice(gomaasapi.CreateDeviceArgs{
+		Hostname:     args.Hostname,
+		MACAddresses: []string{args.MACAddress},
+		Parent:       m.systemID,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := c.setUpDeviceInterface(record.interfaces[0], args); err != nil {
+		c.devices = c.devices[:len(c.devices)-1]
+		return nil, errors.Trace(err)
+	}
+	return record.clone(), nil
+}
+
+// setUpDeviceInterface names the interface of a device created for a
+// machine, and puts it on the VLAN and subnet, as Machine.CreateDevice does.
+func (c *Controller) setUpDeviceInterface(record *iface, args gomaasapi.CreateMachineDeviceArgs) error {
+	vlanToUse := args.VLAN
+	if vlanToUse == nil && args.Subnet != nil {
+		vlanToUse = args.Subnet.VLAN()
+	}
+	if vlanToUse != nil {
+		v := c.vlan(vlanToUse.ID())
+		if v == nil {
+			return errors.Annotatef(
+				gomaasapi.NewUnexpectedError(errors.New("vlan: Select a valid choice.")),
+				"updating device interface %q failed", record.name)
+		}
+		record.vlan = v
+	}
+	record.name = args.InterfaceName
+	if args.Subnet == nil {
+		return nil
+	}
+	s := c.subnet(args.Subnet.ID())
+	if s == nil {
+		return errors.Annotatef(
+			gomaasapi.NewBadRequestError("subnet: Select a valid choice."),
+			"linking device interface %q to subnet %q failed", record.name, args.Subnet.CIDR())
+	}
+	address, err := c.staticAddress(s, "")
+	if err != nil {
+		return errors.Annotatef(err, "linking device interface %q to subnet %q failed", record.name, s.cidr)
+	}
+	record.links = append(record.links, &link{id: c.newID(), mode: "static", subnet: s, ipAddress: address})
+	return nil
+}
+
+// Details implements gomaasapi.Machine. No details are gathered, so they
+// are empty.
+func (m *machine) Details() (gomaasapi.MachineDetails, error) {
+	c := m.controller
+	c.MethodCall(m, "Details")
+	return gomaasapi.MachineDetails{}, c.NextErr()
+}
+
+// CommissioningResults implements gomaasapi.Machine. There are none.
+func (m *machine) CommissioningResults() ([]gomaasapi.CommissioningResult, error) {
+	c := m.controller
+	c.MethodCall(m, "CommissioningResults")
+	return nil, c.NextErr()
+}
+
+// Refresh implements gomaasapi.Machine.
+func (m *machine) Refresh() error {
+	c := m.controller
+	c.MethodCall(m, "Refresh")
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.machine(m.systemID)
+	if record == nil {
+		return notFound()
+	}
+	m.updateFrom(record)
+	return nil
+}
+
+// OwnerData implements gomaasapi.OwnerDataHolder.
+func (m *machine) OwnerData() map[string]string {
+	return copyOwnerData(m.ownerData)
+}
+
+// SetOwnerData implements gomaasapi.OwnerDataHolder.
+func (m *machine) SetOwnerData(ownerData map[string]string) error {
+	c := m.controller
+	c.MethodCall(m, "SetOwnerData", ownerData)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.machine(m.systemID)
+	switch {
+	case record == nil:
+		return notFound()
+	case !c.owns(record.owner):
+		return permissionDenied()
+	}
+	setOwnerData(record.ownerData, ownerData)
+	m.updateFrom(record)
+	return nil
+}
+
+func (m *machine) updateFrom(record *machine) {
+	*m = *record.clone()
+}
+
+// setOwnerData updates the owner data, removing the keys set to "".
+func setOwnerData(ownerData, values map[string]string) {
+	for key, value := range values {
+		if value == "" {
+			delete(ownerData, key)
+		} else {
+			ownerData[key] = value
+		}
+	}
+}
+
+// linkAddresses returns the IP addresses of the links of the interfaces.
+func linkAddresses(interfaces []*iface) []string {
+	var result []string
+	for _, record := range interfaces {
+		for _, l := range record.links {
+			if l.ipAddress != "" {
+				result = append(result, l.ipAddress)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,319 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapitest
+
+import (
+	"net/url"
+
+	"github.com/juju/gomaasapi"
+)
+
+// zone implements gomaasapi.Zone.
+type zone struct {
+	name        string
+	description string
+}
+
+// Name implements gomaasapi.Zone.
+func (z *zone) Name() string {
+	return z.name
+}
+
+// Description implements gomaasapi.Zone.
+func (z *zone) Description() string {
+	return z.description
+}
+
+// fabric implements gomaasapi.Fabric. The VLANs are filled in when the
+// fabric is returned.
+type fabric struct {
+	id        int
+	name      string
+	classType string
+	vlans     []*vlan
+}
+
+// ID implements gomaasapi.Fabric.
+func (f *fabric) ID() int {
+	return f.id
+}
+
+// Name implements gomaasapi.Fabric.
+func (f *fabric) Name() string {
+	return f.name
+}
+
+// ClassType implements gomaasapi.Fabric.
+func (f *fabric) ClassType() string {
+	return f.classType
+}
+
+// VLANs implements gomaasapi.Fabric.
+func (f *fabric) VLANs() []gomaasapi.VLAN {
+	var result []gomaasapi.VLAN
+	for _, v := range f.vlans {
+		result = append(result, v)
+	}
+	return result
+}
+
+// vlan implements gomaasapi.VLAN. VLANs do not change once added, so the
+// same value is shared by the subnets and interfaces on it.
+type vlan struct {
+	id     int
+	name   string
+	fabric string
+	vid    int
+	mtu    int
+	dhcp   bool
+}
+
+// ID implements gomaasapi.VLAN.
+func (v *vlan) ID() int {
+	return v.id
+}
+
+// Name implements gomaasapi.VLAN.
+func (v *vlan) Name() string {
+	return v.name
+}
+
+// Fabric implements gomaasapi.VLAN.
+func (v *vlan) Fabric() string {
+	return v.fabric
+}
+
+// VID implements gomaasapi.VLAN.
+func (v *vlan) VID() int {
+	return v.vid
+}
+
+// MTU implements gomaasapi.VLAN.
+func (v *vlan) MTU() int {
+	return v.mtu
+}
+
+// DHCP implements gomaasapi.VLAN.
+func (v *vlan) DHCP() bool {
+	return v.dhcp
+}
+
+// PrimaryRack implements gomaasapi.VLAN. There are no rack controllers.
+func (v *vlan) PrimaryRack() string {
+	return ""
+}
+
+// SecondaryRack implements gomaasapi.VLAN.
+func (v *vlan) SecondaryRack() string {
+	return ""
+}
+
+// space implements gomaasapi.Space. The subnets are filled in when the
+// space is returned.
+type space struct {
+	id      int
+	name    string
+	subnets []*subnet
+}
+
+// ID implements gomaasapi.Space.
+func (s *space) ID() int {
+	return s.id
+}
+
+// Name implements gomaasapi.Space.
+func (s *space) Name() string {
+	return s.name
+}
+
+// Subnets implements gomaasapi.Space.
+func (s *space) Subnets() []gomaasapi.Subnet {
+	var result []gomaasapi.Subnet
+	for _, subnet := range s.subnets {
+		result = append(result, subnet)
+	}
+	return result
+}
+
+// subnet implements gomaasapi.Subnet. Subnets do not change once added.
+type subnet struct {
+	id         int
+	name       string
+	space      string
+	vlan       *vlan
+	gateway    string
+	cidr       string
+	dnsServers []string
+}
+
+// ID implements gomaasapi.Subnet.
+func (s *subnet) ID() int {
+	return s.id
+}
+
+// Name implements gomaasapi.Subnet.
+func (s *subnet) Name() string {
+	return s.name
+}
+
+// Space implements gomaasapi.Subnet.
+func (s *subnet) Space() string {
+	return s.space
+}
+
+// VLAN implements gomaasapi.Subnet.
+func (s *subnet) VLAN() gomaasapi.VLAN {
+	return s.vlan
+}
+
+// Gateway implements gomaasapi.Subnet.
+func (s *subnet) Gateway() string {
+	return s.gateway
+}
+
+// CIDR implements gomaasapi.Subnet.
+func (s *subnet) CIDR() string {
+	return s.cidr
+}
+
+// DNSServers implements gomaasapi.Subnet.
+func (s *subnet) DNSServers() []string {
+	return append([]string(nil), s.dnsServers...)
+}
+
+// StaticRoutes implements gomaasapi.Subnet. There are none.
+func (s *subnet) StaticRoutes() ([]gomaasapi.StaticRoute, error) {
+	return nil, nil
+}
+
+// link implements gomaasapi.Link.
+type link struct {
+	id        int
+	mode      string
+	subnet    *subnet
+	ipAddress string
+}
+
+// ID implements gomaasapi.Link.
+func (l *link) ID() int {
+	return l.id
+}
+
+// Mode implements gomaasapi.Link.
+func (l *link) Mode() string {
+	return l.mode
+}
+
+// Subnet implements gomaasapi.Link.
+func (l *link) Subnet() gomaasapi.Subnet {
+	if l.subnet == nil {
+		return nil
+	}
+	return l.subnet
+}
+
+// IPAddress implements gomaasapi.Link.
+func (l *link) IPAddress() string {
+	return l.ipAddress
+}
+
+// blockDevice implements gomaasapi.BlockDevice. Block devices do not change
+// once added.
+type blockDevice struct {
+	id        int
+	name      string
+	model     string
+	path      string
+	tags      []string
+	blockSize uint64
+	size      uint64
+}
+
+// ID implements gomaasapi.BlockDevice.
+func (b *blockDevice) ID() int {
+	return b.id
+}
+
+// Name implements gomaasapi.BlockDevice.
+func (b *blockDevice) Name() string {
+	return b.name
+}
+
+// Model implements gomaasapi.BlockDevice.
+func (b *blockDevice) Model() string {
+	return b.model
+}
+
+// Path implements gomaasapi.BlockDevice.
+func (b *blockDevice) Path() string {
+	return b.path
+}
+
+// UsedFor implements gomaasapi.BlockDevice.
+func (b *blockDevice) UsedFor() string {
+	return ""
+}
+
+// Tags implements gomaasapi.BlockDevice.
+func (b *blockDevice) Tags() []string {
+	return append([]string(nil), b.tags...)
+}
+
+// BlockSize implements gomaasapi.BlockDevice.
+func (b *blockDevice) BlockSize() uint64 {
+	return b.blockSize
+}
+
+// UsedSize implements gomaasapi.BlockDevice.
+func (b *blockDevice) UsedSize() uint64 {
+	return 0
+}
+
+// Size implements gomaasapi.BlockDevice.
+func (b *blockDevice) Size() uint64 {
+	return b.size
+}
+
+// Partitions implements gomaasapi.BlockDevice. There are none.
+func (b *blockDevice) Partitions() []gomaasapi.Partition {
+	return nil
+}
+
+// file implements gomaasapi.File.
+type file struct {
+	controller *Controller
+	filename   string
+	content    []byte
+}
+
+// Filename implements gomaasapi.File.
+func (f *file) Filename() string {
+	return f.filename
+}
+
+// AnonymousURL implements gomaasapi.File.
+func (f *file) AnonymousURL() string {
+	return "http://maas.invalid/MAAS/api/2.0/files/?op=get_by_key&key=" + url.QueryEscape(f.filename)
+}
+
+// Delete implements gomaasapi.File.
+func (f *file) Delete() error {
+	c := f.controller
+	c.MethodCall(f, "Delete")
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.files[f.filename]; !ok {
+		return notFound()
+	}
+	delete(c.files, f.filename)
+	return nil
+}
+
+// ReadAll implements gomaasapi.File.
+func (f *file) ReadAll() ([]byte, error) {
+	return append([]byte(nil), f.content...), nil
+}
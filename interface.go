@@ -49,6 +49,28 @@ func (i *interface_) updateFrom(other *interface_) {
 	i.children = other.children
 }
 
+// Refresh implements Interface.
+func (i *interface_) Refresh() error {
+	source, err := i.controller.get(i.resourceURI)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	response, err := readInterface(i.controller.apiVersion, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	i.updateFrom(response)
+	return nil
+}
+
 // ID implements Interface.
 func (i *interface_) ID() int {
 	return i.id
@@ -95,8 +117,11 @@ func (i *interface_) VLAN() VLAN {
 // Links implements Interface.
 func (i *interface_) Links() []Link {
 	result := make([]Link, len(i.links))
-	for i, link := range i.links {
-		result[i] = link
+	for index, link := range i.links {
+		if link.subnet != nil {
+			link.subnet.controller = i.controller
+		}
+		result[index] = link
 	}
 	return result
 }
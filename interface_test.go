@@ -120,6 +120,26 @@ func (s *interfaceSuite) getServerAndNewInterface(c *gc.C) (*SimpleTestServer, *
 	return server, iface.(*interface_)
 }
 
+func (s *interfaceSuite) TestRefresh(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"name":    "eth42",
+		"enabled": false,
+	})
+	server.AddGetResponse(iface.resourceURI, http.StatusOK, response)
+	err := iface.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Name(), gc.Equals, "eth42")
+	c.Check(iface.Enabled(), jc.IsFalse)
+}
+
+func (s *interfaceSuite) TestRefreshNotFound(c *gc.C) {
+	_, iface := s.getServerAndNewInterface(c)
+	// No path, so 404
+	err := iface.Refresh()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
 func (s *interfaceSuite) TestDelete(c *gc.C) {
 	server, iface := s.getServerAndNewInterface(c)
 	// Successful delete is 204 - StatusNoContent - We hope, would be consistent
@@ -35,9 +35,69 @@ type Controller interface {
 	// Zones lists all the zones known to the MAAS controller.
 	Zones() ([]Zone, error)
 
+	// GetConfig returns the value of the named global setting, as decoded
+	// from the JSON response. See Config for the documented names.
+	GetConfig(name string) (interface{}, error)
+
+	// SetConfig changes the value of the named global setting.
+	SetConfig(name string, value interface{}) error
+
+	// RackControllers returns the rack controllers registered with MAAS.
+	RackControllers() ([]RackController, error)
+
+	// RegionControllers returns the region controllers registered with MAAS.
+	RegionControllers() ([]RegionController, error)
+
+	// IPRanges returns the dynamic and reserved IP ranges of all subnets.
+	IPRanges() ([]IPRange, error)
+
+	// CreateIPRange creates a new IP range. The range must lie within the
+	// CIDR of the subnet and must not overlap any existing range, otherwise
+	// an error satisfying errors.IsNotValid is returned.
+	CreateIPRange(CreateIPRangeArgs) (IPRange, error)
+
+	// StaticRoutes returns all the static routes defined in MAAS.
+	StaticRoutes() ([]StaticRoute, error)
+
+	// CreateStaticRoute creates and returns a new StaticRoute.
+	CreateStaticRoute(CreateStaticRouteArgs) (StaticRoute, error)
+
+	// DHCPSnippets returns all the DHCP snippets defined in MAAS.
+	DHCPSnippets() ([]DHCPSnippet, error)
+
+	// CreateDHCPSnippet creates and returns a new DHCPSnippet.
+	CreateDHCPSnippet(CreateDHCPSnippetArgs) (DHCPSnippet, error)
+
+	// PackageRepositories returns all the package repositories defined in
+	// MAAS.
+	PackageRepositories() ([]PackageRepository, error)
+
+	// CreatePackageRepository creates and returns a new PackageRepository.
+	CreatePackageRepository(CreatePackageRepositoryArgs) (PackageRepository, error)
+
+	// Discoveries returns the IP and MAC address pairs that the rack
+	// controllers have observed on their networks.
+	Discoveries() ([]Discovery, error)
+
+	// ClearDiscoveries removes the discoveries that match the args.
+	ClearDiscoveries(ClearDiscoveriesArgs) error
+
+	// ScanDiscoveries asks the rack controllers to actively scan their
+	// networks for new discoveries. The scan happens in the background.
+	ScanDiscoveries(ScanDiscoveriesArgs) error
+
 	// Machines returns a list of machines that match the params.
 	Machines(MachinesArgs) ([]Machine, error)
 
+	// The following return a single entity. If the entity doesn't exist, an
+	// error satisfying IsNoMatchError is returned. See also GetFile.
+	Machine(systemID string) (Machine, error)
+	Device(systemID string) (Device, error)
+	Zone(name string) (Zone, error)
+	Fabric(id int) (Fabric, error)
+	Space(id int) (Space, error)
+	Subnet(id int) (Subnet, error)
+
 	// AllocateMachine will attempt to allocate a machine to the user.
 	// If successful, the allocated machine is returned.
 	AllocateMachine(AllocateMachineArgs) (Machine, ConstraintMatches, error)
@@ -126,6 +186,8 @@ type VLAN interface {
 	MTU() int
 	DHCP() bool
 
+	// PrimaryRack and SecondaryRack are the system IDs of the rack
+	// controllers serving the VLAN. See Controller.RackControllers.
 	PrimaryRack() string
 	SecondaryRack() string
 }
@@ -149,8 +211,101 @@ type BootResource interface {
 	KernelFlavor() string
 }
 
+// RackController represents a MAAS rack controller. Rack controllers provide
+// DHCP, TFTP and HTTP boot services to the machines on the VLANs they are
+// attached to.
+type RackController interface {
+	SystemID() string
+	Hostname() string
+	FQDN() string
+	IPAddresses() []string
+
+	// Version is the version of MAAS running on the controller. It is
+	// empty if the controller does not report it.
+	Version() string
+
+	// InterfaceSet returns all the interfaces for the controller.
+	InterfaceSet() []Interface
+
+	// Services returns the status of all the services on the controller.
+	Services() []ServiceStatus
+	// Service returns the status of the named service, e.g. ServiceDHCPD.
+	// If the controller does not report the service, nil is returned.
+	Service(name string) ServiceStatus
+
+	// VLANs returns the VLANs that this controller is the primary or
+	// secondary rack for.
+	VLANs() []VLAN
+
+	// ImportBootImages starts the import of boot images from the region
+	// onto this rack controller.
+	ImportBootImages() error
+
+	// ListBootImages returns the boot images available on this rack
+	// controller.
+	ListBootImages() (BootImages, error)
+}
+
+// RegionController represents a MAAS region controller. If the region
+// controller is also a rack controller, it will also be listed by
+// Controller.RackControllers.
+type RegionController interface {
+	SystemID() string
+	Hostname() string
+	FQDN() string
+	IPAddresses() []string
+
+	// Version is the version of MAAS running on the controller. It is
+	// empty if the controller does not report it.
+	Version() string
+
+	// InterfaceSet returns all the interfaces for the controller.
+	InterfaceSet() []Interface
+
+	// Services returns the status of all the services on the controller.
+	Services() []ServiceStatus
+	// Service returns the status of the named service, e.g. ServiceRegiond.
+	// If the controller does not report the service, nil is returned.
+	Service(name string) ServiceStatus
+
+	// VLANs returns the VLANs that this controller is the primary or
+	// secondary rack for.
+	VLANs() []VLAN
+}
+
+// ServiceStatus is the state of a service running on a rack or region
+// controller.
+type ServiceStatus interface {
+	Name() string
+	// Status is one of the ServiceStatus* constants.
+	Status() string
+	// StatusInfo is a human readable explanation of the status. It is
+	// often empty when the service is running.
+	StatusInfo() string
+}
+
+// BootImages describes the boot images held by a rack controller.
+type BootImages interface {
+	// Connected is false if the rack controller could not be contacted.
+	Connected() bool
+	// Status describes whether the images on the rack controller match
+	// the images on the region, e.g. "synced" or "out-of-sync".
+	Status() string
+	Images() []BootImage
+}
+
+// BootImage is a boot image held by a rack controller.
+type BootImage interface {
+	// Name is the os/series of the image, e.g. "ubuntu/xenial".
+	Name() string
+	Architecture() string
+	SubArchitectures() set.Strings
+}
+
 // Device represents some form of device in MAAS.
 type Device interface {
+	OwnerDataHolder
+
 	// TODO: add domain
 	SystemID() string
 	Hostname() string
@@ -171,6 +326,13 @@ type Device interface {
 	// CreateInterface will create a physical interface for this machine.
 	CreateInterface(CreateInterfaceArgs) (Interface, error)
 
+	// Update changes the hostname, domain, zone or parent of the device.
+	// On success the device reflects the updated state from MAAS.
+	Update(UpdateDeviceArgs) error
+
+	// Refresh reads the current state of the device from MAAS.
+	Refresh() error
+
 	// Delete will remove this Device.
 	Delete() error
 }
@@ -226,9 +388,44 @@ type Machine interface {
 	// Start the machine and install the operating system specified in the args.
 	Start(StartArgs) error
 
+	// Release the machine, stopping it and making it available to be
+	// allocated again, optionally erasing its disks first.
+	Release(ReleaseArgs) error
+
 	// CreateDevice creates a new Device with this Machine as the parent.
 	// The device will have one interface that is linked to the specified subnet.
 	CreateDevice(CreateMachineDeviceArgs) (Device, error)
+
+	// Details returns the hardware details gathered when the machine was
+	// commissioned.
+	Details() (MachineDetails, error)
+
+	// CommissioningResults returns the results of the commissioning scripts
+	// that were run on the machine.
+	CommissioningResults() ([]CommissioningResult, error)
+
+	// Refresh reads the current state of the machine from MAAS.
+	Refresh() error
+}
+
+// CommissioningResult is the outcome of a single commissioning script run
+// on a Machine.
+type CommissioningResult interface {
+	// SystemID is the system ID of the machine the script ran on.
+	SystemID() string
+	// Name is the name of the script, e.g. "00-maas-01-lshw".
+	Name() string
+	// ScriptResult is the exit code of the script.
+	ScriptResult() int
+	// ResultType is one of the ResultType constants, saying whether the
+	// script ran while commissioning, installing or testing the machine.
+	ResultType() int
+	// Updated is the time the result was last updated, as MAAS reports it
+	// in ISO 8601 format without a zone, e.g. "2016-05-12T21:35:31.398".
+	// It is empty if MAAS didn't report a time.
+	Updated() string
+	// Data is the output of the script.
+	Data() []byte
 }
 
 // Space is a name for a collection of Subnets.
@@ -252,6 +449,132 @@ type Subnet interface {
 	// DNSServers is a list of ip addresses of the DNS servers for the subnet.
 	// This list may be empty.
 	DNSServers() []string
+
+	// StaticRoutes returns the static routes that have this subnet as
+	// their source.
+	StaticRoutes() ([]StaticRoute, error)
+}
+
+// StaticRoute is a route from one subnet to another via a gateway. MAAS
+// renders the static routes into the network configuration of deployed
+// machines with interfaces on the source subnet.
+type StaticRoute interface {
+	ID() int
+	Source() Subnet
+	Destination() Subnet
+	// GatewayIP is the address in the source subnet to route through.
+	GatewayIP() string
+	Metric() int
+
+	// Update changes the static route.
+	Update(UpdateStaticRouteArgs) error
+
+	// Delete removes the static route.
+	Delete() error
+}
+
+// IPRange is a range of addresses within a subnet that MAAS either uses
+// for DHCP (IPRangeTypeDynamic), or never assigns (IPRangeTypeReserved).
+type IPRange interface {
+	ID() int
+	// Type is either IPRangeTypeDynamic or IPRangeTypeReserved.
+	Type() string
+	StartIP() string
+	EndIP() string
+	Comment() string
+	// User is the username of the user that created the range. It may be
+	// empty.
+	User() string
+	Subnet() Subnet
+
+	// Update changes the range. If the start or end is changed, the new
+	// range is checked in the same way as for Controller.CreateIPRange.
+	Update(UpdateIPRangeArgs) error
+
+	// Delete removes the range.
+	Delete() error
+}
+
+// DHCPSnippet is a piece of ISC DHCP configuration that MAAS adds to the
+// DHCP server configuration. A snippet applies either globally, to a subnet
+// or to a single node.
+type DHCPSnippet interface {
+	ID() int
+	Name() string
+	// Value is the DHCP configuration itself.
+	Value() string
+	Description() string
+	Enabled() bool
+
+	// Node is the system ID of the node the snippet applies to. It is empty
+	// unless the snippet is scoped to a node.
+	Node() string
+	// Subnet is the subnet the snippet applies to. It is nil unless the
+	// snippet is scoped to a subnet.
+	Subnet() Subnet
+	// Global is true if the snippet applies to all of the DHCP
+	// configuration.
+	Global() bool
+
+	// Update changes the name, value, description or scope of the snippet.
+	Update(UpdateDHCPSnippetArgs) error
+
+	// SetEnabled enables or disables the snippet.
+	SetEnabled(enabled bool) error
+
+	// Delete removes the snippet.
+	Delete() error
+}
+
+// PackageRepository is an apt archive that deployed machines are configured
+// to use.
+type PackageRepository interface {
+	ID() int
+	Name() string
+	URL() string
+	Distributions() []string
+	Components() []string
+	Arches() []string
+	// Key is the GPG public key used to verify the repository.
+	Key() string
+	Enabled() bool
+
+	// Update changes the repository.
+	Update(UpdatePackageRepositoryArgs) error
+
+	// SetEnabled enables or disables the repository.
+	SetEnabled(enabled bool) error
+
+	// Delete removes the repository.
+	Delete() error
+}
+
+// Discovery is an IP address seen in use with a MAC address by a rack
+// controller, either from ARP traffic or from mDNS.
+type Discovery interface {
+	DiscoveryID() string
+	IP() string
+	MACAddress() string
+	// MACOrganization is the vendor of the MAC address, if known.
+	MACOrganization() string
+	// Hostname is the hostname seen with mDNS, if any.
+	Hostname() string
+	// LastSeen is when the rack controller last saw the pair, as reported
+	// by MAAS.
+	LastSeen() string
+
+	// ObserverSystemID, ObserverHostname and ObserverInterfaceName identify
+	// the rack controller and interface that saw the pair.
+	ObserverSystemID() string
+	ObserverHostname() string
+	ObserverInterfaceName() string
+
+	// Fabric and VID identify the VLAN the pair was seen on.
+	Fabric() string
+	VID() int
+	// VLAN and Subnet may be nil if MAAS doesn't report them.
+	VLAN() VLAN
+	Subnet() Subnet
 }
 
 // Interface represents a physical or virtual network interface on a Machine.
@@ -281,6 +604,9 @@ type Interface interface {
 	// Update the name, mac address or VLAN.
 	Update(UpdateInterfaceArgs) error
 
+	// Refresh reads the current state of the interface from MAAS.
+	Refresh() error
+
 	// Delete this interface.
 	Delete() error
 
@@ -0,0 +1,321 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+const (
+	// IPRangeTypeDynamic ranges are used by MAAS for DHCP leases.
+	IPRangeTypeDynamic = "dynamic"
+	// IPRangeTypeReserved ranges are never assigned by MAAS.
+	IPRangeTypeReserved = "reserved"
+)
+
+type ipRange struct {
+	controller *controller
+
+	resourceURI string
+
+	id        int
+	rangeType string
+	startIP   string
+	endIP     string
+	comment   string
+	user      string
+	subnet    *subnet
+}
+
+// ID implements IPRange.
+func (r *ipRange) ID() int {
+	return r.id
+}
+
+// Type implements IPRange.
+func (r *ipRange) Type() string {
+	return r.rangeType
+}
+
+// StartIP implements IPRange.
+func (r *ipRange) StartIP() string {
+	return r.startIP
+}
+
+// EndIP implements IPRange.
+func (r *ipRange) EndIP() string {
+	return r.endIP
+}
+
+// Comment implements IPRange.
+func (r *ipRange) Comment() string {
+	return r.comment
+}
+
+// User implements IPRange.
+func (r *ipRange) User() string {
+	return r.user
+}
+
+// Subnet implements IPRange.
+func (r *ipRange) Subnet() Subnet {
+	if r.subnet == nil {
+		return nil
+	}
+	r.subnet.controller = r.controller
+	return r.subnet
+}
+
+// UpdateIPRangeArgs is an argument struct for IPRange.Update. Only the
+// non-empty addresses are changed. The comment is changed if Comment is not
+// nil, so it can be cleared by pointing at an empty string.
+type UpdateIPRangeArgs struct {
+	StartIP string
+	EndIP   string
+	Comment *string
+}
+
+// Update implements IPRange.
+func (r *ipRange) Update(args UpdateIPRangeArgs) error {
+	startIP, endIP := r.startIP, r.endIP
+	if args.StartIP != "" {
+		startIP = args.StartIP
+	}
+	if args.EndIP != "" {
+		endIP = args.EndIP
+	}
+	if args.StartIP != "" || args.EndIP != "" {
+		existing, err := r.controller.ipRanges()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := validateIPRange(startIP, endIP, r.subnet, existing, r.id); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	params := NewURLParams()
+	params.MaybeAdd("start_ip", args.StartIP)
+	params.MaybeAdd("end_ip", args.EndIP)
+	if args.Comment != nil {
+		params.Values.Add("comment", *args.Comment)
+	}
+	source, err := r.controller.put(r.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	updated, err := readIPRange(r.controller.apiVersion, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updated.controller = r.controller
+	*r = *updated
+	return nil
+}
+
+// Delete implements IPRange.
+func (r *ipRange) Delete() error {
+	err := r.controller.delete(r.resourceURI)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+// validateIPRange checks that the range from start to end lies within the
+// CIDR of the subnet, and that it does not overlap any of the existing
+// ranges other than the one with the skipID (so a range can be updated).
+// The error satisfies errors.IsNotValid.
+func validateIPRange(start, end string, subnet Subnet, existing []*ipRange, skipID int) error {
+	startIP := net.ParseIP(start)
+	if startIP == nil {
+		return errors.NotValidf("StartIP %q", start)
+	}
+	endIP := net.ParseIP(end)
+	if endIP == nil {
+		return errors.NotValidf("EndIP %q", end)
+	}
+	if compareIPs(startIP, endIP) > 0 {
+		return errors.NotValidf("StartIP %s after EndIP %s", start, end)
+	}
+	if subnet != nil {
+		_, ipNet, err := net.ParseCIDR(subnet.CIDR())
+		if err != nil {
+			return errors.NotValidf("subnet CIDR %q", subnet.CIDR())
+		}
+		if !ipNet.Contains(startIP) || !ipNet.Contains(endIP) {
+			return errors.NotValidf("range %s-%s outside subnet %s", start, end, subnet.CIDR())
+		}
+	}
+	for _, other := range existing {
+		if other.id == skipID {
+			continue
+		}
+		otherStart := net.ParseIP(other.startIP)
+		otherEnd := net.ParseIP(other.endIP)
+		if otherStart == nil || otherEnd == nil {
+			continue
+		}
+		if compareIPs(startIP, otherEnd) <= 0 && compareIPs(otherStart, endIP) <= 0 {
+			return errors.NotValidf("range %s-%s overlaps %s range %s-%s",
+				start, end, other.rangeType, other.startIP, other.endIP)
+		}
+	}
+	return nil
+}
+
+// compareIPs orders IPv4 addresses before IPv6 addresses, and addresses of
+// the same family numerically.
+func compareIPs(a, b net.IP) int {
+	a4, b4 := a.To4(), b.To4()
+	switch {
+	case a4 != nil && b4 != nil:
+		return bytes.Compare(a4, b4)
+	case a4 != nil:
+		return -1
+	case b4 != nil:
+		return 1
+	}
+	return bytes.Compare(a.To16(), b.To16())
+}
+
+func readIPRange(controllerVersion version.Number, source interface{}) (*ipRange, error) {
+	readFunc, err := getIPRangeDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip range base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readIPRanges(controllerVersion version.Number, source interface{}) ([]*ipRange, error) {
+	readFunc, err := getIPRangeDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip range base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	return readIPRangeList(valid, readFunc)
+}
+
+func getIPRangeDeserializationFunc(controllerVersion version.Number) (ipRangeDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range ipRangeDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no ip range read func for version %s", controllerVersion)
+	}
+	return ipRangeDeserializationFuncs[deserialisationVersion], nil
+}
+
+// readIPRangeList expects the values of the sourceList to be string maps.
+func readIPRangeList(sourceList []interface{}, readFunc ipRangeDeserializationFunc) ([]*ipRange, error) {
+	result := make([]*ipRange, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for ip range %d, %T", i, value)
+		}
+		ipRange, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "ip range %d", i)
+		}
+		result = append(result, ipRange)
+	}
+	return result, nil
+}
+
+type ipRangeDeserializationFunc func(map[string]interface{}) (*ipRange, error)
+
+var ipRangeDeserializationFuncs = map[version.Number]ipRangeDeserializationFunc{
+	twoDotOh: ipRange_2_0,
+}
+
+func ipRange_2_0(source map[string]interface{}) (*ipRange, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"id":           schema.ForceInt(),
+		"type":         schema.String(),
+		"start_ip":     schema.String(),
+		"end_ip":       schema.String(),
+		"comment":      schema.OneOf(schema.Nil(""), schema.String()),
+		"user":         schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"subnet":       schema.StringMap(schema.Any()),
+	}
+	defaults := schema.Defaults{
+		"comment": "",
+		"user":    nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip range 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	subnet, err := subnet_2_0(valid["subnet"].(map[string]interface{}))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var user string
+	if userMap, ok := valid["user"].(map[string]interface{}); ok {
+		// Only the username is interesting, the rest of the user details
+		// are not exposed elsewhere in the API.
+		if username, ok := userMap["username"]; ok {
+			user = fmt.Sprint(username)
+		}
+	}
+	comment, _ := valid["comment"].(string)
+	result := &ipRange{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		rangeType:   valid["type"].(string),
+		startIP:     valid["start_ip"].(string),
+		endIP:       valid["end_ip"].(string),
+		comment:     comment,
+		user:        user,
+		subnet:      subnet,
+	}
+	return result, nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type ipRangeSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&ipRangeSuite{})
+
+func (*ipRangeSuite) TestReadIPRangesBadSchema(c *gc.C) {
+	_, err := readIPRanges(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `ip range base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readIPRanges(twoDotOh, []map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `ip range 0: ip range 2.0 schema check failed: .*`)
+}
+
+func (*ipRangeSuite) TestReadIPRanges(c *gc.C) {
+	ranges, err := readIPRanges(twoDotOh, parseJSON(c, ipRangesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ranges, gc.HasLen, 2)
+
+	r := ranges[0]
+	c.Check(r.ID(), gc.Equals, 1)
+	c.Check(r.Type(), gc.Equals, IPRangeTypeDynamic)
+	c.Check(r.StartIP(), gc.Equals, "192.168.100.100")
+	c.Check(r.EndIP(), gc.Equals, "192.168.100.199")
+	c.Check(r.Comment(), gc.Equals, "rack 1 pool")
+	c.Check(r.User(), gc.Equals, "admin")
+	c.Check(r.Subnet().CIDR(), gc.Equals, "192.168.100.0/24")
+
+	r = ranges[1]
+	c.Check(r.Type(), gc.Equals, IPRangeTypeReserved)
+	c.Check(r.Comment(), gc.Equals, "")
+	c.Check(r.User(), gc.Equals, "")
+}
+
+func (*ipRangeSuite) TestLowVersion(c *gc.C) {
+	_, err := readIPRanges(version.MustParse("1.9.0"), parseJSON(c, ipRangesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no ip range read func for version 1.9.0`)
+}
+
+func (*ipRangeSuite) TestHighVersion(c *gc.C) {
+	ranges, err := readIPRanges(version.MustParse("2.1.9"), parseJSON(c, ipRangesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ranges, gc.HasLen, 2)
+}
+
+func (*ipRangeSuite) TestValidateIPRange(c *gc.C) {
+	existing, err := readIPRanges(twoDotOh, parseJSON(c, ipRangesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	subnet := existing[0].subnet
+
+	for i, test := range []struct {
+		start   string
+		end     string
+		skipID  int
+		errText string
+	}{{
+		start: "192.168.100.200",
+		end:   "192.168.100.250",
+	}, {
+		start: "192.168.100.100",
+		end:   "192.168.100.120",
+		// Updating the range itself.
+		skipID: 1,
+	}, {
+		start:   "wat",
+		end:     "192.168.100.250",
+		errText: `StartIP "wat" not valid`,
+	}, {
+		start:   "192.168.100.200",
+		end:     "",
+		errText: `EndIP "" not valid`,
+	}, {
+		start:   "192.168.100.250",
+		end:     "192.168.100.200",
+		errText: `StartIP 192.168.100.250 after EndIP 192.168.100.200 not valid`,
+	}, {
+		start:   "192.168.100.200",
+		end:     "192.168.101.10",
+		errText: `range 192.168.100.200-192.168.101.10 outside subnet 192.168.100.0/24 not valid`,
+	}, {
+		start:   "192.168.100.50",
+		end:     "192.168.100.100",
+		errText: `range 192.168.100.50-192.168.100.100 overlaps dynamic range 192.168.100.100-192.168.100.199 not valid`,
+	}, {
+		start:   "192.168.100.5",
+		end:     "192.168.100.6",
+		errText: `range 192.168.100.5-192.168.100.6 overlaps reserved range 192.168.100.2-192.168.100.9 not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := validateIPRange(test.start, test.end, subnet, existing, test.skipID)
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.errText)
+		}
+	}
+}
+
+func (*ipRangeSuite) TestCompareIPs(c *gc.C) {
+	for i, test := range []struct {
+		a, b   string
+		result int
+	}{
+		{"192.168.1.1", "192.168.1.1", 0},
+		{"192.168.1.1", "192.168.1.2", -1},
+		{"10.0.0.1", "9.0.0.1", 1},
+		{"::ffff:10.0.0.1", "10.0.0.1", 0},
+		{"2001:db8::1", "2001:db8::2", -1},
+		// IPv4 addresses come first, even those that are smaller as
+		// IPv6 addresses.
+		{"::1", "10.0.0.1", 1},
+		{"10.0.0.1", "::1", -1},
+		{"255.255.255.255", "::", -1},
+	} {
+		c.Logf("test %d: %s %s", i, test.a, test.b)
+		c.Check(compareIPs(net.ParseIP(test.a), net.ParseIP(test.b)), gc.Equals, test.result)
+	}
+}
+
+func (s *ipRangeSuite) getServerAndIPRange(c *gc.C) (*SimpleTestServer, *ipRange) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, ipRangesResponse)
+	// Changing the start or end of a range reads the ranges again.
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, ipRangesResponse)
+
+	ranges, err := controller.IPRanges()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ranges, gc.HasLen, 2)
+	return server, ranges[0].(*ipRange)
+}
+
+func (s *ipRangeSuite) TestUpdate(c *gc.C) {
+	server, r := s.getServerAndIPRange(c)
+	response := updateJSONMap(c, ipRangeResponse, map[string]interface{}{
+		"end_ip":  "192.168.100.150",
+		"comment": "smaller pool",
+	})
+	server.AddPutResponse(r.resourceURI, http.StatusOK, response)
+	comment := "smaller pool"
+	err := r.Update(UpdateIPRangeArgs{
+		EndIP:   "192.168.100.150",
+		Comment: &comment,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(r.EndIP(), gc.Equals, "192.168.100.150")
+	c.Check(r.Comment(), gc.Equals, "smaller pool")
+
+	request := server.LastRequest()
+	form := request.PostForm
+	c.Check(form.Get("start_ip"), gc.Equals, "")
+	c.Check(form.Get("end_ip"), gc.Equals, "192.168.100.150")
+	c.Check(form.Get("comment"), gc.Equals, "smaller pool")
+}
+
+func (s *ipRangeSuite) TestUpdateClearsComment(c *gc.C) {
+	server, r := s.getServerAndIPRange(c)
+	response := updateJSONMap(c, ipRangeResponse, map[string]interface{}{
+		"comment": "",
+	})
+	server.AddPutResponse(r.resourceURI, http.StatusOK, response)
+	comment := ""
+	err := r.Update(UpdateIPRangeArgs{Comment: &comment})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(r.Comment(), gc.Equals, "")
+
+	form := server.LastRequest().PostForm
+	c.Check(form["comment"], jc.DeepEquals, []string{""})
+	c.Check(form["end_ip"], gc.HasLen, 0)
+}
+
+func (s *ipRangeSuite) TestUpdateLeavesComment(c *gc.C) {
+	server, r := s.getServerAndIPRange(c)
+	server.AddPutResponse(r.resourceURI, http.StatusOK, ipRangeResponse)
+	err := r.Update(UpdateIPRangeArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form["comment"], gc.HasLen, 0)
+}
+
+func (s *ipRangeSuite) TestUpdateOverlap(c *gc.C) {
+	_, r := s.getServerAndIPRange(c)
+	err := r.Update(UpdateIPRangeArgs{StartIP: "192.168.100.5"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *ipRangeSuite) TestUpdateNotFound(c *gc.C) {
+	server, r := s.getServerAndIPRange(c)
+	server.AddPutResponse(r.resourceURI, http.StatusNotFound, "can't find range")
+	comment := "wat"
+	err := r.Update(UpdateIPRangeArgs{Comment: &comment})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, "can't find range")
+}
+
+func (s *ipRangeSuite) TestDelete(c *gc.C) {
+	server, r := s.getServerAndIPRange(c)
+	// Successful delete is 204 - StatusNoContent
+	server.AddDeleteResponse(r.resourceURI, http.StatusNoContent, "")
+	err := r.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *ipRangeSuite) TestDelete404(c *gc.C) {
+	_, r := s.getServerAndIPRange(c)
+	// No path, so 404
+	err := r.Delete()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *ipRangeSuite) TestDeleteForbidden(c *gc.C) {
+	server, r := s.getServerAndIPRange(c)
+	server.AddDeleteResponse(r.resourceURI, http.StatusForbidden, "")
+	err := r.Delete()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+const (
+	ipRangeSubnetJSON = `
+        {
+            "gateway_ip": "192.168.100.1",
+            "name": "192.168.100.0/24",
+            "vlan": {
+                "fabric": "fabric-0",
+                "resource_uri": "/MAAS/api/2.0/vlans/1/",
+                "name": "untagged",
+                "secondary_rack": null,
+                "primary_rack": "4y3h7n",
+                "vid": 0,
+                "dhcp_on": true,
+                "id": 1,
+                "mtu": 1500
+            },
+            "space": "space-0",
+            "id": 1,
+            "resource_uri": "/MAAS/api/2.0/subnets/1/",
+            "dns_servers": [],
+            "cidr": "192.168.100.0/24",
+            "rdns_mode": 2
+        }`
+	ipRangeResponse = `
+    {
+        "id": 1,
+        "type": "dynamic",
+        "start_ip": "192.168.100.100",
+        "end_ip": "192.168.100.199",
+        "comment": "rack 1 pool",
+        "user": {
+            "is_superuser": true,
+            "username": "admin",
+            "email": "admin@example.com",
+            "resource_uri": "/MAAS/api/2.0/users/admin/"
+        },
+        "resource_uri": "/MAAS/api/2.0/ipranges/1/",
+        "subnet": ` + ipRangeSubnetJSON + `
+    }`
+	ipRangesResponse = `
+[` + ipRangeResponse + `,
+    {
+        "id": 2,
+        "type": "reserved",
+        "start_ip": "192.168.100.2",
+        "end_ip": "192.168.100.9",
+        "comment": null,
+        "user": null,
+        "resource_uri": "/MAAS/api/2.0/ipranges/2/",
+        "subnet": ` + ipRangeSubnetJSON + `
+    }
+]
+`
+)
@@ -62,6 +62,32 @@ func (m *machine) updateFrom(other *machine) {
 	m.zone = other.zone
 	m.tags = other.tags
 	m.ownerData = other.ownerData
+	m.bootInterface = other.bootInterface
+	m.interfaceSet = other.interfaceSet
+	m.physicalBlockDevices = other.physicalBlockDevices
+	m.blockDevices = other.blockDevices
+}
+
+// Refresh implements Machine.
+func (m *machine) Refresh() error {
+	source, err := m.controller.get(m.resourceURI)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	machine, err := readMachine(m.controller.apiVersion, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
 }
 
 // SystemID implements Machine.
@@ -215,11 +241,22 @@ func (m *machine) Devices(args DevicesArgs) ([]Device, error) {
 // StartArgs is an argument struct for passing parameters to the Machine.Start
 // method.
 type StartArgs struct {
-	// UserData needs to be Base64 encoded user data for cloud-init.
+	// UserData needs to be Base64 encoded user data for cloud-init. See
+	// CloudInit.UserData to build it.
 	UserData     string
 	DistroSeries string
 	Kernel       string
 	Comment      string
+	// AgentName is passed through to MAAS unchanged.
+	AgentName string
+	// InstallKVM makes the machine a KVM pod host once it is deployed.
+	InstallKVM bool
+	// EnableHWSync keeps the hardware details of the deployed machine in
+	// sync with MAAS.
+	EnableHWSync bool
+	// EphemeralDeploy runs the operating system in memory, without
+	// installing it to disk.
+	EphemeralDeploy bool
 }
 
 // Start implements Machine.
@@ -229,6 +266,10 @@ func (m *machine) Start(args StartArgs) error {
 	params.MaybeAdd("distro_series", args.DistroSeries)
 	params.MaybeAdd("hwe_kernel", args.Kernel)
 	params.MaybeAdd("comment", args.Comment)
+	params.MaybeAdd("agent_name", args.AgentName)
+	params.MaybeAddBool("install_kvm", args.InstallKVM)
+	params.MaybeAddBool("enable_hw_sync", args.EnableHWSync)
+	params.MaybeAddBool("ephemeral_deploy", args.EphemeralDeploy)
 	result, err := m.controller.post(m.resourceURI, "deploy", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -252,6 +293,56 @@ func (m *machine) Start(args StartArgs) error {
 	return nil
 }
 
+// ReleaseArgs is an argument struct for Machine.Release.
+type ReleaseArgs struct {
+	Comment string
+	// Erase the disks of the machine before it is released. SecureErase
+	// uses the secure erase feature of the disks, and QuickErase wipes just
+	// the start and end of each disk; if both are given, MAAS tries a secure
+	// erase first and falls back to a quick erase.
+	Erase       bool
+	SecureErase bool
+	QuickErase  bool
+	// Force the release of a machine even if MAAS would otherwise refuse,
+	// such as one acting as a pod host.
+	Force bool
+}
+
+// Validate checks that the erase options are only used along with Erase.
+func (a *ReleaseArgs) Validate() error {
+	return validateEraseArgs(a.Erase, a.SecureErase, a.QuickErase)
+}
+
+// Release implements Machine.
+func (m *machine) Release(args ReleaseArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	addReleaseParams(params, args.Comment, args.Erase, args.SecureErase, args.QuickErase, args.Force)
+	result, err := m.controller.post(m.resourceURI, "release", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusConflict:
+				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+
+	machine, err := readMachine(m.controller.apiVersion, result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
 // CreateMachineDeviceArgs is an argument structure for Machine.CreateDevice.
 // Only InterfaceName and MACAddress fields are required, the others are only
 // used if set. If Subnet and VLAN are both set, Subnet.VLAN() must match the
@@ -396,6 +487,53 @@ func (m *machine) SetOwnerData(ownerData map[string]string) error {
 	return nil
 }
 
+// Details implements Machine.
+func (m *machine) Details() (MachineDetails, error) {
+	var empty MachineDetails
+	// The details are returned as a BSON document rather than JSON.
+	bytes, err := m.controller._getRaw(m.resourceURI, "details", nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return empty, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return empty, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return empty, NewUnexpectedError(err)
+	}
+	details, err := readMachineDetails(bytes)
+	if err != nil {
+		return empty, errors.Trace(err)
+	}
+	return details, nil
+}
+
+// CommissioningResults implements Machine.
+func (m *machine) CommissioningResults() ([]CommissioningResult, error) {
+	params := NewURLParams()
+	params.Values.Add("system_id", m.systemID)
+	source, err := m.controller.getQuery("commissioning-results", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusForbidden {
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	results, err := readCommissioningResults(m.controller.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []CommissioningResult
+	for _, r := range results {
+		result = append(result, r)
+	}
+	return result, nil
+}
+
 func readMachine(controllerVersion version.Number, source interface{}) (*machine, error) {
 	readFunc, err := getMachineDeserializationFunc(controllerVersion)
 	if err != nil {
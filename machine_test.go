@@ -161,6 +161,85 @@ func (s *machineSuite) TestStart(c *gc.C) {
 	c.Check(form.Get("comment"), gc.Equals, "a comment")
 }
 
+func (s *machineSuite) TestRelease(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name":    "Disk erasing",
+		"status_message": "",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=release", http.StatusOK, response)
+
+	err := machine.Release(ReleaseArgs{
+		Comment:     "leaving tenant",
+		Erase:       true,
+		SecureErase: true,
+		QuickErase:  true,
+		Force:       true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.StatusName(), gc.Equals, "Disk erasing")
+
+	form := server.LastRequest().PostForm
+	c.Assert(form, gc.HasLen, 5)
+	c.Check(form.Get("comment"), gc.Equals, "leaving tenant")
+	c.Check(form.Get("erase"), gc.Equals, "true")
+	c.Check(form.Get("secure_erase"), gc.Equals, "true")
+	c.Check(form.Get("quick_erase"), gc.Equals, "true")
+	c.Check(form.Get("force"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestReleaseValidate(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.ResetRequests()
+	err := machine.Release(ReleaseArgs{QuickErase: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, "SecureErase or QuickErase without Erase not valid")
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestReleaseConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=release", http.StatusConflict, "machine is Ready")
+	err := machine.Release(ReleaseArgs{})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(err.Error(), gc.Equals, "machine is Ready")
+}
+
+func (s *machineSuite) TestReleaseForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=release", http.StatusForbidden, "not yours")
+	err := machine.Release(ReleaseArgs{})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "not yours")
+}
+
+func (s *machineSuite) TestReleaseNotFound(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	// No path, so 404
+	err := machine.Release(ReleaseArgs{})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestStartOptions(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+
+	err := machine.Start(StartArgs{
+		AgentName:       "agent 42",
+		InstallKVM:      true,
+		EnableHWSync:    true,
+		EphemeralDeploy: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	c.Assert(form, gc.HasLen, 4)
+	c.Check(form.Get("agent_name"), gc.Equals, "agent 42")
+	c.Check(form.Get("install_kvm"), gc.Equals, "true")
+	c.Check(form.Get("enable_hw_sync"), gc.Equals, "true")
+	c.Check(form.Get("ephemeral_deploy"), gc.Equals, "true")
+}
+
 func (s *machineSuite) TestStartMachineNotFound(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusNotFound, "can't find machine")
@@ -389,6 +468,26 @@ func (s *machineSuite) TestCreateDeviceTriesToDeleteDeviceOnError(c *gc.C) {
 	c.Assert(request.RequestURI, gc.Equals, "/MAAS/api/2.0/devices/4y3haf/")
 }
 
+func (s *machineSuite) TestRefresh(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name":    "Deployed",
+		"status_message": "",
+	})
+	server.AddGetResponse(machine.resourceURI, http.StatusOK, response)
+	err := machine.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+	c.Check(machine.StatusMessage(), gc.Equals, "")
+}
+
+func (s *machineSuite) TestRefreshNotFound(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	// No path, so 404
+	err := machine.Refresh()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
 func (s *machineSuite) TestOwnerDataCopies(c *gc.C) {
 	machine := machine{ownerData: make(map[string]string)}
 	ownerData := machine.OwnerData()
@@ -412,6 +511,48 @@ func (s *machineSuite) TestSetOwnerData(c *gc.C) {
 	c.Check(form["empty"], gc.DeepEquals, []string{""})
 }
 
+func (s *machineSuite) TestDetails(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := machineDetailsBSON(c, lshwXML, lldpNeighborsXML)
+	server.AddGetResponse(machine.resourceURI+"?op=details", http.StatusOK, string(response))
+	details, err := machine.Details()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(details.LSHW), gc.Equals, lshwXML)
+	c.Check(details.CPUModel, gc.Equals, "Intel(R) Xeon(R) CPU E5-2620 v3 @ 2.40GHz")
+	c.Assert(details.NICs, gc.HasLen, 2)
+	c.Check(details.NICs[0].Neighbors[0].SystemName, gc.Equals, "tor-switch-1")
+}
+
+func (s *machineSuite) TestDetailsNotFound(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	_, err := machine.Details()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestDetailsForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=details", http.StatusForbidden, "machine not yours")
+	_, err := machine.Details()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "machine not yours")
+}
+
+func (s *machineSuite) TestCommissioningResults(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/commissioning-results/?system_id=4y3ha3", http.StatusOK, commissioningResultsResponse)
+	results, err := machine.CommissioningResults()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 2)
+	c.Check(results[0].Name(), gc.Equals, "00-maas-01-cpuinfo.out")
+}
+
+func (s *machineSuite) TestCommissioningResultsForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/commissioning-results/?system_id=4y3ha3", http.StatusForbidden, "nope")
+	_, err := machine.CommissioningResults()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
 func machineWithOwnerData(data string) string {
 	return fmt.Sprintf(machineOwnerDataTemplate, data)
 }
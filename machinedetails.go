@@ -0,0 +1,220 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MachineDetails holds the hardware information that MAAS gathered about a
+// machine during commissioning. The raw lshw and LLDP XML documents are
+// always returned; the remaining fields are a summary parsed from them.
+type MachineDetails struct {
+	// LSHW is the raw XML output of lshw.
+	LSHW []byte
+	// LLDP is the raw XML output of lldpctl.
+	LLDP []byte
+
+	// CPUModel is the product name of the first processor found.
+	CPUModel string
+	DIMMs    []DIMM
+	NICs     []NIC
+	Disks    []Disk
+}
+
+// DIMM describes a single populated memory bank.
+type DIMM struct {
+	Slot        string
+	Description string
+	Vendor      string
+	Product     string
+	Serial      string
+	// Size is the size of the module in bytes.
+	Size uint64
+}
+
+// NIC describes a network card, and the switch ports that were seen
+// as LLDP neighbours on it.
+type NIC struct {
+	Name       string
+	MACAddress string
+	Vendor     string
+	Product    string
+	Neighbors  []LLDPNeighbor
+}
+
+// LLDPNeighbor is a switch port advertised to a NIC over LLDP.
+type LLDPNeighbor struct {
+	ChassisID       string
+	SystemName      string
+	PortID          string
+	PortDescription string
+}
+
+// Disk describes a physical disk.
+type Disk struct {
+	Name    string
+	Vendor  string
+	Product string
+	Serial  string
+	// Size is the size of the disk in bytes.
+	Size uint64
+}
+
+// lshwNode is a node in the lshw XML tree. Only the fields that are
+// summarised are extracted.
+type lshwNode struct {
+	ID          string     `xml:"id,attr"`
+	Class       string     `xml:"class,attr"`
+	Description string     `xml:"description"`
+	Product     string     `xml:"product"`
+	Vendor      string     `xml:"vendor"`
+	Serial      string     `xml:"serial"`
+	Slot        string     `xml:"slot"`
+	LogicalName []string   `xml:"logicalname"`
+	Size        string     `xml:"size"`
+	Children    []lshwNode `xml:"node"`
+}
+
+type lldpDocument struct {
+	Interfaces []lldpInterface `xml:"interface"`
+}
+
+type lldpInterface struct {
+	Name    string `xml:"name,attr"`
+	Chassis struct {
+		ID   string `xml:"id"`
+		Name string `xml:"name"`
+	} `xml:"chassis"`
+	Port struct {
+		ID          string `xml:"id"`
+		Description string `xml:"descr"`
+	} `xml:"port"`
+}
+
+func readMachineDetails(source []byte) (MachineDetails, error) {
+	var empty MachineDetails
+	var raw map[string]interface{}
+	if err := bson.Unmarshal(source, &raw); err != nil {
+		return empty, WrapWithDeserializationError(err, "machine details bson unmarshal failed")
+	}
+	details := MachineDetails{
+		LSHW: detailsBytes(raw["lshw"]),
+		LLDP: detailsBytes(raw["lldp"]),
+	}
+	if err := details.parseLSHW(); err != nil {
+		return empty, errors.Trace(err)
+	}
+	if err := details.parseLLDP(); err != nil {
+		return empty, errors.Trace(err)
+	}
+	return details, nil
+}
+
+// detailsBytes handles the values in the details document being stored
+// either as binary or as strings.
+func detailsBytes(value interface{}) []byte {
+	switch value := value.(type) {
+	case []byte:
+		return value
+	case string:
+		return []byte(value)
+	}
+	return nil
+}
+
+func (d *MachineDetails) parseLSHW() error {
+	if len(d.LSHW) == 0 {
+		return nil
+	}
+	// The lshw output is either a single top level node, or a list of
+	// them. A list element has no class, so is just walked through.
+	var root lshwNode
+	if err := xml.Unmarshal(d.LSHW, &root); err != nil {
+		return WrapWithDeserializationError(err, "lshw details parse failed")
+	}
+	d.addLSHWNode(root)
+	return nil
+}
+
+func (d *MachineDetails) addLSHWNode(node lshwNode) {
+	switch {
+	case node.Class == "processor" && d.CPUModel == "" && node.Product != "":
+		d.CPUModel = node.Product
+	case node.Class == "memory" && strings.HasPrefix(node.ID, "bank"):
+		// Empty banks are reported with a description but no size.
+		if size := parseLSHWSize(node.Size); size > 0 {
+			d.DIMMs = append(d.DIMMs, DIMM{
+				Slot:        node.Slot,
+				Description: node.Description,
+				Vendor:      node.Vendor,
+				Product:     node.Product,
+				Serial:      node.Serial,
+				Size:        size,
+			})
+		}
+	case node.Class == "network":
+		d.NICs = append(d.NICs, NIC{
+			Name:       firstString(node.LogicalName),
+			MACAddress: node.Serial,
+			Vendor:     node.Vendor,
+			Product:    node.Product,
+		})
+	case node.Class == "disk" && strings.HasPrefix(node.ID, "disk"):
+		d.Disks = append(d.Disks, Disk{
+			Name:    strings.TrimPrefix(firstString(node.LogicalName), "/dev/"),
+			Vendor:  node.Vendor,
+			Product: node.Product,
+			Serial:  node.Serial,
+			Size:    parseLSHWSize(node.Size),
+		})
+	}
+	for _, child := range node.Children {
+		d.addLSHWNode(child)
+	}
+}
+
+func (d *MachineDetails) parseLLDP() error {
+	if len(d.LLDP) == 0 {
+		return nil
+	}
+	var doc lldpDocument
+	if err := xml.Unmarshal(d.LLDP, &doc); err != nil {
+		return WrapWithDeserializationError(err, "lldp details parse failed")
+	}
+	for _, iface := range doc.Interfaces {
+		neighbor := LLDPNeighbor{
+			ChassisID:       strings.TrimSpace(iface.Chassis.ID),
+			SystemName:      strings.TrimSpace(iface.Chassis.Name),
+			PortID:          strings.TrimSpace(iface.Port.ID),
+			PortDescription: strings.TrimSpace(iface.Port.Description),
+		}
+		for i := range d.NICs {
+			if d.NICs[i].Name == iface.Name {
+				d.NICs[i].Neighbors = append(d.NICs[i].Neighbors, neighbor)
+			}
+		}
+	}
+	return nil
+}
+
+func parseLSHWSize(value string) uint64 {
+	size, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+func firstString(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
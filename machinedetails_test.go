@@ -0,0 +1,156 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type machineDetailsSuite struct{}
+
+var _ = gc.Suite(&machineDetailsSuite{})
+
+func machineDetailsBSON(c *gc.C, lshw, lldp string) []byte {
+	bytes, err := bson.Marshal(map[string]interface{}{
+		"lshw": []byte(lshw),
+		"lldp": []byte(lldp),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return bytes
+}
+
+func (*machineDetailsSuite) TestReadMachineDetailsBadBSON(c *gc.C) {
+	_, err := readMachineDetails([]byte("wat?"))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (*machineDetailsSuite) TestReadMachineDetailsBadXML(c *gc.C) {
+	_, err := readMachineDetails(machineDetailsBSON(c, "<list><node>", ""))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, "lshw details parse failed: .*")
+}
+
+func (*machineDetailsSuite) TestReadMachineDetailsEmpty(c *gc.C) {
+	details, err := readMachineDetails(machineDetailsBSON(c, "", ""))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(details.CPUModel, gc.Equals, "")
+	c.Check(details.NICs, gc.HasLen, 0)
+}
+
+func (*machineDetailsSuite) TestReadMachineDetails(c *gc.C) {
+	details, err := readMachineDetails(machineDetailsBSON(c, lshwXML, lldpNeighborsXML))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(details.LSHW), gc.Equals, lshwXML)
+	c.Check(string(details.LLDP), gc.Equals, lldpNeighborsXML)
+	c.Check(details.CPUModel, gc.Equals, "Intel(R) Xeon(R) CPU E5-2620 v3 @ 2.40GHz")
+	c.Check(details.DIMMs, jc.DeepEquals, []DIMM{{
+		Slot:        "DIMM_A1",
+		Description: "DIMM DDR4 Synchronous 2133 MHz (0.5 ns)",
+		Vendor:      "Samsung",
+		Product:     "M393A2G40DB0-CPB",
+		Serial:      "3A1B2C3D",
+		Size:        17179869184,
+	}})
+	c.Check(details.NICs, jc.DeepEquals, []NIC{{
+		Name:       "eth0",
+		MACAddress: "52:54:00:55:b6:80",
+		Vendor:     "Intel Corporation",
+		Product:    "I350 Gigabit Network Connection",
+		Neighbors: []LLDPNeighbor{{
+			ChassisID:       "00:1c:73:aa:bb:cc",
+			SystemName:      "tor-switch-1",
+			PortID:          "Ethernet12",
+			PortDescription: "server rack 3 u12",
+		}},
+	}, {
+		Name:       "eth1",
+		MACAddress: "52:54:00:55:b6:81",
+		Vendor:     "Intel Corporation",
+		Product:    "I350 Gigabit Network Connection",
+	}})
+	c.Check(details.Disks, jc.DeepEquals, []Disk{{
+		Name:    "sda",
+		Vendor:  "ATA",
+		Product: "QEMU HARDDISK",
+		Serial:  "QM00001",
+		Size:    8589934592,
+	}})
+}
+
+const (
+	lshwXML = `<?xml version="1.0" standalone="yes" ?>
+<list>
+<node id="wily" claimed="true" class="system" handle="DMI:0100">
+  <description>Computer</description>
+  <product>Standard PC</product>
+  <node id="core" claimed="true" class="bus" handle="DMI:0800">
+    <description>Motherboard</description>
+    <node id="cpu:0" claimed="true" class="processor" handle="DMI:0400">
+      <description>CPU</description>
+      <product>Intel(R) Xeon(R) CPU E5-2620 v3 @ 2.40GHz</product>
+      <vendor>Intel Corp.</vendor>
+    </node>
+    <node id="memory" claimed="true" class="memory" handle="DMI:1000">
+      <description>System Memory</description>
+      <node id="bank:0" claimed="true" class="memory" handle="DMI:1100">
+        <description>DIMM DDR4 Synchronous 2133 MHz (0.5 ns)</description>
+        <product>M393A2G40DB0-CPB</product>
+        <vendor>Samsung</vendor>
+        <serial>3A1B2C3D</serial>
+        <slot>DIMM_A1</slot>
+        <size units="bytes">17179869184</size>
+      </node>
+      <node id="bank:1" claimed="true" class="memory" handle="DMI:1101">
+        <description>DIMM [empty]</description>
+        <slot>DIMM_A2</slot>
+      </node>
+    </node>
+    <node id="pci" claimed="true" class="bridge" handle="PCIBUS:0000:00">
+      <node id="network:0" claimed="true" class="network" handle="PCI:0000:00:03.0">
+        <description>Ethernet interface</description>
+        <product>I350 Gigabit Network Connection</product>
+        <vendor>Intel Corporation</vendor>
+        <logicalname>eth0</logicalname>
+        <serial>52:54:00:55:b6:80</serial>
+      </node>
+      <node id="network:1" claimed="true" class="network" handle="PCI:0000:00:04.0">
+        <description>Ethernet interface</description>
+        <product>I350 Gigabit Network Connection</product>
+        <vendor>Intel Corporation</vendor>
+        <logicalname>eth1</logicalname>
+        <serial>52:54:00:55:b6:81</serial>
+      </node>
+      <node id="storage" claimed="true" class="storage" handle="PCI:0000:00:01.1">
+        <node id="disk" claimed="true" class="disk" handle="SCSI:00:00:00:00">
+          <description>ATA Disk</description>
+          <product>QEMU HARDDISK</product>
+          <vendor>ATA</vendor>
+          <logicalname>/dev/sda</logicalname>
+          <serial>QM00001</serial>
+          <size units="bytes">8589934592</size>
+        </node>
+      </node>
+    </node>
+  </node>
+</node>
+</list>
+`
+
+	lldpNeighborsXML = `<?xml version="1.0" encoding="UTF-8"?>
+<lldp label="LLDP neighbors">
+ <interface label="Interface" name="eth0" via="LLDP" rid="1" age="0 day, 00:03:41">
+  <chassis label="Chassis">
+   <id label="ChassisID" type="mac">00:1c:73:aa:bb:cc</id>
+   <name label="SysName">tor-switch-1</name>
+  </chassis>
+  <port label="Port">
+   <id label="PortID" type="ifname">Ethernet12</id>
+   <descr label="PortDescr">server rack 3 u12</descr>
+  </port>
+ </interface>
+</lldp>
+`
+)
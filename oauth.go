@@ -75,6 +75,7 @@ func (signer plainTextOAuthSigner) OAuthSign(request *http.Request) error {
 		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, url.QueryEscape(value)))
 	}
 	strHeader := "OAuth " + strings.Join(authHeader, ", ")
-	request.Header.Add("Authorization", strHeader)
+	// Set rather than add the header, as a retried request is signed again.
+	request.Header.Set("Authorization", strHeader)
 	return nil
 }
@@ -0,0 +1,251 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type packageRepository struct {
+	controller *controller
+
+	resourceURI string
+
+	id            int
+	name          string
+	url           string
+	distributions []string
+	components    []string
+	arches        []string
+	key           string
+	enabled       bool
+}
+
+// ID implements PackageRepository.
+func (p *packageRepository) ID() int {
+	return p.id
+}
+
+// Name implements PackageRepository.
+func (p *packageRepository) Name() string {
+	return p.name
+}
+
+// URL implements PackageRepository.
+func (p *packageRepository) URL() string {
+	return p.url
+}
+
+// Distributions implements PackageRepository.
+func (p *packageRepository) Distributions() []string {
+	return p.distributions
+}
+
+// Components implements PackageRepository.
+func (p *packageRepository) Components() []string {
+	return p.components
+}
+
+// Arches implements PackageRepository.
+func (p *packageRepository) Arches() []string {
+	return p.arches
+}
+
+// Key implements PackageRepository.
+func (p *packageRepository) Key() string {
+	return p.key
+}
+
+// Enabled implements PackageRepository.
+func (p *packageRepository) Enabled() bool {
+	return p.enabled
+}
+
+// UpdatePackageRepositoryArgs is an argument struct for
+// PackageRepository.Update. Only the non-empty values are changed.
+type UpdatePackageRepositoryArgs struct {
+	Name          string
+	URL           string
+	Distributions []string
+	Components    []string
+	Arches        []string
+	Key           string
+}
+
+// Update implements PackageRepository.
+func (p *packageRepository) Update(args UpdatePackageRepositoryArgs) error {
+	params := NewURLParams()
+	params.MaybeAdd("name", args.Name)
+	params.MaybeAdd("url", args.URL)
+	params.MaybeAdd("distributions", strings.Join(args.Distributions, ","))
+	params.MaybeAdd("components", strings.Join(args.Components, ","))
+	params.MaybeAdd("arches", strings.Join(args.Arches, ","))
+	params.MaybeAdd("key", args.Key)
+	if len(params.Values) == 0 {
+		return nil
+	}
+	return p.update(params)
+}
+
+// SetEnabled implements PackageRepository.
+func (p *packageRepository) SetEnabled(enabled bool) error {
+	params := NewURLParams()
+	params.Values.Add("enabled", fmt.Sprint(enabled))
+	return p.update(params)
+}
+
+func (p *packageRepository) update(params *URLParams) error {
+	source, err := p.controller.put(p.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	updated, err := readPackageRepository(p.controller.apiVersion, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updated.controller = p.controller
+	*p = *updated
+	return nil
+}
+
+// Delete implements PackageRepository.
+func (p *packageRepository) Delete() error {
+	err := p.controller.delete(p.resourceURI)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+func readPackageRepository(controllerVersion version.Number, source interface{}) (*packageRepository, error) {
+	readFunc, err := getPackageRepositoryDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "package repository base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readPackageRepositories(controllerVersion version.Number, source interface{}) ([]*packageRepository, error) {
+	readFunc, err := getPackageRepositoryDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "package repository base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	return readPackageRepositoryList(valid, readFunc)
+}
+
+func getPackageRepositoryDeserializationFunc(controllerVersion version.Number) (packageRepositoryDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range packageRepositoryDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no package repository read func for version %s", controllerVersion)
+	}
+	return packageRepositoryDeserializationFuncs[deserialisationVersion], nil
+}
+
+// readPackageRepositoryList expects the values of the sourceList to be string maps.
+func readPackageRepositoryList(sourceList []interface{}, readFunc packageRepositoryDeserializationFunc) ([]*packageRepository, error) {
+	result := make([]*packageRepository, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for package repository %d, %T", i, value)
+		}
+		repository, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "package repository %d", i)
+		}
+		result = append(result, repository)
+	}
+	return result, nil
+}
+
+type packageRepositoryDeserializationFunc func(map[string]interface{}) (*packageRepository, error)
+
+var packageRepositoryDeserializationFuncs = map[version.Number]packageRepositoryDeserializationFunc{
+	twoDotOh: packageRepository_2_0,
+}
+
+func packageRepository_2_0(source map[string]interface{}) (*packageRepository, error) {
+	fields := schema.Fields{
+		"resource_uri":  schema.String(),
+		"id":            schema.ForceInt(),
+		"name":          schema.String(),
+		"url":           schema.String(),
+		"distributions": schema.List(schema.String()),
+		"components":    schema.List(schema.String()),
+		"arches":        schema.List(schema.String()),
+		"key":           schema.OneOf(schema.Nil(""), schema.String()),
+		"enabled":       schema.Bool(),
+	}
+	defaults := schema.Defaults{
+		"distributions": []interface{}{},
+		"components":    []interface{}{},
+		"arches":        []interface{}{},
+		"key":           "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "package repository 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	key, _ := valid["key"].(string)
+	result := &packageRepository{
+		resourceURI:   valid["resource_uri"].(string),
+		id:            valid["id"].(int),
+		name:          valid["name"].(string),
+		url:           valid["url"].(string),
+		distributions: convertToStringSlice(valid["distributions"]),
+		components:    convertToStringSlice(valid["components"]),
+		arches:        convertToStringSlice(valid["arches"]),
+		key:           key,
+		enabled:       valid["enabled"].(bool),
+	}
+	return result, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type packageRepositorySuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&packageRepositorySuite{})
+
+func (*packageRepositorySuite) TestReadPackageRepositoriesBadSchema(c *gc.C) {
+	_, err := readPackageRepositories(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `package repository base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readPackageRepositories(twoDotOh, []map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `package repository 0: package repository 2.0 schema check failed: .*`)
+}
+
+func (*packageRepositorySuite) TestReadPackageRepositories(c *gc.C) {
+	repositories, err := readPackageRepositories(twoDotOh, parseJSON(c, packageRepositoriesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(repositories, gc.HasLen, 2)
+
+	repository := repositories[0]
+	c.Check(repository.ID(), gc.Equals, 1)
+	c.Check(repository.Name(), gc.Equals, "main_archive")
+	c.Check(repository.URL(), gc.Equals, "http://archive.ubuntu.com/ubuntu")
+	c.Check(repository.Distributions(), gc.HasLen, 0)
+	c.Check(repository.Components(), gc.HasLen, 0)
+	c.Check(repository.Arches(), jc.DeepEquals, []string{"amd64", "i386"})
+	c.Check(repository.Key(), gc.Equals, "")
+	c.Check(repository.Enabled(), jc.IsTrue)
+
+	repository = repositories[1]
+	c.Check(repository.Distributions(), jc.DeepEquals, []string{"xenial"})
+	c.Check(repository.Components(), jc.DeepEquals, []string{"main", "universe"})
+	c.Check(repository.Key(), gc.Equals, "-----BEGIN PGP PUBLIC KEY BLOCK-----")
+	c.Check(repository.Enabled(), jc.IsFalse)
+}
+
+func (*packageRepositorySuite) TestLowVersion(c *gc.C) {
+	_, err := readPackageRepositories(version.MustParse("1.9.0"), parseJSON(c, packageRepositoriesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no package repository read func for version 1.9.0`)
+}
+
+func (*packageRepositorySuite) TestHighVersion(c *gc.C) {
+	repositories, err := readPackageRepositories(version.MustParse("2.1.9"), parseJSON(c, packageRepositoriesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(repositories, gc.HasLen, 2)
+}
+
+func (s *packageRepositorySuite) getServerAndRepository(c *gc.C) (*SimpleTestServer, *packageRepository) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/package-repositories/", http.StatusOK, packageRepositoriesResponse)
+
+	repositories, err := controller.PackageRepositories()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(repositories, gc.HasLen, 2)
+	return server, repositories[0].(*packageRepository)
+}
+
+func (s *packageRepositorySuite) TestUpdate(c *gc.C) {
+	server, repository := s.getServerAndRepository(c)
+	response := updateJSONMap(c, packageRepositoryResponse, map[string]interface{}{
+		"url":    "http://mirror.internal/ubuntu",
+		"arches": []string{"amd64"},
+	})
+	server.AddPutResponse(repository.resourceURI, http.StatusOK, response)
+	err := repository.Update(UpdatePackageRepositoryArgs{
+		URL:    "http://mirror.internal/ubuntu",
+		Arches: []string{"amd64"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(repository.URL(), gc.Equals, "http://mirror.internal/ubuntu")
+	c.Check(repository.Arches(), jc.DeepEquals, []string{"amd64"})
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 2)
+	c.Check(form.Get("url"), gc.Equals, "http://mirror.internal/ubuntu")
+	c.Check(form.Get("arches"), gc.Equals, "amd64")
+}
+
+func (s *packageRepositorySuite) TestUpdateBadRequest(c *gc.C) {
+	server, repository := s.getServerAndRepository(c)
+	server.AddPutResponse(repository.resourceURI, http.StatusBadRequest, "bad url")
+	err := repository.Update(UpdatePackageRepositoryArgs{URL: "wat"})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "bad url")
+}
+
+func (s *packageRepositorySuite) TestSetEnabled(c *gc.C) {
+	server, repository := s.getServerAndRepository(c)
+	response := updateJSONMap(c, packageRepositoryResponse, map[string]interface{}{
+		"enabled": false,
+	})
+	server.AddPutResponse(repository.resourceURI, http.StatusOK, response)
+	err := repository.SetEnabled(false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(repository.Enabled(), jc.IsFalse)
+	c.Check(server.LastRequest().PostForm.Get("enabled"), gc.Equals, "false")
+}
+
+func (s *packageRepositorySuite) TestDelete(c *gc.C) {
+	server, repository := s.getServerAndRepository(c)
+	// Successful delete is 204 - StatusNoContent
+	server.AddDeleteResponse(repository.resourceURI, http.StatusNoContent, "")
+	err := repository.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *packageRepositorySuite) TestDelete404(c *gc.C) {
+	_, repository := s.getServerAndRepository(c)
+	// No path, so 404
+	err := repository.Delete()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+const (
+	packageRepositoryResponse = `
+    {
+        "id": 1,
+        "name": "main_archive",
+        "url": "http://archive.ubuntu.com/ubuntu",
+        "distributions": [],
+        "disabled_pockets": [],
+        "components": [],
+        "arches": ["amd64", "i386"],
+        "key": "",
+        "enabled": true,
+        "resource_uri": "/MAAS/api/2.0/package-repositories/1/"
+    }`
+	packageRepositoriesResponse = `
+[` + packageRepositoryResponse + `,
+    {
+        "id": 2,
+        "name": "internal mirror",
+        "url": "http://mirror.internal/ubuntu",
+        "distributions": ["xenial"],
+        "disabled_pockets": [],
+        "components": ["main", "universe"],
+        "arches": ["amd64"],
+        "key": "-----BEGIN PGP PUBLIC KEY BLOCK-----",
+        "enabled": false,
+        "resource_uri": "/MAAS/api/2.0/package-repositories/2/"
+    }
+]
+`
+)
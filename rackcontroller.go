@@ -0,0 +1,227 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type rackController struct {
+	controller *controller
+
+	resourceURI string
+
+	systemID    string
+	hostname    string
+	fqdn        string
+	ipAddresses []string
+	version     string
+
+	interfaceSet []*interface_
+	services     []*serviceStatus
+	// vlans are the VLANs that this rack is the primary or secondary
+	// rack for. They are filled in by the controller.
+	vlans []*vlan
+}
+
+// SystemID implements RackController.
+func (r *rackController) SystemID() string {
+	return r.systemID
+}
+
+// Hostname implements RackController.
+func (r *rackController) Hostname() string {
+	return r.hostname
+}
+
+// FQDN implements RackController.
+func (r *rackController) FQDN() string {
+	return r.fqdn
+}
+
+// IPAddresses implements RackController.
+func (r *rackController) IPAddresses() []string {
+	return r.ipAddresses
+}
+
+// Version implements RackController.
+func (r *rackController) Version() string {
+	return r.version
+}
+
+// InterfaceSet implements RackController.
+func (r *rackController) InterfaceSet() []Interface {
+	result := make([]Interface, len(r.interfaceSet))
+	for i, v := range r.interfaceSet {
+		v.controller = r.controller
+		result[i] = v
+	}
+	return result
+}
+
+// Services implements RackController.
+func (r *rackController) Services() []ServiceStatus {
+	result := make([]ServiceStatus, len(r.services))
+	for i, v := range r.services {
+		result[i] = v
+	}
+	return result
+}
+
+// Service implements RackController.
+func (r *rackController) Service(name string) ServiceStatus {
+	for _, service := range r.services {
+		if service.Name() == name {
+			return service
+		}
+	}
+	return nil
+}
+
+// VLANs implements RackController.
+func (r *rackController) VLANs() []VLAN {
+	result := make([]VLAN, len(r.vlans))
+	for i, v := range r.vlans {
+		result[i] = v
+	}
+	return result
+}
+
+// ImportBootImages implements RackController.
+func (r *rackController) ImportBootImages() error {
+	// The response is a human readable string, not JSON.
+	_, err := r.controller._postRaw(r.resourceURI, "import_boot_images", nil, nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+// ListBootImages implements RackController.
+func (r *rackController) ListBootImages() (BootImages, error) {
+	source, err := r.controller.getOp(r.resourceURI, "list_boot_images")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	images, err := readBootImages(r.controller.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return images, nil
+}
+
+func readRackControllers(controllerVersion version.Number, source interface{}) ([]*rackController, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack controller base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range rackControllerDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no rack controller read func for version %s", controllerVersion)
+	}
+	readFunc := rackControllerDeserializationFuncs[deserialisationVersion]
+	return readRackControllerList(valid, readFunc)
+}
+
+// readRackControllerList expects the values of the sourceList to be string maps.
+func readRackControllerList(sourceList []interface{}, readFunc rackControllerDeserializationFunc) ([]*rackController, error) {
+	result := make([]*rackController, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for rack controller %d, %T", i, value)
+		}
+		rack, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "rack controller %d", i)
+		}
+		result = append(result, rack)
+	}
+	return result, nil
+}
+
+type rackControllerDeserializationFunc func(map[string]interface{}) (*rackController, error)
+
+var rackControllerDeserializationFuncs = map[version.Number]rackControllerDeserializationFunc{
+	twoDotOh: rackController_2_0,
+}
+
+func rackController_2_0(source map[string]interface{}) (*rackController, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+
+		"system_id":    schema.String(),
+		"hostname":     schema.String(),
+		"fqdn":         schema.String(),
+		"ip_addresses": schema.List(schema.String()),
+		// Older controllers do not report their version.
+		"version": schema.OneOf(schema.Nil(""), schema.String()),
+
+		"interface_set": schema.List(schema.StringMap(schema.Any())),
+		"service_set":   schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"version":     "",
+		"service_set": []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack controller 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	interfaceSet, err := readInterfaceList(valid["interface_set"].([]interface{}), interface_2_0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	services, err := readServiceStatusList(valid["service_set"].([]interface{}), serviceStatus_2_0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	maasVersion, _ := valid["version"].(string)
+	result := &rackController{
+		resourceURI: valid["resource_uri"].(string),
+
+		systemID:    valid["system_id"].(string),
+		hostname:    valid["hostname"].(string),
+		fqdn:        valid["fqdn"].(string),
+		ipAddresses: convertToStringSlice(valid["ip_addresses"]),
+		version:     maasVersion,
+
+		interfaceSet: interfaceSet,
+		services:     services,
+	}
+	return result, nil
+}
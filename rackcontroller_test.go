@@ -0,0 +1,180 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type rackControllerSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&rackControllerSuite{})
+
+func (*rackControllerSuite) TestReadRackControllersBadSchema(c *gc.C) {
+	_, err := readRackControllers(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `rack controller base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readRackControllers(twoDotOh, []map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `rack controller 0: rack controller 2.0 schema check failed: .*`)
+}
+
+func (*rackControllerSuite) TestReadRackControllers(c *gc.C) {
+	racks, err := readRackControllers(twoDotOh, parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+
+	rack := racks[0]
+	c.Check(rack.SystemID(), gc.Equals, "4y3h7n")
+	c.Check(rack.Hostname(), gc.Equals, "karura")
+	c.Check(rack.FQDN(), gc.Equals, "karura.maas")
+	c.Check(rack.IPAddresses(), jc.DeepEquals, []string{"192.168.100.2"})
+	c.Check(rack.Version(), gc.Equals, "2.1.0")
+	c.Check(rack.InterfaceSet(), gc.HasLen, 1)
+
+	services := rack.Services()
+	c.Assert(services, gc.HasLen, 3)
+	c.Check(services[0].Name(), gc.Equals, ServiceRackd)
+	c.Check(services[0].Status(), gc.Equals, ServiceStatusRunning)
+	c.Check(services[0].StatusInfo(), gc.Equals, "")
+	c.Check(services[1].Name(), gc.Equals, ServiceDHCPD)
+	c.Check(services[1].Status(), gc.Equals, ServiceStatusDead)
+	c.Check(services[1].StatusInfo(), gc.Equals, "dhcpd is not running")
+}
+
+func (*rackControllerSuite) TestReadRackControllersNulls(c *gc.C) {
+	json := parseJSON(c, rackControllersResponse)
+	rack := json.([]interface{})[0].(map[string]interface{})
+	rack["version"] = nil
+	delete(rack, "service_set")
+
+	racks, err := readRackControllers(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(racks[0].Version(), gc.Equals, "")
+	c.Check(racks[0].Services(), gc.HasLen, 0)
+}
+
+func (*rackControllerSuite) TestService(c *gc.C) {
+	racks, err := readRackControllers(twoDotOh, parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	rack := racks[0]
+
+	service := rack.Service(ServiceTFTP)
+	c.Assert(service, gc.NotNil)
+	c.Check(service.Status(), gc.Equals, ServiceStatusRunning)
+	c.Check(rack.Service(ServiceProxy), gc.IsNil)
+}
+
+func (*rackControllerSuite) TestLowVersion(c *gc.C) {
+	_, err := readRackControllers(version.MustParse("1.9.0"), parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no rack controller read func for version 1.9.0`)
+}
+
+func (*rackControllerSuite) TestHighVersion(c *gc.C) {
+	racks, err := readRackControllers(version.MustParse("2.1.9"), parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+}
+
+func (s *rackControllerSuite) getServerAndRack(c *gc.C) (*SimpleTestServer, *rackController) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, rackControllersResponse)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, fabricResponse)
+
+	racks, err := controller.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+	return server, racks[0].(*rackController)
+}
+
+func (s *rackControllerSuite) TestVLANs(c *gc.C) {
+	_, rack := s.getServerAndRack(c)
+	vlans := rack.VLANs()
+	c.Assert(vlans, gc.HasLen, 1)
+	c.Check(vlans[0].ID(), gc.Equals, 1)
+	c.Check(vlans[0].PrimaryRack(), gc.Equals, "4y3h7n")
+}
+
+func (s *rackControllerSuite) TestImportBootImages(c *gc.C) {
+	server, rack := s.getServerAndRack(c)
+	server.AddPostResponse(rack.resourceURI+"?op=import_boot_images", http.StatusOK, "Import of boot images started on karura")
+	err := rack.ImportBootImages()
+	c.Assert(err, jc.ErrorIsNil)
+	request := server.LastRequest()
+	c.Assert(request.URL.Query().Get("op"), gc.Equals, "import_boot_images")
+}
+
+func (s *rackControllerSuite) TestImportBootImagesNotFound(c *gc.C) {
+	server, rack := s.getServerAndRack(c)
+	server.AddPostResponse(rack.resourceURI+"?op=import_boot_images", http.StatusNotFound, "can't find rack")
+	err := rack.ImportBootImages()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, "can't find rack")
+}
+
+func (s *rackControllerSuite) TestImportBootImagesForbidden(c *gc.C) {
+	server, rack := s.getServerAndRack(c)
+	server.AddPostResponse(rack.resourceURI+"?op=import_boot_images", http.StatusForbidden, "bad user")
+	err := rack.ImportBootImages()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *rackControllerSuite) TestImportBootImagesUnexpected(c *gc.C) {
+	server, rack := s.getServerAndRack(c)
+	server.AddPostResponse(rack.resourceURI+"?op=import_boot_images", http.StatusBadGateway, "wat")
+	err := rack.ImportBootImages()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 502 Bad Gateway (wat)")
+}
+
+func (s *rackControllerSuite) TestListBootImages(c *gc.C) {
+	server, rack := s.getServerAndRack(c)
+	server.AddGetResponse(rack.resourceURI+"?op=list_boot_images", http.StatusOK, bootImagesResponse)
+	images, err := rack.ListBootImages()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(images.Connected(), jc.IsTrue)
+	c.Check(images.Status(), gc.Equals, "synced")
+	c.Check(images.Images(), gc.HasLen, 2)
+}
+
+func (s *rackControllerSuite) TestListBootImagesForbidden(c *gc.C) {
+	server, rack := s.getServerAndRack(c)
+	server.AddGetResponse(rack.resourceURI+"?op=list_boot_images", http.StatusForbidden, "bad user")
+	_, err := rack.ListBootImages()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "bad user")
+}
+
+var rackControllersResponse = `
+[
+    {
+        "system_id": "4y3h7n",
+        "hostname": "karura",
+        "fqdn": "karura.maas",
+        "ip_addresses": ["192.168.100.2"],
+        "version": "2.1.0",
+        "resource_uri": "/MAAS/api/2.0/rackcontrollers/4y3h7n/",
+        "interface_set": [` + interfaceResponse + `],
+        "service_set": [
+            {"name": "rackd", "status": "running", "status_info": ""},
+            {"name": "dhcpd", "status": "dead", "status_info": "dhcpd is not running"},
+            {"name": "tftp", "status": "running", "status_info": null}
+        ]
+    }
+]
+`
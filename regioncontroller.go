@@ -0,0 +1,187 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type regionController struct {
+	controller *controller
+
+	resourceURI string
+
+	systemID    string
+	hostname    string
+	fqdn        string
+	ipAddresses []string
+	version     string
+
+	interfaceSet []*interface_
+	services     []*serviceStatus
+	// vlans are the VLANs that this controller is the primary or
+	// secondary rack for, if it is also a rack controller. They are
+	// filled in by the controller.
+	vlans []*vlan
+}
+
+// SystemID implements RegionController.
+func (r *regionController) SystemID() string {
+	return r.systemID
+}
+
+// Hostname implements RegionController.
+func (r *regionController) Hostname() string {
+	return r.hostname
+}
+
+// FQDN implements RegionController.
+func (r *regionController) FQDN() string {
+	return r.fqdn
+}
+
+// IPAddresses implements RegionController.
+func (r *regionController) IPAddresses() []string {
+	return r.ipAddresses
+}
+
+// Version implements RegionController.
+func (r *regionController) Version() string {
+	return r.version
+}
+
+// InterfaceSet implements RegionController.
+func (r *regionController) InterfaceSet() []Interface {
+	result := make([]Interface, len(r.interfaceSet))
+	for i, v := range r.interfaceSet {
+		v.controller = r.controller
+		result[i] = v
+	}
+	return result
+}
+
+// Services implements RegionController.
+func (r *regionController) Services() []ServiceStatus {
+	result := make([]ServiceStatus, len(r.services))
+	for i, v := range r.services {
+		result[i] = v
+	}
+	return result
+}
+
+// Service implements RegionController.
+func (r *regionController) Service(name string) ServiceStatus {
+	for _, service := range r.services {
+		if service.Name() == name {
+			return service
+		}
+	}
+	return nil
+}
+
+// VLANs implements RegionController.
+func (r *regionController) VLANs() []VLAN {
+	result := make([]VLAN, len(r.vlans))
+	for i, v := range r.vlans {
+		result[i] = v
+	}
+	return result
+}
+
+func readRegionControllers(controllerVersion version.Number, source interface{}) ([]*regionController, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "region controller base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range regionControllerDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no region controller read func for version %s", controllerVersion)
+	}
+	readFunc := regionControllerDeserializationFuncs[deserialisationVersion]
+	return readRegionControllerList(valid, readFunc)
+}
+
+// readRegionControllerList expects the values of the sourceList to be string maps.
+func readRegionControllerList(sourceList []interface{}, readFunc regionControllerDeserializationFunc) ([]*regionController, error) {
+	result := make([]*regionController, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for region controller %d, %T", i, value)
+		}
+		region, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "region controller %d", i)
+		}
+		result = append(result, region)
+	}
+	return result, nil
+}
+
+type regionControllerDeserializationFunc func(map[string]interface{}) (*regionController, error)
+
+var regionControllerDeserializationFuncs = map[version.Number]regionControllerDeserializationFunc{
+	twoDotOh: regionController_2_0,
+}
+
+func regionController_2_0(source map[string]interface{}) (*regionController, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+
+		"system_id":    schema.String(),
+		"hostname":     schema.String(),
+		"fqdn":         schema.String(),
+		"ip_addresses": schema.List(schema.String()),
+		// Older controllers do not report their version.
+		"version": schema.OneOf(schema.Nil(""), schema.String()),
+
+		"interface_set": schema.List(schema.StringMap(schema.Any())),
+		"service_set":   schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"version":     "",
+		"service_set": []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "region controller 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	interfaceSet, err := readInterfaceList(valid["interface_set"].([]interface{}), interface_2_0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	services, err := readServiceStatusList(valid["service_set"].([]interface{}), serviceStatus_2_0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	maasVersion, _ := valid["version"].(string)
+	result := &regionController{
+		resourceURI: valid["resource_uri"].(string),
+
+		systemID:    valid["system_id"].(string),
+		hostname:    valid["hostname"].(string),
+		fqdn:        valid["fqdn"].(string),
+		ipAddresses: convertToStringSlice(valid["ip_addresses"]),
+		version:     maasVersion,
+
+		interfaceSet: interfaceSet,
+		services:     services,
+	}
+	return result, nil
+}
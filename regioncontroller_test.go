@@ -0,0 +1,71 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type regionControllerSuite struct{}
+
+var _ = gc.Suite(&regionControllerSuite{})
+
+func (*regionControllerSuite) TestReadRegionControllersBadSchema(c *gc.C) {
+	_, err := readRegionControllers(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `region controller base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*regionControllerSuite) TestReadRegionControllers(c *gc.C) {
+	regions, err := readRegionControllers(twoDotOh, parseJSON(c, regionControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(regions, gc.HasLen, 1)
+
+	region := regions[0]
+	c.Check(region.SystemID(), gc.Equals, "4y3h7n")
+	c.Check(region.Hostname(), gc.Equals, "karura")
+	c.Check(region.FQDN(), gc.Equals, "karura.maas")
+	c.Check(region.IPAddresses(), jc.DeepEquals, []string{"192.168.100.2"})
+	c.Check(region.Version(), gc.Equals, "2.1.0")
+	c.Check(region.InterfaceSet(), gc.HasLen, 1)
+	c.Check(region.Services(), gc.HasLen, 2)
+
+	service := region.Service(ServiceBind9)
+	c.Assert(service, gc.NotNil)
+	c.Check(service.Status(), gc.Equals, ServiceStatusDegraded)
+	c.Check(service.StatusInfo(), gc.Equals, "1 of 2 zones loaded")
+	c.Check(region.Service(ServiceDHCPD), gc.IsNil)
+}
+
+func (*regionControllerSuite) TestLowVersion(c *gc.C) {
+	_, err := readRegionControllers(version.MustParse("1.9.0"), parseJSON(c, regionControllersResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no region controller read func for version 1.9.0`)
+}
+
+func (*regionControllerSuite) TestHighVersion(c *gc.C) {
+	regions, err := readRegionControllers(version.MustParse("2.1.9"), parseJSON(c, regionControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(regions, gc.HasLen, 1)
+}
+
+var regionControllersResponse = `
+[
+    {
+        "system_id": "4y3h7n",
+        "hostname": "karura",
+        "fqdn": "karura.maas",
+        "ip_addresses": ["192.168.100.2"],
+        "version": "2.1.0",
+        "resource_uri": "/MAAS/api/2.0/regioncontrollers/4y3h7n/",
+        "interface_set": [` + interfaceResponse + `],
+        "service_set": [
+            {"name": "regiond", "status": "running", "status_info": ""},
+            {"name": "bind9", "status": "degraded", "status_info": "1 of 2 zones loaded"}
+        ]
+    }
+]
+`
@@ -0,0 +1,111 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+const (
+	// Service names reported by rack controllers.
+	ServiceRackd   = "rackd"
+	ServiceDHCPD   = "dhcpd"
+	ServiceDHCPD6  = "dhcpd6"
+	ServiceTFTP    = "tftp"
+	ServiceHTTP    = "http"
+	ServiceNTPRack = "ntp_rack"
+
+	// Service names reported by region controllers.
+	ServiceRegiond   = "regiond"
+	ServiceBind9     = "bind9"
+	ServiceNTPRegion = "ntp_region"
+	ServiceProxy     = "proxy"
+)
+
+const (
+	// ServiceStatus* values are the states a controller service can be in.
+
+	// The service is running as expected.
+	ServiceStatusRunning = "running"
+
+	// The service is running, but not all of its components are healthy.
+	ServiceStatusDegraded = "degraded"
+
+	// The service should be running, but is not.
+	ServiceStatusDead = "dead"
+
+	// The service is not needed, and is not running.
+	ServiceStatusOff = "off"
+
+	// The controller has not reported on the service.
+	ServiceStatusUnknown = "unknown"
+)
+
+type serviceStatus struct {
+	name       string
+	status     string
+	statusInfo string
+}
+
+// Name implements ServiceStatus.
+func (s *serviceStatus) Name() string {
+	return s.name
+}
+
+// Status implements ServiceStatus.
+func (s *serviceStatus) Status() string {
+	return s.status
+}
+
+// StatusInfo implements ServiceStatus.
+func (s *serviceStatus) StatusInfo() string {
+	return s.statusInfo
+}
+
+// readServiceStatusList expects the values of the sourceList to be string maps.
+func readServiceStatusList(sourceList []interface{}, readFunc serviceStatusDeserializationFunc) ([]*serviceStatus, error) {
+	result := make([]*serviceStatus, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for service %d, %T", i, value)
+		}
+		service, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "service %d", i)
+		}
+		result = append(result, service)
+	}
+	return result, nil
+}
+
+type serviceStatusDeserializationFunc func(map[string]interface{}) (*serviceStatus, error)
+
+func serviceStatus_2_0(source map[string]interface{}) (*serviceStatus, error) {
+	fields := schema.Fields{
+		"name":        schema.String(),
+		"status":      schema.String(),
+		"status_info": schema.OneOf(schema.Nil(""), schema.String()),
+	}
+	defaults := schema.Defaults{
+		"status_info": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "service 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	statusInfo, _ := valid["status_info"].(string)
+	result := &serviceStatus{
+		name:       valid["name"].(string),
+		status:     valid["status"].(string),
+		statusInfo: statusInfo,
+	}
+	return result, nil
+}
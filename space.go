@@ -40,14 +40,37 @@ func (s *space) Subnets() []Subnet {
 	return result
 }
 
+func readSpace(controllerVersion version.Number, source interface{}) (*space, error) {
+	readFunc, err := getSpaceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, errors.Annotatef(err, "space base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
 func readSpaces(controllerVersion version.Number, source interface{}) ([]*space, error) {
+	readFunc, err := getSpaceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "space base schema check failed")
 	}
 	valid := coerced.([]interface{})
+	return readSpaceList(valid, readFunc)
+}
 
+func getSpaceDeserializationFunc(controllerVersion version.Number) (spaceDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range spaceDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -57,8 +80,7 @@ func readSpaces(controllerVersion version.Number, source interface{}) ([]*space,
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no space read func for version %s", controllerVersion)
 	}
-	readFunc := spaceDeserializationFuncs[deserialisationVersion]
-	return readSpaceList(valid, readFunc)
+	return spaceDeserializationFuncs[deserialisationVersion], nil
 }
 
 // readSpaceList expects the values of the sourceList to be string maps.
@@ -0,0 +1,218 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type staticRoute struct {
+	controller *controller
+
+	resourceURI string
+
+	id          int
+	source      *subnet
+	destination *subnet
+	gatewayIP   string
+	metric      int
+}
+
+// ID implements StaticRoute.
+func (r *staticRoute) ID() int {
+	return r.id
+}
+
+// Source implements StaticRoute.
+func (r *staticRoute) Source() Subnet {
+	r.source.controller = r.controller
+	return r.source
+}
+
+// Destination implements StaticRoute.
+func (r *staticRoute) Destination() Subnet {
+	r.destination.controller = r.controller
+	return r.destination
+}
+
+// GatewayIP implements StaticRoute.
+func (r *staticRoute) GatewayIP() string {
+	return r.gatewayIP
+}
+
+// Metric implements StaticRoute.
+func (r *staticRoute) Metric() int {
+	return r.metric
+}
+
+// UpdateStaticRouteArgs is an argument struct for StaticRoute.Update. Only
+// the non-empty values are changed. The metric is changed if Metric is not
+// nil, so it can be set to 0.
+type UpdateStaticRouteArgs struct {
+	Source      Subnet
+	Destination Subnet
+	GatewayIP   string
+	Metric      *int
+}
+
+// Update implements StaticRoute.
+func (r *staticRoute) Update(args UpdateStaticRouteArgs) error {
+	params := NewURLParams()
+	if args.Source != nil {
+		params.Values.Add("source", fmt.Sprint(args.Source.ID()))
+	}
+	if args.Destination != nil {
+		params.Values.Add("destination", fmt.Sprint(args.Destination.ID()))
+	}
+	params.MaybeAdd("gateway_ip", args.GatewayIP)
+	if args.Metric != nil {
+		params.Values.Add("metric", fmt.Sprint(*args.Metric))
+	}
+	source, err := r.controller.put(r.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	updated, err := readStaticRoute(r.controller.apiVersion, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updated.controller = r.controller
+	*r = *updated
+	return nil
+}
+
+// Delete implements StaticRoute.
+func (r *staticRoute) Delete() error {
+	err := r.controller.delete(r.resourceURI)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+func readStaticRoute(controllerVersion version.Number, source interface{}) (*staticRoute, error) {
+	readFunc, err := getStaticRouteDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "static route base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readStaticRoutes(controllerVersion version.Number, source interface{}) ([]*staticRoute, error) {
+	readFunc, err := getStaticRouteDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "static route base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	return readStaticRouteList(valid, readFunc)
+}
+
+func getStaticRouteDeserializationFunc(controllerVersion version.Number) (staticRouteDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range staticRouteDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no static route read func for version %s", controllerVersion)
+	}
+	return staticRouteDeserializationFuncs[deserialisationVersion], nil
+}
+
+// readStaticRouteList expects the values of the sourceList to be string maps.
+func readStaticRouteList(sourceList []interface{}, readFunc staticRouteDeserializationFunc) ([]*staticRoute, error) {
+	result := make([]*staticRoute, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for static route %d, %T", i, value)
+		}
+		route, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "static route %d", i)
+		}
+		result = append(result, route)
+	}
+	return result, nil
+}
+
+type staticRouteDeserializationFunc func(map[string]interface{}) (*staticRoute, error)
+
+var staticRouteDeserializationFuncs = map[version.Number]staticRouteDeserializationFunc{
+	twoDotOh: staticRoute_2_0,
+}
+
+func staticRoute_2_0(source map[string]interface{}) (*staticRoute, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"id":           schema.ForceInt(),
+		"source":       schema.StringMap(schema.Any()),
+		"destination":  schema.StringMap(schema.Any()),
+		"gateway_ip":   schema.String(),
+		"metric":       schema.ForceInt(),
+	}
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "static route 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	sourceSubnet, err := subnet_2_0(valid["source"].(map[string]interface{}))
+	if err != nil {
+		return nil, errors.Annotate(err, "source")
+	}
+	destination, err := subnet_2_0(valid["destination"].(map[string]interface{}))
+	if err != nil {
+		return nil, errors.Annotate(err, "destination")
+	}
+	result := &staticRoute{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		source:      sourceSubnet,
+		destination: destination,
+		gatewayIP:   valid["gateway_ip"].(string),
+		metric:      valid["metric"].(int),
+	}
+	return result, nil
+}
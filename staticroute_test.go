@@ -0,0 +1,208 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type staticRouteSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&staticRouteSuite{})
+
+func (*staticRouteSuite) TestReadStaticRoutesBadSchema(c *gc.C) {
+	_, err := readStaticRoutes(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `static route base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readStaticRoutes(twoDotOh, []map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `static route 0: static route 2.0 schema check failed: .*`)
+}
+
+func (*staticRouteSuite) TestReadStaticRoutes(c *gc.C) {
+	routes, err := readStaticRoutes(twoDotOh, parseJSON(c, staticRoutesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(routes, gc.HasLen, 2)
+
+	route := routes[0]
+	c.Check(route.ID(), gc.Equals, 1)
+	c.Check(route.Source().CIDR(), gc.Equals, "192.168.100.0/24")
+	c.Check(route.Destination().CIDR(), gc.Equals, "10.0.0.0/24")
+	c.Check(route.GatewayIP(), gc.Equals, "192.168.100.254")
+	c.Check(route.Metric(), gc.Equals, 10)
+}
+
+func (*staticRouteSuite) TestLowVersion(c *gc.C) {
+	_, err := readStaticRoutes(version.MustParse("1.9.0"), parseJSON(c, staticRoutesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no static route read func for version 1.9.0`)
+}
+
+func (*staticRouteSuite) TestHighVersion(c *gc.C) {
+	routes, err := readStaticRoutes(version.MustParse("2.1.9"), parseJSON(c, staticRoutesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(routes, gc.HasLen, 2)
+}
+
+func (s *staticRouteSuite) getServerAndRoute(c *gc.C) (*SimpleTestServer, *staticRoute) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/static-routes/", http.StatusOK, staticRoutesResponse)
+
+	routes, err := controller.StaticRoutes()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(routes, gc.HasLen, 2)
+	return server, routes[0].(*staticRoute)
+}
+
+func (s *staticRouteSuite) TestSubnetStaticRoutes(c *gc.C) {
+	server, route := s.getServerAndRoute(c)
+	server.AddGetResponse("/api/2.0/static-routes/", http.StatusOK, staticRoutesResponse)
+
+	routes, err := route.Source().StaticRoutes()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(routes, gc.HasLen, 1)
+	c.Assert(routes[0].ID(), gc.Equals, 1)
+}
+
+func (*staticRouteSuite) TestSubnetStaticRoutesNoController(c *gc.C) {
+	subnets, err := readSubnets(twoDotOh, parseJSON(c, subnetResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = subnets[0].StaticRoutes()
+	c.Assert(err, gc.ErrorMatches, `subnet "192.168.100.0/24" not read from a controller`)
+}
+
+func (s *staticRouteSuite) TestUpdate(c *gc.C) {
+	server, route := s.getServerAndRoute(c)
+	response := updateJSONMap(c, staticRouteResponse, map[string]interface{}{
+		"metric": 20,
+	})
+	server.AddPutResponse(route.resourceURI, http.StatusOK, response)
+	metric := 20
+	err := route.Update(UpdateStaticRouteArgs{Metric: &metric})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(route.Metric(), gc.Equals, 20)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("metric"), gc.Equals, "20")
+}
+
+func (s *staticRouteSuite) TestUpdateMetricToZero(c *gc.C) {
+	server, route := s.getServerAndRoute(c)
+	response := updateJSONMap(c, staticRouteResponse, map[string]interface{}{
+		"metric": 0,
+	})
+	server.AddPutResponse(route.resourceURI, http.StatusOK, response)
+	metric := 0
+	err := route.Update(UpdateStaticRouteArgs{Metric: &metric})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(route.Metric(), gc.Equals, 0)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("metric"), gc.Equals, "0")
+}
+
+func (s *staticRouteSuite) TestUpdateBadRequest(c *gc.C) {
+	server, route := s.getServerAndRoute(c)
+	server.AddPutResponse(route.resourceURI, http.StatusBadRequest, "bad gateway ip")
+	err := route.Update(UpdateStaticRouteArgs{GatewayIP: "10.0.0.1"})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "bad gateway ip")
+}
+
+func (s *staticRouteSuite) TestDelete(c *gc.C) {
+	server, route := s.getServerAndRoute(c)
+	// Successful delete is 204 - StatusNoContent
+	server.AddDeleteResponse(route.resourceURI, http.StatusNoContent, "")
+	err := route.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *staticRouteSuite) TestDelete404(c *gc.C) {
+	_, route := s.getServerAndRoute(c)
+	// No path, so 404
+	err := route.Delete()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+const (
+	staticRouteSourceJSON = `
+        {
+            "gateway_ip": "192.168.100.1",
+            "name": "192.168.100.0/24",
+            "vlan": {
+                "fabric": "fabric-0",
+                "resource_uri": "/MAAS/api/2.0/vlans/1/",
+                "name": "untagged",
+                "secondary_rack": null,
+                "primary_rack": "4y3h7n",
+                "vid": 0,
+                "dhcp_on": true,
+                "id": 1,
+                "mtu": 1500
+            },
+            "space": "space-0",
+            "id": 1,
+            "resource_uri": "/MAAS/api/2.0/subnets/1/",
+            "dns_servers": [],
+            "cidr": "192.168.100.0/24",
+            "rdns_mode": 2
+        }`
+	staticRouteDestinationJSON = `
+        {
+            "gateway_ip": null,
+            "name": "10.0.0.0/24",
+            "vlan": {
+                "fabric": "fabric-1",
+                "resource_uri": "/MAAS/api/2.0/vlans/5001/",
+                "name": "untagged",
+                "secondary_rack": null,
+                "primary_rack": null,
+                "vid": 0,
+                "dhcp_on": false,
+                "id": 5001,
+                "mtu": 1500
+            },
+            "space": "space-1",
+            "id": 2,
+            "resource_uri": "/MAAS/api/2.0/subnets/2/",
+            "dns_servers": [],
+            "cidr": "10.0.0.0/24",
+            "rdns_mode": 2
+        }`
+	staticRouteResponse = `
+    {
+        "id": 1,
+        "source": ` + staticRouteSourceJSON + `,
+        "destination": ` + staticRouteDestinationJSON + `,
+        "gateway_ip": "192.168.100.254",
+        "metric": 10,
+        "resource_uri": "/MAAS/api/2.0/static-routes/1/"
+    }`
+	staticRoutesResponse = `
+[` + staticRouteResponse + `,
+    {
+        "id": 2,
+        "source": ` + staticRouteDestinationJSON + `,
+        "destination": ` + staticRouteSourceJSON + `,
+        "gateway_ip": "10.0.0.254",
+        "metric": 0,
+        "resource_uri": "/MAAS/api/2.0/static-routes/2/"
+    }
+]
+`
+)
@@ -10,8 +10,7 @@ import (
 )
 
 type subnet struct {
-	// Add the controller in when we need to do things with the subnet.
-	// controller Controller
+	controller *controller
 
 	resourceURI string
 
@@ -64,14 +63,55 @@ func (s *subnet) DNSServers() []string {
 	return s.dnsServers
 }
 
+// StaticRoutes implements Subnet.
+func (s *subnet) StaticRoutes() ([]StaticRoute, error) {
+	if s.controller == nil {
+		return nil, errors.Errorf("subnet %q not read from a controller", s.cidr)
+	}
+	routes, err := s.controller.staticRoutes()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []StaticRoute
+	for _, route := range routes {
+		if route.source.id == s.id {
+			result = append(result, route)
+		}
+	}
+	return result, nil
+}
+
+func readSubnet(controllerVersion version.Number, source interface{}) (*subnet, error) {
+	readFunc, err := getSubnetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, errors.Annotatef(err, "subnet base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
 func readSubnets(controllerVersion version.Number, source interface{}) ([]*subnet, error) {
+	readFunc, err := getSubnetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "subnet base schema check failed")
 	}
 	valid := coerced.([]interface{})
+	return readSubnetList(valid, readFunc)
+}
 
+func getSubnetDeserializationFunc(controllerVersion version.Number) (subnetDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range subnetDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -81,8 +121,7 @@ func readSubnets(controllerVersion version.Number, source interface{}) ([]*subne
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no subnet read func for version %s", controllerVersion)
 	}
-	readFunc := subnetDeserializationFuncs[deserialisationVersion]
-	return readSubnetList(valid, readFunc)
+	return subnetDeserializationFuncs[deserialisationVersion], nil
 }
 
 // readSubnetList expects the values of the sourceList to be string maps.
@@ -0,0 +1,269 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// oauthTimestampWindow is how far the timestamp of a signed request may be
+// from the clock of a test server.
+const oauthTimestampWindow = 5 * time.Minute
+
+// testAPIKey is an API key accepted by a test server.
+type testAPIKey struct {
+	consumerKey string
+	tokenSecret string
+	user        string
+	admin       bool
+}
+
+type adminOnlyRule struct {
+	method string
+	path   *regexp.Regexp
+}
+
+// oauthVerifier verifies the OAuth signatures of the requests to a test
+// server. It is embedded in the test servers, so its exported methods are
+// theirs. Until an API key is added, every request is accepted.
+type oauthVerifier struct {
+	mu        sync.Mutex
+	keys      map[string]testAPIKey
+	adminOnly []adminOnlyRule
+	nonces    set.Strings
+	users     []string
+	now       func() time.Time
+}
+
+func newOAuthVerifier() *oauthVerifier {
+	return &oauthVerifier{
+		keys:   make(map[string]testAPIKey),
+		nonces: set.NewStrings(),
+		now:    time.Now,
+	}
+}
+
+// AddAPIKey adds an API key, in the "<consumer key>:<token key>:<token
+// secret>" form used by NewAuthenticatedClient, that authenticates the
+// user. Once a key has been added, the server verifies the OAuth signature,
+// timestamp and nonce of every request but those for the API version, and
+// rejects the requests that are unsigned, badly signed or replayed with a
+// 401. The API key must be valid.
+func (v *oauthVerifier) AddAPIKey(apiKey, user string, admin bool) {
+	elements := strings.Split(apiKey, ":")
+	if len(elements) != 3 {
+		checkError(errors.NotValidf("API key %q", apiKey))
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[elements[1]] = testAPIKey{
+		consumerKey: elements[0],
+		tokenSecret: elements[2],
+		user:        user,
+		admin:       admin,
+	}
+}
+
+// AddAdminOnly makes the requests matching the method and path forbidden
+// for the users that are not admins, who get a 403. The method may be ""
+// for any method, and the path is an unanchored regular expression that
+// must be valid.
+func (v *oauthVerifier) AddAdminOnly(method, path string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.adminOnly = append(v.adminOnly, adminOnlyRule{
+		method: method,
+		path:   regexp.MustCompile(path),
+	})
+}
+
+// AuthenticatedUsers returns the users that made the authenticated
+// requests, in the order the requests were made.
+func (v *oauthVerifier) AuthenticatedUsers() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]string(nil), v.users...)
+}
+
+// authenticate returns a handler that only passes the requests on to the
+// handler if they are allowed.
+func (v *oauthVerifier) authenticate(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, err := v.verify(r); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// verify checks the request, returning the status code to reject it with
+// and why, or a nil error if it is allowed.
+func (v *oauthVerifier) verify(r *http.Request) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.keys) == 0 || strings.HasSuffix(r.URL.Path, "/version/") {
+		return 0, nil
+	}
+	key, err := v.checkSignature(r)
+	if err != nil {
+		return http.StatusUnauthorized, errors.Annotate(err, "Authorization Error")
+	}
+	v.users = append(v.users, key.user)
+	if !key.admin {
+		for _, rule := range v.adminOnly {
+			if (rule.method == "" || rule.method == r.Method) && rule.path.MatchString(r.URL.Path) {
+				return http.StatusForbidden, errors.Errorf("user %q is not an admin", key.user)
+			}
+		}
+	}
+	return 0, nil
+}
+
+// checkSignature returns the API key the request is signed with, if the
+// signature is valid and has not been seen before.
+func (v *oauthVerifier) checkSignature(r *http.Request) (testAPIKey, error) {
+	headers := r.Header["Authorization"]
+	if len(headers) != 1 {
+		return testAPIKey{}, errors.Errorf("expected one Authorization header, got %d", len(headers))
+	}
+	params, err := parseOAuthHeader(headers[0])
+	if err != nil {
+		return testAPIKey{}, errors.Trace(err)
+	}
+	if version, ok := params["oauth_version"]; ok && version != "1.0" {
+		return testAPIKey{}, errors.Errorf("unsupported OAuth version %q", version)
+	}
+	key, ok := v.keys[params["oauth_token"]]
+	if !ok {
+		return testAPIKey{}, errors.Errorf("invalid access token %q", params["oauth_token"])
+	}
+	if params["oauth_consumer_key"] != key.consumerKey {
+		return testAPIKey{}, errors.Errorf("invalid consumer %q", params["oauth_consumer_key"])
+	}
+	timestamp, err := strconv.ParseInt(params["oauth_timestamp"], 10, 64)
+	if err != nil {
+		return testAPIKey{}, errors.Errorf("invalid timestamp %q", params["oauth_timestamp"])
+	}
+	skew := v.now().Sub(time.Unix(timestamp, 0))
+	if skew > oauthTimestampWindow || skew < -oauthTimestampWindow {
+		return testAPIKey{}, errors.Errorf("expired timestamp %d", timestamp)
+	}
+	// The consumer secret is always empty in MAAS.
+	secret := "&" + oauthEscape(key.tokenSecret)
+	var expected string
+	switch method := params["oauth_signature_method"]; method {
+	case "PLAINTEXT":
+		expected = secret
+	case "HMAC-SHA1":
+		base, err := signatureBaseString(r, params)
+		if err != nil {
+			return testAPIKey{}, errors.Trace(err)
+		}
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write([]byte(base))
+		expected = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	default:
+		return testAPIKey{}, errors.Errorf("unsupported signature method %q", method)
+	}
+	if !hmac.Equal([]byte(params["oauth_signature"]), []byte(expected)) {
+		return testAPIKey{}, errors.New("invalid signature")
+	}
+	nonce := strings.Join([]string{key.consumerKey, params["oauth_timestamp"], params["oauth_nonce"]}, ":")
+	if params["oauth_nonce"] == "" || v.nonces.Contains(nonce) {
+		return testAPIKey{}, errors.Errorf("nonce %q already used", params["oauth_nonce"])
+	}
+	v.nonces.Add(nonce)
+	return key, nil
+}
+
+// parseOAuthHeader returns the parameters of an OAuth Authorization header.
+func parseOAuthHeader(header string) (map[string]string, error) {
+	if !strings.HasPrefix(header, "OAuth ") {
+		return nil, errors.NotValidf("Authorization header %q", header)
+	}
+	params := make(map[string]string)
+	for _, param := range strings.Split(header[len("OAuth "):], ",") {
+		parts := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.NotValidf("OAuth parameter %q", param)
+		}
+		value, err := strconv.Unquote(parts[1])
+		if err != nil {
+			return nil, errors.NotValidf("OAuth parameter %q", param)
+		}
+		if value, err = url.QueryUnescape(value); err != nil {
+			return nil, errors.NotValidf("OAuth parameter %q", param)
+		}
+		params[parts[0]] = value
+	}
+	return params, nil
+}
+
+// signatureBaseString returns the string that an HMAC-SHA1 signature of the
+// request signs, see http://oauth.net/core/1.0/#anchor14. The body of a
+// form request is read for its parameters, and restored for the handler.
+func signatureBaseString(r *http.Request, oauthParams map[string]string) (string, error) {
+	values := r.URL.Query()
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		body, err := readAndClose(r.Body)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		for name, formValues := range form {
+			values[name] = append(values[name], formValues...)
+		}
+	}
+	for name, value := range oauthParams {
+		if name != "realm" && name != "oauth_signature" {
+			values.Add(name, value)
+		}
+	}
+	var params []string
+	for name, nameValues := range values {
+		for _, value := range nameValues {
+			params = append(params, oauthEscape(name)+"="+oauthEscape(value))
+		}
+	}
+	sort.Strings(params)
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s%s", scheme, strings.ToLower(r.Host), r.URL.EscapedPath())
+	return strings.Join([]string{
+		r.Method,
+		oauthEscape(baseURL),
+		oauthEscape(strings.Join(params, "&")),
+	}, "&"), nil
+}
+
+// oauthEscape percent-encodes the string as OAuth requires, which differs
+// from url.QueryEscape for spaces and tildes.
+func oauthEscape(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.Replace(s, "+", "%20", -1)
+	return strings.Replace(s, "%7E", "~", -1)
+}
@@ -0,0 +1,208 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const (
+	testAdminKey = "consumer:admin-token:admin-secret"
+	testUserKey  = "consumer:user-token:user-secret"
+)
+
+type authSuite struct {
+	server *SimpleTestServer
+}
+
+var _ = gc.Suite(&authSuite{})
+
+func (s *authSuite) SetUpTest(c *gc.C) {
+	s.server = NewSimpleServer()
+	for i := 0; i < 10; i++ {
+		s.server.AddGetResponse("/api/2.0/things/", http.StatusOK, `["some", "things"]`)
+		s.server.AddPostResponse("/api/2.0/things/?op=frob", http.StatusOK, `"frobbed"`)
+	}
+	s.server.AddAPIKey(testAdminKey, "admin", true)
+	s.server.AddAPIKey(testUserKey, "user", false)
+	s.server.Start()
+}
+
+func (s *authSuite) TearDownTest(c *gc.C) {
+	s.server.Close()
+}
+
+func (s *authSuite) client(c *gc.C, apiKey string) *Client {
+	client, err := NewAuthenticatedClient(s.server.URL, apiKey, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	return client
+}
+
+func (s *authSuite) get(client *Client) error {
+	_, err := client.Get(&url.URL{Path: "things/"}, "", nil)
+	return err
+}
+
+func checkServerStatus(c *gc.C, err error, status int) {
+	svrErr, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("%v", err))
+	c.Check(svrErr.StatusCode, gc.Equals, status)
+}
+
+func (s *authSuite) TestValidKeys(c *gc.C) {
+	c.Assert(s.get(s.client(c, testAdminKey)), jc.ErrorIsNil)
+	c.Assert(s.get(s.client(c, testUserKey)), jc.ErrorIsNil)
+	c.Check(s.server.AuthenticatedUsers(), jc.DeepEquals, []string{"admin", "user"})
+}
+
+func (s *authSuite) TestUnverifiedWithoutKeys(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/things/", http.StatusOK, `[]`)
+	server.Start()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(&url.URL{Path: "things/"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.AuthenticatedUsers(), gc.HasLen, 0)
+}
+
+func (s *authSuite) TestUnsigned(c *gc.C) {
+	client, err := NewAnonymousClient(s.server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	checkServerStatus(c, s.get(client), http.StatusUnauthorized)
+	c.Check(s.server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *authSuite) TestBadKeys(c *gc.C) {
+	for _, apiKey := range []string{
+		"consumer:admin-token:wrong-secret",
+		"consumer:unknown-token:admin-secret",
+		"other-consumer:admin-token:admin-secret",
+	} {
+		checkServerStatus(c, s.get(s.client(c, apiKey)), http.StatusUnauthorized)
+	}
+	c.Check(s.server.RequestCount(), gc.Equals, 0)
+	c.Check(s.server.AuthenticatedUsers(), gc.HasLen, 0)
+}
+
+func (s *authSuite) TestReplay(c *gc.C) {
+	client := s.client(c, testAdminKey)
+	request, err := http.NewRequest("GET", client.GetURL(&url.URL{Path: "things/"}).String(), nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.Signer.OAuthSign(request), jc.ErrorIsNil)
+	header := request.Header.Get("Authorization")
+	for i, status := range []int{http.StatusOK, http.StatusUnauthorized} {
+		request, err := http.NewRequest("GET", client.GetURL(&url.URL{Path: "things/"}).String(), nil)
+		c.Assert(err, jc.ErrorIsNil)
+		request.Header.Set("Authorization", header)
+		response, err := http.DefaultClient.Do(request)
+		c.Assert(err, jc.ErrorIsNil)
+		response.Body.Close()
+		c.Check(response.StatusCode, gc.Equals, status, gc.Commentf("request %d", i))
+	}
+}
+
+func (s *authSuite) TestTimestampWindow(c *gc.C) {
+	s.server.now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+	checkServerStatus(c, s.get(s.client(c, testAdminKey)), http.StatusUnauthorized)
+	s.server.now = func() time.Time { return time.Now().Add(time.Minute) }
+	c.Assert(s.get(s.client(c, testAdminKey)), jc.ErrorIsNil)
+}
+
+func (s *authSuite) TestRetriedRequestIsSignedAgain(c *gc.C) {
+	s.server.AddFault(FaultRule{Status: http.StatusServiceUnavailable, Times: 1})
+	c.Assert(s.get(s.client(c, testAdminKey)), jc.ErrorIsNil)
+	c.Check(s.server.FaultsInjected(), gc.Equals, 1)
+	c.Check(s.server.AuthenticatedUsers(), jc.DeepEquals, []string{"admin", "admin"})
+}
+
+func (s *authSuite) TestAdminOnly(c *gc.C) {
+	s.server.AddAdminOnly("POST", "/things/$")
+	frob := func(apiKey string) error {
+		_, err := s.client(c, apiKey).Post(&url.URL{Path: "things/"}, "frob", nil, nil)
+		return err
+	}
+	c.Assert(frob(testAdminKey), jc.ErrorIsNil)
+	checkServerStatus(c, frob(testUserKey), http.StatusForbidden)
+	c.Assert(s.get(s.client(c, testUserKey)), jc.ErrorIsNil)
+	c.Check(s.server.AuthenticatedUsers(), jc.DeepEquals, []string{"admin", "user", "user"})
+}
+
+// hmacSigner signs requests with the OAuth HMAC-SHA1 method.
+type hmacSigner struct {
+	token OAuthToken
+}
+
+func (signer hmacSigner) OAuthSign(request *http.Request) error {
+	nonce, err := generateNonce()
+	if err != nil {
+		return err
+	}
+	params := map[string]string{
+		"oauth_consumer_key":     signer.token.ConsumerKey,
+		"oauth_token":            signer.token.TokenKey,
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        generateTimestamp(),
+		"oauth_nonce":            nonce,
+		"oauth_version":          "1.0",
+	}
+	base, err := signatureBaseString(request, params)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha1.New, []byte(oauthEscape(signer.token.ConsumerSecret)+"&"+oauthEscape(signer.token.TokenSecret)))
+	mac.Write([]byte(base))
+	params["oauth_signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	header := "OAuth"
+	for name, value := range params {
+		header += fmt.Sprintf(" %s=%s,", name, strconv.Quote(url.QueryEscape(value)))
+	}
+	request.Header.Set("Authorization", header[:len(header)-1])
+	return nil
+}
+
+func (s *authSuite) TestHMACSignature(c *gc.C) {
+	client := s.client(c, testAdminKey)
+	client.Signer = hmacSigner{OAuthToken{ConsumerKey: "consumer", TokenKey: "admin-token", TokenSecret: "admin-secret"}}
+	params := url.Values{"name": {"a thing~"}}
+	_, err := client.Post(&url.URL{Path: "things/"}, "frob", params, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	client.Signer = hmacSigner{OAuthToken{ConsumerKey: "consumer", TokenKey: "admin-token", TokenSecret: "wrong-secret"}}
+	_, err = client.Post(&url.URL{Path: "things/"}, "frob", params, nil)
+	checkServerStatus(c, err, http.StatusUnauthorized)
+}
+
+func (s *authSuite) TestTestServer(c *gc.C) {
+	server := NewTestServer("1.0")
+	defer server.Close()
+	server.AddAPIKey(testUserKey, "user", false)
+	server.AddAdminOnly("POST", "/nodes/$")
+
+	anonymous, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = anonymous.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = anonymous.Get(&url.URL{Path: "nodes/"}, "list", nil)
+	checkServerStatus(c, err, http.StatusUnauthorized)
+
+	client, err := NewAuthenticatedClient(server.URL, testUserKey, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(&url.URL{Path: "nodes/"}, "list", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Post(&url.URL{Path: "nodes/"}, "acquire", nil, nil)
+	checkServerStatus(c, err, http.StatusForbidden)
+	c.Check(server.AuthenticatedUsers(), jc.DeepEquals, []string{"user", "user"})
+}
@@ -0,0 +1,209 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FaultRule describes a fault that a test server injects into its responses
+// to the matching requests, for testing how the client copes with a
+// misbehaving MAAS. A rule with no Status, Reset or Truncate only adds the
+// latency before the normal response.
+type FaultRule struct {
+	// Method is the HTTP method of the requests to match, or "" for any.
+	Method string
+	// Path is a regular expression matched against the path of the
+	// requests, or "" for any. It is not anchored.
+	Path string
+
+	// Every fires the rule on every Nth matching request, starting with
+	// the Nth. Zero or one fires it on every matching request.
+	Every int
+	// Probability, if non-zero, fires the rule with that probability on
+	// the requests selected by Every.
+	Probability float64
+	// Times is the number of times the rule fires before it is exhausted,
+	// or zero for no limit.
+	Times int
+
+	// Latency is the delay before the response. If MaxLatency is greater,
+	// the delay is a random duration between the two.
+	Latency    time.Duration
+	MaxLatency time.Duration
+
+	// Status is the status code to respond with instead of the normal
+	// response, such as 500, 502, 503 or 504.
+	Status int
+	// RetryAfter is the number of seconds sent in the Retry-After header of
+	// a 503 response. It is sent if the Status is 503, even when zero.
+	RetryAfter int
+	// Reset closes the connection without any response.
+	Reset bool
+	// Truncate sends the normal response with only half of its body,
+	// before closing the connection.
+	Truncate bool
+}
+
+// faultRule is a FaultRule with the state of its matching.
+type faultRule struct {
+	FaultRule
+	path    *regexp.Regexp
+	matched int
+	fired   int
+}
+
+// faultInjector injects faults into the responses of a test server. It is
+// embedded in the test servers, so its exported methods are theirs.
+type faultInjector struct {
+	mu       sync.Mutex
+	rules    []*faultRule
+	rand     *rand.Rand
+	injected int
+}
+
+func newFaultInjector() *faultInjector {
+	// A fixed seed keeps the probabilities the same on every run.
+	return &faultInjector{rand: rand.New(rand.NewSource(1))}
+}
+
+// AddFault adds a rule for injecting faults. The rules are checked in the
+// order they are added, and only the first one that fires for a request is
+// applied. The path must be a valid regular expression.
+func (f *faultInjector) AddFault(rule FaultRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, &faultRule{
+		FaultRule: rule,
+		path:      regexp.MustCompile(rule.Path),
+	})
+}
+
+// ClearFaults removes all the fault rules.
+func (f *faultInjector) ClearFaults() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = nil
+	f.injected = 0
+}
+
+// SetFaultSeed seeds the random numbers used for the probabilities and
+// latencies of the rules.
+func (f *faultInjector) SetFaultSeed(seed int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rand = rand.New(rand.NewSource(seed))
+}
+
+// FaultsInjected returns the number of requests that rules have fired for
+// since the faults were last cleared.
+func (f *faultInjector) FaultsInjected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.injected
+}
+
+// fire returns the first rule that fires for the request, and the latency
+// to add, or nil if none does.
+func (f *faultInjector) fire(r *http.Request) (*FaultRule, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rule := range f.rules {
+		if rule.Method != "" && rule.Method != r.Method || !rule.path.MatchString(r.URL.Path) {
+			continue
+		}
+		if rule.Times > 0 && rule.fired >= rule.Times {
+			continue
+		}
+		rule.matched++
+		if rule.Every > 1 && rule.matched%rule.Every != 0 {
+			continue
+		}
+		if rule.Probability > 0 && f.rand.Float64() >= rule.Probability {
+			continue
+		}
+		rule.fired++
+		f.injected++
+		latency := rule.Latency
+		if spread := rule.MaxLatency - rule.Latency; spread > 0 {
+			latency += time.Duration(f.rand.Int63n(int64(spread)))
+		}
+		result := rule.FaultRule
+		return &result, latency
+	}
+	return nil, 0
+}
+
+// wrap returns a handler that injects the faults into the responses of the
+// handler.
+func (f *faultInjector) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, latency := f.fire(r)
+		if rule == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if latency > 0 {
+			time.Sleep(latency)
+		}
+		switch {
+		case rule.Reset:
+			resetConnection(w)
+		case rule.Status != 0:
+			if rule.Status == http.StatusServiceUnavailable {
+				w.Header().Set(RetryAfterHeaderName, strconv.Itoa(rule.RetryAfter))
+			}
+			http.Error(w, fmt.Sprintf("injected fault: %s", http.StatusText(rule.Status)), rule.Status)
+		case rule.Truncate:
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+			writeTruncated(w, recorder)
+		default:
+			handler.ServeHTTP(w, r)
+		}
+	})
+}
+
+// resetConnection closes the connection of the response abruptly, so that
+// the client sees a reset rather than a response.
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	checkError(err)
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		// Discard any unsent data and send a RST rather than a FIN.
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// writeTruncated writes the recorded response with the full length of its
+// body in the headers but only half of the body, and closes the connection.
+func writeTruncated(w http.ResponseWriter, recorder *httptest.ResponseRecorder) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	checkError(err)
+	defer conn.Close()
+	body := recorder.Body.Bytes()
+	length := len(body)
+	if length == 0 {
+		// The client must expect something that never arrives.
+		length = 1
+	}
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", recorder.Code, http.StatusText(recorder.Code))
+	header := recorder.Header()
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(length))
+	header.Set("Connection", "close")
+	header.Write(buf)
+	buf.WriteString("\r\n")
+	buf.Write(body[:len(body)/2])
+	buf.Flush()
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type faultSuite struct {
+	server *SimpleTestServer
+	client *Client
+}
+
+var _ = gc.Suite(&faultSuite{})
+
+func (s *faultSuite) SetUpTest(c *gc.C) {
+	s.server = NewSimpleServer()
+	for i := 0; i < 10; i++ {
+		s.server.AddGetResponse("/api/2.0/things/", http.StatusOK, `["some", "things"]`)
+	}
+	s.server.Start()
+	client, err := NewAnonymousClient(s.server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	s.client = client
+}
+
+func (s *faultSuite) TearDownTest(c *gc.C) {
+	s.server.Close()
+}
+
+func (s *faultSuite) get() ([]byte, error) {
+	return s.client.Get(&url.URL{Path: "things/"}, "", nil)
+}
+
+func (s *faultSuite) TestRetriedServiceUnavailable(c *gc.C) {
+	s.server.AddFault(FaultRule{Path: "/things/$", Status: http.StatusServiceUnavailable, Times: 2})
+	content, err := s.get()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, `["some", "things"]`)
+	c.Check(s.server.FaultsInjected(), gc.Equals, 2)
+	c.Check(s.server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *faultSuite) TestServerErrors(c *gc.C) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout} {
+		s.server.ClearFaults()
+		s.server.AddFault(FaultRule{Method: "GET", Status: status})
+		_, err := s.get()
+		svrErr, ok := GetServerError(err)
+		c.Assert(ok, jc.IsTrue)
+		c.Check(svrErr.StatusCode, gc.Equals, status)
+		// Only a 503 is retried.
+		c.Check(s.server.FaultsInjected(), gc.Equals, 1)
+	}
+}
+
+func (s *faultSuite) TestMatching(c *gc.C) {
+	s.server.AddFault(FaultRule{Method: "POST", Status: http.StatusInternalServerError})
+	s.server.AddFault(FaultRule{Path: "/machines/", Status: http.StatusInternalServerError})
+	_, err := s.get()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.FaultsInjected(), gc.Equals, 0)
+}
+
+func (s *faultSuite) TestEvery(c *gc.C) {
+	s.server.AddFault(FaultRule{Every: 3, Status: http.StatusInternalServerError})
+	var failed []int
+	for i := 1; i <= 6; i++ {
+		if _, err := s.get(); err != nil {
+			failed = append(failed, i)
+		}
+	}
+	c.Check(failed, jc.DeepEquals, []int{3, 6})
+}
+
+func (s *faultSuite) TestProbability(c *gc.C) {
+	s.server.AddFault(FaultRule{Probability: 0.5})
+	for i := 0; i < 100; i++ {
+		s.server.fire(&http.Request{Method: "GET", URL: &url.URL{Path: "/"}})
+	}
+	c.Check(s.server.FaultsInjected() > 30, jc.IsTrue)
+	c.Check(s.server.FaultsInjected() < 70, jc.IsTrue)
+}
+
+func (s *faultSuite) TestLatency(c *gc.C) {
+	s.server.AddFault(FaultRule{Latency: 50 * time.Millisecond, MaxLatency: 60 * time.Millisecond})
+	start := time.Now()
+	_, err := s.get()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(time.Since(start) >= 50*time.Millisecond, jc.IsTrue)
+}
+
+func (s *faultSuite) TestReset(c *gc.C) {
+	s.server.AddFault(FaultRule{Reset: true})
+	_, err := s.get()
+	c.Assert(err, gc.NotNil)
+	_, ok := GetServerError(err)
+	c.Check(ok, jc.IsFalse)
+	c.Check(s.server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *faultSuite) TestTruncate(c *gc.C) {
+	s.server.AddFault(FaultRule{Truncate: true})
+	_, err := s.get()
+	c.Assert(err, gc.ErrorMatches, ".*unexpected EOF")
+	c.Check(s.server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *faultSuite) TestTestServer(c *gc.C) {
+	server := NewTestServer("1.0")
+	defer server.Close()
+	server.AddFault(FaultRule{Path: "/version/", Status: http.StatusBadGateway})
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	svrErr, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.StatusCode, gc.Equals, http.StatusBadGateway)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 )
 
 type singleServingServer struct {
@@ -83,12 +84,23 @@ func newFlakyServer(uri string, code int, nbFlakyResponses int) *flakyServer {
 }
 
 type simpleResponse struct {
-	status int
-	body   string
+	status  int
+	body    string
+	respond func(*http.Request) (int, string)
 }
 
+// SimpleTestServer responds to requests with canned responses, added for
+// exact URLs with AddGetResponse and the like, or for routes with AddRoute.
+// Each response is used once, and the query parameters of the URLs may be
+// given in any order.
 type SimpleTestServer struct {
 	*httptest.Server
+	*faultInjector
+	*oauthVerifier
+
+	// mu guards the responses and requests, as the handler may be called
+	// concurrently.
+	mu sync.Mutex
 
 	getResponses        map[string][]simpleResponse
 	getResponseIndex    map[string]int
@@ -100,10 +112,16 @@ type SimpleTestServer struct {
 	deleteResponseIndex map[string]int
 
 	requests []*http.Request
+
+	routes     []*simpleRoute
+	strict     bool
+	unexpected []string
 }
 
 func NewSimpleServer() *SimpleTestServer {
 	server := &SimpleTestServer{
+		faultInjector:       newFaultInjector(),
+		oauthVerifier:       newOAuthVerifier(),
 		getResponses:        make(map[string][]simpleResponse),
 		getResponseIndex:    make(map[string]int),
 		putResponses:        make(map[string][]simpleResponse),
@@ -113,31 +131,45 @@ func NewSimpleServer() *SimpleTestServer {
 		deleteResponses:     make(map[string][]simpleResponse),
 		deleteResponseIndex: make(map[string]int),
 	}
-	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.handler))
+	server.Server = httptest.NewUnstartedServer(server.authenticate(server.wrap(http.HandlerFunc(server.handler))))
 	return server
 }
 
 func (s *SimpleTestServer) AddGetResponse(path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	logger.Debugf("add get response for: %s, %d", path, status)
-	s.getResponses[path] = append(s.getResponses[path], simpleResponse{status: status, body: body})
+	uri := canonicalURI(path)
+	s.getResponses[uri] = append(s.getResponses[uri], simpleResponse{status: status, body: body})
 }
 
 func (s *SimpleTestServer) AddPutResponse(path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	logger.Debugf("add put response for: %s, %d", path, status)
-	s.putResponses[path] = append(s.putResponses[path], simpleResponse{status: status, body: body})
+	uri := canonicalURI(path)
+	s.putResponses[uri] = append(s.putResponses[uri], simpleResponse{status: status, body: body})
 }
 
 func (s *SimpleTestServer) AddPostResponse(path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	logger.Debugf("add post response for: %s, %d", path, status)
-	s.postResponses[path] = append(s.postResponses[path], simpleResponse{status: status, body: body})
+	uri := canonicalURI(path)
+	s.postResponses[uri] = append(s.postResponses[uri], simpleResponse{status: status, body: body})
 }
 
 func (s *SimpleTestServer) AddDeleteResponse(path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	logger.Debugf("add delete response for: %s, %d", path, status)
-	s.deleteResponses[path] = append(s.deleteResponses[path], simpleResponse{status: status, body: body})
+	uri := canonicalURI(path)
+	s.deleteResponses[uri] = append(s.deleteResponses[uri], simpleResponse{status: status, body: body})
 }
 
 func (s *SimpleTestServer) LastRequest() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	pos := len(s.requests) - 1
 	if pos < 0 {
 		return nil
@@ -146,6 +178,8 @@ func (s *SimpleTestServer) LastRequest() *http.Request {
 }
 
 func (s *SimpleTestServer) LastNRequests(n int) []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	start := len(s.requests) - n
 	if start < 0 {
 		start = 0
@@ -154,69 +188,82 @@ func (s *SimpleTestServer) LastNRequests(n int) []*http.Request {
 }
 
 func (s *SimpleTestServer) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return len(s.requests)
 }
 
 func (s *SimpleTestServer) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.requests = nil
 }
 
 func (s *SimpleTestServer) handler(writer http.ResponseWriter, request *http.Request) {
 	method := request.Method
-	var (
-		err           error
-		responses     map[string][]simpleResponse
-		responseIndex map[string]int
-	)
+	var err error
 	switch method {
-	case "GET":
-		responses = s.getResponses
-		responseIndex = s.getResponseIndex
+	case "GET", "DELETE":
 		_, err = readAndClose(request.Body)
-		if err != nil {
-			panic(err) // it is a test, panic should be fine
-		}
 	case "PUT":
-		responses = s.putResponses
-		responseIndex = s.putResponseIndex
 		err = request.ParseForm()
-		if err != nil {
-			panic(err)
-		}
 	case "POST":
-		responses = s.postResponses
-		responseIndex = s.postResponseIndex
 		contentType := request.Header.Get("Content-Type")
 		if strings.HasPrefix(contentType, "multipart/form-data;") {
 			err = request.ParseMultipartForm(2 << 20)
 		} else {
 			err = request.ParseForm()
 		}
-		if err != nil {
-			panic(err)
-		}
-	case "DELETE":
-		responses = s.deleteResponses
-		responseIndex = s.deleteResponseIndex
-		_, err := readAndClose(request.Body)
-		if err != nil {
-			panic(err)
-		}
 	default:
 		panic("unsupported method " + method)
 	}
-	s.requests = append(s.requests, request)
-	uri := request.URL.String()
-	testResponses, found := responses[uri]
+	if err != nil {
+		panic(err) // it is a test, panic should be fine
+	}
+	response, found := s.response(request)
 	if !found {
-		errorMsg := fmt.Sprintf("Error 404: page not found ('%v').", uri)
+		errorMsg := fmt.Sprintf("Error 404: page not found ('%v').", request.URL)
 		http.Error(writer, errorMsg, http.StatusNotFound)
-	} else {
-		index := responseIndex[uri]
-		response := testResponses[index]
+		return
+	}
+	if response.respond != nil {
+		response.status, response.body = response.respond(request)
+	}
+	writer.WriteHeader(response.status)
+	fmt.Fprint(writer, response.body)
+}
+
+// response records the request and returns the response for it, using up
+// the response.
+func (s *SimpleTestServer) response(request *http.Request) (simpleResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+	responses, responseIndex := s.responses(request.Method)
+	uri := canonicalURI(request.URL.String())
+	if index := responseIndex[uri]; index < len(responses[uri]) {
 		responseIndex[uri] = index + 1
+		return responses[uri][index], true
+	}
+	if route := s.matchRoute(request); route != nil {
+		route.used++
+		return simpleResponse{status: route.Status, body: route.Body, respond: route.Respond}, true
+	}
+	s.unexpected = append(s.unexpected, request.Method+" "+request.URL.String())
+	return simpleResponse{}, false
+}
 
-		writer.WriteHeader(response.status)
-		fmt.Fprint(writer, response.body)
+// responses returns the responses added for the exact URLs for the method,
+// and the indexes of the next ones to use.
+func (s *SimpleTestServer) responses(method string) (map[string][]simpleResponse, map[string]int) {
+	switch method {
+	case "GET":
+		return s.getResponses, s.getResponseIndex
+	case "PUT":
+		return s.putResponses, s.putResponseIndex
+	case "POST":
+		return s.postResponses, s.postResponseIndex
+	default:
+		return s.deleteResponses, s.deleteResponseIndex
 	}
 }
@@ -0,0 +1,188 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// SimpleRoute matches requests to a SimpleTestServer by their parts rather
+// than by their full URL, see AddRoute. The empty fields match anything.
+type SimpleRoute struct {
+	// Method is the HTTP method of the requests.
+	Method string
+	// Path is the path of the requests, without the query.
+	Path string
+	// Query holds the query parameters the requests must have, in any
+	// order. The requests may have other parameters too.
+	Query url.Values
+	// Form holds the form fields the requests must have, whether the body
+	// is URL encoded or multipart. The requests may have other fields too.
+	Form url.Values
+	// Files holds the contents of the multipart files the requests must
+	// have.
+	Files map[string]string
+
+	// Status and Body are the response to the requests.
+	Status int
+	Body   string
+	// Respond, if set, is called for the response instead of using Status
+	// and Body.
+	Respond func(request *http.Request) (status int, body string)
+	// Repeat makes the route respond to every matching request, rather
+	// than only to the first.
+	Repeat bool
+}
+
+// simpleRoute is a SimpleRoute with the number of requests it has
+// responded to.
+type simpleRoute struct {
+	SimpleRoute
+	used int
+}
+
+// AddRoute adds a route for responding to the matching requests. The
+// routes are checked in the order they are added, after the responses
+// added for exact URLs, and the first matching route that has not been
+// used up responds.
+func (s *SimpleTestServer) AddRoute(route SimpleRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logger.Debugf("add route for: %s %s, %d", route.Method, route.Path, route.Status)
+	s.routes = append(s.routes, &simpleRoute{SimpleRoute: route})
+}
+
+// SetStrict sets whether Verify reports the requests that had no response
+// and the responses that were never used. By default the server is
+// lenient, and Verify only reports the former.
+func (s *SimpleTestServer) SetStrict(strict bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strict = strict
+}
+
+// Verify returns an error describing the requests that no response
+// matched. If the server is strict, the error also describes the added
+// responses and routes that have not been used.
+func (s *SimpleTestServer) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var problems []string
+	for _, uri := range s.unexpected {
+		problems = append(problems, "unexpected request "+uri)
+	}
+	if s.strict {
+		problems = append(problems, s.unconsumed()...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "\n"))
+}
+
+// unconsumed describes the responses and routes that have not been used.
+func (s *SimpleTestServer) unconsumed() []string {
+	var result []string
+	for _, method := range []string{"GET", "PUT", "POST", "DELETE"} {
+		responses, responseIndex := s.responses(method)
+		var uris []string
+		for uri, methodResponses := range responses {
+			if unused := len(methodResponses) - responseIndex[uri]; unused > 0 {
+				uris = append(uris, uri)
+			}
+		}
+		sort.Strings(uris)
+		for _, uri := range uris {
+			result = append(result, "unused response for "+method+" "+uri)
+		}
+	}
+	for _, route := range s.routes {
+		if route.used == 0 {
+			result = append(result, "unused route for "+route.Method+" "+route.Path)
+		}
+	}
+	return result
+}
+
+// matchRoute returns the first route that can respond to the request.
+func (s *SimpleTestServer) matchRoute(request *http.Request) *simpleRoute {
+	for _, route := range s.routes {
+		if route.used > 0 && !route.Repeat {
+			continue
+		}
+		if route.matches(request) {
+			return route
+		}
+	}
+	return nil
+}
+
+func (route *simpleRoute) matches(request *http.Request) bool {
+	if route.Method != "" && route.Method != request.Method {
+		return false
+	}
+	if route.Path != "" && route.Path != request.URL.Path {
+		return false
+	}
+	if !valuesSubset(route.Query, request.URL.Query()) {
+		return false
+	}
+	form := request.PostForm
+	if request.MultipartForm != nil {
+		form = request.MultipartForm.Value
+	}
+	if !valuesSubset(route.Form, form) {
+		return false
+	}
+	for name, content := range route.Files {
+		if request.MultipartForm == nil || len(request.MultipartForm.File[name]) == 0 {
+			return false
+		}
+		file, err := request.MultipartForm.File[name][0].Open()
+		if err != nil {
+			return false
+		}
+		data, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil || string(data) != content {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesSubset reports whether got has the same values as want for each of
+// the names in want, in any order.
+func valuesSubset(want, got url.Values) bool {
+	for name, wantValues := range want {
+		gotValues := append([]string(nil), got[name]...)
+		wantValues = append([]string(nil), wantValues...)
+		sort.Strings(gotValues)
+		sort.Strings(wantValues)
+		if len(gotValues) != len(wantValues) || strings.Join(gotValues, "\x00") != strings.Join(wantValues, "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalURI returns the path and query of the URI with the query
+// parameters sorted, so that the order they are given in doesn't matter.
+func canonicalURI(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := parsed.Query()
+	if len(query) == 0 {
+		return parsed.Path
+	}
+	return parsed.Path + "?" + query.Encode()
+}
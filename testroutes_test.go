@@ -0,0 +1,152 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type routeSuite struct {
+	server *SimpleTestServer
+	client *Client
+}
+
+var _ = gc.Suite(&routeSuite{})
+
+func (s *routeSuite) SetUpTest(c *gc.C) {
+	s.server = NewSimpleServer()
+	s.server.Start()
+	client, err := NewAnonymousClient(s.server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	s.client = client
+}
+
+func (s *routeSuite) TearDownTest(c *gc.C) {
+	s.server.Close()
+}
+
+func (s *routeSuite) get(query url.Values) (string, error) {
+	content, err := s.client.Get(&url.URL{Path: "machines/"}, "", query)
+	return string(content), err
+}
+
+func (s *routeSuite) TestExactURLQueryOrder(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?zone=b&hostname=a", http.StatusOK, `"found"`)
+	content, err := s.get(url.Values{"hostname": {"a"}, "zone": {"b"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(content, gc.Equals, `"found"`)
+}
+
+func (s *routeSuite) TestExhaustedResponse(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `[]`)
+	_, err := s.get(nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = s.get(nil)
+	checkServerStatus(c, err, http.StatusNotFound)
+	c.Check(s.server.Verify(), gc.ErrorMatches, "unexpected request GET /api/2.0/machines/")
+}
+
+func (s *routeSuite) TestQuerySubset(c *gc.C) {
+	s.server.AddRoute(SimpleRoute{
+		Method: "GET",
+		Path:   "/api/2.0/machines/",
+		Query:  url.Values{"hostname": {"b", "a"}},
+		Status: http.StatusOK,
+		Body:   `"found"`,
+	})
+	_, err := s.get(url.Values{"hostname": {"a"}})
+	checkServerStatus(c, err, http.StatusNotFound)
+	content, err := s.get(url.Values{"zone": {"z"}, "hostname": {"a", "b"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(content, gc.Equals, `"found"`)
+}
+
+func (s *routeSuite) TestRoutesInOrder(c *gc.C) {
+	s.server.AddRoute(SimpleRoute{Path: "/api/2.0/machines/", Status: http.StatusOK, Body: `"first"`})
+	s.server.AddRoute(SimpleRoute{Path: "/api/2.0/machines/", Status: http.StatusOK, Body: `"second"`, Repeat: true})
+	for _, expected := range []string{`"first"`, `"second"`, `"second"`} {
+		content, err := s.get(nil)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(content, gc.Equals, expected)
+	}
+}
+
+func (s *routeSuite) TestExactURLBeforeRoute(c *gc.C) {
+	s.server.AddRoute(SimpleRoute{Method: "GET", Status: http.StatusOK, Body: `"route"`})
+	s.server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `"exact"`)
+	content, err := s.get(nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(content, gc.Equals, `"exact"`)
+}
+
+func (s *routeSuite) TestFormFields(c *gc.C) {
+	s.server.AddRoute(SimpleRoute{
+		Method: "POST",
+		Query:  url.Values{"op": {"release"}},
+		Form:   url.Values{"machines": {"b", "a"}},
+		Status: http.StatusOK,
+		Body:   `"released"`,
+	})
+	_, err := s.client.Post(&url.URL{Path: "machines/"}, "release", url.Values{"machines": {"a"}}, nil)
+	checkServerStatus(c, err, http.StatusNotFound)
+	content, err := s.client.Post(&url.URL{Path: "machines/"}, "release", url.Values{"machines": {"a", "b"}, "comment": {"done"}}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, `"released"`)
+}
+
+func (s *routeSuite) TestMultipartFields(c *gc.C) {
+	s.server.AddRoute(SimpleRoute{
+		Method: "POST",
+		Path:   "/api/2.0/files/",
+		Form:   url.Values{"filename": {"config"}},
+		Files:  map[string]string{"file": "content"},
+		Status: http.StatusOK,
+	})
+	files := map[string][]byte{"file": []byte("other")}
+	params := url.Values{"filename": {"config"}}
+	_, err := s.client.Post(&url.URL{Path: "files/"}, "add", params, files)
+	checkServerStatus(c, err, http.StatusNotFound)
+	files["file"] = []byte("content")
+	_, err = s.client.Post(&url.URL{Path: "files/"}, "add", params, files)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *routeSuite) TestRespond(c *gc.C) {
+	s.server.AddRoute(SimpleRoute{
+		Path: "/api/2.0/machines/",
+		Respond: func(request *http.Request) (int, string) {
+			if hostname := request.URL.Query().Get("hostname"); hostname != "" {
+				return http.StatusOK, `"` + hostname + `"`
+			}
+			return http.StatusBadRequest, "no hostname"
+		},
+		Repeat: true,
+	})
+	content, err := s.get(url.Values{"hostname": {"a"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(content, gc.Equals, `"a"`)
+	_, err = s.get(nil)
+	checkServerStatus(c, err, http.StatusBadRequest)
+}
+
+func (s *routeSuite) TestVerify(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `[]`)
+	s.server.AddRoute(SimpleRoute{Method: "POST", Path: "/api/2.0/machines/", Status: http.StatusOK})
+	c.Check(s.server.Verify(), jc.ErrorIsNil)
+
+	s.server.SetStrict(true)
+	c.Check(s.server.Verify(), gc.ErrorMatches, ""+
+		"unused response for GET /api/2.0/machines/\n"+
+		"unused route for POST /api/2.0/machines/")
+
+	_, err := s.get(nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = s.client.Post(&url.URL{Path: "machines/"}, "", nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.Verify(), jc.ErrorIsNil)
+}
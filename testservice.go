@@ -58,9 +58,12 @@ func (testMAASObject *TestMAASObject) Close() {
 // A TestServer is an HTTP server listening on a system-chosen port on the
 // local loopback interface, which simulates the behavior of a MAAS server.
 // It is intendend for use in end-to-end HTTP tests using the gomaasapi
-// library.
+// library. See AddFault for injecting faults into its responses, and
+// AddAPIKey for verifying the OAuth signatures of the requests.
 type TestServer struct {
 	*httptest.Server
+	*faultInjector
+	*oauthVerifier
 	serveMux   *http.ServeMux
 	client     Client
 	nodes      map[string]MAASObject
@@ -105,6 +108,9 @@ type TestServer struct {
 	nextSpace      uint
 	vlans          map[int]TestVLAN
 	nextVLAN       int
+
+	// The simulated lifecycle of the nodes, see SetLifecycle.
+	lifecycleSimulator
 }
 
 type TestDevice struct {
@@ -208,7 +214,8 @@ func getZonesEndpoint(version string) string {
 }
 
 // Clear clears all the fake data stored and recorded by the test server
-// (nodes, recorded operations, etc.).
+// (nodes, recorded operations, etc.), disables the simulated lifecycle of
+// the nodes and sets its clock back.
 func (server *TestServer) Clear() {
 	server.nodes = make(map[string]MAASObject)
 	server.ownedNodes = make(map[string]bool)
@@ -236,6 +243,7 @@ func (server *TestServer) Clear() {
 	server.nextSpace = 1
 	server.vlans = make(map[int]TestVLAN)
 	server.nextVLAN = 1
+	server.lifecycleSimulator = newLifecycleSimulator()
 }
 
 // SetVersionJSON sets the JSON response (capabilities) returned from the
@@ -372,6 +380,9 @@ func (server *TestServer) ChangeNode(systemId, key, value string) {
 		panic("No node with such 'system_id'.")
 	}
 	node.GetMap()[key] = maasify(server.client, value)
+	if key == "status" {
+		server.statusChanged(systemId)
+	}
 }
 
 // NewIPAddress creates a new static IP address reservation for the
@@ -552,7 +563,12 @@ func (server *TestServer) Devices() map[string]*TestDevice {
 
 // NewTestServer starts and returns a new MAAS test server. The caller should call Close when finished, to shut it down.
 func NewTestServer(version string) *TestServer {
-	server := &TestServer{version: version}
+	server := &TestServer{
+		version:            version,
+		faultInjector:      newFaultInjector(),
+		oauthVerifier:      newOAuthVerifier(),
+		lifecycleSimulator: newLifecycleSimulator(),
+	}
 
 	serveMux := http.NewServeMux()
 	devicesURL := getDevicesEndpoint(server.version)
@@ -619,7 +635,7 @@ func NewTestServer(version string) *TestServer {
 		serveMux.ServeHTTP(w, req)
 	}
 
-	newServer := httptest.NewServer(http.HandlerFunc(singleFile))
+	newServer := httptest.NewServer(server.authenticate(server.wrap(http.HandlerFunc(singleFile))))
 	client, err := NewAnonymousClient(newServer.URL, "1.0")
 	checkError(err)
 	server.Server = newServer
@@ -921,13 +937,22 @@ func nodeHandler(server *TestServer, w http.ResponseWriter, r *http.Request, sys
 		}
 	}
 	if r.Method == "POST" {
-		// The only operations supported are "start", "stop" and "release".
-		if operation == "start" || operation == "stop" || operation == "release" {
+		// The only operations supported are "start", "stop", "release"
+		// and "commission".
+		if operation == "start" || operation == "stop" || operation == "release" || operation == "commission" {
 			// Record operation on node.
-			server.addNodeOperation(systemId, operation, r)
+			values := server.addNodeOperation(systemId, operation, r)
 
-			if operation == "release" {
+			switch operation {
+			case "start":
+				server.startNode(systemId)
+			case "release":
 				delete(server.OwnedNodes(), systemId)
+				server.releaseNode(systemId, values)
+			case "commission":
+				if !server.commissionNode(w, systemId, values) {
+					return
+				}
 			}
 
 			w.WriteHeader(http.StatusOK)
@@ -1124,6 +1149,7 @@ func nodesReleaseHandler(server *TestServer, w http.ResponseWriter, r *http.Requ
 			continue
 		}
 		delete(server.OwnedNodes(), systemId)
+		server.releaseNode(systemId, values[len(values)-1])
 		node := server.Nodes()[systemId]
 		releasedNodes = append(releasedNodes, node.GetMap())
 	}
@@ -1487,13 +1513,15 @@ func reserveIPAddressHandler(server *TestServer, w http.ResponseWriter, r *http.
 		netMask, err := netObj.GetField("netmask")
 		checkError(err)
 
-		// Convert the netmask string to net.IPMask.
-		parts := strings.Split(netMask, ".")
-		ipMask := make(net.IPMask, len(parts))
-		for i, part := range parts {
-			intPart, err := strconv.Atoi(part)
-			checkError(err)
-			ipMask[i] = byte(intPart)
+		// Convert the netmask string, such as "255.255.255.0" or
+		// "ffff:ffff:ffff:ffff::", to net.IPMask.
+		maskIP := net.ParseIP(netMask)
+		if maskIP == nil {
+			panic("invalid netmask: " + netMask)
+		}
+		ipMask := net.IPMask(maskIP.To16())
+		if maskIP.To4() != nil {
+			ipMask = net.IPMask(maskIP.To4())
 		}
 		netNet := &net.IPNet{IP: net.ParseIP(netIP), Mask: ipMask}
 		if netNet.String() == network {
@@ -1518,10 +1546,8 @@ func reserveIPAddressHandler(server *TestServer, w http.ResponseWriter, r *http.
 		reservedIP = reqAddress
 	} else {
 		// Generate an IP in the network range by incrementing the
-		// last byte of the network's IP.
-		firstIP := ipNet.IP
-		firstIP[len(firstIP)-1] += byte(len(ips) + 1)
-		reservedIP = firstIP.String()
+		// network's IP.
+		reservedIP = IPFromNetIP(ipNet.IP).Add(int64(len(ips) + 1)).String()
 	}
 	ips = append(ips, reservedIP)
 	server.ipAddressesPerNetwork[foundNetworkName] = ips
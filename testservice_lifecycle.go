@@ -0,0 +1,154 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/juju/utils/set"
+)
+
+// testNodeStatusNames are the names of the node statuses a TestServer
+// records events with.
+var testNodeStatusNames = map[string]string{
+	NodeStatusCommissioning:     machineStatusCommissioning,
+	NodeStatusFailedTests:       machineStatusFailedCommissioning,
+	NodeStatusReady:             machineStatusReady,
+	NodeStatusDeployed:          machineStatusDeployed,
+	NodeStatusDeploying:         machineStatusDeploying,
+	NodeStatusFailedDeployment:  machineStatusFailedDeployment,
+	NodeStatusReleasing:         machineStatusReleasing,
+	NodeStatusDiskErasing:       machineStatusDiskErasing,
+	NodeStatusFailedDiskErasing: machineStatusFailedDiskErasing,
+}
+
+// commissionableNodeStatuses are the statuses a node of a TestServer can be
+// commissioned from.
+var commissionableNodeStatuses = set.NewStrings(
+	NodeStatusDeclared,
+	NodeStatusFailedTests,
+	NodeStatusReady,
+	NodeStatusBroken,
+)
+
+// SetLifecycle enables the simulated lifecycle of the nodes. Without it,
+// the start, release and commission operations are only recorded, and the
+// status of a node only changes when ChangeNode is called.
+func (server *TestServer) SetLifecycle(lifecycle FakeLifecycle) {
+	server.setLifecycle(lifecycle)
+}
+
+// Now returns the time on the clock of the server.
+func (server *TestServer) Now() time.Time {
+	return server.lifecycleSimulator.now
+}
+
+// Advance moves the clock of the server forward, completing the transitions
+// that are due by the new time in the order they are due. The transitions
+// that follow on from them, such as releasing a node once its disks are
+// erased, are completed too if they are due.
+func (server *TestServer) Advance(d time.Duration) {
+	server.advance(d, server.nodeStatus)
+}
+
+// Events returns the events of the node, or of all the nodes if the system
+// ID is empty, oldest first.
+func (server *TestServer) Events(systemId string) []FakeEvent {
+	return server.machineEvents(systemId)
+}
+
+// nodeStatus returns the status of the node, or "" if there is no such
+// node.
+func (server *TestServer) nodeStatus(systemId string) string {
+	node, ok := server.nodes[systemId]
+	if !ok {
+		return ""
+	}
+	status, _ := node.GetField("status")
+	return status
+}
+
+// setNodeStatus changes the status of the node and records an event for it.
+func (server *TestServer) setNodeStatus(systemId, status, message string) {
+	node := server.nodes[systemId]
+	node.GetMap()["status"] = maasify(server.client, status)
+	hostname, _ := node.GetField("hostname")
+	server.recordEvent(systemId, hostname, testNodeStatusNames[status], message)
+}
+
+// scheduleNode calls complete after the duration, if the node is still in
+// its current status.
+func (server *TestServer) scheduleNode(systemId string, d time.Duration, complete func()) {
+	server.scheduleTransition(systemId, server.nodeStatus(systemId), d, complete)
+}
+
+// startNode starts deploying the node.
+func (server *TestServer) startNode(systemId string) {
+	if server.lifecycle == nil {
+		return
+	}
+	server.setNodeStatus(systemId, NodeStatusDeploying, "")
+	server.scheduleNode(systemId, server.lifecycle.DeployTime, func() {
+		if server.transitionFails(systemId) {
+			server.setNodeStatus(systemId, NodeStatusFailedDeployment, "Installation failed")
+			return
+		}
+		server.setNodeStatus(systemId, NodeStatusDeployed, "")
+	})
+}
+
+// releaseNode starts releasing the node, erasing its disks first if the
+// request values ask to.
+func (server *TestServer) releaseNode(systemId string, values url.Values) {
+	if server.lifecycle == nil {
+		return
+	}
+	comment := values.Get("comment")
+	if erase, _ := strconv.ParseBool(values.Get("erase")); !erase {
+		server.startReleasingNode(systemId, comment)
+		return
+	}
+	server.setNodeStatus(systemId, NodeStatusDiskErasing, comment)
+	server.scheduleNode(systemId, server.lifecycle.EraseTime, func() {
+		if server.transitionFails(systemId) {
+			server.setNodeStatus(systemId, NodeStatusFailedDiskErasing, "Failed to erase disks")
+			return
+		}
+		server.startReleasingNode(systemId, comment)
+	})
+}
+
+func (server *TestServer) startReleasingNode(systemId, comment string) {
+	server.setNodeStatus(systemId, NodeStatusReleasing, comment)
+	server.scheduleNode(systemId, server.lifecycle.ReleaseTime, func() {
+		server.setNodeStatus(systemId, NodeStatusReady, comment)
+	})
+}
+
+// commissionNode starts commissioning the node, writing an error to the
+// response and returning false if the node can't be commissioned.
+func (server *TestServer) commissionNode(w http.ResponseWriter, systemId string, values url.Values) bool {
+	if server.lifecycle == nil {
+		return true
+	}
+	status := server.nodeStatus(systemId)
+	if !commissionableNodeStatuses.Contains(status) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, "Can't commission a node that is in the '%s' state.", status)
+		return false
+	}
+	server.setNodeStatus(systemId, NodeStatusCommissioning, values.Get("comment"))
+	server.scheduleNode(systemId, server.lifecycle.CommissionTime, func() {
+		if server.transitionFails(systemId) {
+			server.setNodeStatus(systemId, NodeStatusFailedTests, "Commissioning failed")
+			return
+		}
+		server.setNodeStatus(systemId, NodeStatusReady, "")
+	})
+	return true
+}
@@ -0,0 +1,251 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+)
+
+// TestServerSnapshot is a copy of the simulated inventory of a TestServer,
+// see Snapshot and Restore. It can be saved with json.Marshal and loaded
+// with json.Unmarshal, such as to keep the fixture of a whole datacenter in
+// a file. The MAAS objects are kept as the JSON the server serves.
+type TestServerSnapshot struct {
+	Nodes                  map[string]json.RawMessage            `json:"nodes,omitempty"`
+	OwnedNodes             map[string]bool                       `json:"owned_nodes,omitempty"`
+	NodeMetadata           map[string]Node                       `json:"node_metadata,omitempty"`
+	NodeDetails            map[string]string                     `json:"node_details,omitempty"`
+	Files                  map[string]json.RawMessage            `json:"files,omitempty"`
+	Networks               map[string]json.RawMessage            `json:"networks,omitempty"`
+	NetworksPerNode        map[string][]string                   `json:"networks_per_node,omitempty"`
+	IPAddressesPerNetwork  map[string][]string                   `json:"ip_addresses_per_network,omitempty"`
+	MACAddressesPerNetwork map[string]map[string]json.RawMessage `json:"mac_addresses_per_network,omitempty"`
+	Zones                  map[string]json.RawMessage            `json:"zones,omitempty"`
+	BootImages             map[string][]json.RawMessage          `json:"boot_images,omitempty"`
+	NodegroupsInterfaces   map[string][]json.RawMessage          `json:"nodegroups_interfaces,omitempty"`
+	VersionJSON            string                                `json:"version_json"`
+	Devices                map[string]TestDevice                 `json:"devices,omitempty"`
+
+	Subnets        map[uint]TestSubnetSnapshot `json:"subnets,omitempty"`
+	SubnetNameToID map[string]uint             `json:"subnet_name_to_id,omitempty"`
+	NextSubnet     uint                        `json:"next_subnet"`
+	Spaces         map[uint]TestSpace          `json:"spaces,omitempty"`
+	SpaceNameToID  map[string]uint             `json:"space_name_to_id,omitempty"`
+	NextSpace      uint                        `json:"next_space"`
+	VLANs          map[int]TestVLAN            `json:"vlans,omitempty"`
+	NextVLAN       int                         `json:"next_vlan"`
+}
+
+// TestSubnetSnapshot is a subnet in a TestServerSnapshot, along with the
+// addresses in use and the fixed address ranges, which the API does not
+// show.
+type TestSubnetSnapshot struct {
+	TestSubnet
+	InUseIPAddresses   []TestIPSnapshot `json:"in_use_ip_addresses,omitempty"`
+	FixedAddressRanges []AddressRange   `json:"fixed_address_ranges,omitempty"`
+}
+
+// TestIPSnapshot is an address in use in a TestSubnetSnapshot.
+type TestIPSnapshot struct {
+	Address string   `json:"address"`
+	Purpose []string `json:"purpose,omitempty"`
+}
+
+// Snapshot returns a copy of the simulated inventory of the server: its
+// nodes, files, networks, IP addresses, zones, devices, subnets, spaces and
+// VLANs. The recorded operations are not part of it.
+func (server *TestServer) Snapshot() TestServerSnapshot {
+	snapshot := TestServerSnapshot{
+		Nodes:                  make(map[string]json.RawMessage),
+		OwnedNodes:             server.ownedNodes,
+		NodeMetadata:           server.nodeMetadata,
+		NodeDetails:            server.nodeDetails,
+		Files:                  make(map[string]json.RawMessage),
+		Networks:               make(map[string]json.RawMessage),
+		NetworksPerNode:        server.networksPerNode,
+		IPAddressesPerNetwork:  server.ipAddressesPerNetwork,
+		MACAddressesPerNetwork: make(map[string]map[string]json.RawMessage),
+		Zones:                  make(map[string]json.RawMessage),
+		BootImages:             make(map[string][]json.RawMessage),
+		NodegroupsInterfaces:   make(map[string][]json.RawMessage),
+		VersionJSON:            server.versionJSON,
+		Devices:                make(map[string]TestDevice),
+		Subnets:                make(map[uint]TestSubnetSnapshot),
+		SubnetNameToID:         server.subnetNameToID,
+		NextSubnet:             server.nextSubnet,
+		Spaces:                 make(map[uint]TestSpace),
+		SpaceNameToID:          server.spaceNameToID,
+		NextSpace:              server.nextSpace,
+		VLANs:                  server.vlans,
+		NextVLAN:               server.nextVLAN,
+	}
+	for systemId, node := range server.nodes {
+		snapshot.Nodes[systemId] = marshalSnapshot(node)
+	}
+	for filename, file := range server.files {
+		snapshot.Files[filename] = marshalSnapshot(file)
+	}
+	for name, network := range server.networks {
+		snapshot.Networks[name] = marshalSnapshot(network)
+	}
+	for name, macAddresses := range server.macAddressesPerNetwork {
+		snapshot.MACAddressesPerNetwork[name] = make(map[string]json.RawMessage)
+		for systemId, macAddress := range macAddresses {
+			snapshot.MACAddressesPerNetwork[name][systemId] = marshalSnapshot(macAddress)
+		}
+	}
+	for name, zone := range server.zones {
+		snapshot.Zones[name] = marshalSnapshot(zone)
+	}
+	for uuid, bootImages := range server.bootImages {
+		for _, bootImage := range bootImages {
+			snapshot.BootImages[uuid] = append(snapshot.BootImages[uuid], marshalSnapshot(bootImage))
+		}
+	}
+	for uuid, interfaces := range server.nodegroupsInterfaces {
+		for _, iface := range interfaces {
+			snapshot.NodegroupsInterfaces[uuid] = append(snapshot.NodegroupsInterfaces[uuid], marshalSnapshot(iface))
+		}
+	}
+	for systemId, device := range server.devices {
+		snapshot.Devices[systemId] = *device
+	}
+	for id, subnet := range server.subnets {
+		subnetSnapshot := TestSubnetSnapshot{
+			TestSubnet:         subnet,
+			FixedAddressRanges: subnet.FixedAddressRanges,
+		}
+		for _, ip := range subnet.InUseIPAddresses {
+			subnetSnapshot.InUseIPAddresses = append(subnetSnapshot.InUseIPAddresses, TestIPSnapshot{
+				Address: ip.String(),
+				Purpose: ip.Purpose,
+			})
+		}
+		snapshot.Subnets[id] = subnetSnapshot
+	}
+	for id, space := range server.spaces {
+		snapshot.Spaces[id] = *space
+	}
+	// The snapshot shares nothing with the server once it has been
+	// through JSON.
+	return copySnapshot(snapshot)
+}
+
+// Restore replaces the simulated inventory of the server with the
+// snapshot, and clears the recorded operations and events. The lifecycle
+// set with SetLifecycle is kept. The snapshot is not changed, so it can be
+// restored again.
+func (server *TestServer) Restore(snapshot TestServerSnapshot) {
+	// The server takes over the maps of the copy.
+	snapshot = copySnapshot(snapshot)
+	lifecycle := server.lifecycle
+	server.Clear()
+	server.lifecycle = lifecycle
+	if snapshot.VersionJSON != "" {
+		server.versionJSON = snapshot.VersionJSON
+	}
+	for systemId, node := range snapshot.Nodes {
+		server.nodes[systemId] = newJSONMAASObject(unmarshalSnapshot(node).(map[string]interface{}), server.client)
+	}
+	if snapshot.OwnedNodes != nil {
+		server.ownedNodes = snapshot.OwnedNodes
+	}
+	if snapshot.NodeMetadata != nil {
+		server.nodeMetadata = snapshot.NodeMetadata
+	}
+	if snapshot.NodeDetails != nil {
+		server.nodeDetails = snapshot.NodeDetails
+	}
+	for filename, file := range snapshot.Files {
+		server.files[filename] = newJSONMAASObject(unmarshalSnapshot(file).(map[string]interface{}), server.client)
+	}
+	for name, network := range snapshot.Networks {
+		server.networks[name] = newJSONMAASObject(unmarshalSnapshot(network).(map[string]interface{}), server.client)
+	}
+	if snapshot.NetworksPerNode != nil {
+		server.networksPerNode = snapshot.NetworksPerNode
+	}
+	if snapshot.IPAddressesPerNetwork != nil {
+		server.ipAddressesPerNetwork = snapshot.IPAddressesPerNetwork
+	}
+	for name, macAddresses := range snapshot.MACAddressesPerNetwork {
+		server.macAddressesPerNetwork[name] = make(map[string]JSONObject)
+		for systemId, macAddress := range macAddresses {
+			server.macAddressesPerNetwork[name][systemId] = maasify(server.client, unmarshalSnapshot(macAddress))
+		}
+	}
+	for name, zone := range snapshot.Zones {
+		server.zones[name] = maasify(server.client, unmarshalSnapshot(zone))
+	}
+	for uuid, bootImages := range snapshot.BootImages {
+		for _, bootImage := range bootImages {
+			server.bootImages[uuid] = append(server.bootImages[uuid], maasify(server.client, unmarshalSnapshot(bootImage)))
+		}
+	}
+	for uuid, interfaces := range snapshot.NodegroupsInterfaces {
+		for _, iface := range interfaces {
+			server.nodegroupsInterfaces[uuid] = append(server.nodegroupsInterfaces[uuid], maasify(server.client, unmarshalSnapshot(iface)))
+		}
+	}
+	for systemId, device := range snapshot.Devices {
+		device := device
+		server.devices[systemId] = &device
+	}
+	for id, subnetSnapshot := range snapshot.Subnets {
+		subnet := subnetSnapshot.TestSubnet
+		for _, address := range subnetSnapshot.InUseIPAddresses {
+			ip := IPFromString(address.Address)
+			ip.Purpose = address.Purpose
+			subnet.InUseIPAddresses = append(subnet.InUseIPAddresses, ip)
+		}
+		server.subnets[id] = subnet
+		for _, ar := range subnetSnapshot.FixedAddressRanges {
+			server.AddFixedAddressRange(id, ar)
+		}
+	}
+	if snapshot.SubnetNameToID != nil {
+		server.subnetNameToID = snapshot.SubnetNameToID
+	}
+	for id, space := range snapshot.Spaces {
+		space := space
+		server.spaces[id] = &space
+	}
+	if snapshot.SpaceNameToID != nil {
+		server.spaceNameToID = snapshot.SpaceNameToID
+	}
+	if snapshot.VLANs != nil {
+		server.vlans = snapshot.VLANs
+	}
+	if snapshot.NextSubnet != 0 {
+		server.nextSubnet = snapshot.NextSubnet
+	}
+	if snapshot.NextSpace != 0 {
+		server.nextSpace = snapshot.NextSpace
+	}
+	if snapshot.NextVLAN != 0 {
+		server.nextVLAN = snapshot.NextVLAN
+	}
+}
+
+// copySnapshot returns a deep copy of the snapshot, made by taking it
+// through JSON.
+func copySnapshot(snapshot TestServerSnapshot) TestServerSnapshot {
+	data, err := json.Marshal(snapshot)
+	checkError(err)
+	var result TestServerSnapshot
+	checkError(json.Unmarshal(data, &result))
+	return result
+}
+
+func marshalSnapshot(value interface{}) json.RawMessage {
+	data, err := json.Marshal(value)
+	checkError(err)
+	return data
+}
+
+func unmarshalSnapshot(data json.RawMessage) interface{} {
+	var value interface{}
+	checkError(json.Unmarshal(data, &value))
+	return value
+}
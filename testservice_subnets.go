@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/http"
 	"net/url"
@@ -67,8 +68,8 @@ type TestSubnet struct {
 // that subnet stores.
 func (server *TestServer) AddFixedAddressRange(subnetID uint, ar AddressRange) {
 	subnet := server.subnets[subnetID]
-	ar.startUint = IPFromString(ar.Start).UInt64()
-	ar.endUint = IPFromString(ar.End).UInt64()
+	ar.startIP = IPFromString(ar.Start)
+	ar.endIP = IPFromString(ar.End)
 	subnet.FixedAddressRanges = append(subnet.FixedAddressRanges, ar)
 	server.subnets[subnetID] = subnet
 }
@@ -150,18 +151,30 @@ func subnetsHandler(server *TestServer, w http.ResponseWriter, r *http.Request)
 	}
 }
 
-type addressList []IP
+// usedRange is a range of addresses with a purpose, from a single address in
+// use to a fixed address range.
+type usedRange struct {
+	start, end IP
+	purpose    []string
+}
+
+type usedRangeList []usedRange
 
-func (a addressList) Len() int           { return len(a) }
-func (a addressList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a addressList) Less(i, j int) bool { return a[i].UInt64() < a[j].UInt64() }
+func (a usedRangeList) Len() int      { return len(a) }
+func (a usedRangeList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a usedRangeList) Less(i, j int) bool {
+	if c := a[i].start.Cmp(a[j].start); c != 0 {
+		return c < 0
+	}
+	return a[i].end.Cmp(a[j].end) < 0
+}
 
 // AddressRange is used to generate reserved IP address range lists
 type AddressRange struct {
 	Start        string `json:"start"`
-	startUint    uint64
+	startIP      IP
 	End          string `json:"end"`
-	endUint      uint64
+	endIP        IP
 	Purpose      []string `json:"purpose,omitempty"`
 	NumAddresses uint     `json:"num_addresses"`
 }
@@ -175,66 +188,90 @@ type AddressRangeList struct {
 func (ranges *AddressRangeList) Append(startIP, endIP IP) {
 	var i AddressRange
 	i.Start, i.End = startIP.String(), endIP.String()
-	i.startUint, i.endUint = startIP.UInt64(), endIP.UInt64()
-	i.NumAddresses = uint(1 + endIP.UInt64() - startIP.UInt64())
+	i.startIP, i.endIP = startIP, endIP
+	size := new(big.Int).Sub(endIP.BigInt(), startIP.BigInt())
+	i.NumAddresses = bigToUint(size.Add(size, big.NewInt(1)))
 	i.Purpose = startIP.Purpose
 	ranges.ar = append(ranges.ar, i)
 }
 
-func appendRangesToIPList(subnet TestSubnet, ipAddresses *[]IP) {
+// bigToUint returns v as a uint, clamped to the range of a uint, since the
+// number of addresses in an IPv6 range may not fit in one.
+func bigToUint(v *big.Int) uint {
+	if v.Sign() < 0 {
+		return 0
+	}
+	max := new(big.Int).SetUint64(uint64(^uint(0)))
+	if v.Cmp(max) > 0 {
+		return ^uint(0)
+	}
+	return uint(v.Uint64())
+}
+
+// subnetUsedRanges returns the addresses in use and the fixed address
+// ranges of the subnet as ranges, sorted by their start. The ranges are kept
+// whole, as an IPv6 range may hold more addresses than could be listed.
+func subnetUsedRanges(subnet TestSubnet) []usedRange {
+	var ranges []usedRange
+	for _, ip := range subnet.InUseIPAddresses {
+		ranges = append(ranges, usedRange{start: ip, end: ip, purpose: ip.Purpose})
+	}
 	for _, r := range subnet.FixedAddressRanges {
-		for v := r.startUint; v <= r.endUint; v++ {
-			ip := IPFromInt64(v)
-			ip.Purpose = r.Purpose
-			*ipAddresses = append(*ipAddresses, ip)
+		if r.startIP.Cmp(r.endIP) <= 0 {
+			ranges = append(ranges, usedRange{start: r.startIP, end: r.endIP, purpose: r.Purpose})
 		}
 	}
+	sort.Sort(usedRangeList(ranges))
+	return ranges
 }
 
-func (server *TestServer) subnetUnreservedIPRanges(subnet TestSubnet) []AddressRange {
-	// Make a sorted copy of subnet.InUseIPAddresses
-	ipAddresses := make([]IP, len(subnet.InUseIPAddresses))
-	copy(ipAddresses, subnet.InUseIPAddresses)
-	appendRangesToIPList(subnet, &ipAddresses)
-	sort.Sort(addressList(ipAddresses))
+// subnetUsableIPRange returns the first and last usable addresses of the
+// network. These leave out the network address and, for IPv4, the broadcast
+// address; IPv6 has no broadcast address, but keeps the first address of the
+// network as the subnet-router anycast address. Single address and
+// point-to-point networks, such as /32 and /31 for IPv4 or /128 and /127 for
+// IPv6, leave out nothing.
+func subnetUsableIPRange(ipNet *net.IPNet) (first, last IP) {
+	ones, bits := ipNet.Mask.Size()
+	network := IPFromNetIP(ipNet.IP)
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	first = network
+	last = IPFromBigInt(size.Add(size, network.BigInt()), !network.Is4()).Add(-1)
+	if bits-ones <= 1 {
+		return first, last
+	}
+	first = first.Add(1)
+	if network.Is4() {
+		last = last.Add(-1)
+	}
+	return first, last
+}
 
+func (server *TestServer) subnetUnreservedIPRanges(subnet TestSubnet) []AddressRange {
 	// We need the first and last address in the subnet
 	var ranges AddressRangeList
-	var startIP, endIP, lastUsableIP IP
-
 	_, ipNet, err := net.ParseCIDR(subnet.CIDR)
 	checkError(err)
-	startIP = IPFromNetIP(ipNet.IP)
-	// Start with the lowest usable address in the range, which is 1 above
-	// what net.ParseCIDR will give back.
-	startIP.SetUInt64(startIP.UInt64() + 1)
+	startIP, lastUsableIP := subnetUsableIPRange(ipNet)
 
-	ones, bits := ipNet.Mask.Size()
-	set := ^((^uint64(0)) << uint(bits-ones))
-
-	// The last usable address is one below the broadcast address, which is
-	// what you get by bitwise ORing 'set' with any IP address in the subnet.
-	lastUsableIP.SetUInt64((startIP.UInt64() | set) - 1)
-
-	for _, endIP = range ipAddresses {
-		end := endIP.UInt64()
-
-		if endIP.UInt64() == startIP.UInt64() {
-			if endIP.UInt64() != lastUsableIP.UInt64() {
-				startIP.SetUInt64(end + 1)
-			}
+	// The ranges sort by family, so on a dual-stack subnet those of the
+	// other family are all below startIP or above lastUsableIP.
+	for _, r := range subnetUsedRanges(subnet) {
+		if r.end.Cmp(startIP) < 0 {
 			continue
 		}
-
-		if end == lastUsableIP.UInt64() {
-			continue
+		if r.start.Cmp(lastUsableIP) > 0 {
+			break
 		}
-
-		ranges.Append(startIP, IPFromInt64(end-1))
-		startIP.SetUInt64(end + 1)
+		if r.start.Cmp(startIP) > 0 {
+			ranges.Append(startIP, r.start.Add(-1))
+		}
+		if r.end.Cmp(lastUsableIP) >= 0 {
+			return ranges.ar
+		}
+		startIP = r.end.Add(1)
 	}
-
-	if startIP.UInt64() != lastUsableIP.UInt64() {
+	if startIP.Cmp(lastUsableIP) <= 0 {
 		ranges.Append(startIP, lastUsableIP)
 	}
 
@@ -242,43 +279,48 @@ func (server *TestServer) subnetUnreservedIPRanges(subnet TestSubnet) []AddressR
 }
 
 func (server *TestServer) subnetReservedIPRanges(subnet TestSubnet) []AddressRange {
-	var ranges AddressRangeList
-	var startIP, thisIP IP
-
-	// Make a sorted copy of subnet.InUseIPAddresses
-	ipAddresses := make([]IP, len(subnet.InUseIPAddresses))
-	copy(ipAddresses, subnet.InUseIPAddresses)
-	appendRangesToIPList(subnet, &ipAddresses)
-	sort.Sort(addressList(ipAddresses))
-	if len(ipAddresses) == 0 {
-		ar := ranges.ar
-		if ar == nil {
-			ar = []AddressRange{}
-		}
-		return ar
+	ranges := subnetUsedRanges(subnet)
+	if len(ranges) == 0 {
+		return []AddressRange{}
 	}
 
-	startIP = ipAddresses[0]
-	lastIP := ipAddresses[0]
-	for _, thisIP = range ipAddresses {
-		var purposeMissmatch bool
-		for i, p := range thisIP.Purpose {
-			if startIP.Purpose[i] != p {
-				purposeMissmatch = true
+	// Merge the ranges that overlap or are next to each other and have the
+	// same purpose.
+	var merged []usedRange
+	for _, r := range ranges {
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			next := new(big.Int).Add(last.end.BigInt(), big.NewInt(1))
+			adjacent := r.start.Is4() == last.end.Is4() && r.start.BigInt().Cmp(next) <= 0
+			if adjacent && samePurpose(r.purpose, last.purpose) {
+				if r.end.Cmp(last.end) > 0 {
+					last.end = r.end
+				}
+				continue
 			}
 		}
-		if (thisIP.UInt64() != lastIP.UInt64() && thisIP.UInt64() != lastIP.UInt64()+1) || purposeMissmatch {
-			ranges.Append(startIP, lastIP)
-			startIP = thisIP
-		}
-		lastIP = thisIP
+		merged = append(merged, r)
 	}
 
-	if len(ranges.ar) == 0 || ranges.ar[len(ranges.ar)-1].endUint != lastIP.UInt64() {
-		ranges.Append(startIP, lastIP)
+	var result AddressRangeList
+	for _, r := range merged {
+		startIP := r.start
+		startIP.Purpose = r.purpose
+		result.Append(startIP, r.end)
 	}
+	return result.ar
+}
 
-	return ranges.ar
+func samePurpose(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // SubnetStats holds statistics about a subnet
@@ -297,11 +339,22 @@ func (server *TestServer) subnetStatistics(subnet TestSubnet, includeRanges bool
 	_, ipNet, err := net.ParseCIDR(subnet.CIDR)
 	checkError(err)
 
-	ones, bits := ipNet.Mask.Size()
-	stats.TotalAddresses = (1 << uint(bits-ones)) - 2
-	stats.NumUnavailable = uint(len(subnet.InUseIPAddresses))
-	stats.NumAvailable = stats.TotalAddresses - stats.NumUnavailable
-	stats.Usage = float32(stats.NumUnavailable) / float32(stats.TotalAddresses)
+	first, last := subnetUsableIPRange(ipNet)
+	total := new(big.Int).Sub(last.BigInt(), first.BigInt())
+	stats.TotalAddresses = bigToUint(total.Add(total, big.NewInt(1)))
+	// Only the addresses of the subnet's own family count on a dual-stack
+	// subnet.
+	for _, ip := range subnet.InUseIPAddresses {
+		if ipNet.Contains(ip.netIP) {
+			stats.NumUnavailable++
+		}
+	}
+	if stats.NumUnavailable < stats.TotalAddresses {
+		stats.NumAvailable = stats.TotalAddresses - stats.NumUnavailable
+	}
+	if stats.TotalAddresses > 0 {
+		stats.Usage = float32(stats.NumUnavailable) / float32(stats.TotalAddresses)
+	}
 	stats.UsageString = fmt.Sprintf("%0.1f%%", stats.Usage*100)
 
 	// Calculate stats.LargestAvailable - the largest contiguous block of IP addresses available
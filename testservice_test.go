@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"math/rand"
 	"mime/multipart"
 	"net"
@@ -17,6 +18,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	jc "github.com/juju/testing/checkers"
 	. "gopkg.in/check.v1"
@@ -1071,6 +1073,103 @@ func (suite *TestServerSuite) TestSubnetsInNodes(c *C) {
 	c.Check(i.Links[0].Subnet.Name, Equals, "maas-eth0")
 }
 
+func (suite *TestServerSuite) TestSubnetIPv6Ranges(c *C) {
+	subnet := defaultSubnet()
+	subnet.DNSServers = []string{"2001:db8::2"}
+	subnet.GatewayIP = "2001:db8::1"
+	subnet.CIDR = "2001:db8::/120"
+	suite.server.NewSubnet(subnetJSON(subnet))
+	suite.server.NewIPAddress("2001:db8::1", "maas-eth0")
+	suite.server.NewIPAddress("2001:db8::ff", "maas-eth0")
+	suite.server.AddFixedAddressRange(1, AddressRange{Start: "2001:db8::10", End: "2001:db8::1f", Purpose: []string{"dynamic"}})
+
+	c.Check(suite.reservedRanges(c), DeepEquals, []AddressRange{
+		{Start: "2001:db8::1", End: "2001:db8::1", Purpose: []string{"assigned-ip"}, NumAddresses: 1},
+		{Start: "2001:db8::10", End: "2001:db8::1f", Purpose: []string{"dynamic"}, NumAddresses: 16},
+		{Start: "2001:db8::ff", End: "2001:db8::ff", Purpose: []string{"assigned-ip"}, NumAddresses: 1},
+	})
+
+	// IPv6 has no broadcast address, so the last address of the subnet
+	// is usable.
+	stats := suite.getSubnetStats(c, 1)
+	c.Check(stats.TotalAddresses, Equals, uint(255))
+	c.Check(stats.NumUnavailable, Equals, uint(2))
+	c.Check(stats.NumAvailable, Equals, uint(253))
+	c.Check(stats.LargestAvailable, Equals, uint(223))
+
+	resp, err := http.Get(suite.subnetURL(1) + "?op=unreserved_ip_ranges")
+	c.Assert(err, IsNil)
+	var unreserved []AddressRange
+	err = json.NewDecoder(resp.Body).Decode(&unreserved)
+	c.Assert(err, IsNil)
+	c.Check(unreserved, DeepEquals, []AddressRange{
+		{Start: "2001:db8::2", End: "2001:db8::f", NumAddresses: 14},
+		{Start: "2001:db8::20", End: "2001:db8::fe", NumAddresses: 223},
+	})
+}
+
+func (suite *TestServerSuite) TestSubnetIPv6LargeStats(c *C) {
+	subnet := defaultSubnet()
+	subnet.CIDR = "2001:db8::/64"
+	suite.server.NewSubnet(subnetJSON(subnet))
+	// An IPv4 address on the dual-stack subnet does not count.
+	suite.server.NewIPAddress("192.168.1.10", "maas-eth0")
+	suite.server.NewIPAddress("2001:db8::10", "maas-eth0")
+
+	stats := suite.getSubnetStats(c, 1)
+	c.Check(stats.TotalAddresses, Equals, ^uint(0))
+	c.Check(stats.NumUnavailable, Equals, uint(1))
+	// From 2001:db8::11 to the end of the subnet.
+	c.Check(stats.LargestAvailable, Equals, ^uint(0)-16)
+
+	ranges := suite.reservedRanges(c)
+	c.Assert(ranges, HasLen, 2)
+	c.Check(ranges[0].Start, Equals, "192.168.1.10")
+	c.Check(ranges[1].Start, Equals, "2001:db8::10")
+}
+
+func (suite *TestServerSuite) TestSubnetStatsSmallPrefixes(c *C) {
+	for i, test := range []struct {
+		cidr  string
+		total uint
+	}{
+		{"192.168.1.0/30", 2},
+		{"192.168.1.0/31", 2},
+		{"192.168.1.1/32", 1},
+		{"2001:db8::/126", 3},
+		{"2001:db8::/127", 2},
+		{"2001:db8::1/128", 1},
+	} {
+		c.Logf("test %d: %s", i, test.cidr)
+		suite.server.Clear()
+		subnet := defaultSubnet()
+		subnet.CIDR = test.cidr
+		suite.server.NewSubnet(subnetJSON(subnet))
+		stats := suite.getSubnetStats(c, 1)
+		c.Check(stats.TotalAddresses, Equals, test.total)
+		c.Check(stats.NumAvailable, Equals, test.total)
+		c.Check(stats.LargestAvailable, Equals, test.total)
+		c.Check(stats.Usage, Equals, float32(0))
+	}
+}
+
+func (suite *TestServerSuite) TestSubnetIPv6LargeFixedRange(c *C) {
+	subnet := defaultSubnet()
+	subnet.CIDR = "2001:db8::/64"
+	suite.server.NewSubnet(subnetJSON(subnet))
+	suite.server.NewIPAddress("2001:db8::1", "maas-eth0")
+	suite.server.AddFixedAddressRange(1, AddressRange{Start: "2001:db8::2", End: "2001:db8::ffff:ffff", Purpose: []string{"dynamic"}})
+
+	c.Check(suite.reservedRanges(c), DeepEquals, []AddressRange{
+		{Start: "2001:db8::1", End: "2001:db8::1", Purpose: []string{"assigned-ip"}, NumAddresses: 1},
+		{Start: "2001:db8::2", End: "2001:db8::ffff:ffff", Purpose: []string{"dynamic"}, NumAddresses: 0xfffffffe},
+	})
+	unreserved := suite.server.subnetUnreservedIPRanges(suite.server.subnets[1])
+	c.Assert(unreserved, HasLen, 1)
+	c.Check(unreserved[0].Start, Equals, "2001:db8::1:0:0")
+	c.Check(unreserved[0].End, Equals, "2001:db8::ffff:ffff:ffff:ffff")
+}
+
 type IPSuite struct {
 }
 
@@ -1093,6 +1192,86 @@ func (suite *IPSuite) TestIPSetUInt64(c *C) {
 	c.Check(ip.String(), Equals, "1.2.3.4")
 }
 
+func (suite *IPSuite) TestIPBigInt(c *C) {
+	ip := IPFromString("2001:db8::1:2")
+	v := ip.BigInt()
+	c.Check(v.Text(16), Equals, "20010db8000000000000000000010002")
+	c.Check(IPFromBigInt(v, true).String(), Equals, "2001:db8::1:2")
+	c.Check(IPFromString("1.2.3.4").BigInt().Int64(), Equals, int64(0x01020304))
+	c.Check(IPFromBigInt(big.NewInt(0x01020304), false).String(), Equals, "1.2.3.4")
+}
+
+func (suite *IPSuite) TestIPAdd(c *C) {
+	c.Check(IPFromString("2001:db8::ffff:ffff:ffff:ffff").Add(1).String(), Equals, "2001:db8:0:1::")
+	c.Check(IPFromString("2001:db8:0:1::").Add(-1).String(), Equals, "2001:db8::ffff:ffff:ffff:ffff")
+	c.Check(IPFromString("1.2.3.255").Add(1).String(), Equals, "1.2.4.0")
+	// Addresses wrap around their own address space.
+	c.Check(IPFromString("255.255.255.255").Add(1).String(), Equals, "0.0.0.0")
+}
+
+func (suite *IPSuite) TestIPCmp(c *C) {
+	c.Check(IPFromString("2001:db8::1").Cmp(IPFromString("2001:db8::2")), Equals, -1)
+	c.Check(IPFromString("2001:db8::2").Cmp(IPFromString("2001:db8::2")), Equals, 0)
+	c.Check(IPFromString("2001:db9::").Cmp(IPFromString("2001:db8::ffff")), Equals, 1)
+	// IPv4 addresses sort before IPv6 ones.
+	c.Check(IPFromString("255.255.255.255").Cmp(IPFromString("::1")), Equals, -1)
+}
+
+func (suite *TestServerSuite) reservedRanges(c *C) []AddressRange {
+	resp, err := http.Get(suite.subnetURL(1) + "?op=reserved_ip_ranges")
+	c.Assert(err, IsNil)
+	var ranges []AddressRange
+	err = json.NewDecoder(resp.Body).Decode(&ranges)
+	c.Assert(err, IsNil)
+	return ranges
+}
+
+func (suite *TestServerSuite) TestSnapshotAndRestore(c *C) {
+	suite.server.NewNode(`{"system_id": "mysystemid", "hostname": "node-1"}`)
+	suite.server.OwnedNodes()["mysystemid"] = true
+	suite.server.NewFile("config", []byte("content"))
+	suite.server.AddZone("east", "the east zone")
+	suite.server.NewSpace(spaceJSON(CreateSpace{Name: "db"}))
+	suite.server.NewSubnet(subnetJSON(defaultSubnet()))
+	suite.server.NewIPAddress("192.168.1.10", "maas-eth0")
+	suite.server.AddFixedAddressRange(1, AddressRange{Start: "192.168.1.100", End: "192.168.1.200", Purpose: []string{"dynamic"}})
+	suite.server.AddDevice(&TestDevice{SystemId: "device-1", Hostname: "device", MACAddresses: []string{"52:54:00:00:00:01"}})
+	suite.server.NewNetwork(`{"name": "net-1", "ip": "10.0.0.0", "netmask": "255.255.255.0"}`)
+	suite.server.ConnectNodeToNetworkWithMACAddress("mysystemid", "net-1", "52:54:00:00:00:02")
+	ranges := suite.reservedRanges(c)
+
+	// The snapshot survives being saved as JSON.
+	data, err := json.Marshal(suite.server.Snapshot())
+	c.Assert(err, IsNil)
+	var snapshot TestServerSnapshot
+	err = json.Unmarshal(data, &snapshot)
+	c.Assert(err, IsNil)
+
+	for i := 0; i < 2; i++ {
+		suite.server.NewNode(`{"system_id": "other"}`)
+		suite.server.NewIPAddress("192.168.1.11", "maas-eth0")
+		suite.server.Restore(snapshot)
+
+		c.Check(suite.server.Nodes(), HasLen, 1)
+		hostname, err := suite.server.Nodes()["mysystemid"].GetField("hostname")
+		c.Check(err, IsNil)
+		c.Check(hostname, Equals, "node-1")
+		c.Check(suite.server.OwnedNodes(), DeepEquals, map[string]bool{"mysystemid": true})
+		c.Check(suite.server.files["config"].GetMap()["content"], NotNil)
+		c.Check(suite.server.zones, HasLen, 1)
+		c.Check(suite.server.Devices()["device-1"].MACAddresses, DeepEquals, []string{"52:54:00:00:00:01"})
+		c.Check(suite.server.macAddressesPerNetwork["net-1"], HasLen, 1)
+		c.Check(suite.reservedRanges(c), DeepEquals, ranges)
+		c.Check(suite.server.spaceNameToID["db"], Equals, uint(1))
+	}
+
+	// New subnets and spaces follow on from the restored ones.
+	subnet := suite.server.NewSubnet(subnetJSON(newSubnetOnSpace("db", 2)))
+	c.Check(subnet.ID, Equals, uint(2))
+	space := suite.server.NewSpace(spaceJSON(CreateSpace{Name: "web"}))
+	c.Check(space.ID, Equals, uint(2))
+}
+
 // TestMAASObjectSuite validates that the object created by
 // NewTestMAAS can be used by the gomaasapi library as if it were a real
 // MAAS server.
@@ -1280,6 +1459,127 @@ func (suite *TestMAASObjectSuite) TestNodesRelease(c *C) {
 	c.Check(nodesOperationRequestValues, DeepEquals, []url.Values{expectedValues})
 }
 
+func (suite *TestMAASObjectSuite) TestLifecycleDeploysNode(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetLifecycle(FakeLifecycle{DeployTime: time.Minute})
+	node := server.NewNode(`{"system_id": "mysystemid", "hostname": "myhost"}`)
+
+	_, err := node.CallPost("start", url.Values{})
+	c.Assert(err, IsNil)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDeploying)
+
+	server.Advance(59 * time.Second)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDeploying)
+	server.Advance(time.Second)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDeployed)
+
+	c.Check(server.Now(), Equals, fakeEpoch.Add(time.Minute))
+	c.Check(server.Events("mysystemid"), DeepEquals, []FakeEvent{{
+		ID:       1,
+		SystemID: "mysystemid",
+		Hostname: "myhost",
+		Type:     "Deploying",
+		Level:    "INFO",
+		Created:  fakeEpoch,
+	}, {
+		ID:       2,
+		SystemID: "mysystemid",
+		Hostname: "myhost",
+		Type:     "Deployed",
+		Level:    "INFO",
+		Created:  fakeEpoch.Add(time.Minute),
+	}})
+}
+
+func (suite *TestMAASObjectSuite) TestLifecycleRedeployIgnoresEarlierDeploy(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetLifecycle(FakeLifecycle{DeployTime: 10 * time.Minute, ReleaseTime: time.Minute})
+	node := server.NewNode(`{"system_id": "mysystemid"}`)
+
+	_, err := node.CallPost("start", url.Values{})
+	c.Assert(err, IsNil)
+	server.Advance(time.Minute)
+	_, err = node.CallPost("release", url.Values{})
+	c.Assert(err, IsNil)
+	server.Advance(time.Minute)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusReady)
+
+	_, err = node.CallPost("start", url.Values{})
+	c.Assert(err, IsNil)
+	// The first deploy would have finished now.
+	server.Advance(8 * time.Minute)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDeploying)
+	server.Advance(2 * time.Minute)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDeployed)
+}
+
+func (suite *TestMAASObjectSuite) TestLifecycleFailsDeployment(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetLifecycle(FakeLifecycle{DeployTime: time.Minute, FailMachines: []string{"mysystemid"}})
+	node := server.NewNode(`{"system_id": "mysystemid"}`)
+
+	_, err := node.CallPost("start", url.Values{})
+	c.Assert(err, IsNil)
+	server.Advance(time.Minute)
+
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusFailedDeployment)
+	events := server.Events("mysystemid")
+	c.Assert(events, HasLen, 2)
+	c.Check(events[1].Type, Equals, "Failed deployment")
+	c.Check(events[1].Level, Equals, "ERROR")
+}
+
+func (suite *TestMAASObjectSuite) TestLifecycleReleasesNodeWithErase(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetLifecycle(FakeLifecycle{ReleaseTime: time.Minute, EraseTime: time.Hour})
+	server.NewNode(`{"system_id": "mysystemid"}`)
+	server.OwnedNodes()["mysystemid"] = true
+	nodesObj := suite.TestMAASObject.GetSubObject("nodes/")
+
+	params := url.Values{"nodes": {"mysystemid"}, "erase": {"true"}}
+	_, err := nodesObj.CallPost("release", params)
+	c.Assert(err, IsNil)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDiskErasing)
+
+	server.Advance(time.Hour)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusReleasing)
+	server.Advance(time.Minute)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusReady)
+}
+
+func (suite *TestMAASObjectSuite) TestLifecycleCommissionsNode(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetLifecycle(FakeLifecycle{CommissionTime: time.Minute})
+	node := server.NewNode(`{"system_id": "mysystemid", "status": "0"}`)
+
+	_, err := node.CallPost("commission", url.Values{})
+	c.Assert(err, IsNil)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusCommissioning)
+	server.Advance(time.Minute)
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusReady)
+}
+
+func (suite *TestMAASObjectSuite) TestLifecycleRefusesToCommissionDeployedNode(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetLifecycle(FakeLifecycle{CommissionTime: time.Minute})
+	node := server.NewNode(`{"system_id": "mysystemid"}`)
+
+	_, err := node.CallPost("commission", url.Values{})
+	c.Check(err, ErrorMatches, ".*409.*")
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDeployed)
+}
+
+func (suite *TestMAASObjectSuite) TestWithoutLifecycleStartLeavesStatus(c *C) {
+	server := suite.TestMAASObject.TestServer
+	node := server.NewNode(`{"system_id": "mysystemid"}`)
+
+	_, err := node.CallPost("start", url.Values{})
+	c.Assert(err, IsNil)
+
+	c.Check(server.nodeStatus("mysystemid"), Equals, NodeStatusDeployed)
+	c.Check(server.Events(""), HasLen, 0)
+}
+
 func (suite *TestMAASObjectSuite) TestNodesReleaseUnknown(c *C) {
 	suite.TestMAASObject.TestServer.NewNode(`{"system_id": "mysystemid"}`)
 	suite.TestMAASObject.TestServer.OwnedNodes()["mysystemid"] = true
@@ -1653,6 +1953,22 @@ func (suite *TestMAASObjectSuite) TestReserveIPAddress(c *C) {
 	suite.assertIPAmong(c, res, "0.1.2.2")
 }
 
+func (suite *TestMAASObjectSuite) TestReserveIPv6Address(c *C) {
+	suite.TestMAASObject.TestServer.NewNetwork(
+		`{"name": "net_1", "ip": "2001:db8::", "netmask": "ffff:ffff:ffff:ffff::"}`,
+	)
+	ipAddresses := suite.TestMAASObject.GetSubObject("ipaddresses")
+	params := url.Values{"network": []string{"2001:db8::/64"}, "requested_address": []string{"2001:db8::ff"}}
+	res, err := ipAddresses.CallPost("reserve", params)
+	c.Assert(err, IsNil)
+	suite.assertIPAmong(c, res, "2001:db8::ff")
+
+	delete(params, "requested_address")
+	res, err = ipAddresses.CallPost("reserve", params)
+	c.Assert(err, IsNil)
+	suite.assertIPAmong(c, res, "2001:db8::2")
+}
+
 func (suite *TestMAASObjectSuite) TestReleaseIPAddress(c *C) {
 	suite.TestMAASObject.TestServer.NewNetwork(
 		`{"name": "net_1", "ip": "0.1.2.0", "netmask": "255.255.255.0"}`,
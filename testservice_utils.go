@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"math/big"
 	"net"
 	"net/http"
 	"strconv"
@@ -57,6 +58,21 @@ func IPFromInt64(v uint64) IP {
 	return ip
 }
 
+// IPFromBigInt creates a new IP from a big.Int IP address representation.
+// The address is an IPv6 one if ipv6 is true, and an IPv4 one otherwise; v
+// is taken modulo the size of the address space.
+func IPFromBigInt(v *big.Int, ipv6 bool) IP {
+	size := net.IPv4len
+	if ipv6 {
+		size = net.IPv6len
+	}
+	bits := new(big.Int).Lsh(big.NewInt(1), uint(size*8))
+	b := new(big.Int).Mod(v, bits).Bytes()
+	netIP := make(net.IP, size)
+	copy(netIP[size-len(b):], b)
+	return IPFromNetIP(netIP.To16())
+}
+
 // To4 converts the IPv4 address ip to a 4-byte representation. If ip is not
 // an IPv4 address, To4 returns nil.
 func (ip IP) To4() net.IP {
@@ -73,7 +89,44 @@ func (ip IP) String() string {
 	return ip.netIP.String()
 }
 
-// UInt64 returns a uint64 holding the IP address
+// Is4 returns true if ip is an IPv4 address.
+func (ip IP) Is4() bool {
+	return ip.To4() != nil
+}
+
+// BigInt returns a big.Int holding the IP address, which unlike UInt64
+// works for IPv6 addresses as well as IPv4 ones.
+func (ip IP) BigInt() *big.Int {
+	if ip.Is4() {
+		return new(big.Int).SetBytes(ip.To4())
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+// Add returns the address n addresses after ip, or before it if n is
+// negative, wrapping around the address space of ip. The result has no
+// purpose.
+func (ip IP) Add(n int64) IP {
+	v := ip.BigInt()
+	v.Add(v, big.NewInt(n))
+	return IPFromBigInt(v, !ip.Is4())
+}
+
+// Cmp compares ip and other, returning -1, 0 or +1. IPv4 addresses are
+// ordered before IPv6 ones, so that the addresses of a dual-stack subnet
+// sort by family.
+func (ip IP) Cmp(other IP) int {
+	if ip.Is4() != other.Is4() {
+		if ip.Is4() {
+			return -1
+		}
+		return 1
+	}
+	return ip.BigInt().Cmp(other.BigInt())
+}
+
+// UInt64 returns a uint64 holding the IP address. IPv6 addresses do not fit
+// in it, use BigInt for them.
 func (ip IP) UInt64() uint64 {
 	if len(ip.netIP) == 0 {
 		return uint64(0)
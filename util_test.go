@@ -34,6 +34,18 @@ func (suite *GomaasapiTestSuite) TestEnsureTrailingSlashReturnsSlashIfEmpty(c *g
 	c.Check(EnsureTrailingSlash(""), gc.Equals, "/")
 }
 
+// firstJSONElement returns the first element of the JSON list in source,
+// for when a test needs a single entity response from a list response.
+func firstJSONElement(c *gc.C, source string) string {
+	var parsed []interface{}
+	err := json.Unmarshal([]byte(source), &parsed)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(parsed, gc.Not(gc.HasLen), 0)
+	bytes, err := json.Marshal(parsed[0])
+	c.Assert(err, jc.ErrorIsNil)
+	return string(bytes)
+}
+
 func parseJSON(c *gc.C, source string) interface{} {
 	var parsed interface{}
 	err := json.Unmarshal([]byte(source), &parsed)
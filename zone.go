@@ -29,14 +29,37 @@ func (z *zone) Description() string {
 	return z.description
 }
 
+func readZone(controllerVersion version.Number, source interface{}) (*zone, error) {
+	readFunc, err := getZoneDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, errors.Annotatef(err, "zone base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
 func readZones(controllerVersion version.Number, source interface{}) ([]*zone, error) {
+	readFunc, err := getZoneDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "zone base schema check failed")
 	}
 	valid := coerced.([]interface{})
+	return readZoneList(valid, readFunc)
+}
 
+func getZoneDeserializationFunc(controllerVersion version.Number) (zoneDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range zoneDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -46,8 +69,7 @@ func readZones(controllerVersion version.Number, source interface{}) ([]*zone, e
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no zone read func for version %s", controllerVersion)
 	}
-	readFunc := zoneDeserializationFuncs[deserialisationVersion]
-	return readZoneList(valid, readFunc)
+	return zoneDeserializationFuncs[deserialisationVersion], nil
 }
 
 // readZoneList expects the values of the sourceList to be string maps.